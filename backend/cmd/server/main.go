@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -16,12 +17,16 @@ import (
 	"github.com/stolos-cloud/stolos/backend/internal/config"
 	"github.com/stolos-cloud/stolos/backend/internal/database"
 	"github.com/stolos-cloud/stolos/backend/internal/handlers"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/routes"
 	"github.com/stolos-cloud/stolos/backend/internal/services"
 	discoveryservice "github.com/stolos-cloud/stolos/backend/internal/services/cluster"
+	gcpservices "github.com/stolos-cloud/stolos/backend/internal/services/gcp"
 	"github.com/stolos-cloud/stolos/backend/internal/services/job"
 	"github.com/stolos-cloud/stolos/backend/internal/services/node"
 	talosservice "github.com/stolos-cloud/stolos/backend/internal/services/talos"
+	"github.com/stolos-cloud/stolos/backend/internal/tracing"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -53,26 +58,52 @@ func main() {
 		log.Fatal("Failed to load configuration:", err)
 	}
 
+	logs, err := logging.New(cfg.Logging, os.Stdout)
+	if err != nil {
+		log.Fatal("Failed to initialize logging:", err)
+	}
+	logs.SetDefault()
+	logger := logs.Logger(logging.SubsystemDefault)
+
+	// "main migrate" runs the migrations of an upgrade before the new backend rolls out
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.Migrate(cfg.Database, logger); err != nil {
+			fatal(logger, "failed to migrate database", err)
+		}
+		logger.Info("database migrated")
+		return
+	}
+
 	// -- Load talos values
 
 	// ---
 
 	// Generate random if not provided
 	if cfg.JWT.SecretKey == "" {
-		log.Println("JWT_SECRET_KEY not set, generating random secret")
-		cfg.JWT.SecretKey = generateRandomSecret(32)
+		logger.Info("JWT_SECRET_KEY not set, generating random secret")
+		cfg.JWT.SecretKey = generateRandomSecret(logger, 32)
 	}
 
-	db, err := database.Initialize(cfg.Database)
+	db, err := database.Initialize(cfg.Database, logger)
 	if err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		fatal(logger, "failed to initialize database", err)
 	}
 
 	// Initialize context
 	ctx := context.Background()
 
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing, logger)
+	if err != nil {
+		fatal(logger, "failed to initialize tracing", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
 	// Register all services using modular wire.go files
-	allServices := RegisterAllServices(db, cfg)
+	allServices := RegisterAllServices(db, cfg, logs)
 
 	// Add entrypoint
 	allServices = append(allServices, gontainer.NewEntrypoint(
@@ -83,6 +114,8 @@ func main() {
 			h *handlers.Handlers,
 			infrastructureService *services.InfrastructureService,
 			jobService *job.JobService,
+			provisioningService *gcpservices.ProvisioningService,
+			gcpService *gcpservices.GCPService,
 			resolver *gontainer.Resolver) {
 			providerManager.SetInfrastructureService(infrastructureService)
 
@@ -91,33 +124,45 @@ func main() {
 
 			// Initialize providers
 			if err := providerManager.InitializeProviders(ctx); err != nil {
-				log.Fatal("Failed to initialize providers:", err)
+				fatal(logger, "failed to initialize providers", err)
 			}
 
 			if !providerManager.HasConfiguredProviders() {
-				log.Println("No cloud providers configured")
+				logger.InfoContext(ctx, "no cloud providers configured")
 			}
 
 			// discover the cluster the backend is running on
 			if err := clusterDiscovery.InitializeCluster(ctx); err != nil {
-				log.Fatal("Failed to initialize cluster:", err)
+				fatal(logger, "failed to initialize cluster", err)
 			}
 
 			// Migrate Talos configs from files to db
 			if err := talosService.MigrateTalosConfigFromFiles(); err != nil {
-				log.Printf("Note: Talos config migration skipped: %v", err)
+				logger.InfoContext(ctx, "Talos config migration skipped", "reason", err)
+			}
+
+			// Resume or clean up provision requests interrupted by a restart
+			if gcpService.IsConfigured() {
+				if err := provisioningService.ResumeIncompleteRequests(ctx); err != nil {
+					logger.WarnContext(ctx, "failed to resume provision requests", "error", err)
+				}
 			}
 
 			jobService.Start()
 
-			r := gin.Default()
+			r := gin.New()
+			r.Use(gin.Recovery())
 
 			r.Use(cors.New(cors.Config{
 				AllowOrigins:     []string{"*"},
 				AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-				AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+				AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "traceparent", "tracestate", logging.RequestIDHeader},
+				ExposeHeaders:    []string{tracing.TraceIDHeader, logging.RequestIDHeader},
 				AllowCredentials: true,
 			}))
+			r.Use(metrics.Middleware())
+			r.Use(tracing.Middleware())
+			r.Use(logging.Middleware(logs.Logger(logging.SubsystemHTTP)))
 
 			routes.SetupRoutes(r, h)
 
@@ -131,9 +176,9 @@ func main() {
 				port = ":" + port
 			}
 
-			log.Printf("Starting server on %s", port)
+			logger.InfoContext(ctx, "starting server", "addr", port)
 			if err := r.Run(port); err != nil {
-				log.Fatal("Failed to start server:", err)
+				fatal(logger, "failed to start server", err)
 			}
 		}))
 
@@ -144,14 +189,20 @@ func main() {
 
 	err = gontainer.Run(ctx, options...)
 	if err != nil {
-		log.Fatal("Failed to run application:", err)
+		fatal(logger, "failed to run application", err)
 	}
 }
 
-func generateRandomSecret(length int) string {
+// fatal logs an error that stops the backend and exits
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func generateRandomSecret(logger *slog.Logger, length int) string {
 	bytes := make([]byte, length)
 	if _, err := rand.Read(bytes); err != nil {
-		log.Fatal("Failed to generate random secret:", err)
+		fatal(logger, "failed to generate random secret", err)
 	}
 	return hex.EncodeToString(bytes)
 }
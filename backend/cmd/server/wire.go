@@ -4,6 +4,8 @@ import (
 	"github.com/NVIDIA/gontainer/v2"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
 	"github.com/stolos-cloud/stolos/backend/internal/handlers"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/middleware"
 	"github.com/stolos-cloud/stolos/backend/internal/services"
 	discoveryservice "github.com/stolos-cloud/stolos/backend/internal/services/cluster"
@@ -18,7 +20,7 @@ import (
 )
 
 // RegisterInfrastructure registers core infrastructure components
-func RegisterInfrastructure(db *gorm.DB, cfg *config.Config) []any {
+func RegisterInfrastructure(db *gorm.DB, cfg *config.Config, logs *logging.Logging) []any {
 	return []any{
 		gontainer.NewFactory(func() *gorm.DB {
 			return db
@@ -26,6 +28,9 @@ func RegisterInfrastructure(db *gorm.DB, cfg *config.Config) []any {
 		gontainer.NewFactory(func() *config.Config {
 			return cfg
 		}),
+		gontainer.NewFactory(func() *logging.Logging {
+			return logs
+		}),
 	}
 }
 
@@ -35,9 +40,10 @@ func RegisterMiddleware() []any {
 		gontainer.NewFactory(func(cfg *config.Config) *middleware.JWTService {
 			return middleware.NewJWTService(cfg)
 		}),
-		gontainer.NewFactory(func() *wsservices.Manager {
-			wsManager := wsservices.NewManager()
+		gontainer.NewFactory(func(logs *logging.Logging) *wsservices.Manager {
+			wsManager := wsservices.NewManager(logs)
 			go wsManager.Run()
+			metrics.SetWebSocketClientCounter(wsManager.ClientCounts)
 			return wsManager
 		}),
 	}
@@ -46,17 +52,17 @@ func RegisterMiddleware() []any {
 // RegisterCoreServices registers core business services
 func RegisterCoreServices() []any {
 	return []any{
-		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, wsManager *wsservices.Manager) *talosservice.TalosService {
-			return talosservice.NewTalosService(db, cfg, wsManager)
+		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, wsManager *wsservices.Manager, logs *logging.Logging) *talosservice.TalosService {
+			return talosservice.NewTalosService(db, cfg, wsManager, logs)
 		}),
-		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, ts *talosservice.TalosService) *discoveryservice.DiscoveryService {
-			return discoveryservice.NewDiscoveryService(db, cfg, ts)
+		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, ts *talosservice.TalosService, logs *logging.Logging) *discoveryservice.DiscoveryService {
+			return discoveryservice.NewDiscoveryService(db, cfg, ts, logs)
 		}),
-		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, pm *services.ProviderManager, ts *talosservice.TalosService) *node.NodeService {
-			return node.NewNodeService(db, cfg, pm, ts)
+		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, pm *services.ProviderManager, ts *talosservice.TalosService, logs *logging.Logging) *node.NodeService {
+			return node.NewNodeService(db, cfg, pm, ts, logs)
 		}),
-		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config) *gitops.GitOpsService {
-			return gitops.NewGitOpsService(db, cfg)
+		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, logs *logging.Logging) *gitops.GitOpsService {
+			return gitops.NewGitOpsService(db, cfg, logs)
 		}),
 		gontainer.NewFactory(k8s.NewK8sClient),
 	}
@@ -71,8 +77,17 @@ func RegisterGCPServices() []any {
 		gontainer.NewFactory(func(db *gorm.DB, gcpService *gcpservices.GCPService) *gcpservices.GCPResourcesService {
 			return gcpservices.NewGCPResourcesService(db, gcpService)
 		}),
-		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, ts *talosservice.TalosService, gcpService *gcpservices.GCPService, gitopsService *gitops.GitOpsService) *gcpservices.ProvisioningService {
-			return gcpservices.NewProvisioningService(db, cfg, ts, gcpService, gitopsService)
+		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, gcpService *gcpservices.GCPService) *gcpservices.GCPPricingService {
+			return gcpservices.NewGCPPricingService(db, cfg, gcpService)
+		}),
+		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, ts *talosservice.TalosService, gcpService *gcpservices.GCPService, gitopsService *gitops.GitOpsService, pricingService *gcpservices.GCPPricingService, resourcesService *gcpservices.GCPResourcesService, logs *logging.Logging) *gcpservices.ProvisioningService {
+			return gcpservices.NewProvisioningService(db, cfg, ts, gcpService, gitopsService, pricingService, resourcesService, logs)
+		}),
+		gontainer.NewFactory(func(db *gorm.DB, gcpService *gcpservices.GCPService, provisioningService *gcpservices.ProvisioningService, logs *logging.Logging) *gcpservices.GCPStateService {
+			return gcpservices.NewGCPStateService(db, gcpService, provisioningService, logs)
+		}),
+		gontainer.NewFactory(func(db *gorm.DB, gcpService *gcpservices.GCPService, provisioningService *gcpservices.ProvisioningService, logs *logging.Logging) *gcpservices.SpotService {
+			return gcpservices.NewSpotService(db, gcpService, provisioningService, logs)
 		}),
 	}
 }
@@ -80,8 +95,8 @@ func RegisterGCPServices() []any {
 // RegisterInfrastructureServices registers infrastructure orchestration services
 func RegisterInfrastructureServices() []any {
 	return []any{
-		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, pm *services.ProviderManager, gitopsService *gitops.GitOpsService, ts *talosservice.TalosService) *services.InfrastructureService {
-			return services.NewInfrastructureService(db, cfg, pm, gitopsService, ts)
+		gontainer.NewFactory(func(db *gorm.DB, cfg *config.Config, pm *services.ProviderManager, gitopsService *gitops.GitOpsService, ts *talosservice.TalosService, logs *logging.Logging) *services.InfrastructureService {
+			return services.NewInfrastructureService(db, cfg, pm, gitopsService, ts, logs)
 		}),
 		gontainer.NewFactory(func(
 			db *gorm.DB,
@@ -91,22 +106,23 @@ func RegisterInfrastructureServices() []any {
 			talosService *talosservice.TalosService,
 			gitopsService *gitops.GitOpsService,
 			wsManager *wsservices.Manager,
+			logs *logging.Logging,
 		) *services.ProviderManager {
-			return services.NewProviderManager(db, cfg, gcpService, gcpResourcesService, talosService, gitopsService, wsManager)
+			return services.NewProviderManager(db, cfg, gcpService, gcpResourcesService, talosService, gitopsService, wsManager, logs)
 		}),
-		gontainer.NewFactory(func(resolver *gontainer.Resolver) *job.JobService {
-			s, _ := job.NewJobService(resolver)
+		gontainer.NewFactory(func(resolver *gontainer.Resolver, logs *logging.Logging) *job.JobService {
+			s, _ := job.NewJobService(resolver, logs)
 			return s
 		}),
 	}
 }
 
 // RegisterAllServices combines all service registrations into a single slice
-func RegisterAllServices(db *gorm.DB, cfg *config.Config) []any {
+func RegisterAllServices(db *gorm.DB, cfg *config.Config, logs *logging.Logging) []any {
 	var allServices []any
 
-	// Infrastructure (DB, Config)
-	allServices = append(allServices, RegisterInfrastructure(db, cfg)...)
+	// Infrastructure (DB, Config, Logging)
+	allServices = append(allServices, RegisterInfrastructure(db, cfg, logs)...)
 
 	// Middleware (JWT, WebSocket)
 	allServices = append(allServices, RegisterMiddleware()...)
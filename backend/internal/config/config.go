@@ -9,15 +9,19 @@ import (
 )
 
 type Config struct {
-	ClusterName  string         `mapstructure:"cluster_name"`
-	Database     DatabaseConfig `mapstructure:"database"`
-	GitOps       GitOpsConfig   `mapstructure:"gitops"`
-	GCP          GCPConfig      `mapstructure:"gcp"`
-	GitHub       GitHubConfig   `mapstructure:"github"`
-	JWT          JWTConfig      `mapstructure:"jwt"`
-	GCPResources GCPResources   `mapstructure:"gcp_resources"`
-	Talos        TalosConfig    `mapstructure:"talos"`
-	TalosFolder  string         `mapstructure:"talos_folder"`
+	ClusterName  string          `mapstructure:"cluster_name"`
+	Database     DatabaseConfig  `mapstructure:"database"`
+	GitOps       GitOpsConfig    `mapstructure:"gitops"`
+	GCP          GCPConfig       `mapstructure:"gcp"`
+	GitHub       GitHubConfig    `mapstructure:"github"`
+	JWT          JWTConfig       `mapstructure:"jwt"`
+	GCPResources GCPResources    `mapstructure:"gcp_resources"`
+	GCPPrices    GCPPriceCatalog `mapstructure:"gcp_prices"`
+	Pricing      PricingConfig   `mapstructure:"pricing"`
+	Talos        TalosConfig     `mapstructure:"talos"`
+	Tracing      TracingConfig   `mapstructure:"tracing"`
+	Logging      LoggingConfig   `mapstructure:"logging"`
+	TalosFolder  string          `mapstructure:"talos_folder"`
 }
 
 type DatabaseConfig struct {
@@ -27,6 +31,8 @@ type DatabaseConfig struct {
 	Password string `mapstructure:"password"`
 	Database string `mapstructure:"database"`
 	SSLMode  string `mapstructure:"sslmode"`
+	// SkipMigrations is set when the migrations run in a Job before the rollout
+	SkipMigrations bool `mapstructure:"skip_migrations"`
 }
 
 type GitOpsConfig struct {
@@ -69,12 +75,53 @@ type GCPMachineType struct {
 	MemoryMb    int64  `mapstructure:"memory_mb" json:"memory_mb"`
 }
 
+// GCPPriceCatalog holds on-demand GCP list prices used for cost estimation.
+// It is loaded from an offline JSON file and can be refreshed from the Cloud Billing Catalog API.
+type GCPPriceCatalog struct {
+	LastUpdated string                     `mapstructure:"last_updated" json:"last_updated"`
+	Source      string                     `mapstructure:"source" json:"source"` // offline, billing-api
+	Currency    string                     `mapstructure:"currency" json:"currency"`
+	Regions     map[string]GCPRegionPrices `mapstructure:"regions" json:"regions"`
+}
+
+type GCPRegionPrices struct {
+	MachineFamilies  map[string]GCPMachineFamilyPrice `mapstructure:"machine_families" json:"machine_families"`     // keyed by family (e2, n1, n2...)
+	MachineTypes     map[string]float64               `mapstructure:"machine_types" json:"machine_types"`           // hourly price for shared-core types
+	Disks            map[string]float64               `mapstructure:"disks" json:"disks"`                           // per GB-month, keyed by disk type
+	ExternalIPHourly float64                          `mapstructure:"external_ip_hourly" json:"external_ip_hourly"` // in-use external IPv4
+	StaticIPHourly   float64                          `mapstructure:"static_ip_hourly" json:"static_ip_hourly"`     // reserved but unused IPv4
+}
+
+type GCPMachineFamilyPrice struct {
+	CPUHourly          float64 `mapstructure:"cpu_hourly" json:"cpu_hourly"`
+	MemoryGBHourly     float64 `mapstructure:"memory_gb_hourly" json:"memory_gb_hourly"`
+	SpotCPUHourly      float64 `mapstructure:"spot_cpu_hourly" json:"spot_cpu_hourly,omitempty"` // Spot (preemptible) VMs
+	SpotMemoryGBHourly float64 `mapstructure:"spot_memory_gb_hourly" json:"spot_memory_gb_hourly,omitempty"`
+}
+
+type PricingConfig struct {
+	GCPCatalogFile string `mapstructure:"gcp_catalog_file"`
+}
+
 type TalosConfig struct {
 	EventSinkHostname     string `mapstructure:"event_sink_hostname"`
 	EventSinkBindHostname string `mapstructure:"event_sink_bind_hostname"`
 	EventSinkPort         string `mapstructure:"event_sink_port"`
 }
 
+// TracingConfig is read from the standard OpenTelemetry variables, traces are exported when an endpoint is set
+type TracingConfig struct {
+	Endpoint    string `mapstructure:"endpoint"`
+	ServiceName string `mapstructure:"service_name"`
+}
+
+// LoggingConfig sets the output of the logs and the levels of the subsystems
+type LoggingConfig struct {
+	Format string `mapstructure:"format"` // json or text
+	Level  string `mapstructure:"level"`
+	Levels string `mapstructure:"levels"` // subsystem=level list
+}
+
 func Load() (*Config, error) {
 	// setDefaults()
 
@@ -110,6 +157,11 @@ func Load() (*Config, error) {
 	if dbName := os.Getenv("DB_NAME"); dbName != "" {
 		config.Database.Database = dbName
 	}
+	if skipMigrations := os.Getenv("DB_SKIP_MIGRATIONS"); skipMigrations != "" {
+		if skip, err := strconv.ParseBool(skipMigrations); err == nil {
+			config.Database.SkipMigrations = skip
+		}
+	}
 	if gcpSAJSON := os.Getenv("GCP_SERVICE_ACCOUNT_JSON"); gcpSAJSON != "" {
 		config.GCP.ServiceAccountJSON = gcpSAJSON
 	}
@@ -164,6 +216,13 @@ func Load() (*Config, error) {
 		config.JWT.ExpiryMinutes = 1440 // default 24 hours
 	}
 
+	// Pricing Config
+	if catalogFile := os.Getenv("GCP_PRICE_CATALOG_FILE"); catalogFile != "" {
+		config.Pricing.GCPCatalogFile = catalogFile
+	} else {
+		config.Pricing.GCPCatalogFile = "pricing/gcp-price-catalog.json"
+	}
+
 	// Talos Event Sink Config
 	if talosHostname := os.Getenv("TALOS_EVENT_SINK_HOSTNAME"); talosHostname != "" {
 		config.Talos.EventSinkHostname = talosHostname
@@ -179,6 +238,29 @@ func Load() (*Config, error) {
 		config.Talos.EventSinkPort = "8082"
 	}
 
+	// Logging Config
+	if logFormat := os.Getenv("LOG_FORMAT"); logFormat != "" {
+		config.Logging.Format = logFormat
+	}
+	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
+		config.Logging.Level = logLevel
+	}
+	if logLevels := os.Getenv("LOG_LEVELS"); logLevels != "" {
+		config.Logging.Levels = logLevels
+	}
+
+	// Tracing Config
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		config.Tracing.Endpoint = endpoint
+	} else if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		config.Tracing.Endpoint = endpoint
+	}
+	if serviceName := os.Getenv("OTEL_SERVICE_NAME"); serviceName != "" {
+		config.Tracing.ServiceName = serviceName
+	} else {
+		config.Tracing.ServiceName = "stolos-backend"
+	}
+
 	return &config, nil
 }
 
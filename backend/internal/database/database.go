@@ -2,7 +2,7 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -11,10 +11,52 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	gormlogger "gorm.io/gorm/logger"
 )
 
-func Initialize(cfg config.DatabaseConfig) (*gorm.DB, error) {
+func Initialize(cfg config.DatabaseConfig, logger *slog.Logger) (*gorm.DB, error) {
+	db, err := open(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SkipMigrations {
+		logger.Info("skipping migrations, they are run by the migration job")
+	} else if err := runMigrations(db, logger); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	// Auto-create admin user if environment variables are set
+	if err := createDefaultAdmin(db, logger); err != nil {
+		logger.Warn("failed to create admin user", "error", err)
+	}
+
+	return db, nil
+}
+
+// Migrate runs the migrations and returns, it is the entrypoint of the migration job of an upgrade
+func Migrate(cfg config.DatabaseConfig, logger *slog.Logger) error {
+	db, err := open(cfg, logger)
+	if err != nil {
+		return err
+	}
+	// The job must not migrate the SQLite fallback and exit successfully
+	if cfg.Host != "" && db.Dialector.Name() != "postgres" {
+		return fmt.Errorf("failed to connect to PostgreSQL at %s", cfg.Host)
+	}
+
+	if err := runMigrations(db, logger); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	return sqlDB.Close()
+}
+
+func open(cfg config.DatabaseConfig, logger *slog.Logger) (*gorm.DB, error) {
 	var db *gorm.DB
 	var err error
 
@@ -23,21 +65,21 @@ func Initialize(cfg config.DatabaseConfig) (*gorm.DB, error) {
 			cfg.Host, cfg.User, cfg.Password, cfg.Database, cfg.Port, cfg.SSLMode)
 
 		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
+			Logger: gormlogger.Default.LogMode(gormlogger.Info),
 		})
 
 		if err == nil {
-			log.Println("Connected to PostgreSQL database")
+			logger.Info("connected to PostgreSQL database", "host", cfg.Host, "database", cfg.Database)
 		} else {
-			log.Printf("PostgreSQL connection failed: %v", err)
+			logger.Warn("PostgreSQL connection failed", "host", cfg.Host, "error", err)
 		}
 	}
 
 	// Fallback to SQLite
 	if db == nil {
-		log.Println("Using SQLite as database (development mode)")
+		logger.Info("using SQLite as database (development mode)")
 		db, err = gorm.Open(sqlite.Open("./data.db"), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
+			Logger: gormlogger.Default.LogMode(gormlogger.Info),
 		})
 
 		if err != nil {
@@ -45,19 +87,10 @@ func Initialize(cfg config.DatabaseConfig) (*gorm.DB, error) {
 		}
 	}
 
-	if err := runMigrations(db); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	// Auto-create admin user if environment variables are set
-	if err := createDefaultAdmin(db); err != nil {
-		log.Printf("Warning: Failed to create admin user: %v", err)
-	}
-
 	return db, nil
 }
 
-func createDefaultAdmin(db *gorm.DB) error {
+func createDefaultAdmin(db *gorm.DB, logger *slog.Logger) error {
 	adminEmail := os.Getenv("ADMIN_EMAIL")
 	adminPassword := os.Getenv("ADMIN_PASSWORD")
 
@@ -72,7 +105,7 @@ func createDefaultAdmin(db *gorm.DB) error {
 	err := db.Where("email = ?", adminEmail).First(&existingUser).Error
 	if err == nil {
 		// Admin already exists
-		log.Printf("Admin user already exists: %s", adminEmail)
+		logger.Info("admin user already exists", "email", adminEmail)
 		return nil
 	}
 	if err != gorm.ErrRecordNotFound {
@@ -100,25 +133,25 @@ func createDefaultAdmin(db *gorm.DB) error {
 	case gorm.ErrRecordNotFound:
 		adminNamespace = models.Namespace{Name: "administrators"}
 		if err := db.Create(&adminNamespace).Error; err != nil {
-			log.Printf("Warning: Failed to create administrators namespace: %v", err)
+			logger.Warn("failed to create administrators namespace", "error", err)
 		} else {
 			// Add admin to namespace
 			if err := db.Model(&admin).Association("Namespaces").Append(&adminNamespace); err != nil {
-				log.Printf("Warning: Failed to add admin to administrators namespace: %v", err)
+				logger.Warn("failed to add admin to administrators namespace", "error", err)
 			}
 		}
 	case nil:
 		// Namespace exists, add admin to it
 		if err := db.Model(&admin).Association("Namespaces").Append(&adminNamespace); err != nil {
-			log.Printf("Warning: Failed to add admin to existing administrators namespace: %v", err)
+			logger.Warn("failed to add admin to existing administrators namespace", "error", err)
 		}
 	}
 
-	log.Printf("Admin user created successfully: %s", adminEmail)
+	logger.Info("admin user created", "email", adminEmail)
 	return nil
 }
 
-func runMigrations(db *gorm.DB) error {
+func runMigrations(db *gorm.DB, logger *slog.Logger) error {
 	// UUID extension no needed for SQLite
 	if db.Dialector.Name() == "postgres" {
 		if err := db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"").Error; err != nil {
@@ -126,7 +159,7 @@ func runMigrations(db *gorm.DB) error {
 		}
 	}
 
-	if err := migrateUserEmailIndex(db); err != nil {
+	if err := migrateUserEmailIndex(db, logger); err != nil {
 		return fmt.Errorf("failed to migrate user email index: %w", err)
 	}
 
@@ -138,6 +171,7 @@ func runMigrations(db *gorm.DB) error {
 		&models.GCPResources{},
 		&models.GitOpsConfig{},
 		&models.ProvisionRequest{},
+		&models.NodePreemption{},
 		&models.Namespace{},
 		&models.User{},
 		&models.UserNamespace{},
@@ -147,12 +181,12 @@ func runMigrations(db *gorm.DB) error {
 
 // drops the old email index so AutoMigrate can recreate it
 // as a partial index to allow email reuse after soft delete
-func migrateUserEmailIndex(db *gorm.DB) error {
+func migrateUserEmailIndex(db *gorm.DB, logger *slog.Logger) error {
 	if db.Dialector.Name() == "postgres" {
 		if err := db.Exec("DROP INDEX IF EXISTS idx_users_email").Error; err != nil {
 			return err
 		}
-		log.Println("Dropped old users email index")
+		logger.Info("dropped old users email index")
 	}
 	return nil
 }
@@ -1,11 +1,10 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"path/filepath"
 	"time"
@@ -13,12 +12,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	"github.com/stolos-cloud/stolos/backend/internal/services"
 	gcpservices "github.com/stolos-cloud/stolos/backend/internal/services/gcp"
 	gitopsservices "github.com/stolos-cloud/stolos/backend/internal/services/gitops"
 	"github.com/stolos-cloud/stolos/backend/internal/services/node"
 	wsservices "github.com/stolos-cloud/stolos/backend/internal/services/websocket"
+	"github.com/stolos-cloud/stolos/backend/internal/tracing"
 	"gorm.io/gorm"
 )
 
@@ -38,7 +39,11 @@ type GCPHandlers struct {
 	infrastructureService *services.InfrastructureService
 	gcpResourcesService   *gcpservices.GCPResourcesService
 	provisioningService   *gcpservices.ProvisioningService
+	pricingService        *gcpservices.GCPPricingService
+	stateService          *gcpservices.GCPStateService
+	spotService           *gcpservices.SpotService
 	wsManager             *wsservices.Manager
+	logger                *slog.Logger
 }
 
 func NewGCPHandlers(
@@ -49,7 +54,11 @@ func NewGCPHandlers(
 	infrastructureService *services.InfrastructureService,
 	gcpResourcesService *gcpservices.GCPResourcesService,
 	provisioningService *gcpservices.ProvisioningService,
+	pricingService *gcpservices.GCPPricingService,
+	stateService *gcpservices.GCPStateService,
+	spotService *gcpservices.SpotService,
 	wsManager *wsservices.Manager,
+	logs *logging.Logging,
 ) *GCPHandlers {
 	return &GCPHandlers{
 		db:                    db,
@@ -59,7 +68,11 @@ func NewGCPHandlers(
 		infrastructureService: infrastructureService,
 		gcpResourcesService:   gcpResourcesService,
 		provisioningService:   provisioningService,
+		pricingService:        pricingService,
+		stateService:          stateService,
+		spotService:           spotService,
 		wsManager:             wsManager,
+		logger:                logs.Logger(logging.SubsystemGCP),
 	}
 }
 
@@ -101,6 +114,7 @@ func (h *GCPHandlers) GetGCPStatus(c *gin.Context) {
 			"configured":              true,
 			"project_id":              gcpConfig.ProjectID,
 			"region":                  gcpConfig.Region,
+			"regions":                 gcpConfig.Regions(),
 			"bucket_name":             gcpConfig.BucketName,
 			"service_account_email":   gcpConfig.ServiceAccountEmail,
 			"infrastructure_status":   gcpConfig.InfrastructureStatus,
@@ -124,7 +138,7 @@ func (h *GCPHandlers) GetGCPStatus(c *gin.Context) {
 		if gcpConfig.InfrastructureStatus != "unconfigured" {
 			infraStatus, err := h.infrastructureService.GetInfrastructureStatus(c.Request.Context(), "gcp")
 			if err != nil {
-				log.Printf("Warning: Failed to get infrastructure status: %v", err)
+				h.logger.WarnContext(c.Request.Context(), "failed to get infrastructure status", "error", err)
 			} else {
 				response["infrastructure"] = infraStatus
 			}
@@ -182,16 +196,17 @@ func (h *GCPHandlers) ConfigureGCP(c *gin.Context) {
 
 	// Initialize infrastructure in background only if not already ready
 	if gcpConfig.InfrastructureStatus != "ready" {
+		ctx := tracing.Detach(c.Request.Context())
 		go func() {
-			log.Printf("Starting infrastructure initialization for GCP provider...")
-			if err := h.infrastructureService.InitializeInfrastructure(context.Background(), "gcp"); err != nil {
-				log.Printf("Failed to initialize GCP infrastructure: %v", err)
+			h.logger.InfoContext(ctx, "starting infrastructure initialization for GCP provider")
+			if err := h.infrastructureService.InitializeInfrastructure(ctx, "gcp"); err != nil {
+				h.logger.ErrorContext(ctx, "failed to initialize GCP infrastructure", "error", err)
 			} else {
-				log.Printf("GCP infrastructure initialized successfully")
+				h.logger.InfoContext(ctx, "GCP infrastructure initialized")
 			}
 		}()
 	} else {
-		log.Printf("GCP infrastructure already ready, skipping initialization")
+		h.logger.InfoContext(c.Request.Context(), "GCP infrastructure already ready, skipping initialization")
 	}
 
 	c.JSON(http.StatusOK, gcpConfig)
@@ -252,16 +267,17 @@ func (h *GCPHandlers) ConfigureGCPUpload(c *gin.Context) {
 
 	// Initialize infrastructure in background only if not already ready
 	if gcpConfig.InfrastructureStatus != "ready" {
+		ctx := tracing.Detach(c.Request.Context())
 		go func() {
-			log.Printf("Starting infrastructure initialization for GCP provider...")
-			if err := h.infrastructureService.InitializeInfrastructure(context.Background(), "gcp"); err != nil {
-				log.Printf("Failed to initialize GCP infrastructure: %v", err)
+			h.logger.InfoContext(ctx, "starting infrastructure initialization for GCP provider")
+			if err := h.infrastructureService.InitializeInfrastructure(ctx, "gcp"); err != nil {
+				h.logger.ErrorContext(ctx, "failed to initialize GCP infrastructure", "error", err)
 			} else {
-				log.Printf("GCP infrastructure initialized successfully")
+				h.logger.InfoContext(ctx, "GCP infrastructure initialized")
 			}
 		}()
 	} else {
-		log.Printf("GCP infrastructure already ready, skipping initialization")
+		h.logger.InfoContext(c.Request.Context(), "GCP infrastructure already ready, skipping initialization")
 	}
 
 	c.JSON(http.StatusOK, gcpConfig)
@@ -329,6 +345,45 @@ func (h *GCPHandlers) QueryGCPInstances(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Sample : successfully queried GCP instances"})
 }
 
+// SetGCPRegions godoc
+// @Summary Set additional GCP regions
+// @Description Set the regions, besides the primary one, where nodes can be provisioned. Regions can only be added. Each region gets its own subnet, the infrastructure is re-initialized and the zone cache refreshed.
+// @Tags gcp
+// @Accept json
+// @Produce json
+// @Param request body object{regions=[]string} true "Additional regions"
+// @Success 200 {object} models.GCPConfig
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /gcp/regions [put]
+// @Security BearerAuth
+func (h *GCPHandlers) SetGCPRegions(c *gin.Context) {
+	var req struct {
+		Regions []string `json:"regions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	gcpConfig, err := h.gcpService.SetAdditionalRegions(c.Request.Context(), req.Regions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.infrastructureService.InitializeInfrastructure(c.Request.Context(), "gcp"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create region subnets: %v", err)})
+		return
+	}
+
+	if _, err := h.gcpResourcesService.RefreshFromGCP(c.Request.Context()); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "failed to refresh GCP resources after region change", "error", err)
+	}
+
+	c.JSON(http.StatusOK, gcpConfig)
+}
+
 // InitInfra godoc
 // @Summary Initialize Terraform infrastructure
 // @Description Initialize the Terraform infrastructure on GCP
@@ -420,6 +475,68 @@ func (h *GCPHandlers) RefreshGCPResources(c *gin.Context) {
 	})
 }
 
+// GetGCPPrices godoc
+// @Summary Get GCP price catalog
+// @Description Retrieve the price catalog used to estimate the cost of provisioning plans
+// @Tags gcp
+// @Accept json
+// @Produce json
+// @Success 200 {object} config.GCPPriceCatalog
+// @Failure 500 {object} map[string]string
+// @Router /gcp/pricing [get]
+// @Security BearerAuth
+func (h *GCPHandlers) GetGCPPrices(c *gin.Context) {
+	catalog, err := h.pricingService.GetCatalog()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, catalog)
+}
+
+// RefreshGCPPrices godoc
+// @Summary Refresh GCP price catalog
+// @Description Fetch current list prices for the configured region from the Cloud Billing Catalog API
+// @Tags gcp
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /gcp/pricing/refresh [post]
+// @Security BearerAuth
+func (h *GCPHandlers) RefreshGCPPrices(c *gin.Context) {
+	catalog, err := h.pricingService.RefreshFromBillingAPI(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "GCP prices refreshed successfully",
+		"last_updated": catalog.LastUpdated,
+		"regions":      len(catalog.Regions),
+	})
+}
+
+// GetGCPRunningCosts godoc
+// @Summary Get running cost of GCP nodes
+// @Description Estimate the current hourly and monthly cost of the Stolos-managed GCP instances
+// @Tags gcp
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.RunningCostSummary
+// @Failure 500 {object} map[string]string
+// @Router /gcp/costs [get]
+// @Security BearerAuth
+func (h *GCPHandlers) GetGCPRunningCosts(c *gin.Context) {
+	summary, err := h.pricingService.GetRunningCosts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
 // ForceUnlockTerraformState godoc
 // @Summary Force unlock Terraform state
 // @Description Remove a stuck Terraform state lock. WARNING: Only use when certain no operations are running
@@ -454,6 +571,132 @@ func (h *GCPHandlers) ForceUnlockTerraformState(c *gin.Context) {
 	})
 }
 
+// GetTerraformState godoc
+// @Summary List resources in the Terraform state
+// @Description List the resources tracked in a remote Terraform state stored in the GCS backend
+// @Tags gcp
+// @Produce json
+// @Param state query string false "State to read: nodes or infrastructure" default(nodes)
+// @Success 200 {object} models.TerraformStateSummary
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /gcp/terraform/state [get]
+// @Security BearerAuth
+func (h *GCPHandlers) GetTerraformState(c *gin.Context) {
+	state := c.DefaultQuery("state", "nodes")
+
+	summary, err := h.stateService.ListStateResources(c.Request.Context(), state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetTerraformStateResource godoc
+// @Summary Get a resource from the Terraform state
+// @Description Show the attributes of a resource tracked in a remote Terraform state. Sensitive values are redacted
+// @Tags gcp
+// @Produce json
+// @Param state query string false "State to read: nodes or infrastructure" default(nodes)
+// @Param address query string true "Resource address, e.g. module.worker-1.google_compute_instance.node"
+// @Success 200 {object} models.TerraformStateResource
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /gcp/terraform/state/resource [get]
+// @Security BearerAuth
+func (h *GCPHandlers) GetTerraformStateResource(c *gin.Context) {
+	state := c.DefaultQuery("state", "nodes")
+	address := c.Query("address")
+	if address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "address is required"})
+		return
+	}
+
+	resource, err := h.stateService.GetStateResource(c.Request.Context(), state, address)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "resource not found in state"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resource)
+}
+
+// CompareGCPInstances godoc
+// @Summary Compare Terraform state, nodes and GCP instances
+// @Description Compare the instances tracked in the nodes state with node records and live GCP instances to find unmanaged or missing ones
+// @Tags gcp
+// @Produce json
+// @Success 200 {array} models.GCPInstanceComparison
+// @Failure 500 {object} map[string]string
+// @Router /gcp/terraform/compare [get]
+// @Security BearerAuth
+func (h *GCPHandlers) CompareGCPInstances(c *gin.Context) {
+	comparisons, err := h.stateService.CompareInstances(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comparisons)
+}
+
+// ImportGCPInstance godoc
+// @Summary Import an unmanaged Talos instance
+// @Description Import an existing Talos GCP instance into the node module so it becomes a Stolos node. Use dry_run to preview the generated node file first
+// @Tags gcp
+// @Accept json
+// @Produce json
+// @Param request body models.TerraformImportRequest true "Instance to import"
+// @Success 200 {object} models.TerraformImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /gcp/terraform/import [post]
+// @Security BearerAuth
+func (h *GCPHandlers) ImportGCPInstance(c *gin.Context) {
+	var req models.TerraformImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.stateService.ImportInstance(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetNodePreemptions godoc
+// @Summary Get spot node preemption history
+// @Description List the preemptions of a spot node and how they were recovered, most recent first
+// @Tags gcp
+// @Produce json
+// @Param node_id path string true "Node ID"
+// @Success 200 {array} models.NodePreemption
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /gcp/nodes/{node_id}/preemptions [get]
+// @Security BearerAuth
+func (h *GCPHandlers) GetNodePreemptions(c *gin.Context) {
+	nodeID, err := uuid.Parse(c.Param("node_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid node_id"})
+		return
+	}
+
+	preemptions, err := h.spotService.ListPreemptions(nodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preemptions)
+}
+
 // ProvisionGCPNodes godoc
 // @Summary Provision GCP nodes with Talos
 // @Description Create a provision request and return request_id for WebSocket connection
@@ -479,6 +722,32 @@ func (h *GCPHandlers) ProvisionGCPNodes(c *gin.Context) {
 		return
 	}
 
+	if err := gcpservices.ValidateSpotRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate zones against the cached GCP resources
+	gcpConfig, err := h.gcpService.GetCurrentConfig()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "GCP is not configured"})
+		return
+	}
+	resources, err := h.gcpResourcesService.GetResources()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no cached GCP resources, refresh them first"})
+		return
+	}
+	zones, err := gcpservices.ResolveZones(req, gcpConfig, resources)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := gcpservices.SpreadZones(zones, nil, req.Number, req.SpreadPolicy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create provision request record
 	requestID := uuid.New()
 	requestJSON, _ := json.Marshal(req)
@@ -602,7 +871,8 @@ func (h *GCPHandlers) GetProvisionApply(c *gin.Context) {
 
 // ProvisionGCPNodesStream godoc
 // @Summary WebSocket stream for GCP node provisioning
-// @Description Connect to this WebSocket endpoint to receive real-time logs and approval requests
+// @Description Connect to this WebSocket endpoint to receive real-time logs and approval requests.
+// @Description Connecting to a request that is already running reattaches to its stream.
 // @Tags gcp
 // @Param request_id path string true "Provision request ID"
 // @Param token query string true "JWT token"
@@ -611,10 +881,12 @@ func (h *GCPHandlers) ProvisionGCPNodesStream(c *gin.Context) {
 	requestID := c.Param("request_id")
 
 	// Validate request ID
-	if _, err := uuid.Parse(requestID); err != nil {
+	requestUUID, err := uuid.Parse(requestID)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request_id"})
 		return
 	}
+	requestID = requestUUID.String()
 
 	// Check if provision request exists
 	var provisionRequest models.ProvisionRequest
@@ -623,6 +895,13 @@ func (h *GCPHandlers) ProvisionGCPNodesStream(c *gin.Context) {
 		return
 	}
 
+	// Parse the provision request
+	var req models.GCPNodeProvisionRequest
+	if err := json.Unmarshal(provisionRequest.Request, &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to parse provision request: %v", err)})
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -633,37 +912,31 @@ func (h *GCPHandlers) ProvisionGCPNodesStream(c *gin.Context) {
 	// Register WebSocket client
 	client := h.wsManager.RegisterClient(requestID, conn, nil)
 
-	// Create approval session for GCP provisioning workflow
-	session := wsservices.NewApprovalSession(requestID, client)
+	// Finished requests are not run again, only report their final status
+	if provisionRequest.Status.IsTerminal() && !h.provisioningService.IsRunning(requestUUID) {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			if provisionRequest.Error != "" {
+				client.SendError(provisionRequest.Error)
+			}
+			client.SendStatus(string(provisionRequest.Status))
+		}()
+		return
+	}
 
-	// Update client's session so it can route incoming messages
-	client.SetSession(session)
+	// Reattach to the running workflow or create an approval session for a new one
+	session, start := h.provisioningService.AttachSession(requestUUID, client)
+	if !start {
+		return
+	}
 
 	// Start provisioning in a goroutine
+	// The HTTP context is canceled after the WebSocket upgrade completes, the workflow only keeps its trace
+	ctx := tracing.Detach(c.Request.Context())
 	go func() {
 		// Give write pump time to start
 		time.Sleep(100 * time.Millisecond)
 
-		// Parse the provision request
-		var req models.GCPNodeProvisionRequest
-		if err := json.Unmarshal(provisionRequest.Request, &req); err != nil {
-			session.SendErrorString(fmt.Sprintf("Failed to parse provision request: %v", err))
-			session.SendStatus("failed")
-			return
-		}
-
-		// Run the provisioning workflow with a background context
-		// We use context.Background() instead of c.Request.Context() because the HTTP context
-		// is canceled after the WebSocket upgrade completes
-		requestUUID, _ := uuid.Parse(requestID)
-		if err := h.provisioningService.ProvisionNodes(context.Background(), requestUUID, req, session); err != nil {
-			session.SendErrorString(fmt.Sprintf("Provisioning failed: %v", err))
-			session.SendStatus("failed")
-
-			// Update provision request status
-			h.db.Model(&models.ProvisionRequest{}).
-				Where("id = ?", requestID).
-				Update("status", models.ProvisionStatusFailed)
-		}
+		h.provisioningService.RunProvisioning(ctx, requestUUID, req, session)
 	}()
 }
@@ -16,6 +16,7 @@ type Handlers struct {
 	eventHandlers     *EventHandlers
 	templatesHandlers *TemplatesHandler
 	scaffoldsHandlers *ScaffoldsHandler
+	loggingHandlers   *LoggingHandlers
 	jwtService        *middleware.JWTService
 	db                *gorm.DB
 	wsManager         *wsservices.Manager
@@ -31,6 +32,7 @@ func NewHandlers(
 	eventHandlers *EventHandlers,
 	templatesHandlers *TemplatesHandler,
 	scaffoldsHandlers *ScaffoldsHandler,
+	loggingHandlers *LoggingHandlers,
 	jwtService *middleware.JWTService,
 	db *gorm.DB,
 	wsManager *wsservices.Manager,
@@ -45,6 +47,7 @@ func NewHandlers(
 		eventHandlers:     eventHandlers,
 		templatesHandlers: templatesHandlers,
 		scaffoldsHandlers: scaffoldsHandlers,
+		loggingHandlers:   loggingHandlers,
 		jwtService:        jwtService,
 		db:                db,
 		wsManager:         wsManager,
@@ -90,3 +93,5 @@ func (h *Handlers) DB() *gorm.DB {
 func (h *Handlers) TemplatesHandlers() *TemplatesHandler { return h.templatesHandlers }
 
 func (h *Handlers) ScaffoldsHandlers() *ScaffoldsHandler { return h.scaffoldsHandlers }
+
+func (h *Handlers) LoggingHandlers() *LoggingHandlers { return h.loggingHandlers }
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+)
+
+type LoggingHandlers struct {
+	logs *logging.Logging
+}
+
+func NewLoggingHandlers(logs *logging.Logging) *LoggingHandlers {
+	return &LoggingHandlers{
+		logs: logs,
+	}
+}
+
+// GetLogLevels godoc
+// @Summary Get log levels
+// @Description Get the log level of each backend subsystem
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /admin/log-levels [get]
+// @Security BearerAuth
+func (h *LoggingHandlers) GetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, h.logs.Levels())
+}
+
+// SetLogLevel godoc
+// @Summary Set a log level
+// @Description Change the log level of a subsystem at runtime, or of all subsystems when none is given
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param level body object{subsystem=string,level=string} true "Subsystem and level (debug, info, warn, error)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /admin/log-levels [put]
+// @Security BearerAuth
+func (h *LoggingHandlers) SetLogLevel(c *gin.Context) {
+	var req struct {
+		Subsystem string `json:"subsystem"`
+		Level     string `json:"level" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.logs.SetLevel(req.Subsystem, req.Level); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.logs.Levels())
+}
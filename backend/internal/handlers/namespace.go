@@ -1,18 +1,20 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"regexp"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stolos-cloud/stolos/backend/internal/api"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/middleware"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	gitopsservices "github.com/stolos-cloud/stolos/backend/internal/services/gitops"
 	"github.com/stolos-cloud/stolos/backend/internal/services/k8s"
+	"github.com/stolos-cloud/stolos/backend/internal/tracing"
 	"gorm.io/gorm"
 )
 
@@ -20,13 +22,15 @@ type NamespaceHandlers struct {
 	db            *gorm.DB
 	gitopsService *gitopsservices.GitOpsService
 	k8sClient     *k8s.K8sClient
+	logger        *slog.Logger
 }
 
-func NewNamespaceHandlers(db *gorm.DB, gitopsService *gitopsservices.GitOpsService, k8sClient *k8s.K8sClient) *NamespaceHandlers {
+func NewNamespaceHandlers(db *gorm.DB, gitopsService *gitopsservices.GitOpsService, k8sClient *k8s.K8sClient, logs *logging.Logging) *NamespaceHandlers {
 	return &NamespaceHandlers{
 		db:            db,
 		gitopsService: gitopsService,
 		k8sClient:     k8sClient,
+		logger:        logs.Logger(logging.SubsystemNamespaces),
 	}
 }
 
@@ -96,13 +100,13 @@ func (h *NamespaceHandlers) CreateNamespace(c *gin.Context) {
 	}
 
 	// Create the actual Kubernetes namespace
-	if err := h.k8sClient.CreateNamespace(context.Background(), fullName); err != nil {
-		fmt.Printf("Warning: Failed to create Kubernetes namespace %s: %v\n", fullName, err)
+	if err := h.k8sClient.CreateNamespace(tracing.Detach(c.Request.Context()), fullName); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "failed to create Kubernetes namespace", "namespace", fullName, "error", err)
 	}
 
 	// Create GitOps manifests for the namespace
-	if err := h.gitopsService.CreateNamespaceDirectory(context.Background(), fullName); err != nil {
-		fmt.Printf("Warning: Failed to create GitOps manifests for namespace %s: %v\n", req.Name, err)
+	if err := h.gitopsService.CreateNamespaceDirectory(tracing.Detach(c.Request.Context()), fullName); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "failed to create GitOps manifests", "namespace", fullName, "error", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"namespace": api.ToNamespaceResponse(&namespace, false)})
@@ -462,13 +466,13 @@ func (h *NamespaceHandlers) DeleteNamespace(c *gin.Context) {
 	}
 
 	// Delete the actual Kubernetes namespace
-	if err := h.k8sClient.DeleteNamespace(context.Background(), namespace.Name); err != nil {
-		fmt.Printf("Warning: Failed to delete Kubernetes namespace %s: %v\n", namespace.Name, err)
+	if err := h.k8sClient.DeleteNamespace(tracing.Detach(c.Request.Context()), namespace.Name); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "failed to delete Kubernetes namespace", "namespace", namespace.Name, "error", err)
 	}
 
 	// Delete GitOps manifests for the namespace
-	if err := h.gitopsService.DeleteNamespaceManifests(context.Background(), namespace.Name); err != nil {
-		fmt.Printf("Warning: Failed to delete GitOps manifests for namespace %s: %v\n", namespace.Name, err)
+	if err := h.gitopsService.DeleteNamespaceManifests(tracing.Detach(c.Request.Context()), namespace.Name); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "failed to delete GitOps manifests", "namespace", namespace.Name, "error", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Namespace deleted successfully"})
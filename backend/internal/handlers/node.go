@@ -231,7 +231,7 @@ func (h *NodeHandlers) ProvisionNodes(c *gin.Context) {
 		return
 	}
 
-	nodes, err := h.nodeService.ProvisionNodes(req.Nodes)
+	nodes, err := h.nodeService.ProvisionNodes(c.Request.Context(), req.Nodes)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -340,3 +340,52 @@ func (h *NodeHandlers) GetTalosconfig(c *gin.Context) {
 	}
 	c.Data(http.StatusOK, "application/yaml", cfg)
 }
+
+// GetControlPlaneEndpoint godoc
+// @Summary Get the control plane endpoint
+// @Description Returns the Kubernetes API endpoint, VIP and cert SANs of the control plane config, and the progress of the last endpoint conversion
+// @Tags cluster
+// @Produce json
+// @Success 200 {object} models.ControlPlaneEndpointStatus
+// @Failure 500 {object} map[string]string
+// @Router /cluster/endpoint [get]
+// @Security BearerAuth
+func (h *NodeHandlers) GetControlPlaneEndpoint(c *gin.Context) {
+	status, err := h.talosService.GetControlPlaneEndpoint()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// UpdateControlPlaneEndpoint godoc
+// @Summary Move the control plane endpoint to a VIP or external endpoint
+// @Description Starts a rolling conversion: control planes get the new cert SANs (and VIP), then every node is pointed at the new endpoint. Kubeconfigs must be fetched again afterwards.
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param request body models.ControlPlaneEndpointRequest true "New endpoint"
+// @Success 202 {object} models.EndpointConversion
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /cluster/endpoint [put]
+// @Security BearerAuth
+func (h *NodeHandlers) UpdateControlPlaneEndpoint(c *gin.Context) {
+	var req models.ControlPlaneEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	conversion, err := h.talosService.StartControlPlaneEndpointConversion(&req)
+	if errors.Is(err, talos.ErrEndpointConversionRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, conversion)
+}
@@ -3,16 +3,19 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/middleware"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	"github.com/stolos-cloud/stolos/backend/internal/services/gitops"
 	"github.com/stolos-cloud/stolos/backend/internal/services/k8s"
 	"github.com/stolos-cloud/stolos/backend/internal/services/templates"
+	"github.com/stolos-cloud/stolos/backend/internal/tracing"
 	"gorm.io/gorm"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -26,6 +29,7 @@ type TemplatesHandler struct {
 	k8sClient     *k8s.K8sClient
 	gitOpsService *gitops.GitOpsService
 	db            *gorm.DB
+	logger        *slog.Logger
 }
 
 type DetailTemplate struct {
@@ -34,11 +38,12 @@ type DetailTemplate struct {
 	DefaultYaml string               `json:"defaultYaml"`
 }
 
-func NewTemplatesHandler(k8s *k8s.K8sClient, gitOpsService *gitops.GitOpsService, db *gorm.DB) *TemplatesHandler {
+func NewTemplatesHandler(k8s *k8s.K8sClient, gitOpsService *gitops.GitOpsService, db *gorm.DB, logs *logging.Logging) *TemplatesHandler {
 	return &TemplatesHandler{
 		k8sClient:     k8s,
 		gitOpsService: gitOpsService,
 		db:            db,
+		logger:        logs.Logger(logging.SubsystemTemplates),
 	}
 }
 
@@ -61,6 +66,25 @@ func (h *TemplatesHandler) GetTemplatesList(c *gin.Context) {
 	c.JSON(http.StatusOK, templatesList)
 }
 
+// GetLoadBalancerPools godoc
+// @Summary Get load balancer pools
+// @Description returns the MetalLB address pools, a template can request one for its LoadBalancer services
+// @Tags templates
+// @Produce json
+// @Success 200 {object} []templates.LoadBalancerPool
+// @Failure 500 {object} map[string]string "error"
+// @Router /templates/loadbalancer-pools [get]
+// @Security BearerAuth
+func (h *TemplatesHandler) GetLoadBalancerPools(c *gin.Context) {
+	pools, err := templates.ListLoadBalancerPools(c.Request.Context(), h.k8sClient)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pools)
+}
+
 // GetTemplate godoc
 // @Summary Get a detailed template
 // @Description Get a template from a CRD and returns it, its json schema and a default yaml
@@ -194,7 +218,7 @@ func (h *TemplatesHandler) doApplyAction(c *gin.Context, onlyDryRun bool) {
 		Version:  crdTemplate.GetCRD().Spec.Versions[0].Name,
 	}
 
-	if err := h.k8sClient.ApplyCR(cr, gvr, onlyDryRun); err != nil {
+	if err := h.k8sClient.ApplyCR(c.Request.Context(), cr, gvr, onlyDryRun); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		return
 	}
@@ -206,8 +230,8 @@ func (h *TemplatesHandler) doApplyAction(c *gin.Context, onlyDryRun bool) {
 			return
 		}
 
-		if err := h.gitOpsService.CreateDeploymentFile(context.Background(), userNamespace.Name, instanceName, string(yamlBytes)); err != nil {
-			fmt.Printf("Warning: Failed to create deployment file in GitOps repo: %v\n", err)
+		if err := h.gitOpsService.CreateDeploymentFile(tracing.Detach(c.Request.Context()), userNamespace.Name, instanceName, string(yamlBytes)); err != nil {
+			h.logger.WarnContext(c.Request.Context(), "failed to create deployment file in GitOps repo", "namespace", userNamespace.Name, "deployment", instanceName, "error", err)
 		}
 	}
 
@@ -239,7 +263,7 @@ func (h *TemplatesHandler) ListDeployments(c *gin.Context) {
 		Group:     templateGroup,
 	}
 
-	deployments, err := templates.ListDeploymentsForFilter(h.k8sClient, filter)
+	deployments, err := templates.ListDeploymentsForFilter(c.Request.Context(), h.k8sClient, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -262,7 +286,7 @@ func (h *TemplatesHandler) ListDeployments(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
 
-		fmt.Printf("myNamespaces: %+v\n", myNamespaces)
+		h.logger.DebugContext(c.Request.Context(), "namespaces of the user", "namespaces", myNamespaces)
 
 		allDeployments := deployments
 		deployments = []templates.Deployment{}
@@ -372,7 +396,7 @@ func (h *TemplatesHandler) DeleteDeployment(c *gin.Context) {
 	}
 
 	// Delete deployment file from GitOps repo
-	if err := h.gitOpsService.DeleteDeploymentFile(context.Background(), namespace, deploymentName); err != nil {
+	if err := h.gitOpsService.DeleteDeploymentFile(tracing.Detach(c.Request.Context()), namespace, deploymentName); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to delete deployment file: %v", err)})
 		return
 	}
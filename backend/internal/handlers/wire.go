@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"github.com/NVIDIA/gontainer/v2"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/middleware"
 	"github.com/stolos-cloud/stolos/backend/internal/services"
 	gcpservices "github.com/stolos-cloud/stolos/backend/internal/services/gcp"
@@ -20,8 +21,8 @@ func RegisterHandlers() []any {
 		gontainer.NewFactory(func(db *gorm.DB, jwt *middleware.JWTService) *AuthHandlers {
 			return NewAuthHandlers(db, jwt)
 		}),
-		gontainer.NewFactory(func(db *gorm.DB, gitopsService *gitops.GitOpsService, k8sClient *k8s.K8sClient) *NamespaceHandlers {
-			return NewNamespaceHandlers(db, gitopsService, k8sClient)
+		gontainer.NewFactory(func(db *gorm.DB, gitopsService *gitops.GitOpsService, k8sClient *k8s.K8sClient, logs *logging.Logging) *NamespaceHandlers {
+			return NewNamespaceHandlers(db, gitopsService, k8sClient, logs)
 		}),
 		gontainer.NewFactory(func(db *gorm.DB) *UserHandlers {
 			return NewUserHandlers(db)
@@ -32,8 +33,8 @@ func RegisterHandlers() []any {
 		gontainer.NewFactory(func(db *gorm.DB, ns *node.NodeService, ts *talosservice.TalosService, wsManager *wsservices.Manager) *NodeHandlers {
 			return NewNodeHandlers(db, ns, ts, wsManager)
 		}),
-		gontainer.NewFactory(func(db *gorm.DB, gitopsService *gitops.GitOpsService, k8s *k8s.K8sClient) *TemplatesHandler {
-			return NewTemplatesHandler(k8s, gitopsService, db)
+		gontainer.NewFactory(func(db *gorm.DB, gitopsService *gitops.GitOpsService, k8s *k8s.K8sClient, logs *logging.Logging) *TemplatesHandler {
+			return NewTemplatesHandler(k8s, gitopsService, db, logs)
 		}),
 		gontainer.NewFactory(func(db *gorm.DB, gitops *gitops.GitOpsService, k8s *k8s.K8sClient) *ScaffoldsHandler {
 			return NewScaffoldsHandler(k8s, gitops, db)
@@ -41,6 +42,9 @@ func RegisterHandlers() []any {
 		gontainer.NewFactory(func(wsManager *wsservices.Manager) *EventHandlers {
 			return NewEventHandlers(wsManager)
 		}),
+		gontainer.NewFactory(func(logs *logging.Logging) *LoggingHandlers {
+			return NewLoggingHandlers(logs)
+		}),
 		gontainer.NewFactory(func(
 			db *gorm.DB,
 			gcpService *gcpservices.GCPService,
@@ -49,7 +53,11 @@ func RegisterHandlers() []any {
 			infrastructureService *services.InfrastructureService,
 			gcpResourcesService *gcpservices.GCPResourcesService,
 			provisioningService *gcpservices.ProvisioningService,
+			pricingService *gcpservices.GCPPricingService,
+			stateService *gcpservices.GCPStateService,
+			spotService *gcpservices.SpotService,
 			wsManager *wsservices.Manager,
+			logs *logging.Logging,
 		) *GCPHandlers {
 			return NewGCPHandlers(
 				db,
@@ -59,7 +67,11 @@ func RegisterHandlers() []any {
 				infrastructureService,
 				gcpResourcesService,
 				provisioningService,
+				pricingService,
+				stateService,
+				spotService,
 				wsManager,
+				logs,
 			)
 		}),
 
@@ -75,6 +87,7 @@ func RegisterHandlers() []any {
 			jwtService *middleware.JWTService,
 			templatesHandler *TemplatesHandler,
 			scaffoldsHandler *ScaffoldsHandler,
+			loggingHandlers *LoggingHandlers,
 			db *gorm.DB,
 			wsManager *wsservices.Manager,
 		) *Handlers {
@@ -88,6 +101,7 @@ func RegisterHandlers() []any {
 				eventHandlers,
 				templatesHandler,
 				scaffoldsHandler,
+				loggingHandlers,
 				jwtService,
 				db,
 				wsManager,
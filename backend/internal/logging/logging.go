@@ -0,0 +1,200 @@
+// Package logging is the structured logger of the backend. Each subsystem has a level that can be changed at runtime,
+// and the attributes attached to a context (request, job or workflow IDs, trace) are added to its log lines.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Subsystems of the backend
+const (
+	SubsystemDefault        = "default" // startup and slog default logger
+	SubsystemHTTP           = "http"
+	SubsystemJobs           = "jobs"
+	SubsystemProvisioning   = "provisioning"
+	SubsystemNamespaces     = "namespaces"
+	SubsystemTalos          = "talos"
+	SubsystemNodes          = "nodes"
+	SubsystemGCP            = "gcp"
+	SubsystemInfrastructure = "infrastructure"
+	SubsystemGitOps         = "gitops"
+	SubsystemKubernetes     = "kubernetes"
+	SubsystemWebSocket      = "websocket"
+	SubsystemCluster        = "cluster"
+	SubsystemTemplates      = "templates"
+)
+
+// Logging creates the loggers of the subsystems and holds their levels
+type Logging struct {
+	handler      slog.Handler
+	mu           sync.RWMutex
+	defaultLevel slog.Level
+	levels       map[string]*slog.LevelVar
+}
+
+// New creates the loggers writing to w, in JSON unless the format is text
+func New(cfg config.LoggingConfig, w io.Writer) (*Logging, error) {
+	defaultLevel, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	// Levels are checked per subsystem, the output handler accepts everything
+	options := &slog.HandlerOptions{Level: slog.Level(-8)}
+	var handler slog.Handler
+	switch cfg.Format {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected json or text", cfg.Format)
+	}
+
+	l := &Logging{
+		handler:      handler,
+		defaultLevel: defaultLevel,
+		levels:       make(map[string]*slog.LevelVar),
+	}
+
+	// LOG_LEVELS is a list of subsystem=level, e.g. provisioning=debug,http=warn
+	for _, entry := range strings.Split(cfg.Levels, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subsystem, level, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log level %q, expected subsystem=level", entry)
+		}
+		if err := l.SetLevel(strings.TrimSpace(subsystem), strings.TrimSpace(level)); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// Logger returns the logger of a subsystem
+func (l *Logging) Logger(subsystem string) *slog.Logger {
+	return slog.New(&handler{Handler: l.handler, level: l.levelVar(subsystem)}).With("subsystem", subsystem)
+}
+
+// SetDefault makes the default subsystem the output of slog, and of the standard log package used by libraries
+func (l *Logging) SetDefault() {
+	slog.SetDefault(l.Logger(SubsystemDefault))
+}
+
+func (l *Logging) levelVar(subsystem string) *slog.LevelVar {
+	l.mu.RLock()
+	level, ok := l.levels[subsystem]
+	l.mu.RUnlock()
+	if ok {
+		return level
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level, ok := l.levels[subsystem]; ok {
+		return level
+	}
+	level = new(slog.LevelVar)
+	level.Set(l.defaultLevel)
+	l.levels[subsystem] = level
+	return level
+}
+
+// Levels returns the level of each subsystem
+func (l *Logging) Levels() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	levels := make(map[string]string, len(l.levels))
+	for subsystem, level := range l.levels {
+		levels[subsystem] = strings.ToLower(level.Level().String())
+	}
+	return levels
+}
+
+// SetLevel changes the level of a subsystem. An empty subsystem changes the level of all of them.
+func (l *Logging) SetLevel(subsystem, level string) error {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	if subsystem == "" {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.defaultLevel = parsed
+		for _, levelVar := range l.levels {
+			levelVar.Set(parsed)
+		}
+		return nil
+	}
+
+	l.levelVar(subsystem).Set(parsed)
+	return nil
+}
+
+// ParseLevel parses debug, info, warn or error, an empty level is info
+func ParseLevel(level string) (slog.Level, error) {
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", level)
+	}
+	return parsed, nil
+}
+
+type attrsKey struct{}
+
+// WithAttrs attaches attributes to ctx, they are added to the log lines written with it
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// handler filters the records with the level of its subsystem and adds the attributes of the context
+type handler struct {
+	slog.Handler
+	level *slog.LevelVar
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
@@ -0,0 +1,147 @@
+// Package metrics holds the Prometheus metrics of the backend, served on /metrics
+package metrics
+
+import (
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "stolos"
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests per route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Runs of the scheduled jobs per result.",
+	}, []string{"job", "result"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Duration of the scheduled jobs.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 900},
+	}, []string{"job"})
+
+	ProvisioningStageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provisioning_stage_duration_seconds",
+		Help:      "Duration of the node provisioning workflow stages.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800},
+	}, []string{"stage", "result"})
+
+	ProvisioningRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provisioning_requests_total",
+		Help:      "Node provisioning requests per result.",
+	}, []string{"result"})
+
+	GitOpsCommitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gitops_commit_duration_seconds",
+		Help:      "Duration of the commits to the GitOps repository.",
+		Buckets:   []float64{0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation", "result"})
+
+	TalosEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "talos_events_total",
+		Help:      "Events received by the Talos event sink.",
+	}, []string{"type", "result"})
+
+	Nodes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "nodes",
+		Help:      "Nodes per status and provider.",
+	}, []string{"status", "provider"})
+)
+
+// webSocketClients counts the connected clients per session type when scraped
+var webSocketClients atomic.Pointer[func() map[string]int]
+
+var webSocketClientsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "websocket_clients"),
+	"Connected websocket clients per session type.",
+	[]string{"session_type"}, nil,
+)
+
+type webSocketCollector struct{}
+
+func (webSocketCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- webSocketClientsDesc
+}
+
+func (webSocketCollector) Collect(ch chan<- prometheus.Metric) {
+	count := webSocketClients.Load()
+	if count == nil {
+		return
+	}
+	for sessionType, clients := range (*count)() {
+		ch <- prometheus.MustNewConstMetric(webSocketClientsDesc, prometheus.GaugeValue, float64(clients), sessionType)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(webSocketCollector{})
+}
+
+// SetWebSocketClientCounter sets the function counting the websocket clients
+func SetWebSocketClientCounter(count func() map[string]int) {
+	webSocketClients.Store(&count)
+}
+
+// Handler serves the metrics
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records the duration of the requests per route template, so path parameters don't create new series
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Result is the result label of an error
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// ObserveStage records the duration of a provisioning stage started at start
+func ObserveStage(stage string, start time.Time, err error) {
+	ProvisioningStageDuration.WithLabelValues(stage, Result(err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveGitOpsCommit records a commit started at start, it is deferred with the named error of the caller
+func ObserveGitOpsCommit(operation string, start time.Time, err *error) {
+	GitOpsCommitDuration.WithLabelValues(operation, Result(*err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveTalosEvent counts an event of the event sink by its payload type
+func ObserveTalosEvent(typeURL string, err error) {
+	TalosEvents.WithLabelValues(path.Base(typeURL), Result(err)).Inc()
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -35,6 +38,8 @@ type Node struct {
 	IPAddress    string         `json:"ip_address"`
 	MACAddress   string         `json:"mac_address"`
 	InstanceID   string         `json:"instance_id,omitempty"` // GCP instance ID
+	Zone         string         `json:"zone,omitempty"`        // GCP zone
+	Spot         bool           `json:"spot"`                  // GCP spot instance, recovered automatically when preempted
 	ClusterID    uuid.UUID      `json:"cluster_id" gorm:"type:uuid;index"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -85,6 +90,7 @@ type GCPConfig struct {
 	ServiceAccountEmail   string         `json:"service_account_email" gorm:"not null"`
 	ServiceAccountKeyJSON string         `json:"-" gorm:"type:text"`
 	Region                string         `json:"region" gorm:"default:'us-central1'"`
+	AdditionalRegions     datatypes.JSON `json:"additional_regions" gorm:"type:jsonb"` // Extra regions with their own subnet, in creation order
 	IsConfigured          bool           `json:"is_configured" gorm:"default:false"`
 	InfrastructureStatus  string         `json:"infrastructure_status" gorm:"default:'unconfigured'"` // unconfigured, pending, initializing, ready, failed
 	TalosVersion          string         `json:"talos_version" gorm:"default:'v1.11.1'"`
@@ -102,6 +108,30 @@ func (g *GCPConfig) TerraformEnvVars() map[string]string {
 	}
 }
 
+// Regions returns the primary region followed by the additional regions
+func (g *GCPConfig) Regions() []string {
+	regions := []string{g.Region}
+	var additional []string
+	if len(g.AdditionalRegions) > 0 {
+		_ = json.Unmarshal(g.AdditionalRegions, &additional)
+	}
+	for _, region := range additional {
+		if region != "" && !slices.Contains(regions, region) {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// SubnetName returns the name of the cluster subnet in a region.
+// The primary region keeps the original subnet name.
+func (g *GCPConfig) SubnetName(clusterName, region string) string {
+	if region == "" || region == g.Region {
+		return clusterName + "-subnet"
+	}
+	return fmt.Sprintf("%s-subnet-%s", clusterName, region)
+}
+
 func (g *GCPConfig) BeforeCreate(tx *gorm.DB) error {
 	if g.ID == (uuid.UUID{}) {
 		g.ID = uuid.New()
@@ -175,6 +205,31 @@ type ISOResponse struct {
 	Architecture string `json:"architecture"`
 }
 
+// ControlPlaneEndpointRequest moves the Kubernetes API endpoint to a shared VIP or an external endpoint
+type ControlPlaneEndpointRequest struct {
+	VIP        string `json:"vip,omitempty" example:"192.168.1.250"`             // shared IP announced by the control planes
+	Interface  string `json:"interface,omitempty" example:"eth0"`                // VIP interface, physical NICs when empty
+	Endpoint   string `json:"endpoint,omitempty" example:"k8s.example.com:6443"` // DNS name or load-balancer, exclusive with VIP
+	BaseDomain string `json:"base_domain,omitempty" example:"example.com"`       // added to the cert SANs
+}
+
+type ControlPlaneEndpointStatus struct {
+	Endpoint   string              `json:"endpoint" example:"https://192.168.1.250:6443"`
+	VIP        string              `json:"vip,omitempty" example:"192.168.1.250"`
+	CertSANs   []string            `json:"cert_sans"`
+	Conversion *EndpointConversion `json:"conversion,omitempty"`
+}
+
+// EndpointConversion is the progress of the last rolling endpoint change
+type EndpointConversion struct {
+	Endpoint   string     `json:"endpoint"`
+	Phase      string     `json:"phase"` // certificates, endpoint, templates, done, failed
+	Node       string     `json:"node,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
 type OnPremNodeProvisionRequest struct {
 	Nodes []OnPremNodeProvisionConfig `json:"nodes" binding:"required"`
 }
@@ -196,16 +251,38 @@ type NodeProvisionResult struct {
 
 // GCP Node Provision Request (with multiplier support)
 type GCPNodeProvisionRequest struct {
-	NamePrefix  string   `json:"name_prefix" binding:"required" example:"worker"`
-	Number      int      `json:"number" binding:"required,min=1,max=20" example:"5"`
-	Zone        string   `json:"zone" binding:"required" example:"us-central1-a"`
-	MachineType string   `json:"machine_type" binding:"required" example:"n1-standard-2"`
-	Role        string   `json:"role" binding:"required" example:"worker"`
-	Labels      []string `json:"labels" example:"zone=us-central1"`
-	DiskSizeGB  int      `json:"disk_size_gb" example:"100"`
-	DiskType    string   `json:"disk_type" example:"pd-standard"`
+	NamePrefix   string   `json:"name_prefix" binding:"required" example:"worker"`
+	Number       int      `json:"number" binding:"required,min=1,max=20" example:"5"`
+	Zone         string   `json:"zone" example:"us-central1-a"`                // Single zone, ignored when Zones or Regions are set
+	Zones        []string `json:"zones" example:"us-central1-a,us-central1-b"` // Zones to spread the nodes across
+	Regions      []string `json:"regions" example:"us-central1"`               // Spread across every cached zone of these regions
+	SpreadPolicy string   `json:"spread_policy" example:"balanced"`            // balanced (default) or round-robin
+	MachineType  string   `json:"machine_type" binding:"required" example:"n1-standard-2"`
+	Role         string   `json:"role" binding:"required" example:"worker"`
+	Labels       []string `json:"labels" example:"team=data"`
+	DiskSizeGB   int      `json:"disk_size_gb" example:"100"`
+	DiskType     string   `json:"disk_type" example:"pd-standard"`
+	Spot         bool     `json:"spot" example:"false"`                   // Spot VM, only workloads tolerating the spot taint are scheduled
+	SpotAction   string   `json:"spot_termination_action" example:"STOP"` // STOP (restarted) or DELETE (replaced) on preemption
 }
 
+// Spot termination actions, what GCP does with a preempted spot instance
+const (
+	SpotActionStop   = "STOP"
+	SpotActionDelete = "DELETE"
+)
+
+// ProvisioningModelSpot is the provisioning model of spot instances
+const ProvisioningModelSpot = "SPOT"
+
+// Zone spread policies
+const (
+	// SpreadPolicyBalanced places each node in the zone with the fewest nodes of the same role
+	SpreadPolicyBalanced = "balanced"
+	// SpreadPolicyRoundRobin places the nodes in the given zone order
+	SpreadPolicyRoundRobin = "round-robin"
+)
+
 // Provision Request Status
 type ProvisionRequestStatus string
 
@@ -219,18 +296,93 @@ const (
 	ProvisionStatusRejected         ProvisionRequestStatus = "rejected"
 )
 
+// Provision Checkpoint - last completed step of a provision request, used to resume after a restart
+type ProvisionCheckpoint string
+
+const (
+	ProvisionCheckpointNone            ProvisionCheckpoint = ""
+	ProvisionCheckpointConfigsUploaded ProvisionCheckpoint = "configs_uploaded"
+	ProvisionCheckpointPlanned         ProvisionCheckpoint = "planned"
+	ProvisionCheckpointApproved        ProvisionCheckpoint = "approved"
+	ProvisionCheckpointCommitted       ProvisionCheckpoint = "committed"
+	ProvisionCheckpointApplied         ProvisionCheckpoint = "applied"
+	ProvisionCheckpointRegistered      ProvisionCheckpoint = "registered"
+)
+
+var provisionCheckpointOrder = map[ProvisionCheckpoint]int{
+	ProvisionCheckpointNone:            0,
+	ProvisionCheckpointConfigsUploaded: 1,
+	ProvisionCheckpointPlanned:         2,
+	ProvisionCheckpointApproved:        3,
+	ProvisionCheckpointCommitted:       4,
+	ProvisionCheckpointApplied:         5,
+	ProvisionCheckpointRegistered:      6,
+}
+
+// Reached reports whether the checkpoint is at or past the given one
+func (c ProvisionCheckpoint) Reached(other ProvisionCheckpoint) bool {
+	return provisionCheckpointOrder[c] >= provisionCheckpointOrder[other]
+}
+
+// IsTerminal reports whether the status is final
+func (s ProvisionRequestStatus) IsTerminal() bool {
+	return s == ProvisionStatusCompleted || s == ProvisionStatusFailed || s == ProvisionStatusRejected
+}
+
+// Node Preemption - a spot node preemption and how it was recovered
+type NodePreemption struct {
+	ID             uuid.UUID      `json:"id" gorm:"type:uuid;primary_key"`
+	NodeID         uuid.UUID      `json:"node_id" gorm:"type:uuid;index;not null"`
+	NodeName       string         `json:"node_name" gorm:"not null"`
+	Zone           string         `json:"zone"`
+	InstanceStatus string         `json:"instance_status"` // GCP instance status when detected, MISSING if deleted
+	NodeNotReady   bool           `json:"node_not_ready"`  // Node was reported NotReady by Kubernetes
+	Action         string         `json:"action"`          // restart, replace
+	Status         string         `json:"status" gorm:"type:varchar(50);not null;default:'recovering'"`
+	Attempts       int            `json:"attempts" gorm:"default:0"`
+	Error          string         `json:"error,omitempty" gorm:"type:text"`
+	DetectedAt     time.Time      `json:"detected_at"`
+	LastAttemptAt  *time.Time     `json:"last_attempt_at,omitempty"`
+	RecoveredAt    *time.Time     `json:"recovered_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Node preemption statuses
+const (
+	PreemptionStatusRecovering = "recovering"
+	PreemptionStatusRecovered  = "recovered"
+	PreemptionStatusFailed     = "failed"
+)
+
+func (p *NodePreemption) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == (uuid.UUID{}) {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
 // Provision Request - tracks async node provisioning operations
 type ProvisionRequest struct {
-	ID         uuid.UUID              `json:"id" gorm:"type:uuid;primary_key"`
-	Provider   string                 `json:"provider" gorm:"not null"` // gcp, aws, azure
-	Status     ProvisionRequestStatus `json:"status" gorm:"type:varchar(50);not null;default:'pending'"`
-	Request    datatypes.JSON         `json:"request" gorm:"type:jsonb;not null"` // Original request payload
-	PlanOutput string                 `json:"plan_output" gorm:"type:text"`       // Terraform plan output
-	NodeIDs    datatypes.JSON         `json:"node_ids" gorm:"type:jsonb"`         // Array of created node IDs
-	Error      string                 `json:"error,omitempty" gorm:"type:text"`
-	CreatedAt  time.Time              `json:"created_at"`
-	UpdatedAt  time.Time              `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt         `json:"-" gorm:"index"`
+	ID           uuid.UUID              `json:"id" gorm:"type:uuid;primary_key"`
+	Provider     string                 `json:"provider" gorm:"not null"` // gcp, aws, azure
+	Status       ProvisionRequestStatus `json:"status" gorm:"type:varchar(50);not null;default:'pending'"`
+	Request      datatypes.JSON         `json:"request" gorm:"type:jsonb;not null"` // Original request payload
+	PlanOutput   string                 `json:"plan_output" gorm:"type:text"`       // Terraform plan output
+	NodeIDs      datatypes.JSON         `json:"node_ids" gorm:"type:jsonb"`         // Array of created node IDs
+	CostEstimate datatypes.JSON         `json:"cost_estimate" gorm:"type:jsonb"`    // Estimated cost delta of the plan
+	Checkpoint   ProvisionCheckpoint    `json:"checkpoint" gorm:"type:varchar(50)"` // Last completed step
+	PlanChanges  datatypes.JSON         `json:"plan_changes,omitempty"`             // Digest of each approved change, a resumed request planning other changes is approved again
+	Nodes        datatypes.JSON         `json:"nodes,omitempty" gorm:"type:jsonb"`  // Generated node configs, used to resume
+	LockHolder   string                 `json:"lock_holder,omitempty"`              // Terraform lock owner of the process running the request
+	LeaseOwner   string                 `json:"lease_owner,omitempty"`              // Backend process running the request
+	LeaseExpiry  *time.Time             `json:"lease_expiry,omitempty"`             // Another process may take the request over once the lease expired
+	Attempts     int                    `json:"attempts" gorm:"default:0"`          // Number of times the workflow was started or resumed
+	Error        string                 `json:"error,omitempty" gorm:"type:text"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt         `json:"-" gorm:"index"`
 }
 
 func (p *ProvisionRequest) BeforeCreate(tx *gorm.DB) error {
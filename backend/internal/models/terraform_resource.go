@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // TerraformResourceStatus represents the status of a resource operation
 type TerraformResourceStatus string
@@ -29,6 +33,7 @@ type TerraformResourceUpdate struct {
 	Error       string                  `json:"error,omitempty"`
 	Details     map[string]any          `json:"details,omitempty"`
 	Parent      string                  `json:"parent,omitempty"`
+	Cost        *ResourceCostEstimate   `json:"cost,omitempty"`
 }
 
 // TerraformPlanResource represents a resource that will be affected in the plan
@@ -46,4 +51,115 @@ type TerraformWorkflowUpdate struct {
 	Resources []TerraformResourceUpdate `json:"resources"`
 	Summary   map[string]int            `json:"summary"`
 	Outputs   map[string]any            `json:"outputs,omitempty"`
+	Cost      *CostEstimate             `json:"cost,omitempty"`
+}
+
+// ResourceCostEstimate represents the estimated cost delta of a single planned resource
+type ResourceCostEstimate struct {
+	ResourceID   string  `json:"resource_id"`
+	Type         string  `json:"type"`
+	Action       string  `json:"action"`
+	Description  string  `json:"description"`
+	HourlyDelta  float64 `json:"hourly_delta"`
+	MonthlyDelta float64 `json:"monthly_delta"`
+}
+
+// CostEstimate represents the estimated cost delta of a whole plan
+type CostEstimate struct {
+	Currency     string                 `json:"currency"`
+	HourlyDelta  float64                `json:"hourly_delta"`
+	MonthlyDelta float64                `json:"monthly_delta"`
+	Items        []ResourceCostEstimate `json:"items"`
+	Warnings     []string               `json:"warnings,omitempty"`
+	PricesAsOf   string                 `json:"prices_as_of,omitempty"`
+}
+
+// NodeRunningCost represents the current cost of a running GCP instance
+type NodeRunningCost struct {
+	InstanceName string     `json:"instance_name"`
+	NodeID       *uuid.UUID `json:"node_id,omitempty"`
+	Zone         string     `json:"zone"`
+	MachineType  string     `json:"machine_type"`
+	Status       string     `json:"status"`
+	HourlyCost   float64    `json:"hourly_cost"`
+	MonthlyCost  float64    `json:"monthly_cost"`
+}
+
+// RunningCostSummary represents the running cost of all Stolos-managed GCP instances
+type RunningCostSummary struct {
+	Currency    string            `json:"currency"`
+	HourlyCost  float64           `json:"hourly_cost"`
+	MonthlyCost float64           `json:"monthly_cost"`
+	Nodes       []NodeRunningCost `json:"nodes"`
+	Warnings    []string          `json:"warnings,omitempty"`
+	PricesAsOf  string            `json:"prices_as_of,omitempty"`
+}
+
+// TerraformStateResource represents a resource instance tracked in a Terraform state
+type TerraformStateResource struct {
+	Address    string         `json:"address"`
+	Module     string         `json:"module,omitempty"`
+	Mode       string         `json:"mode"`
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	Provider   string         `json:"provider"`
+	ID         string         `json:"id,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// TerraformStateSummary represents the content of a remote Terraform state
+type TerraformStateSummary struct {
+	State            string                   `json:"state"`
+	Bucket           string                   `json:"bucket"`
+	Object           string                   `json:"object"`
+	TerraformVersion string                   `json:"terraform_version"`
+	Serial           int64                    `json:"serial"`
+	Lineage          string                   `json:"lineage"`
+	Resources        []TerraformStateResource `json:"resources"`
+}
+
+// Instance management states when comparing Terraform state, database and GCP
+const (
+	InstanceManaged   = "managed"   // in state, database and GCP
+	InstanceUntracked = "untracked" // in state and GCP, no node record
+	InstanceUnmanaged = "unmanaged" // only in GCP, can be imported
+	InstanceMissing   = "missing"   // in state or database but not in GCP
+)
+
+// GCPInstanceComparison compares an instance across Terraform state, node records and live GCP
+type GCPInstanceComparison struct {
+	Name         string     `json:"name"`
+	Zone         string     `json:"zone,omitempty"`
+	Status       string     `json:"status"`
+	InState      bool       `json:"in_state"`
+	StateAddress string     `json:"state_address,omitempty"`
+	InDatabase   bool       `json:"in_database"`
+	NodeID       *uuid.UUID `json:"node_id,omitempty"`
+	Live         bool       `json:"live"`
+	LiveStatus   string     `json:"live_status,omitempty"`
+	MachineType  string     `json:"machine_type,omitempty"`
+	IsTalos      bool       `json:"is_talos"`
+	Importable   bool       `json:"importable"`
+}
+
+// TerraformImportRequest is a request to import an unmanaged GCP instance into the node module
+type TerraformImportRequest struct {
+	InstanceName string `json:"instance_name" binding:"required" example:"talos-worker-1"`
+	Zone         string `json:"zone" binding:"required" example:"us-central1-a"`
+	Role         string `json:"role" binding:"required" example:"worker"`
+	DryRun       bool   `json:"dry_run" example:"true"`
+}
+
+// TerraformImportResult describes the import of a GCP instance
+type TerraformImportResult struct {
+	InstanceName   string     `json:"instance_name"`
+	Address        string     `json:"address"`
+	ImportID       string     `json:"import_id"`
+	NodeFile       string     `json:"node_file"`
+	NodeFileBody   string     `json:"node_file_body"`
+	DryRun         bool       `json:"dry_run"`
+	PlanSummary    string     `json:"plan_summary,omitempty"`
+	PendingChanges []string   `json:"pending_changes,omitempty"`
+	NodeID         *uuid.UUID `json:"node_id,omitempty"`
+	Warnings       []string   `json:"warnings,omitempty"`
 }
@@ -5,20 +5,25 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stolos-cloud/stolos/backend/internal/handlers"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/middleware"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 )
 
 func SetupRoutes(r *gin.Engine, h *handlers.Handlers) {
-	r.GET("/health", func(c *gin.Context) {
+	health := func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	}
+	r.GET("/health", health)
 	r.HEAD("/health", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
+	r.GET("/metrics", metrics.Handler())
 
 	api := r.Group("/api/v1")
 	{
+		// the ingress only routes /api/v1 to the backend
+		api.GET("/health", health)
 		setupAuthRoutes(api, h)
 
 		// temporary: don't require authentication for nodes routes
@@ -35,6 +40,7 @@ func SetupRoutes(r *gin.Engine, h *handlers.Handlers) {
 			setupEventRoutes(protected, h)
 			setupTemplateRoutes(protected, h)
 			setupScaffoldRoutes(protected, h)
+			setupAdminRoutes(protected, h)
 		}
 	}
 }
@@ -43,6 +49,8 @@ func setupClusterRoutes(api *gin.RouterGroup, h *handlers.Handlers) {
 	cluster := api.Group("/cluster")
 	{
 		cluster.GET("/info", h.GetClusterInfo)
+		cluster.GET("/endpoint", h.NodeHandlers().GetControlPlaneEndpoint)
+		cluster.PUT("/endpoint", middleware.RequireRole(models.RoleAdmin), h.NodeHandlers().UpdateControlPlaneEndpoint)
 	}
 }
 
@@ -142,16 +150,27 @@ func setupGCPRoutes(public *gin.RouterGroup, protected *gin.RouterGroup, h *hand
 		// Uploads with form-data (file)
 		gcp.POST("/bucket", h.GCPHandlers().CreateTerraformBucket)
 
+		gcp.PUT("/regions", h.GCPHandlers().SetGCPRegions)
+
 		gcp.POST("/init-infra", h.GCPHandlers().InitInfra)
 		gcp.POST("/delete-infra", h.GCPHandlers().DeleteInfra)
 
 		gcp.POST("/terraform/force-unlock", h.GCPHandlers().ForceUnlockTerraformState)
+		gcp.GET("/terraform/state", h.GCPHandlers().GetTerraformState)
+		gcp.GET("/terraform/state/resource", h.GCPHandlers().GetTerraformStateResource)
+		gcp.GET("/terraform/compare", h.GCPHandlers().CompareGCPInstances)
+		gcp.POST("/terraform/import", h.GCPHandlers().ImportGCPInstance)
 
 		gcp.POST("/instances", h.GCPHandlers().QueryGCPInstances)
 
 		gcp.POST("/resources/refresh", h.GCPHandlers().RefreshGCPResources)
 
+		gcp.GET("/pricing", h.GCPHandlers().GetGCPPrices)
+		gcp.POST("/pricing/refresh", h.GCPHandlers().RefreshGCPPrices)
+		gcp.GET("/costs", h.GCPHandlers().GetGCPRunningCosts)
+
 		gcp.POST("/nodes/provision", h.GCPHandlers().ProvisionGCPNodes)
+		gcp.GET("/nodes/:node_id/preemptions", h.GCPHandlers().GetNodePreemptions)
 	}
 }
 
@@ -159,6 +178,7 @@ func setupTemplateRoutes(api *gin.RouterGroup, h *handlers.Handlers) {
 	templateRoutes := api.Group("/templates")
 	{
 		templateRoutes.GET("", h.TemplatesHandlers().GetTemplatesList)
+		templateRoutes.GET("/loadbalancer-pools", h.TemplatesHandlers().GetLoadBalancerPools)
 		templateRoutes.GET("/:name", h.TemplatesHandlers().GetTemplate)
 		templateRoutes.POST("/:id/validate/:instance_name", h.TemplatesHandlers().ValidateTemplate)
 		templateRoutes.POST("/:id/apply/:instance_name", h.TemplatesHandlers().ApplyTemplate)
@@ -179,3 +199,12 @@ func setupScaffoldRoutes(api *gin.RouterGroup, h *handlers.Handlers) {
 		deploymentRoutes.POST("/delete", h.TemplatesHandlers().DeleteDeployment)
 	}
 }
+
+func setupAdminRoutes(api *gin.RouterGroup, h *handlers.Handlers) {
+	admin := api.Group("/admin")
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/log-levels", h.LoggingHandlers().GetLogLevels)
+		admin.PUT("/log-levels", h.LoggingHandlers().SetLogLevel)
+	}
+}
@@ -3,10 +3,11 @@ package cluster
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/google/uuid"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	"github.com/stolos-cloud/stolos/backend/internal/services/talos"
 	"gorm.io/gorm"
@@ -14,29 +15,31 @@ import (
 
 // DiscoveryService handles cluster discovery and initialization
 type DiscoveryService struct {
-	db  *gorm.DB
-	cfg *config.Config
-	ts  *talos.TalosService
+	db     *gorm.DB
+	cfg    *config.Config
+	ts     *talos.TalosService
+	logger *slog.Logger
 }
 
 // NewDiscoveryService creates a new cluster discovery service
-func NewDiscoveryService(db *gorm.DB, cfg *config.Config, ts *talos.TalosService) *DiscoveryService {
+func NewDiscoveryService(db *gorm.DB, cfg *config.Config, ts *talos.TalosService, logs *logging.Logging) *DiscoveryService {
 	return &DiscoveryService{
-		db:  db,
-		cfg: cfg,
-		ts:  ts,
+		db:     db,
+		cfg:    cfg,
+		ts:     ts,
+		logger: logs.Logger(logging.SubsystemCluster),
 	}
 }
 
 // InitializeCluster ensures a cluster exists in the database
 func (s *DiscoveryService) InitializeCluster(ctx context.Context) error {
-	log.Println("Starting cluster discovery...")
+	s.logger.InfoContext(ctx, "starting cluster discovery")
 
 	var existingCluster models.Cluster
 	err := s.db.First(&existingCluster).Error
 	if err == nil {
 		// Cluster already exists
-		log.Printf("Found existing cluster: %s (ID: %s)", existingCluster.Name, existingCluster.ID)
+		s.logger.InfoContext(ctx, "found existing cluster", "cluster", existingCluster.Name, "cluster_id", existingCluster.ID)
 		return nil
 	}
 
@@ -44,7 +47,7 @@ func (s *DiscoveryService) InitializeCluster(ctx context.Context) error {
 		return fmt.Errorf("failed to query clusters: %w", err)
 	}
 
-	log.Println("No cluster found in database, creating cluster record...")
+	s.logger.InfoContext(ctx, "no cluster found in database, creating cluster record")
 
 	// Get cluster name from config or use default
 	clusterName := s.cfg.ClusterName
@@ -61,11 +64,11 @@ func (s *DiscoveryService) InitializeCluster(ctx context.Context) error {
 		return fmt.Errorf("failed to create cluster: %w", err)
 	}
 
-	log.Printf("Created cluster: %s (ID: %s)", cluster.Name, cluster.ID)
+	s.logger.InfoContext(ctx, "created cluster", "cluster", cluster.Name, "cluster_id", cluster.ID)
 
 	// Discover existing nodes
 	if err := s.discoverNodes(ctx, cluster.ID); err != nil {
-		log.Printf("Warning: failed to discover nodes: %v", err)
+		s.logger.WarnContext(ctx, "failed to discover nodes", "error", err)
 		// Don't fail initialization if node discovery fails
 	}
 
@@ -74,7 +77,7 @@ func (s *DiscoveryService) InitializeCluster(ctx context.Context) error {
 
 // discoverNodes discovers existing nodes in the Talos cluster
 func (s *DiscoveryService) discoverNodes(ctx context.Context, clusterID uuid.UUID) error {
-	log.Println("Discovering existing cluster nodes from TALOS_FOLDER ...")
+	s.logger.InfoContext(ctx, "discovering existing cluster nodes", "folder", s.cfg.TalosFolder)
 
 	nodes, err := s.ts.GetBootstrapCachedNodes(clusterID)
 
@@ -83,7 +86,7 @@ func (s *DiscoveryService) discoverNodes(ctx context.Context, clusterID uuid.UUI
 	}
 
 	if err := s.db.Save(&nodes).Error; err != nil {
-		log.Printf("Failed to save nodes: %v", err)
+		s.logger.ErrorContext(ctx, "failed to save nodes", "error", err)
 	}
 
 	return nil
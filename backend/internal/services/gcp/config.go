@@ -2,13 +2,16 @@ package gcp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
 
 	"github.com/google/uuid"
 	gcpconfig "github.com/stolos-cloud/stolos-bootstrap/pkg/gcp"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	"github.com/stolos-cloud/stolos/backend/pkg/gcp"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -169,6 +172,53 @@ func (s *GCPService) UpdateServiceAccount(ctx context.Context, projectID, region
 	return &dbConfig, nil
 }
 
+// SetAdditionalRegions sets the regions, besides the primary one, where nodes can be provisioned.
+// Each region gets its own subnet on the next infrastructure initialization. Regions are only
+// appended, the subnet ranges are allocated in order.
+func (s *GCPService) SetAdditionalRegions(ctx context.Context, regions []string) (*models.GCPConfig, error) {
+	dbConfig, err := s.GetCurrentConfigWithCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("GCP not configured: %w", err)
+	}
+
+	client, err := s.GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP client: %w", err)
+	}
+
+	current := dbConfig.Regions()[1:]
+	for _, region := range current {
+		if !slices.Contains(regions, region) {
+			return nil, fmt.Errorf("region %s has a subnet and cannot be removed", region)
+		}
+	}
+
+	additional := current
+	for _, region := range regions {
+		if region == dbConfig.Region || slices.Contains(additional, region) {
+			continue
+		}
+		if _, err := client.GetRegion(ctx, region); err != nil {
+			return nil, err
+		}
+		additional = append(additional, region)
+	}
+
+	if len(additional) > MaxAdditionalRegions {
+		return nil, fmt.Errorf("at most %d additional regions are supported", MaxAdditionalRegions)
+	}
+
+	regionsJSON, err := json.Marshal(additional)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal regions: %w", err)
+	}
+	if err := s.db.Model(dbConfig).Update("additional_regions", datatypes.JSON(regionsJSON)).Error; err != nil {
+		return nil, fmt.Errorf("failed to save additional regions: %w", err)
+	}
+
+	return s.GetCurrentConfig()
+}
+
 // GetClient creates a GCP client from database config or falls back to env config
 // Uses the currently configured project ID and region
 func (s *GCPService) GetClient() (*gcp.Client, error) {
@@ -198,7 +248,12 @@ func (s *GCPService) getGCPClient(projectID, region string) (*gcp.Client, error)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create GCP config from database: %w", err)
 		}
-		return gcp.NewClientFromConfig(gcpCfg)
+		client, err := gcp.NewClientFromConfig(gcpCfg)
+		if err != nil {
+			return nil, err
+		}
+		client.SetAdditionalRegions(config.Regions()[1:])
+		return client, nil
 	}
 
 	if s.IsConfiguredFromEnv() {
@@ -249,6 +304,17 @@ func (s *GCPService) GetRegion() string {
 	return s.cfg.GCP.Region
 }
 
+// GetRegions returns the primary region followed by the additional regions
+func (s *GCPService) GetRegions() []string {
+	if s.IsConfiguredFromDatabase() {
+		config, err := s.GetCurrentConfig()
+		if err == nil {
+			return config.Regions()
+		}
+	}
+	return []string{s.cfg.GCP.Region}
+}
+
 func (s *GCPService) IsConfigured() bool {
 	return s.IsConfiguredFromDatabase() || s.IsConfiguredFromEnv()
 }
@@ -0,0 +1,280 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/stolos-cloud/stolos/backend/internal/helpers"
+	"github.com/stolos-cloud/stolos/backend/internal/models"
+	terraformservices "github.com/stolos-cloud/stolos/backend/internal/services/terraform"
+	wsservices "github.com/stolos-cloud/stolos/backend/internal/services/websocket"
+	compute "google.golang.org/api/compute/v1"
+	"gorm.io/gorm"
+)
+
+// ImportInstance brings an unmanaged Talos instance under the node module.
+// With DryRun it only returns the generated node file and the import that would run.
+// The import is rolled back if the resulting plan would replace or delete the instance.
+func (s *GCPStateService) ImportInstance(ctx context.Context, req models.TerraformImportRequest) (*models.TerraformImportResult, error) {
+	if req.Role != "worker" && req.Role != "control-plane" {
+		return nil, fmt.Errorf("role must be 'worker' or 'control-plane'")
+	}
+
+	gcpConfig, err := s.gcpService.GetCurrentConfigWithCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP config: %w", err)
+	}
+
+	client, err := s.gcpService.GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP client: %w", err)
+	}
+
+	instance, err := client.GetInstance(ctx, req.Zone, req.InstanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsTalosInstance(ctx, client, req.Zone, instance) {
+		return nil, fmt.Errorf("instance %s does not boot from a Talos image", req.InstanceName)
+	}
+
+	// Refuse instances already tracked in the nodes state
+	summary, err := s.GetState(ctx, "nodes")
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range summary.Resources {
+		if resource.Type == "google_compute_instance" && resource.Attributes["name"] == req.InstanceName {
+			return nil, fmt.Errorf("instance %s is already managed by terraform at %s", req.InstanceName, resource.Address)
+		}
+	}
+
+	talosConfig := instanceUserData(instance)
+	if talosConfig == "" {
+		return nil, fmt.Errorf("instance %s has no Talos machine config in its user-data metadata", req.InstanceName)
+	}
+
+	nodeConfig, warnings, err := s.nodeConfigFromInstance(ctx, req, instance)
+	if err != nil {
+		return nil, err
+	}
+	nodeConfig.TalosConfig = talosConfig
+
+	sanitizedName := helpers.SanitizeResourceName(nodeConfig.Name)
+	result := &models.TerraformImportResult{
+		InstanceName: req.InstanceName,
+		Address:      fmt.Sprintf("module.%s.google_compute_instance.node", sanitizedName),
+		ImportID:     fmt.Sprintf("projects/%s/zones/%s/instances/%s", gcpConfig.ProjectID, req.Zone, req.InstanceName),
+		NodeFile:     fmt.Sprintf("node-%s.tf", sanitizedName),
+		DryRun:       req.DryRun,
+		Warnings:     warnings,
+	}
+
+	gitopsConfig, err := s.provisioningService.gitopsService.GetConfigOrDefault()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitOps config: %w", err)
+	}
+
+	ghClient, err := s.provisioningService.gitopsService.GetGitHubClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	// Render the node file in a work directory alongside the existing node files
+	workID := uuid.New()
+	nodes := []NodeConfig{*nodeConfig}
+	if err := s.provisioningService.createTerraformFiles(ctx, workID, gcpConfig, nodes, ghClient, gitopsConfig); err != nil {
+		return nil, fmt.Errorf("failed to create terraform files: %w", err)
+	}
+
+	provSession, ok := s.provisioningService.getProvisionSession(workID)
+	if !ok {
+		return nil, fmt.Errorf("provision session not found")
+	}
+	defer func() {
+		os.RemoveAll(provSession.WorkDir)
+		s.provisioningService.deleteProvisionSession(workID)
+	}()
+
+	nodeFileBody, err := os.ReadFile(filepath.Join(provSession.Orchestrator.WorkDir(), result.NodeFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated node file: %w", err)
+	}
+	result.NodeFileBody = string(nodeFileBody)
+
+	if req.DryRun {
+		return result, nil
+	}
+
+	// The node file reads the machine config from the bucket
+	if err := s.provisioningService.uploadTalosConfigsToGCS(ctx, gcpConfig, nodes); err != nil {
+		return nil, fmt.Errorf("failed to upload Talos config: %w", err)
+	}
+
+	if err := provSession.Orchestrator.Init(ctx); err != nil {
+		return nil, fmt.Errorf("terraform init failed: %w", err)
+	}
+
+	if err := provSession.Orchestrator.Import(ctx, result.Address, result.ImportID); err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "imported instance into terraform state", "import_id", result.ImportID, "address", result.Address)
+
+	hasChanges, _, err := provSession.Orchestrator.PlanWithOutput(ctx)
+	if err != nil {
+		s.rollbackImport(ctx, provSession, result.Address)
+		return nil, fmt.Errorf("terraform plan after import failed: %w", err)
+	}
+
+	if hasChanges {
+		planJSON, err := provSession.Orchestrator.GetPlanJSON(ctx)
+		if err != nil {
+			s.rollbackImport(ctx, provSession, result.Address)
+			return nil, fmt.Errorf("failed to read plan after import: %w", err)
+		}
+		plannedResources, err := terraformservices.ParsePlanJSON(planJSON)
+		if err != nil {
+			s.rollbackImport(ctx, provSession, result.Address)
+			return nil, fmt.Errorf("failed to parse plan after import: %w", err)
+		}
+
+		modulePrefix := fmt.Sprintf("module.%s.", sanitizedName)
+		for _, resource := range plannedResources {
+			if !strings.HasPrefix(resource.ID, modulePrefix) {
+				continue
+			}
+			if resource.Action == "replace" || resource.Action == "delete" {
+				s.rollbackImport(ctx, provSession, result.Address)
+				return nil, fmt.Errorf("importing %s would %s %s, the instance does not match the node module (image, network or disk differ)",
+					req.InstanceName, resource.Action, resource.ID)
+			}
+			result.PendingChanges = append(result.PendingChanges, fmt.Sprintf("%s: %s", resource.Action, resource.ID))
+		}
+	}
+
+	if len(result.PendingChanges) > 0 {
+		result.PlanSummary = fmt.Sprintf("Imported with %d pending in-place change(s), applied with the next provisioning", len(result.PendingChanges))
+	} else {
+		result.PlanSummary = "Imported, no changes pending"
+	}
+
+	if err := s.provisioningService.commitTerraformFiles(ctx, workID, ghClient, gitopsConfig); err != nil {
+		s.rollbackImport(ctx, provSession, result.Address)
+		return nil, fmt.Errorf("import rolled back, failed to commit terraform files: %w", err)
+	}
+
+	var cluster models.Cluster
+	if err := s.db.First(&cluster).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch cluster: %w", err)
+	}
+
+	instanceDetails := []InstanceDetails{{
+		InstanceName: instance.Name,
+		InstanceID:   fmt.Sprintf("%d", instance.Id),
+	}}
+	if len(instance.NetworkInterfaces) > 0 {
+		instanceDetails[0].InternalIP = instance.NetworkInterfaces[0].NetworkIP
+	}
+
+	session := wsservices.NewApprovalSession(workID.String(), nil)
+	nodeIDs := s.provisioningService.registerNodes(cluster.ID, nodes, instanceDetails, session)
+	if len(nodeIDs) > 0 {
+		result.NodeID = &nodeIDs[0]
+	}
+
+	return result, nil
+}
+
+// nodeConfigFromInstance derives the node module settings from a live instance
+func (s *GCPStateService) nodeConfigFromInstance(ctx context.Context, req models.TerraformImportRequest, instance *compute.Instance) (*NodeConfig, []string, error) {
+	client, err := s.gcpService.GetClient()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get GCP client: %w", err)
+	}
+
+	var warnings []string
+	diskSizeGB := 100
+	diskType := "pd-standard"
+	for _, attached := range instance.Disks {
+		if !attached.Boot {
+			continue
+		}
+		disk, err := client.GetDisk(ctx, req.Zone, path.Base(attached.Source))
+		if err != nil {
+			return nil, nil, err
+		}
+		diskSizeGB = int(disk.SizeGb)
+		diskType = path.Base(disk.Type)
+	}
+
+	if len(instance.Disks) > 1 {
+		warnings = append(warnings, "additional disks are not managed by the node module and stay unmanaged")
+	}
+	if instance.Labels["managed-by"] != "stolos" {
+		warnings = append(warnings, "instance labels will be updated to the Stolos labels on the next apply")
+	}
+
+	var existing models.Node
+	if err := s.db.Where("name = ?", instance.Name).First(&existing).Error; err == nil {
+		warnings = append(warnings, fmt.Sprintf("a node record named %s already exists and will be reused", instance.Name))
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, nil, fmt.Errorf("failed to check existing node: %w", err)
+	}
+
+	labels := []string{
+		"provider=gcp",
+		fmt.Sprintf("role=%s", req.Role),
+		fmt.Sprintf("disk_type=%s", diskType),
+		fmt.Sprintf("%s=%s", TopologyZoneLabel, req.Zone),
+		fmt.Sprintf("%s=%s", TopologyRegionLabel, ZoneRegion(req.Zone)),
+	}
+
+	spot, spotAction := false, ""
+	if instance.Scheduling != nil && instance.Scheduling.ProvisioningModel == models.ProvisioningModelSpot {
+		spot, spotAction = true, instance.Scheduling.InstanceTerminationAction
+		if spotAction == "" {
+			spotAction = models.SpotActionStop
+		}
+		labels = append(labels, fmt.Sprintf("%s=true", SpotNodeLabel))
+		warnings = append(warnings, fmt.Sprintf("spot instance, the %s taint is only present if its machine config sets it", SpotNodeLabel))
+	}
+
+	return &NodeConfig{
+		Name:        instance.Name,
+		Zone:        req.Zone,
+		Region:      ZoneRegion(req.Zone),
+		MachineType: path.Base(instance.MachineType),
+		Role:        req.Role,
+		Labels:      labels,
+		DiskSizeGB:  diskSizeGB,
+		DiskType:    diskType,
+		Spot:        spot,
+		SpotAction:  spotAction,
+	}, warnings, nil
+}
+
+// rollbackImport removes an imported instance from the state without touching it
+func (s *GCPStateService) rollbackImport(ctx context.Context, provSession *ProvisionSession, address string) {
+	if err := provSession.Orchestrator.StateRm(ctx, address); err != nil {
+		s.logger.WarnContext(ctx, "failed to roll back import", "address", address, "error", err)
+	}
+}
+
+// instanceUserData returns the user-data metadata of an instance
+func instanceUserData(instance *compute.Instance) string {
+	if instance.Metadata == nil {
+		return ""
+	}
+	for _, item := range instance.Metadata.Items {
+		if item.Key == "user-data" && item.Value != nil {
+			return *item.Value
+		}
+	}
+	return ""
+}
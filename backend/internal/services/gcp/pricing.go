@@ -0,0 +1,550 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"github.com/stolos-cloud/stolos/backend/internal/models"
+	"google.golang.org/api/cloudbilling/v1"
+	"gorm.io/gorm"
+)
+
+// HoursPerMonth is the number of hours GCP uses to convert hourly prices to monthly ones
+const HoursPerMonth = 730.0
+
+const (
+	defaultBootDiskSizeGB = 10
+	defaultDiskType       = "pd-standard"
+)
+
+type GCPPricingService struct {
+	db         *gorm.DB
+	cfg        *config.Config
+	gcpService *GCPService
+	mu         sync.RWMutex
+}
+
+func NewGCPPricingService(db *gorm.DB, cfg *config.Config, gcpService *GCPService) *GCPPricingService {
+	return &GCPPricingService{
+		db:         db,
+		cfg:        cfg,
+		gcpService: gcpService,
+	}
+}
+
+// LoadCatalog loads the offline price catalog file into the config
+func (s *GCPPricingService) LoadCatalog() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadCatalogLocked()
+}
+
+func (s *GCPPricingService) loadCatalogLocked() error {
+	data, err := os.ReadFile(s.cfg.Pricing.GCPCatalogFile)
+	if err != nil {
+		return fmt.Errorf("failed to read price catalog %s: %w", s.cfg.Pricing.GCPCatalogFile, err)
+	}
+
+	var catalog config.GCPPriceCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("failed to parse price catalog: %w", err)
+	}
+
+	s.cfg.GCPPrices = catalog
+	return nil
+}
+
+// ensureCatalog lazily loads the catalog on first use. Caller must hold the lock.
+func (s *GCPPricingService) ensureCatalogLocked() error {
+	if s.cfg.GCPPrices.Regions != nil {
+		return nil
+	}
+	return s.loadCatalogLocked()
+}
+
+func (s *GCPPricingService) GetCatalog() (*config.GCPPriceCatalog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureCatalogLocked(); err != nil {
+		return nil, err
+	}
+	catalog := s.cfg.GCPPrices
+	return &catalog, nil
+}
+
+// RefreshFromBillingAPI fetches current list prices for the configured regions from the
+// Cloud Billing Catalog API, merges them into the catalog and persists it to the catalog file.
+func (s *GCPPricingService) RefreshFromBillingAPI(ctx context.Context) (*config.GCPPriceCatalog, error) {
+	client, err := s.gcpService.GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP client: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A missing offline catalog is fine here, we are about to build one
+	if err := s.ensureCatalogLocked(); err != nil {
+		s.cfg.GCPPrices = config.GCPPriceCatalog{Currency: "USD", Regions: make(map[string]config.GCPRegionPrices)}
+	}
+
+	currency := s.cfg.GCPPrices.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+
+	skus, err := client.ListComputeSKUs(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := config.GCPPriceCatalog{
+		LastUpdated: time.Now().UTC().Format(time.RFC3339),
+		Source:      "billing-api",
+		Currency:    currency,
+		Regions:     make(map[string]config.GCPRegionPrices),
+	}
+	for name, regionPrices := range s.cfg.GCPPrices.Regions {
+		catalog.Regions[name] = regionPrices
+	}
+	for _, region := range s.gcpService.GetRegions() {
+		prices := parseComputeSKUs(skus, region)
+
+		// Shared-core machine types are not exposed as their own SKUs, keep the previous values
+		if previous, ok := s.cfg.GCPPrices.Regions[region]; ok {
+			prices.MachineTypes = previous.MachineTypes
+		}
+		catalog.Regions[region] = prices
+	}
+
+	if err := s.saveCatalogLocked(&catalog); err != nil {
+		return nil, err
+	}
+
+	s.cfg.GCPPrices = catalog
+	return &catalog, nil
+}
+
+func (s *GCPPricingService) saveCatalogLocked(catalog *config.GCPPriceCatalog) error {
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal price catalog: %w", err)
+	}
+
+	if dir := filepath.Dir(s.cfg.Pricing.GCPCatalogFile); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create price catalog directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(s.cfg.Pricing.GCPCatalogFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write price catalog: %w", err)
+	}
+	return nil
+}
+
+// parseComputeSKUs extracts on-demand prices, and the spot prices of machine families, for a region from Compute Engine SKUs
+func parseComputeSKUs(skus []*cloudbilling.Sku, region string) config.GCPRegionPrices {
+	prices := config.GCPRegionPrices{
+		MachineFamilies: make(map[string]config.GCPMachineFamilyPrice),
+		MachineTypes:    make(map[string]float64),
+		Disks:           make(map[string]float64),
+	}
+
+	for _, sku := range skus {
+		if sku.Category == nil || !slices.Contains(sku.ServiceRegions, region) {
+			continue
+		}
+
+		// Spot VMs are billed with the Preemptible usage type: "Spot Preemptible N2 Instance Core running in ..."
+		var spot bool
+		switch sku.Category.UsageType {
+		case "OnDemand":
+		case "Preemptible":
+			spot = true
+		default:
+			continue
+		}
+
+		desc := strings.TrimPrefix(strings.TrimPrefix(sku.Description, "Spot "), "Preemptible ")
+		if strings.Contains(desc, "Commitment") || strings.Contains(desc, "Custom") ||
+			strings.Contains(desc, "Sole Tenancy") || strings.Contains(desc, "Extended") {
+			continue
+		}
+		// Only the cores and memory of spot VMs are discounted
+		if spot && !strings.Contains(desc, "Instance Core") && !strings.Contains(desc, "Instance Ram") {
+			continue
+		}
+
+		price, ok := skuUnitPrice(sku)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.Contains(desc, "Instance Core"):
+			family := strings.ToLower(strings.Fields(desc)[0])
+			fp := prices.MachineFamilies[family]
+			if spot {
+				fp.SpotCPUHourly = price
+			} else {
+				fp.CPUHourly = price
+			}
+			prices.MachineFamilies[family] = fp
+		case strings.Contains(desc, "Instance Ram"):
+			family := strings.ToLower(strings.Fields(desc)[0])
+			fp := prices.MachineFamilies[family]
+			if spot {
+				fp.SpotMemoryGBHourly = price
+			} else {
+				fp.MemoryGBHourly = price
+			}
+			prices.MachineFamilies[family] = fp
+		case strings.HasPrefix(desc, "Storage PD Capacity"):
+			prices.Disks["pd-standard"] = price
+		case strings.HasPrefix(desc, "SSD backed PD Capacity"):
+			prices.Disks["pd-ssd"] = price
+		case strings.HasPrefix(desc, "Balanced PD Capacity"):
+			prices.Disks["pd-balanced"] = price
+		case strings.HasPrefix(desc, "Extreme PD Capacity"):
+			prices.Disks["pd-extreme"] = price
+		case strings.HasPrefix(desc, "External IP Charge on a Standard VM"):
+			prices.ExternalIPHourly = price
+		case strings.HasPrefix(desc, "Static Ip Charge"):
+			prices.StaticIPHourly = price
+		}
+	}
+
+	return prices
+}
+
+// skuUnitPrice returns the price of the highest tier of the SKU's current pricing
+func skuUnitPrice(sku *cloudbilling.Sku) (float64, bool) {
+	if len(sku.PricingInfo) == 0 || sku.PricingInfo[0].PricingExpression == nil {
+		return 0, false
+	}
+	rates := sku.PricingInfo[0].PricingExpression.TieredRates
+	if len(rates) == 0 || rates[len(rates)-1].UnitPrice == nil {
+		return 0, false
+	}
+	unitPrice := rates[len(rates)-1].UnitPrice
+	return float64(unitPrice.Units) + float64(unitPrice.Nanos)/1e9, true
+}
+
+// EstimatePlan computes the cost delta of each planned resource and sets it on the resource
+func (s *GCPPricingService) EstimatePlan(resources []models.TerraformResourceUpdate) (*models.CostEstimate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureCatalogLocked(); err != nil {
+		return nil, err
+	}
+
+	estimate := &models.CostEstimate{
+		Currency:   s.cfg.GCPPrices.Currency,
+		Items:      []models.ResourceCostEstimate{},
+		PricesAsOf: s.cfg.GCPPrices.LastUpdated,
+	}
+	defaultRegion := s.gcpService.GetRegion()
+
+	for i := range resources {
+		resource := &resources[i]
+
+		var after, before map[string]any
+		switch resource.Action {
+		case "create":
+			after = resource.Details
+		case "delete":
+			before = resource.Details
+		case "update", "replace":
+			after = resource.Details
+			if b, ok := resource.Details["before"].(map[string]any); ok {
+				before = b
+			}
+		default:
+			continue
+		}
+
+		afterCost, description, warnings := s.resourceHourlyCost(resource.Type, after, defaultRegion)
+		beforeCost, beforeDescription, beforeWarnings := s.resourceHourlyCost(resource.Type, before, defaultRegion)
+		estimate.Warnings = append(estimate.Warnings, warnings...)
+		estimate.Warnings = append(estimate.Warnings, beforeWarnings...)
+		if description == "" {
+			// deletions only have the previous values
+			description = beforeDescription
+		}
+		if description == "" {
+			// resource type has no direct cost
+			continue
+		}
+
+		hourly := afterCost - beforeCost
+		item := models.ResourceCostEstimate{
+			ResourceID:   resource.ID,
+			Type:         resource.Type,
+			Action:       resource.Action,
+			Description:  description,
+			HourlyDelta:  roundPrice(hourly),
+			MonthlyDelta: roundPrice(hourly * HoursPerMonth),
+		}
+		resource.Cost = &item
+		estimate.Items = append(estimate.Items, item)
+		estimate.HourlyDelta += hourly
+	}
+
+	estimate.HourlyDelta = roundPrice(estimate.HourlyDelta)
+	estimate.MonthlyDelta = roundPrice(estimate.HourlyDelta * HoursPerMonth)
+	return estimate, nil
+}
+
+// resourceHourlyCost prices a single resource from its plan attributes. Caller must hold the lock.
+// An empty description means the resource type is not priced.
+func (s *GCPPricingService) resourceHourlyCost(resourceType string, attrs map[string]any, defaultRegion string) (float64, string, []string) {
+	var warnings []string
+	if attrs == nil {
+		return 0, "", nil
+	}
+
+	zone, _ := attrs["zone"].(string)
+	region, _ := attrs["region"].(string)
+	if region == "" {
+		region = ZoneRegion(zone)
+	}
+	if region == "" {
+		region = defaultRegion
+	}
+
+	prices, ok := s.cfg.GCPPrices.Regions[region]
+	if !ok {
+		return 0, "", []string{fmt.Sprintf("no prices for region %s", region)}
+	}
+
+	switch resourceType {
+	case "google_compute_instance":
+		machineType, _ := attrs["machine_type"].(string)
+		machineType = path.Base(machineType)
+		spot := attrs["provisioning_model"] == models.ProvisioningModelSpot
+		hourly, err := s.machineTypeHourly(prices, zone, machineType, spot)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		}
+
+		diskSize := float64(defaultBootDiskSizeGB)
+		if size, ok := attrs["boot_disk_size"].(float64); ok && size > 0 {
+			diskSize = size
+		}
+		diskType := defaultDiskType
+		if t, ok := attrs["boot_disk_type"].(string); ok && t != "" {
+			diskType = path.Base(t)
+		}
+		hourly += diskHourly(prices, diskType, diskSize)
+
+		if externalIP, ok := attrs["external_ip"].(bool); ok && externalIP {
+			hourly += prices.ExternalIPHourly
+		}
+
+		description := fmt.Sprintf("%s instance, %.0fGB %s boot disk", machineType, diskSize, diskType)
+		if spot {
+			description = "spot " + description
+		}
+		return hourly, description, warnings
+
+	case "google_compute_disk":
+		size, _ := attrs["size"].(float64)
+		if size == 0 {
+			size = defaultBootDiskSizeGB
+		}
+		diskType := defaultDiskType
+		if t, ok := attrs["disk_type"].(string); ok && t != "" {
+			diskType = path.Base(t)
+		}
+		return diskHourly(prices, diskType, size), fmt.Sprintf("%.0fGB %s disk", size, diskType), nil
+
+	case "google_compute_address":
+		if addressType, _ := attrs["address_type"].(string); addressType == "INTERNAL" {
+			return 0, "internal static IP", nil
+		}
+		return prices.StaticIPHourly, "external static IP", nil
+	}
+
+	return 0, "", nil
+}
+
+// machineTypeHourly prices a machine type using the cached specs from GCPResources. Caller must hold the lock.
+// Spot VMs without spot prices are priced on-demand, with an error to report as a warning.
+func (s *GCPPricingService) machineTypeHourly(prices config.GCPRegionPrices, zone, machineType string, spot bool) (float64, error) {
+	if price, ok := prices.MachineTypes[machineType]; ok {
+		if spot {
+			return price, fmt.Errorf("no spot price for shared-core machine type %s, priced on-demand", machineType)
+		}
+		return price, nil
+	}
+
+	family := strings.SplitN(machineType, "-", 2)[0]
+	familyPrice, ok := prices.MachineFamilies[family]
+	if !ok {
+		return 0, fmt.Errorf("no prices for machine family %s", family)
+	}
+
+	spec, ok := s.findMachineType(zone, machineType)
+	if !ok {
+		return 0, fmt.Errorf("machine type %s not found in cached GCP resources", machineType)
+	}
+
+	cpuHourly, memoryGBHourly := familyPrice.CPUHourly, familyPrice.MemoryGBHourly
+	var err error
+	if spot {
+		if familyPrice.SpotCPUHourly > 0 && familyPrice.SpotMemoryGBHourly > 0 {
+			cpuHourly, memoryGBHourly = familyPrice.SpotCPUHourly, familyPrice.SpotMemoryGBHourly
+		} else {
+			err = fmt.Errorf("no spot prices for machine family %s, %s priced on-demand", family, machineType)
+		}
+	}
+
+	return float64(spec.GuestCpus)*cpuHourly + float64(spec.MemoryMb)/1024*memoryGBHourly, err
+}
+
+func (s *GCPPricingService) findMachineType(zone, machineType string) (config.GCPMachineType, bool) {
+	if zone != "" {
+		for _, mt := range s.cfg.GCPResources.MachineTypesByZone[zone] {
+			if mt.Name == machineType {
+				return mt, true
+			}
+		}
+	}
+	// Specs are the same in every zone, so any zone will do
+	for _, machineTypes := range s.cfg.GCPResources.MachineTypesByZone {
+		for _, mt := range machineTypes {
+			if mt.Name == machineType {
+				return mt, true
+			}
+		}
+	}
+	return config.GCPMachineType{}, false
+}
+
+// GetRunningCosts computes the current cost of the Stolos-managed GCP instances
+func (s *GCPPricingService) GetRunningCosts(ctx context.Context) (*models.RunningCostSummary, error) {
+	client, err := s.gcpService.GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP client: %w", err)
+	}
+
+	instancesByZone, err := client.ListAllInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	disksByZone, err := client.ListAllDisks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disks: %w", err)
+	}
+
+	var nodes []models.Node
+	if err := s.db.Where("provider = ?", "gcp").Find(&nodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	nodesByName := make(map[string]models.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByName[node.Name] = node
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureCatalogLocked(); err != nil {
+		return nil, err
+	}
+
+	summary := &models.RunningCostSummary{
+		Currency:   s.cfg.GCPPrices.Currency,
+		Nodes:      []models.NodeRunningCost{},
+		PricesAsOf: s.cfg.GCPPrices.LastUpdated,
+	}
+	defaultRegion := s.gcpService.GetRegion()
+
+	for zone, instances := range instancesByZone {
+		region := ZoneRegion(zone)
+		if region == "" {
+			region = defaultRegion
+		}
+		prices, ok := s.cfg.GCPPrices.Regions[region]
+		if !ok {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("no prices for region %s", region))
+			continue
+		}
+
+		for _, instance := range instances {
+			if instance.Labels["managed-by"] != "stolos" {
+				continue
+			}
+
+			machineType := path.Base(instance.MachineType)
+			hourly := 0.0
+
+			// Stopped instances only pay for their disks
+			if instance.Status == "RUNNING" {
+				spot := instance.Scheduling != nil && (instance.Scheduling.ProvisioningModel == models.ProvisioningModelSpot || instance.Scheduling.Preemptible)
+				machineHourly, err := s.machineTypeHourly(prices, zone, machineType, spot)
+				if err != nil {
+					summary.Warnings = append(summary.Warnings, fmt.Sprintf("%s: %v", instance.Name, err))
+				}
+				hourly += machineHourly
+
+				for _, nic := range instance.NetworkInterfaces {
+					if len(nic.AccessConfigs) > 0 {
+						hourly += prices.ExternalIPHourly
+					}
+				}
+			}
+
+			for _, disk := range disksByZone[zone] {
+				if slices.Contains(disk.Users, instance.SelfLink) {
+					hourly += diskHourly(prices, path.Base(disk.Type), float64(disk.SizeGb))
+				}
+			}
+
+			nodeCost := models.NodeRunningCost{
+				InstanceName: instance.Name,
+				Zone:         zone,
+				MachineType:  machineType,
+				Status:       instance.Status,
+				HourlyCost:   roundPrice(hourly),
+				MonthlyCost:  roundPrice(hourly * HoursPerMonth),
+			}
+			if node, ok := nodesByName[instance.Name]; ok {
+				nodeID := node.ID
+				nodeCost.NodeID = &nodeID
+			}
+
+			summary.Nodes = append(summary.Nodes, nodeCost)
+			summary.HourlyCost += hourly
+		}
+	}
+
+	slices.SortFunc(summary.Nodes, func(a, b models.NodeRunningCost) int {
+		return strings.Compare(a.InstanceName, b.InstanceName)
+	})
+	summary.HourlyCost = roundPrice(summary.HourlyCost)
+	summary.MonthlyCost = roundPrice(summary.HourlyCost * HoursPerMonth)
+	return summary, nil
+}
+
+func diskHourly(prices config.GCPRegionPrices, diskType string, sizeGB float64) float64 {
+	return prices.Disks[diskType] * sizeGB / HoursPerMonth
+}
+
+func roundPrice(price float64) float64 {
+	return math.Round(price*10000) / 10000
+}
@@ -2,13 +2,17 @@ package gcp
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -17,15 +21,19 @@ import (
 	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
 	"github.com/siderolabs/talos/pkg/machinery/config/encoder"
 	machineconf "github.com/siderolabs/talos/pkg/machinery/config/machine"
+	tfpkg "github.com/stolos-cloud/stolos-bootstrap/pkg/terraform"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
 	"github.com/stolos-cloud/stolos/backend/internal/helpers"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	gitopsservices "github.com/stolos-cloud/stolos/backend/internal/services/gitops"
 	talosservices "github.com/stolos-cloud/stolos/backend/internal/services/talos"
 	terraformservices "github.com/stolos-cloud/stolos/backend/internal/services/terraform"
 	wsservices "github.com/stolos-cloud/stolos/backend/internal/services/websocket"
+	"github.com/stolos-cloud/stolos/backend/internal/tracing"
 	githubpkg "github.com/stolos-cloud/stolos/backend/pkg/github"
-	tfpkg "github.com/stolos-cloud/stolos/backend/pkg/terraform"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/api/option"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -37,7 +45,12 @@ type ProvisioningService struct {
 	talosService     *talosservices.TalosService
 	gcpService       *GCPService
 	gitopsService    *gitopsservices.GitOpsService
+	pricingService   *GCPPricingService
+	resourcesService *GCPResourcesService
 	activeProvisions map[uuid.UUID]*ProvisionSession
+	sessions         map[uuid.UUID]*wsservices.ApprovalSession
+	logger           *slog.Logger
+	mu               sync.Mutex
 }
 
 // ProvisionSession tracks terraform state for an active provision
@@ -54,14 +67,21 @@ func NewProvisioningService(
 	talosService *talosservices.TalosService,
 	gcpService *GCPService,
 	gitopsService *gitopsservices.GitOpsService,
+	pricingService *GCPPricingService,
+	resourcesService *GCPResourcesService,
+	logs *logging.Logging,
 ) *ProvisioningService {
 	return &ProvisioningService{
+		logger:           logs.Logger(logging.SubsystemProvisioning),
 		db:               db,
 		cfg:              cfg,
 		talosService:     talosService,
 		gcpService:       gcpService,
 		gitopsService:    gitopsService,
+		pricingService:   pricingService,
+		resourcesService: resourcesService,
 		activeProvisions: make(map[uuid.UUID]*ProvisionSession),
+		sessions:         make(map[uuid.UUID]*wsservices.ApprovalSession),
 	}
 }
 
@@ -96,20 +116,60 @@ func (s *ProvisioningService) getNextNodeNumber(clusterID uuid.UUID, namePrefix
 	return maxNum + 1, nil
 }
 
-// ProvisionNodes orchestrates the complete node provisioning workflow
+// ProvisionNodes orchestrates the complete node provisioning workflow.
+// Each completed step is checkpointed on the ProvisionRequest so an interrupted request can be resumed.
 func (s *ProvisioningService) ProvisionNodes(
 	ctx context.Context,
 	requestID uuid.UUID,
 	req models.GCPNodeProvisionRequest,
 	session *wsservices.ApprovalSession,
-) error {
-	// Update status to planning
-	if err := s.updateProvisionStatus(requestID, models.ProvisionStatusPlanning); err != nil {
-		return err
+) (err error) {
+	defer s.releaseSession(requestID)
+
+	ctx, span := tracing.Start(ctx, "ProvisionNodes", attribute.String("provision.request_id", requestID.String()))
+	defer func() { tracing.End(span, err) }()
+	session.SetTraceID(tracing.TraceID(ctx))
+	ctx = logging.WithAttrs(ctx, slog.String("provision_request_id", requestID.String()))
+
+	workflowStart := time.Now()
+	defer func() {
+		metrics.ObserveStage("total", workflowStart, err)
+		metrics.ProvisioningRequests.WithLabelValues(metrics.Result(err)).Inc()
+	}()
+
+	var provisionRequest models.ProvisionRequest
+	if err := s.db.Where("id = ?", requestID).First(&provisionRequest).Error; err != nil {
+		return fmt.Errorf("failed to fetch provision request: %w", err)
+	}
+	checkpoint := provisionRequest.Checkpoint
+
+	// Record who runs the request so a lock left behind by this process can be recognized after a restart
+	if err := s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Updates(map[string]interface{}{
+			"attempts":    gorm.Expr("attempts + 1"),
+			"lock_holder": terraformLockHolder(),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to update provision request: %w", err)
+	}
+
+	if checkpoint.Reached(models.ProvisionCheckpointApproved) {
+		if err := s.updateProvisionStatus(requestID, models.ProvisionStatusApplying); err != nil {
+			return err
+		}
+		session.SendStatus("applying")
+	} else {
+		// Update status to planning
+		if err := s.updateProvisionStatus(requestID, models.ProvisionStatusPlanning); err != nil {
+			return err
+		}
+		session.SendStatus("planning")
 	}
 
-	session.SendStatus("planning")
 	session.SendLog("Starting node provisioning workflow...")
+	if checkpoint != models.ProvisionCheckpointNone {
+		session.SendLog(fmt.Sprintf("Resuming provisioning from checkpoint '%s'", checkpoint))
+	}
 
 	// Get GCP configuration
 	session.SendLog("Fetching GCP configuration...")
@@ -129,36 +189,294 @@ func (s *ProvisioningService) ProvisionNodes(
 	clusterID := cluster.ID
 	session.SendLog(fmt.Sprintf("Using cluster: %s (ID: %s)", cluster.Name, cluster.ID))
 
+	var nodes []NodeConfig
+	if checkpoint.Reached(models.ProvisionCheckpointConfigsUploaded) {
+		// Talos configs are already in the bucket, reuse the node names and settings
+		if err := json.Unmarshal(provisionRequest.Nodes, &nodes); err != nil {
+			return fmt.Errorf("failed to load node configurations: %w", err)
+		}
+		session.SendLog(fmt.Sprintf("Loaded %d previously generated node configuration(s)", len(nodes)))
+	} else {
+		stageStart := time.Now()
+		nodes, err = s.generateNodeConfigs(req, clusterID, gcpConfig, session)
+		metrics.ObserveStage("generate_configs", stageStart, err)
+		if err != nil {
+			return err
+		}
+
+		// Upload Talos configs to GCS bucket
+		session.SendLog("Uploading Talos configurations to  storage...")
+		stageStart = time.Now()
+		err = s.uploadTalosConfigsToGCS(ctx, gcpConfig, nodes)
+		metrics.ObserveStage("upload_configs", stageStart, err)
+		if err != nil {
+			return fmt.Errorf("failed to upload Talos configs to GCS: %w", err)
+		}
+		session.SendLog("Talos configurations uploaded successfully")
+
+		if err := s.saveNodeConfigs(requestID, nodes); err != nil {
+			return err
+		}
+	}
+
+	// Get GitOps config and GitHub client (used for both createTerraformFiles and commitTerraformFiles)
+	gitopsConfig, err := s.gitopsService.GetConfigOrDefault()
+	if err != nil {
+		return fmt.Errorf("failed to get GitOps config: %w", err)
+	}
+
+	ghClient, err := s.gitopsService.GetGitHubClient()
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	// Create terraform files
+	session.SendLog("Creating Terraform configuration files...")
+	stageStart := time.Now()
+	err = s.createTerraformFiles(ctx, requestID, gcpConfig, nodes, ghClient, gitopsConfig)
+	metrics.ObserveStage("terraform_files", stageStart, err)
+	if err != nil {
+		return fmt.Errorf("failed to create terraform files: %w", err)
+	}
+
+	session.SendLog("Terraform files created successfully")
+
+	// Get the provision session
+	provSession, ok := s.getProvisionSession(requestID)
+	if !ok {
+		return fmt.Errorf("provision session not found")
+	}
+
+	// Cleanup the provision session and temp directory
+	defer func() {
+		if provSession.WorkDir != "" {
+			os.RemoveAll(provSession.WorkDir)
+		}
+		s.deleteProvisionSession(requestID)
+	}()
+
+	session.SendLog("Initializing Terraform...")
+	stageStart = time.Now()
+	err = provSession.Orchestrator.Init(ctx)
+	metrics.ObserveStage("init", stageStart, err)
+	if err != nil {
+		return fmt.Errorf("terraform init failed: %w", err)
+	}
+
+	stageStart = time.Now()
+	resourceTracker, planChanges, err := s.planNodes(ctx, requestID, provSession, session)
+	metrics.ObserveStage("plan", stageStart, err)
+	if err != nil {
+		return err
+	}
+
+	// A resumed request only skips the approval when it applies what is left of the plan the user approved
+	approved := checkpoint.Reached(models.ProvisionCheckpointApproved)
+	if approved && !checkpoint.Reached(models.ProvisionCheckpointApplied) {
+		if unapproved := unapprovedChanges(planChanges, provisionRequest.PlanChanges); len(unapproved) > 0 {
+			session.SendLog(fmt.Sprintf("The plan changed since it was approved (%s), approval is required again", strings.Join(unapproved, ", ")))
+			approved = false
+		}
+	}
+
+	if approved {
+		session.SendLog("Plan was already approved, continuing")
+	} else {
+		if !checkpoint.Reached(models.ProvisionCheckpointApproved) {
+			if err := s.updateCheckpoint(requestID, models.ProvisionCheckpointPlanned); err != nil {
+				return err
+			}
+		}
+
+		// Update status to awaiting approval
+		if err := s.updateProvisionStatus(requestID, models.ProvisionStatusAwaitingApproval); err != nil {
+			return err
+		}
+
+		session.SendStatus("awaiting_approval")
+		session.SendApprovalRequest("Please review the plan and approve to continue.")
+
+		// Wait for approval (with timeout)
+		session.SendLog("Waiting for user approval...")
+		stageStart = time.Now()
+		approved, err := session.WaitForApprovalCtx(ctx, 30*time.Minute)
+		metrics.ObserveStage("approval", stageStart, err)
+		if err != nil {
+			return fmt.Errorf("approval failed: %w", err)
+		}
+
+		if !approved {
+			session.SendLog("Provisioning rejected by user")
+			if err := s.updateProvisionStatus(requestID, models.ProvisionStatusFailed); err != nil {
+				s.logger.WarnContext(ctx, "failed to update status", "error", err)
+			}
+			return fmt.Errorf("provisioning rejected by user")
+		}
+
+		session.SendLog("Provisioning approved by user")
+		if err := s.approvePlan(requestID, planChanges, checkpoint); err != nil {
+			return err
+		}
+	}
+
+	if !checkpoint.Reached(models.ProvisionCheckpointCommitted) {
+		// Commit terraform files to git repo after approval
+		session.SendLog("Committing terraform files to GitOps repository...")
+		stageStart = time.Now()
+		err = s.commitTerraformFiles(ctx, requestID, ghClient, gitopsConfig)
+		metrics.ObserveStage("commit", stageStart, err)
+		if err != nil {
+			return fmt.Errorf("failed to commit terraform files: %w", err)
+		}
+		session.SendLog("Terraform files committed successfully")
+
+		if err := s.updateCheckpoint(requestID, models.ProvisionCheckpointCommitted); err != nil {
+			return err
+		}
+	}
+
+	// Update status to applying
+	if err := s.updateProvisionStatus(requestID, models.ProvisionStatusApplying); err != nil {
+		return err
+	}
+
+	if !checkpoint.Reached(models.ProvisionCheckpointApplied) {
+		session.SendStatus("applying")
+		stageStart = time.Now()
+		err = s.applyNodes(ctx, requestID, provSession, resourceTracker, session)
+		metrics.ObserveStage("apply", stageStart, err)
+		if err != nil {
+			return err
+		}
+
+		if err := s.updateCheckpoint(requestID, models.ProvisionCheckpointApplied); err != nil {
+			return err
+		}
+	}
+
+	// Parse terraform output to get instance details
+	instanceDetails, err := s.getTerraformOutputs(ctx, requestID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get terraform outputs", "error", err)
+	} else if len(instanceDetails) > 0 {
+		outputsMap := make(map[string]any)
+		for _, detail := range instanceDetails {
+			if detail.InstanceName != "" {
+				// Use node name as key for better readability on frontend
+				outputsMap[detail.InstanceName] = map[string]any{
+					"instance_id": detail.InstanceID,
+					"internal_ip": detail.InternalIP,
+					"external_ip": detail.ExternalIP,
+				}
+			}
+		}
+
+		if len(outputsMap) > 0 {
+			session.SendWorkflowUpdate(models.TerraformWorkflowUpdate{
+				Outputs: outputsMap,
+			})
+		}
+	}
+
+	nodeIDs := s.registerNodes(clusterID, nodes, instanceDetails, session)
+
+	// Update provision request with node IDs
+	nodeIDsJSON, _ := json.Marshal(nodeIDs)
+	if err := s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Updates(map[string]interface{}{
+			"node_ids":   datatypes.JSON(nodeIDsJSON),
+			"status":     models.ProvisionStatusCompleted,
+			"checkpoint": models.ProvisionCheckpointRegistered,
+		}).Error; err != nil {
+		s.logger.WarnContext(ctx, "failed to update provision request", "error", err)
+	}
+
+	session.SendStatus("completed")
+
+	if len(nodeIDs) > 0 {
+		session.SendLog(fmt.Sprintf("✓ Provisioning completed successfully: %d node(s) ready", len(nodeIDs)))
+	} else {
+		session.SendLog("⚠ Provisioning completed but no node records were created/updated")
+	}
+
+	// Send completion message with node details
+	session.SendComplete(map[string]interface{}{
+		"node_ids":    nodeIDs,
+		"nodes_count": len(nodeIDs),
+	})
+
+	return nil
+}
+
+// generateNodeConfigs generates the names and Talos machine configs of the requested nodes
+func (s *ProvisioningService) generateNodeConfigs(
+	req models.GCPNodeProvisionRequest,
+	clusterID uuid.UUID,
+	gcpConfig *models.GCPConfig,
+	session *wsservices.ApprovalSession,
+) ([]NodeConfig, error) {
 	// Get Talos image information
 	talosImageName, err := s.talosService.GetGCPImageName("amd64")
 	if err != nil {
-		return fmt.Errorf("failed to get Talos image: %w", err)
+		return nil, fmt.Errorf("failed to get Talos image: %w", err)
 	}
 
 	// Get next available node number
 	startNum, err := s.getNextNodeNumber(clusterID, req.NamePrefix)
 	if err != nil {
-		return fmt.Errorf("failed to determine next node number: %w", err)
+		return nil, fmt.Errorf("failed to determine next node number: %w", err)
+	}
+
+	// Spread the nodes across the requested zones
+	resources, err := s.resourcesService.GetResources()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached GCP resources: %w", err)
+	}
+	zones, err := ResolveZones(req, gcpConfig, resources)
+	if err != nil {
+		return nil, err
+	}
+	var clusterNodes []models.Node
+	if err := s.db.Where("cluster_id = ?", clusterID).Find(&clusterNodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	nodeZones, err := SpreadZones(zones, nodesPerZone(clusterNodes, req.Role), req.Number, req.SpreadPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if len(zones) > 1 {
+		session.SendLog(fmt.Sprintf("Spreading %d node(s) across zones %s", req.Number, strings.Join(zones, ", ")))
 	}
 
 	// Generate node names and configs
 	session.SendLog(fmt.Sprintf("Generating configurations for %d node(s)...", req.Number))
 	nodes := make([]NodeConfig, 0, req.Number)
 
-	// Merge automatic labels with user-provided labels
-	autoLabels := []string{
-		"provider=gcp",
-		fmt.Sprintf("role=%s", req.Role),
-		fmt.Sprintf("disk_type=%s", req.DiskType),
-	}
-	allLabels := append(autoLabels, req.Labels...)
-
 	for i := 0; i < req.Number; i++ {
+		zone := nodeZones[i]
+		region := ZoneRegion(zone)
+
+		// Merge automatic labels with user-provided labels
+		allLabels := []string{
+			"provider=gcp",
+			fmt.Sprintf("role=%s", req.Role),
+			fmt.Sprintf("disk_type=%s", req.DiskType),
+			fmt.Sprintf("%s=%s", TopologyZoneLabel, zone),
+			fmt.Sprintf("%s=%s", TopologyRegionLabel, region),
+		}
+		var taints map[string]string
+		if req.Spot {
+			allLabels = append(allLabels, fmt.Sprintf("%s=true", SpotNodeLabel))
+			taints = map[string]string{SpotNodeLabel: "true:NoSchedule"}
+		}
+		allLabels = append(allLabels, req.Labels...)
+
 		// Get a fresh config bundle for each node
 		session.SendLog("Loading Talos machine configuration bundle...")
 		configBundle, err := s.talosService.GetMachineConfigBundle()
 		if err != nil {
-			return fmt.Errorf("failed to get machine config bundle: %w", err)
+			return nil, fmt.Errorf("failed to get machine config bundle: %w", err)
 		}
 
 		nodeName := fmt.Sprintf("%s-%d", req.NamePrefix, startNum+i)
@@ -176,9 +494,9 @@ func (s *ProvisioningService) ProvisionNodes(
 		diskPath := "/dev/sda"
 
 		// Create typed config patch with hostname, disk, network settings, and labels
-		typedPatch, err := talosservices.CreateMachineConfigPatch(nodeName, diskPath, allLabels)
+		typedPatch, err := talosservices.CreateMachineConfigPatchWithTaints(nodeName, diskPath, allLabels, taints)
 		if err != nil {
-			return fmt.Errorf("failed to create config patch: %w", err)
+			return nil, fmt.Errorf("failed to create config patch: %w", err)
 		}
 		session.SendLog(fmt.Sprintf("Created typed config patch for hostname: %s, disk: %s, labels: %v", nodeName, diskPath, allLabels))
 
@@ -186,13 +504,13 @@ func (s *ProvisioningService) ProvisionNodes(
 		isControlPlane := machineType == machineconf.TypeControlPlane
 		isWorker := machineType == machineconf.TypeWorker
 		if err := configBundle.ApplyPatches([]configpatcher.Patch{typedPatch}, isControlPlane, isWorker); err != nil {
-			return fmt.Errorf("failed to apply typed patch to config bundle: %w", err)
+			return nil, fmt.Errorf("failed to apply typed patch to config bundle: %w", err)
 		}
 
 		// Serialize the patched config
 		rendered, err := configBundle.Serialize(encoder.CommentsDocs, machineType)
 		if err != nil {
-			return fmt.Errorf("failed to serialize config: %w", err)
+			return nil, fmt.Errorf("failed to serialize config: %w", err)
 		}
 
 		// Remove diskSelector from base config (hardware-specific busPath won't work on GCP)
@@ -202,12 +520,15 @@ func (s *ProvisioningService) ProvisionNodes(
 
 		nodes = append(nodes, NodeConfig{
 			Name:              nodeName,
-			Zone:              req.Zone,
+			Zone:              zone,
+			Region:            region,
 			MachineType:       req.MachineType,
 			Role:              req.Role,
 			Labels:            allLabels,
 			DiskSizeGB:        req.DiskSizeGB,
 			DiskType:          req.DiskType,
+			Spot:              req.Spot,
+			SpotAction:        req.SpotAction,
 			TalosConfig:       machineConfig,
 			TalosImageProject: gcpConfig.ProjectID,
 			TalosImageName:    talosImageName,
@@ -215,67 +536,50 @@ func (s *ProvisioningService) ProvisionNodes(
 	}
 
 	session.SendLog(fmt.Sprintf("Generated configurations for %d node(s)", len(nodes)))
+	return nodes, nil
+}
 
-	// Upload Talos configs to GCS bucket
-	session.SendLog("Uploading Talos configurations to  storage...")
-	if err := s.uploadTalosConfigsToGCS(ctx, gcpConfig, nodes); err != nil {
-		return fmt.Errorf("failed to upload Talos configs to GCS: %w", err)
-	}
-	session.SendLog("Talos configurations uploaded successfully")
-
-	// Get GitOps config and GitHub client (used for both createTerraformFiles and commitTerraformFiles)
-	gitopsConfig, err := s.gitopsService.GetConfigOrDefault()
-	if err != nil {
-		return fmt.Errorf("failed to get GitOps config: %w", err)
-	}
-
-	ghClient, err := s.gitopsService.GetGitHubClient()
-	if err != nil {
-		return fmt.Errorf("failed to create GitHub client: %w", err)
-	}
-
-	// Create terraform files
-	session.SendLog("Creating Terraform configuration files...")
-	if err := s.createTerraformFiles(ctx, requestID, gcpConfig, nodes, ghClient, gitopsConfig); err != nil {
-		return fmt.Errorf("failed to create terraform files: %w", err)
-	}
-
-	session.SendLog("Terraform files created successfully")
-
-	// Get the provision session
-	provSession, ok := s.activeProvisions[requestID]
-	if !ok {
-		return fmt.Errorf("provision session not found")
-	}
-
-	session.SendLog("Initializing Terraform...")
-	if err := provSession.Orchestrator.Init(ctx); err != nil {
-		return fmt.Errorf("terraform init failed: %w", err)
-	}
-
+// planNodes runs terraform plan, reports planned resources and their cost and stores the plan output
+func (s *ProvisioningService) planNodes(
+	ctx context.Context,
+	requestID uuid.UUID,
+	provSession *ProvisionSession,
+	session *wsservices.ApprovalSession,
+) (*terraformservices.ResourceTracker, map[string]string, error) {
 	session.SendLog("Running terraform plan...")
-	session.SendStatus("planning")
 
 	// Create resource tracker for ws updates
-	resourceTracker := terraformservices.NewResourceTracker(session)
+	resourceTracker := terraformservices.NewResourceTracker(session, s.logger)
 
 	hasChanges, planOutput, err := provSession.Orchestrator.PlanWithOutput(ctx)
 	if err != nil {
-		return fmt.Errorf("terraform plan failed: %w", err)
+		return nil, nil, fmt.Errorf("terraform plan failed: %w", err)
 	}
 
 	session.SendLog("Terraform plan executed successfully")
 
+	var costEstimate *models.CostEstimate
+	// Without the planned resources the changes stay nil, and are never considered approved
+	var planChanges map[string]string
+
 	planJSON, err := provSession.Orchestrator.GetPlanJSON(ctx)
 	if err != nil {
-		log.Printf("Warning: Failed to get plan JSON: %v", err)
+		s.logger.WarnContext(ctx, "failed to get plan JSON", "error", err)
 		// Continue without resource details
 	} else {
 		// Parse the plan to get actual resources
 		plannedResources, err := terraformservices.ParsePlanJSON(planJSON)
 		if err != nil {
-			log.Printf("Warning: Failed to parse plan JSON: %v", err)
+			s.logger.WarnContext(ctx, "failed to parse plan JSON", "error", err)
 		} else {
+			planChanges = digestChanges(plannedResources)
+
+			// Estimate cost delta before sending resources so each one carries its cost
+			costEstimate, err = s.pricingService.EstimatePlan(plannedResources)
+			if err != nil {
+				s.logger.WarnContext(ctx, "failed to estimate plan cost", "error", err)
+			}
+
 			// Initialize resource tracker with planned resources
 			resourceTracker.InitializeWithPlan(plannedResources)
 
@@ -293,6 +597,7 @@ func (s *ProvisioningService) ProvisionNodes(
 			workflowUpdate := models.TerraformWorkflowUpdate{
 				Resources: plannedResources,
 				Summary:   summary,
+				Cost:      costEstimate,
 			}
 			session.SendWorkflowUpdate(workflowUpdate)
 
@@ -304,14 +609,14 @@ func (s *ProvisioningService) ProvisionNodes(
 	// Save plan output to file
 	planDir := "plans"
 	if err := os.MkdirAll(planDir, 0755); err != nil {
-		log.Printf("Warning: failed to create plans directory: %v", err)
+		s.logger.WarnContext(ctx, "failed to create plans directory", "error", err)
 	}
 
 	planFilename := fmt.Sprintf("plan-%s.txt", requestID.String())
 	planFilePath := filepath.Join(planDir, planFilename)
 
 	if err := os.WriteFile(planFilePath, []byte(planOutput), 0644); err != nil {
-		log.Printf("Warning: failed to save plan output to file: %v", err)
+		s.logger.WarnContext(ctx, "failed to save plan output to file", "error", err)
 	} else {
 		session.SendLog(fmt.Sprintf("Plan saved to file: %s", planFilename))
 	}
@@ -319,65 +624,88 @@ func (s *ProvisioningService) ProvisionNodes(
 	// Create summary
 	planSummary := "Terraform plan completed\n"
 	if hasChanges {
-		planSummary += fmt.Sprintf("Plan: %d node(s) to add\n", len(nodes))
+		planSummary += fmt.Sprintf("Plan: %d node(s) to add\n", len(provSession.Nodes))
 	} else {
 		planSummary += "No changes detected\n"
 	}
+	if costEstimate != nil {
+		planSummary += fmt.Sprintf("Estimated cost: %+.4f %s/hour (%+.2f %s/month)\n",
+			costEstimate.HourlyDelta, costEstimate.Currency, costEstimate.MonthlyDelta, costEstimate.Currency)
+		for _, warning := range costEstimate.Warnings {
+			planSummary += fmt.Sprintf("Cost warning: %s\n", warning)
+		}
+
+		if err := s.updateCostEstimate(requestID, costEstimate); err != nil {
+			s.logger.WarnContext(ctx, "failed to store cost estimate", "error", err)
+		}
+	}
 	session.SendLog(planSummary)
 
 	// Store plan output in database
 	if err := s.updatePlanOutput(requestID, planSummary); err != nil {
-		log.Printf("Warning: failed to store plan output: %v", err)
+		s.logger.WarnContext(ctx, "failed to store plan output", "error", err)
 	}
 
 	// Send plan file path to client
 	session.SendLog("Terraform plan completed successfully")
 	session.SendPlan(fmt.Sprintf("Plan file: /api/gcp/nodes/provision/%s/plan", requestID.String()))
 
-	// Update status to awaiting approval
-	if err := s.updateProvisionStatus(requestID, models.ProvisionStatusAwaitingApproval); err != nil {
-		return err
-	}
-
-	session.SendStatus("awaiting_approval")
-	session.SendApprovalRequest("Please review the plan and approve to continue.")
+	return resourceTracker, planChanges, nil
+}
 
-	// Wait for approval (with timeout)
-	session.SendLog("Waiting for user approval...")
-	approved, err := session.WaitForApprovalCtx(ctx, 30*time.Minute)
-	if err != nil {
-		return fmt.Errorf("approval failed: %w", err)
+// digestChanges identifies the changes of a plan: a digest of the action and values of each planned resource, by address
+func digestChanges(resources []models.TerraformResourceUpdate) map[string]string {
+	type change struct {
+		Action  string         `json:"action"`
+		Details map[string]any `json:"details"`
 	}
-
-	if !approved {
-		session.SendLog("Provisioning rejected by user")
-		if err := s.updateProvisionStatus(requestID, models.ProvisionStatusFailed); err != nil {
-			log.Printf("Warning: failed to update status: %v", err)
+	changes := make(map[string]string, len(resources))
+	for _, resource := range resources {
+		data, err := json.Marshal(change{Action: resource.Action, Details: resource.Details})
+		if err != nil {
+			return nil
 		}
-		return fmt.Errorf("provisioning rejected by user")
+		sum := sha256.Sum256(data)
+		changes[resource.ID] = hex.EncodeToString(sum[:])
 	}
+	return changes
+}
 
-	session.SendLog("Provisioning approved by user")
-
-	// Commit terraform files to git repo after approval
-	session.SendLog("Committing terraform files to GitOps repository...")
-	if err := s.commitTerraformFiles(ctx, requestID, ghClient, gitopsConfig); err != nil {
-		return fmt.Errorf("failed to commit terraform files: %w", err)
+// unapprovedChanges returns the addresses of the planned changes missing from the approved ones.
+// A request resumed mid-apply plans the changes not applied yet, a subset of the approved plan.
+func unapprovedChanges(planned map[string]string, approvedJSON datatypes.JSON) []string {
+	if planned == nil {
+		return []string{"plan unavailable"}
+	}
+	var approved map[string]string
+	if len(approvedJSON) > 0 {
+		_ = json.Unmarshal(approvedJSON, &approved)
 	}
-	session.SendLog("Terraform files committed successfully")
 
-	// Update status to applying
-	if err := s.updateProvisionStatus(requestID, models.ProvisionStatusApplying); err != nil {
-		return err
+	var unapproved []string
+	for address, digest := range planned {
+		if approved[address] != digest {
+			unapproved = append(unapproved, address)
+		}
 	}
+	sort.Strings(unapproved)
+	return unapproved
+}
 
-	session.SendStatus("applying")
+// applyNodes runs terraform apply, streaming resource updates to the session and saving the JSON log
+func (s *ProvisioningService) applyNodes(
+	ctx context.Context,
+	requestID uuid.UUID,
+	provSession *ProvisionSession,
+	resourceTracker *terraformservices.ResourceTracker,
+	session *wsservices.ApprovalSession,
+) error {
 	session.SendLog("Running terraform apply...")
 
 	// Prepare file for saving apply logs
 	applyDir := "applies"
 	if err := os.MkdirAll(applyDir, 0755); err != nil {
-		log.Printf("Warning: failed to create applies directory: %v", err)
+		s.logger.WarnContext(ctx, "failed to create applies directory", "error", err)
 	}
 
 	applyFilename := fmt.Sprintf("apply-%s.json", requestID.String())
@@ -385,7 +713,7 @@ func (s *ProvisioningService) ProvisionNodes(
 
 	applyFile, err := os.Create(applyFilePath)
 	if err != nil {
-		log.Printf("Warning: failed to create apply log file: %v", err)
+		s.logger.WarnContext(ctx, "failed to create apply log file", "error", err)
 	}
 
 	// for streaming JSON output
@@ -408,7 +736,7 @@ func (s *ProvisioningService) ProvisionNodes(
 		}
 
 		if err := resourceTracker.StreamApplyJSON(ctx, reader); err != nil {
-			log.Printf("Error processing apply JSON: %v", err)
+			s.logger.ErrorContext(ctx, "failed to process apply JSON", "error", err)
 			streamDone <- err
 		} else {
 			streamDone <- nil
@@ -435,48 +763,19 @@ func (s *ProvisioningService) ProvisionNodes(
 		return applyErr
 	}
 	if streamErr != nil {
-		log.Printf("Warning: error processing apply stream: %v", streamErr)
+		s.logger.WarnContext(ctx, "failed to process apply stream", "error", streamErr)
 	}
 
-	applyOutput := fmt.Sprintf("Apply complete! Resources requested: %d node(s) added\n", len(nodes))
+	applyOutput := fmt.Sprintf("Apply complete! Resources requested: %d node(s) added\n", len(provSession.Nodes))
 
 	session.SendLog("Terraform apply executed successfully")
 	session.SendLog(applyOutput)
 	session.SendLog("Terraform apply completed successfully")
+	return nil
+}
 
-	// Parse terraform output to get instance details
-	instanceDetails, err := s.getTerraformOutputs(ctx, requestID)
-	if err != nil {
-		log.Printf("Failed to get terraform outputs: %v", err)
-	} else if len(instanceDetails) > 0 {
-		outputsMap := make(map[string]any)
-		for _, detail := range instanceDetails {
-			if detail.InstanceName != "" {
-				// Use node name as key for better readability on frontend
-				outputsMap[detail.InstanceName] = map[string]any{
-					"instance_id": detail.InstanceID,
-					"internal_ip": detail.InternalIP,
-					"external_ip": detail.ExternalIP,
-				}
-			}
-		}
-
-		if len(outputsMap) > 0 {
-			session.SendWorkflowUpdate(models.TerraformWorkflowUpdate{
-				Outputs: outputsMap,
-			})
-		}
-	}
-
-	// Cleanup the provision session and temp directory
-	defer func() {
-		if provSession.WorkDir != "" {
-			os.RemoveAll(provSession.WorkDir)
-		}
-		delete(s.activeProvisions, requestID)
-	}()
-
-	// Create or update node records in database
+// registerNodes creates or updates node records in database and returns their IDs
+func (s *ProvisioningService) registerNodes(clusterID uuid.UUID, nodes []NodeConfig, instanceDetails []InstanceDetails, session *wsservices.ApprovalSession) []uuid.UUID {
 	session.SendLog("Creating node records in database...")
 	nodeIDs := make([]uuid.UUID, 0, len(nodes))
 
@@ -493,9 +792,11 @@ func (s *ProvisioningService) ProvisionNodes(
 			// Allow retry for failed or stuck provisioning
 			session.SendLog(fmt.Sprintf("Node %s exists with status '%s', retrying provision", nodeConfig.Name, existingNode.Status))
 			existingNode.Status = "provisioning"
+			existingNode.Zone = nodeConfig.Zone
+			existingNode.Spot = nodeConfig.Spot
 
 			if err := s.db.Save(&existingNode).Error; err != nil {
-				log.Printf("Warning: failed to update node record for %s: %v", nodeConfig.Name, err)
+				s.logger.Warn("failed to update node record", "node", nodeConfig.Name, "error", err)
 				continue
 			}
 
@@ -509,6 +810,8 @@ func (s *ProvisioningService) ProvisionNodes(
 				Status:       "provisioning",
 				ClusterID:    clusterID,
 				Architecture: "amd64",
+				Zone:         nodeConfig.Zone,
+				Spot:         nodeConfig.Spot,
 			}
 
 			// Add labels
@@ -524,7 +827,7 @@ func (s *ProvisioningService) ProvisionNodes(
 			}
 
 			if err := s.db.Create(&node).Error; err != nil {
-				log.Printf("Warning: failed to create node record for %s: %v", nodeConfig.Name, err)
+				s.logger.Warn("failed to create node record", "node", nodeConfig.Name, "error", err)
 				continue
 			}
 
@@ -532,51 +835,30 @@ func (s *ProvisioningService) ProvisionNodes(
 			session.SendLog(fmt.Sprintf("Created node record: %s (ID: %s)", node.Name, node.ID))
 		} else {
 			// Database error
-			log.Printf("Error checking for existing node %s: %v", nodeConfig.Name, err)
+			s.logger.Error("failed to check for existing node", "node", nodeConfig.Name, "error", err)
 			continue
 		}
 	}
 
-	// Update provision request with node IDs
-	nodeIDsJSON, _ := json.Marshal(nodeIDs)
-	if err := s.db.Model(&models.ProvisionRequest{}).
-		Where("id = ?", requestID).
-		Updates(map[string]interface{}{
-			"node_ids": datatypes.JSON(nodeIDsJSON),
-			"status":   models.ProvisionStatusCompleted,
-		}).Error; err != nil {
-		log.Printf("Warning: failed to update provision request: %v", err)
-	}
-
-	session.SendStatus("completed")
-
-	if len(nodeIDs) > 0 {
-		session.SendLog(fmt.Sprintf("✓ Provisioning completed successfully: %d node(s) ready", len(nodeIDs)))
-	} else {
-		session.SendLog("⚠ Provisioning completed but no node records were created/updated")
-	}
-
-	// Send completion message with node details
-	session.SendComplete(map[string]interface{}{
-		"node_ids":    nodeIDs,
-		"nodes_count": len(nodeIDs),
-	})
-
-	return nil
+	return nodeIDs
 }
 
 // NodeConfig holds the configuration for a single node
+// It is persisted on the provision request to resume it; the Talos config lives in the bucket.
 type NodeConfig struct {
-	Name              string
-	Zone              string
-	MachineType       string
-	Role              string
-	Labels            []string
-	DiskSizeGB        int
-	DiskType          string
-	TalosConfig       string
-	TalosImageProject string
-	TalosImageName    string
+	Name              string   `json:"name"`
+	Zone              string   `json:"zone"`
+	Region            string   `json:"region"`
+	MachineType       string   `json:"machine_type"`
+	Role              string   `json:"role"`
+	Labels            []string `json:"labels"`
+	DiskSizeGB        int      `json:"disk_size_gb"`
+	DiskType          string   `json:"disk_type"`
+	Spot              bool     `json:"spot"`
+	SpotAction        string   `json:"spot_termination_action,omitempty"`
+	TalosConfig       string   `json:"-"`
+	TalosImageProject string   `json:"talos_image_project"`
+	TalosImageName    string   `json:"talos_image_name"`
 }
 
 // InstanceDetails holds terraform output for a node
@@ -592,7 +874,7 @@ func (s *ProvisioningService) createTerraformFiles(ctx context.Context, requestI
 
 	existingFiles, err := s.fetchExistingNodeFiles(ctx, ghClient, gitopsConfig)
 	if err != nil {
-		log.Printf("Warning: failed to fetch existing node files: %v", err)
+		s.logger.WarnContext(ctx, "failed to fetch existing node files", "error", err)
 	}
 
 	tempRoot, err := os.MkdirTemp("", "terraform-nodes-*")
@@ -632,9 +914,8 @@ func (s *ProvisioningService) createTerraformFiles(ctx context.Context, requestI
 	}
 
 	orchestrator, err := tfpkg.NewOrchestrator(tfpkg.OrchestratorConfig{
-		WorkDir:         workDir,
-		TemplateBaseDir: "terraform-templates",
-		EnvVars:         gcpConfig.TerraformEnvVars(),
+		WorkDir: workDir,
+		EnvVars: gcpConfig.TerraformEnvVars(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
@@ -668,15 +949,21 @@ func (s *ProvisioningService) createTerraformFiles(ctx context.Context, requestI
 	}
 
 	// Store session
-	s.activeProvisions[requestID] = &ProvisionSession{
+	s.setProvisionSession(requestID, &ProvisionSession{
 		WorkDir:      tempRoot, // Store root for cleanup
 		Orchestrator: orchestrator,
 		Nodes:        nodes,
 		GCPConfig:    gcpConfig,
-	}
+	})
 
 	// Render a node.tf file for each node
 	for _, node := range nodes {
+		region := node.Region
+		if region == "" {
+			region = gcpConfig.Region
+		}
+		clusterName := helpers.SanitizeResourceName(cluster.Name)
+
 		content, err := orchestrator.RenderTemplate("gcp/node.tf.tmpl", struct {
 			Name         string
 			Timestamp    string
@@ -685,22 +972,28 @@ func (s *ProvisioningService) createTerraformFiles(ctx context.Context, requestI
 			MachineType  string
 			Zone         string
 			Region       string
+			SubnetName   string
 			Role         string
 			Architecture string
 			DiskSizeGB   int
 			DiskType     string
+			Spot         bool
+			SpotAction   string
 		}{
 			Name:         helpers.SanitizeResourceName(node.Name),
 			Timestamp:    time.Now().Format(time.RFC3339),
 			BucketName:   gcpConfig.BucketName,
-			ClusterName:  helpers.SanitizeResourceName(cluster.Name),
+			ClusterName:  clusterName,
 			MachineType:  node.MachineType,
 			Zone:         node.Zone,
-			Region:       gcpConfig.Region,
+			Region:       region,
+			SubnetName:   gcpConfig.SubnetName(clusterName, region),
 			Role:         node.Role,
 			Architecture: "amd64",
 			DiskSizeGB:   node.DiskSizeGB,
 			DiskType:     node.DiskType,
+			Spot:         node.Spot,
+			SpotAction:   node.SpotAction,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to render template for node %s: %w", node.Name, err)
@@ -712,7 +1005,7 @@ func (s *ProvisioningService) createTerraformFiles(ctx context.Context, requestI
 		}
 	}
 
-	log.Printf("Created terraform files for %d nodes in %s", len(nodes), workDir)
+	s.logger.InfoContext(ctx, "created terraform files", "nodes", len(nodes), "work_dir", workDir)
 	return nil
 }
 
@@ -740,13 +1033,13 @@ func (s *ProvisioningService) fetchExistingNodeFiles(ctx context.Context, ghClie
 				Ref: gitopsConfig.Branch,
 			})
 			if err != nil {
-				log.Printf("Warning: failed to fetch %s: %v", item.GetName(), err)
+				s.logger.WarnContext(ctx, "failed to fetch node file", "file", item.GetName(), "error", err)
 				continue
 			}
 
 			content, err := fileContent.GetContent()
 			if err != nil {
-				log.Printf("Warning: failed to decode %s: %v", item.GetName(), err)
+				s.logger.WarnContext(ctx, "failed to decode node file", "file", item.GetName(), "error", err)
 				continue
 			}
 
@@ -759,7 +1052,7 @@ func (s *ProvisioningService) fetchExistingNodeFiles(ctx context.Context, ghClie
 
 // commitTerraformFiles commits terraform files to GitOps repo using GitHub API
 func (s *ProvisioningService) commitTerraformFiles(ctx context.Context, requestID uuid.UUID, ghClient *githubpkg.Client, gitopsConfig *models.GitOpsConfig) error {
-	provSession, ok := s.activeProvisions[requestID]
+	provSession, ok := s.getProvisionSession(requestID)
 	if !ok {
 		return fmt.Errorf("provision session not found")
 	}
@@ -772,6 +1065,7 @@ func (s *ProvisioningService) commitTerraformFiles(ctx context.Context, requestI
 	commitMessage := fmt.Sprintf("Add node configurations: %v", nodeNames)
 
 	// Commit to GitOps repository using the orchestrator
+	commitStart := time.Now()
 	committed, err := provSession.Orchestrator.CommitToGitOps(ctx, ghClient.Client, tfpkg.GitOpsConfig{
 		Owner:    gitopsConfig.RepoOwner,
 		Repo:     gitopsConfig.RepoName,
@@ -780,12 +1074,13 @@ func (s *ProvisioningService) commitTerraformFiles(ctx context.Context, requestI
 		Username: gitopsConfig.Username,
 		Email:    gitopsConfig.Email,
 	}, commitMessage)
+	metrics.ObserveGitOpsCommit("terraform_nodes", commitStart, &err)
 	if err != nil {
 		return fmt.Errorf("failed to commit to repository: %w", err)
 	}
 
 	if committed {
-		log.Printf("Committed terraform files for nodes: %v", nodeNames)
+		s.logger.InfoContext(ctx, "committed terraform files", "nodes", nodeNames)
 	}
 	return nil
 }
@@ -801,9 +1096,9 @@ func (s *ProvisioningService) uploadTalosConfigsToGCS(ctx context.Context, gcpCo
 
 	bucket := client.Bucket(gcpConfig.BucketName)
 
-	// Upload each node's Talos config
+	// Upload each node's Talos config under the name the node file reads
 	for _, node := range nodes {
-		objectName := fmt.Sprintf("talos-configs/%s.yaml", node.Name)
+		objectName := fmt.Sprintf("talos-configs/%s.yaml", helpers.SanitizeResourceName(node.Name))
 		obj := bucket.Object(objectName)
 
 		writer := obj.NewWriter(ctx)
@@ -818,7 +1113,7 @@ func (s *ProvisioningService) uploadTalosConfigsToGCS(ctx context.Context, gcpCo
 			return fmt.Errorf("failed to close writer for %s: %w", node.Name, err)
 		}
 
-		log.Printf("Uploaded Talos config to gs://%s/%s", gcpConfig.BucketName, objectName)
+		s.logger.InfoContext(ctx, "uploaded Talos config", "bucket", gcpConfig.BucketName, "object", objectName)
 	}
 
 	return nil
@@ -842,7 +1137,7 @@ func parseOutputValue(value any) (map[string]any, error) {
 
 // getTerraformOutputs retrieves instance details from terraform outputs
 func (s *ProvisioningService) getTerraformOutputs(ctx context.Context, requestID uuid.UUID) ([]InstanceDetails, error) {
-	provSession, ok := s.activeProvisions[requestID]
+	provSession, ok := s.getProvisionSession(requestID)
 	if !ok {
 		return nil, fmt.Errorf("provision session not found")
 	}
@@ -859,13 +1154,13 @@ func (s *ProvisioningService) getTerraformOutputs(ctx context.Context, requestID
 
 		outputValue, ok := outputs[outputKey]
 		if !ok {
-			log.Printf("Output key %s not found", outputKey)
+			s.logger.WarnContext(ctx, "terraform output not found", "output", outputKey)
 			continue
 		}
 
 		nodeInfo, err := parseOutputValue(outputValue)
 		if err != nil {
-			log.Printf("Failed to parse output for %s: %v", outputKey, err)
+			s.logger.WarnContext(ctx, "failed to parse terraform output", "output", outputKey, "error", err)
 			continue
 		}
 
@@ -903,3 +1198,69 @@ func (s *ProvisioningService) updatePlanOutput(requestID uuid.UUID, planOutput s
 		Where("id = ?", requestID).
 		Update("plan_output", planOutput).Error
 }
+
+// updateCostEstimate stores the estimated cost delta of the plan
+func (s *ProvisioningService) updateCostEstimate(requestID uuid.UUID, estimate *models.CostEstimate) error {
+	costJSON, err := json.Marshal(estimate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cost estimate: %w", err)
+	}
+	return s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Update("cost_estimate", datatypes.JSON(costJSON)).Error
+}
+
+// updateCheckpoint records the last completed step of a provision request
+func (s *ProvisioningService) updateCheckpoint(requestID uuid.UUID, checkpoint models.ProvisionCheckpoint) error {
+	return s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Update("checkpoint", checkpoint).Error
+}
+
+// approvePlan records the approved changes, and the approved checkpoint unless the request is further
+func (s *ProvisioningService) approvePlan(requestID uuid.UUID, planChanges map[string]string, checkpoint models.ProvisionCheckpoint) error {
+	changesJSON, err := json.Marshal(planChanges)
+	if err != nil {
+		return fmt.Errorf("failed to marshal approved changes: %w", err)
+	}
+	updates := map[string]interface{}{"plan_changes": datatypes.JSON(changesJSON)}
+	if !checkpoint.Reached(models.ProvisionCheckpointApproved) {
+		updates["checkpoint"] = models.ProvisionCheckpointApproved
+	}
+	return s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Updates(updates).Error
+}
+
+// saveNodeConfigs stores the generated node configs so the request can be resumed with the same nodes
+func (s *ProvisioningService) saveNodeConfigs(requestID uuid.UUID, nodes []NodeConfig) error {
+	nodesJSON, err := json.Marshal(nodes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node configurations: %w", err)
+	}
+	return s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Updates(map[string]interface{}{
+			"nodes":      datatypes.JSON(nodesJSON),
+			"checkpoint": models.ProvisionCheckpointConfigsUploaded,
+		}).Error
+}
+
+func (s *ProvisioningService) getProvisionSession(requestID uuid.UUID) (*ProvisionSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	provSession, ok := s.activeProvisions[requestID]
+	return provSession, ok
+}
+
+func (s *ProvisioningService) setProvisionSession(requestID uuid.UUID, provSession *ProvisionSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activeProvisions[requestID] = provSession
+}
+
+func (s *ProvisioningService) deleteProvisionSession(requestID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.activeProvisions, requestID)
+}
@@ -0,0 +1,324 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"github.com/stolos-cloud/stolos/backend/internal/models"
+	wsservices "github.com/stolos-cloud/stolos/backend/internal/services/websocket"
+	"google.golang.org/api/option"
+)
+
+// maxProvisionAttempts is the number of times a request may be started or resumed before it is failed
+const maxProvisionAttempts = 3
+
+const (
+	// provisionLeaseDuration is how long a process owns a request without renewing its lease
+	provisionLeaseDuration = 2 * time.Minute
+	// provisionLeaseRenewal is the interval at which the running process renews its lease
+	provisionLeaseRenewal = provisionLeaseDuration / 4
+)
+
+// processStartedAt is used to tell locks taken by a previous process apart from our own
+var processStartedAt = time.Now()
+
+// leaseOwner identifies this process in the lease of the provision requests it runs, unique across replicas and restarts
+var leaseOwner = terraformLockHolder() + "/" + uuid.NewString()
+
+// terraformLockInfo is the content of a terraform state lock file
+type terraformLockInfo struct {
+	ID        string    `json:"ID"`
+	Operation string    `json:"Operation"`
+	Who       string    `json:"Who"`
+	Created   time.Time `json:"Created"`
+}
+
+// terraformLockHolder returns the "Who" terraform records in the locks taken by this process
+func terraformLockHolder() string {
+	userName := ""
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s", userName, host)
+}
+
+// AttachSession returns the session of a running provision request reattached to the client,
+// or creates a new one. The boolean is true when the caller must start the workflow.
+func (s *ProvisioningService) AttachSession(requestID uuid.UUID, client *wsservices.Client) (*wsservices.ApprovalSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[requestID]; ok {
+		session.Reattach(client)
+		return session, false
+	}
+
+	session := wsservices.NewApprovalSession(requestID.String(), client)
+	if client != nil {
+		client.SetSession(session)
+	}
+	s.sessions[requestID] = session
+	return session, true
+}
+
+// IsRunning reports whether a workflow is currently running for the request in this process
+func (s *ProvisioningService) IsRunning(requestID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[requestID]
+	return ok
+}
+
+func (s *ProvisioningService) releaseSession(requestID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, requestID)
+}
+
+// RunProvisioning runs the workflow under the lease of the request and marks the request as failed if it does not complete.
+// A request leased by another backend replica is left to it.
+func (s *ProvisioningService) RunProvisioning(ctx context.Context, requestID uuid.UUID, req models.GCPNodeProvisionRequest, session *wsservices.ApprovalSession) {
+	claimed, err := s.claimLease(requestID)
+	if err != nil || !claimed {
+		s.releaseSession(requestID)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to claim the lease of the provision request", "provision_request_id", requestID.String(), "error", err)
+		}
+		session.SendErrorString("The provision request is run by another backend replica, reconnect later to follow it")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		s.renewLease(ctx, cancel, requestID)
+	}()
+	defer func() {
+		cancel()
+		<-renewed
+		s.releaseLease(requestID)
+	}()
+
+	if err := s.ProvisionNodes(ctx, requestID, req, session); err != nil {
+		session.SendErrorString(fmt.Sprintf("Provisioning failed: %v", err))
+		session.SendStatus("failed")
+		s.failProvision(requestID, err)
+	}
+}
+
+// failProvision marks a provision request as failed
+func (s *ProvisioningService) failProvision(requestID uuid.UUID, cause error) {
+	if err := s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Updates(map[string]interface{}{
+			"status": models.ProvisionStatusFailed,
+			"error":  cause.Error(),
+		}).Error; err != nil {
+		s.logger.Warn("failed to mark provision request as failed", "provision_request_id", requestID.String(), "error", err)
+	}
+}
+
+// claimLease makes this process the owner of the request, unless another process holds an unexpired lease on it
+func (s *ProvisioningService) claimLease(requestID uuid.UUID) (bool, error) {
+	now := time.Now()
+	result := s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ?", requestID).
+		Where("lease_owner = ? OR lease_expiry IS NULL OR lease_expiry < ?", leaseOwner, now).
+		Updates(map[string]interface{}{
+			"lease_owner":  leaseOwner,
+			"lease_expiry": now.Add(provisionLeaseDuration),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// renewLease extends the lease of the request until ctx is done, and cancels the workflow if another process took it over
+func (s *ProvisioningService) renewLease(ctx context.Context, cancel context.CancelFunc, requestID uuid.UUID) {
+	ticker := time.NewTicker(provisionLeaseRenewal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result := s.db.Model(&models.ProvisionRequest{}).
+				Where("id = ? AND lease_owner = ?", requestID, leaseOwner).
+				Update("lease_expiry", time.Now().Add(provisionLeaseDuration))
+			if result.Error != nil {
+				// The lease is still valid for a while, the next renewal may succeed
+				s.logger.WarnContext(ctx, "failed to renew the lease of the provision request", "error", result.Error)
+				continue
+			}
+			if result.RowsAffected == 0 {
+				s.logger.ErrorContext(ctx, "lost the lease of the provision request, stopping the workflow")
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// releaseLease lets another process take the request over right away
+func (s *ProvisioningService) releaseLease(requestID uuid.UUID) {
+	if err := s.db.Model(&models.ProvisionRequest{}).
+		Where("id = ? AND lease_owner = ?", requestID, leaseOwner).
+		Updates(map[string]interface{}{
+			"lease_owner":  "",
+			"lease_expiry": nil,
+		}).Error; err != nil {
+		s.logger.Warn("failed to release the lease of the provision request", "provision_request_id", requestID.String(), "error", err)
+	}
+}
+
+// ResumeIncompleteRequests recovers provision requests left unfinished by a backend process that stopped.
+// Only requests whose lease expired are taken over, the others are still run by a live replica.
+// Approved requests are resumed in the background, and wait for a new approval if their plan has changes
+// that were not approved. Requests that were not approved yet had no side
+// effects besides uploaded Talos configs, so they go back to pending and are re-planned when a client reattaches.
+func (s *ProvisioningService) ResumeIncompleteRequests(ctx context.Context) error {
+	var requests []models.ProvisionRequest
+	if err := s.db.Where("provider = ? AND status IN ?", "gcp", []models.ProvisionRequestStatus{
+		models.ProvisionStatusPlanning,
+		models.ProvisionStatusAwaitingApproval,
+		models.ProvisionStatusApplying,
+	}).Find(&requests).Error; err != nil {
+		return fmt.Errorf("failed to list incomplete provision requests: %w", err)
+	}
+
+	// The lock of the nodes state is only released for the processes whose requests were taken over
+	var claimed []models.ProvisionRequest
+	holders := make(map[string]bool)
+	for _, pr := range requests {
+		ok, err := s.claimLease(pr.ID)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to claim the lease of the provision request", "provision_request_id", pr.ID.String(), "error", err)
+			continue
+		}
+		if !ok {
+			s.logger.InfoContext(ctx, "provision request is run by another replica, leaving it", "provision_request_id", pr.ID.String())
+			continue
+		}
+		claimed = append(claimed, pr)
+		if pr.LockHolder != "" {
+			holders[pr.LockHolder] = true
+		}
+	}
+
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	if err := s.releaseStaleLock(ctx, holders); err != nil {
+		s.logger.WarnContext(ctx, "failed to release stale terraform lock", "error", err)
+	}
+
+	for _, pr := range claimed {
+		switch {
+		case pr.Attempts >= maxProvisionAttempts:
+			s.logger.WarnContext(ctx, "provision request interrupted too many times, marking it as failed", "provision_request_id", pr.ID.String(), "attempts", pr.Attempts)
+			s.failProvision(pr.ID, fmt.Errorf("provisioning interrupted %d times at checkpoint '%s', giving up", pr.Attempts, pr.Checkpoint))
+			s.releaseLease(pr.ID)
+
+		case pr.Checkpoint.Reached(models.ProvisionCheckpointApproved):
+			var req models.GCPNodeProvisionRequest
+			if err := json.Unmarshal(pr.Request, &req); err != nil {
+				s.failProvision(pr.ID, fmt.Errorf("failed to parse provision request: %w", err))
+				s.releaseLease(pr.ID)
+				continue
+			}
+
+			// RunProvisioning keeps the lease claimed above
+			session, start := s.AttachSession(pr.ID, nil)
+			if !start {
+				continue
+			}
+			s.logger.InfoContext(ctx, "resuming provision request", "provision_request_id", pr.ID.String(), "checkpoint", pr.Checkpoint)
+			go s.RunProvisioning(context.Background(), pr.ID, req, session)
+
+		default:
+			checkpoint := models.ProvisionCheckpointNone
+			if pr.Checkpoint.Reached(models.ProvisionCheckpointConfigsUploaded) {
+				checkpoint = models.ProvisionCheckpointConfigsUploaded
+			}
+			s.logger.InfoContext(ctx, "provision request interrupted before approval, resetting it to pending", "provision_request_id", pr.ID.String())
+			if err := s.db.Model(&models.ProvisionRequest{}).
+				Where("id = ?", pr.ID).
+				Updates(map[string]interface{}{
+					"status":     models.ProvisionStatusPending,
+					"checkpoint": checkpoint,
+				}).Error; err != nil {
+				s.logger.WarnContext(ctx, "failed to reset provision request", "provision_request_id", pr.ID.String(), "error", err)
+			}
+			s.releaseLease(pr.ID)
+		}
+	}
+
+	return nil
+}
+
+// releaseStaleLock removes the nodes state lock if it was taken by one of the given holders
+// before this process started, meaning the operation holding it was interrupted.
+func (s *ProvisioningService) releaseStaleLock(ctx context.Context, holders map[string]bool) error {
+	if len(holders) == 0 {
+		return nil
+	}
+
+	gcpConfig, err := s.gcpService.GetCurrentConfigWithCredentials()
+	if err != nil {
+		return fmt.Errorf("failed to get GCP config: %w", err)
+	}
+
+	client, err := storage.NewClient(ctx, option.WithCredentialsJSON([]byte(gcpConfig.ServiceAccountKeyJSON)))
+	if err != nil {
+		return fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	defer client.Close()
+
+	obj := client.Bucket(gcpConfig.BucketName).Object(path.Join(NodesStatePrefix, "default.tflock"))
+	attrs, err := obj.Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read lock attributes: %w", err)
+	}
+
+	reader, err := obj.NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read lock: %w", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read lock: %w", err)
+	}
+
+	var lock terraformLockInfo
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return fmt.Errorf("failed to parse lock: %w", err)
+	}
+
+	if !holders[lock.Who] || !lock.Created.Before(processStartedAt) {
+		s.logger.InfoContext(ctx, "terraform lock is not ours, leaving it", "lock_id", lock.ID, "holder", lock.Who)
+		return nil
+	}
+
+	// Same as terraform force-unlock on the gcs backend, guarded against a concurrent relock
+	if err := obj.If(storage.Conditions{GenerationMatch: attrs.Generation}).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete lock %s: %w", lock.ID, err)
+	}
+
+	s.logger.InfoContext(ctx, "released stale terraform lock", "lock_id", lock.ID, "operation", lock.Operation, "holder", lock.Who, "created", lock.Created.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,309 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stolos-cloud/stolos/backend/internal/helpers"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/models"
+	"github.com/stolos-cloud/stolos/backend/pkg/gcp"
+	"gorm.io/gorm"
+)
+
+// SpotNodeLabel is the label and NoSchedule taint key of spot nodes, workloads must tolerate it to land there
+const SpotNodeLabel = "stolos.cloud/spot"
+
+const (
+	// spotRecoveryBackoff is the delay between two recovery attempts of a preempted node
+	spotRecoveryBackoff = 5 * time.Minute
+	// maxSpotRecoveryAttempts is the number of recovery attempts before a preemption is marked as failed
+	maxSpotRecoveryAttempts = 6
+	// spotRecoveryTimeout is how long a restarted or replaced node may take to be Ready again
+	spotRecoveryTimeout = 20 * time.Minute
+	// instanceMissing is recorded as the instance status of deleted instances
+	instanceMissing = "MISSING"
+)
+
+// Preemption recovery actions
+const (
+	PreemptionActionRestart = "restart"
+	PreemptionActionReplace = "replace"
+)
+
+type SpotService struct {
+	db                  *gorm.DB
+	gcpService          *GCPService
+	provisioningService *ProvisioningService
+	logger              *slog.Logger
+}
+
+func NewSpotService(db *gorm.DB, gcpService *GCPService, provisioningService *ProvisioningService, logs *logging.Logging) *SpotService {
+	return &SpotService{
+		db:                  db,
+		gcpService:          gcpService,
+		provisioningService: provisioningService,
+		logger:              logs.Logger(logging.SubsystemGCP),
+	}
+}
+
+// ValidateSpotRequest checks the spot options of a provision request and defaults the termination action
+func ValidateSpotRequest(req *models.GCPNodeProvisionRequest) error {
+	if !req.Spot {
+		if req.SpotAction != "" {
+			return fmt.Errorf("spot_termination_action requires spot")
+		}
+		return nil
+	}
+	if req.Role != "worker" {
+		return fmt.Errorf("spot instances can only be used for worker nodes")
+	}
+	switch req.SpotAction {
+	case "":
+		req.SpotAction = models.SpotActionStop
+	case models.SpotActionStop, models.SpotActionDelete:
+	default:
+		return fmt.Errorf("spot_termination_action must be '%s' or '%s'", models.SpotActionStop, models.SpotActionDelete)
+	}
+	return nil
+}
+
+// CheckPreemptions looks for preempted spot nodes and recovers them.
+// Spot nodes reported NotReady by the node status job are checked against their instance status:
+// stopped instances are restarted and deleted ones are recreated from their node file.
+// It returns the preemptions detected during this run.
+func (s *SpotService) CheckPreemptions(ctx context.Context) ([]models.NodePreemption, error) {
+	if !s.gcpService.IsConfigured() {
+		return nil, nil
+	}
+
+	var nodes []models.Node
+	if err := s.db.Where("provider = ? AND spot = ?", "gcp", true).Find(&nodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list spot nodes: %w", err)
+	}
+
+	var open []models.NodePreemption
+	if err := s.db.Where("status = ?", models.PreemptionStatusRecovering).Find(&open).Error; err != nil {
+		return nil, fmt.Errorf("failed to list open preemptions: %w", err)
+	}
+	openByNode := make(map[uuid.UUID]*models.NodePreemption, len(open))
+	for i := range open {
+		openByNode[open[i].NodeID] = &open[i]
+	}
+
+	// Only query GCP when a spot node is NotReady or still recovering
+	suspects := make([]*models.Node, 0)
+	for i := range nodes {
+		if nodes[i].Status == models.StatusFailed || openByNode[nodes[i].ID] != nil {
+			suspects = append(suspects, &nodes[i])
+		}
+	}
+	if len(suspects) == 0 {
+		return nil, nil
+	}
+
+	client, err := s.gcpService.GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP client: %w", err)
+	}
+
+	instancesByZone, err := client.ListAllInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	instanceStatus := make(map[string]string)
+	instanceZone := make(map[string]string)
+	for zone, instances := range instancesByZone {
+		for _, instance := range instances {
+			instanceStatus[instance.Name] = instance.Status
+			instanceZone[instance.Name] = zone
+		}
+	}
+
+	var detected []models.NodePreemption
+	for _, node := range suspects {
+		status, found := instanceStatus[node.Name]
+		if !found {
+			status = instanceMissing
+		}
+		if node.Zone == "" && found {
+			node.Zone = instanceZone[node.Name]
+			s.db.Model(node).Update("zone", node.Zone)
+		}
+
+		preemption := openByNode[node.ID]
+		if preemption == nil {
+			if status != "TERMINATED" && status != instanceMissing {
+				// NotReady for another reason, or already coming back
+				continue
+			}
+			preemption = &models.NodePreemption{
+				NodeID:         node.ID,
+				NodeName:       node.Name,
+				Zone:           node.Zone,
+				InstanceStatus: status,
+				NodeNotReady:   node.Status == models.StatusFailed,
+				Status:         models.PreemptionStatusRecovering,
+				DetectedAt:     time.Now(),
+			}
+			if err := s.db.Create(preemption).Error; err != nil {
+				s.logger.WarnContext(ctx, "failed to record preemption", "node", node.Name, "error", err)
+				continue
+			}
+			s.logger.WarnContext(ctx, "spot node was preempted", "node", node.Name, "instance_status", status)
+			detected = append(detected, *preemption)
+		}
+
+		switch {
+		case status == "RUNNING" && node.Status == models.StatusActive:
+			now := time.Now()
+			preemption.Status = models.PreemptionStatusRecovered
+			preemption.RecoveredAt = &now
+			preemption.Error = ""
+			if err := s.db.Save(preemption).Error; err != nil {
+				s.logger.WarnContext(ctx, "failed to update preemption", "node", node.Name, "error", err)
+			}
+			s.logger.InfoContext(ctx, "spot node recovered", "node", node.Name, "downtime", now.Sub(preemption.DetectedAt).Round(time.Second))
+
+		case status == "TERMINATED" || status == instanceMissing:
+			if preemption.LastAttemptAt != nil && time.Since(*preemption.LastAttemptAt) < spotRecoveryBackoff {
+				continue
+			}
+			s.recoverNode(ctx, client, node, preemption, status)
+
+		case node.Status == models.StatusProvisioning && preemption.LastAttemptAt != nil && time.Since(*preemption.LastAttemptAt) > spotRecoveryTimeout:
+			s.failRecovery(ctx, node, preemption, fmt.Errorf("node not Ready %s after the %s of its instance", spotRecoveryTimeout, preemption.Action))
+		}
+	}
+
+	return detected, nil
+}
+
+// ListPreemptions returns the preemption history of a node, most recent first
+func (s *SpotService) ListPreemptions(nodeID uuid.UUID) ([]models.NodePreemption, error) {
+	var preemptions []models.NodePreemption
+	if err := s.db.Where("node_id = ?", nodeID).Order("detected_at DESC").Find(&preemptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list preemptions: %w", err)
+	}
+	return preemptions, nil
+}
+
+// recoverNode restarts a stopped instance or replaces a deleted one
+func (s *SpotService) recoverNode(ctx context.Context, client *gcp.Client, node *models.Node, preemption *models.NodePreemption, status string) {
+	now := time.Now()
+	preemption.Attempts++
+	preemption.LastAttemptAt = &now
+
+	var err error
+	if status == instanceMissing {
+		preemption.Action = PreemptionActionReplace
+		err = s.replaceInstance(ctx, client, node)
+	} else {
+		preemption.Action = PreemptionActionRestart
+		err = client.StartInstance(ctx, node.Zone, node.Name)
+	}
+
+	if err != nil {
+		preemption.Error = err.Error()
+		s.logger.WarnContext(ctx, "failed to recover preempted node", "node", node.Name, "action", preemption.Action, "attempt", preemption.Attempts, "error", err)
+		if preemption.Attempts >= maxSpotRecoveryAttempts {
+			s.failRecovery(ctx, node, preemption, err)
+			return
+		}
+	} else {
+		preemption.Error = ""
+		// The node status job marks the node active again once it is Ready
+		if err := s.db.Model(node).Update("status", models.StatusProvisioning).Error; err != nil {
+			s.logger.WarnContext(ctx, "failed to update node status", "node", node.Name, "error", err)
+		}
+		s.logger.InfoContext(ctx, "started recovery of preempted node", "node", node.Name, "action", preemption.Action)
+	}
+
+	if err := s.db.Save(preemption).Error; err != nil {
+		s.logger.WarnContext(ctx, "failed to update preemption", "node", node.Name, "error", err)
+	}
+}
+
+// failRecovery gives up on a preempted node: the preemption and the node are marked as failed
+func (s *SpotService) failRecovery(ctx context.Context, node *models.Node, preemption *models.NodePreemption, cause error) {
+	s.logger.ErrorContext(ctx, "gave up recovering preempted node", "node", node.Name, "action", preemption.Action, "attempts", preemption.Attempts, "error", cause)
+
+	preemption.Status = models.PreemptionStatusFailed
+	preemption.Error = cause.Error()
+	if err := s.db.Save(preemption).Error; err != nil {
+		s.logger.WarnContext(ctx, "failed to update preemption", "node", node.Name, "error", err)
+	}
+	if err := s.db.Model(node).Update("status", models.StatusFailed).Error; err != nil {
+		s.logger.WarnContext(ctx, "failed to update node status", "node", node.Name, "error", err)
+	}
+}
+
+// replaceInstance recreates a deleted instance by applying its node module.
+// The instance boots with the Talos config kept in the bucket and rejoins under the same name.
+func (s *SpotService) replaceInstance(ctx context.Context, client *gcp.Client, node *models.Node) error {
+	gcpConfig, err := s.gcpService.GetCurrentConfigWithCredentials()
+	if err != nil {
+		return fmt.Errorf("failed to get GCP config: %w", err)
+	}
+
+	gitopsConfig, err := s.provisioningService.gitopsService.GetConfigOrDefault()
+	if err != nil {
+		return fmt.Errorf("failed to get GitOps config: %w", err)
+	}
+
+	ghClient, err := s.provisioningService.gitopsService.GetGitHubClient()
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	// Render the existing node files only
+	workID := uuid.New()
+	if err := s.provisioningService.createTerraformFiles(ctx, workID, gcpConfig, nil, ghClient, gitopsConfig); err != nil {
+		return fmt.Errorf("failed to create terraform files: %w", err)
+	}
+
+	provSession, ok := s.provisioningService.getProvisionSession(workID)
+	if !ok {
+		return fmt.Errorf("provision session not found")
+	}
+	defer func() {
+		os.RemoveAll(provSession.WorkDir)
+		s.provisioningService.deleteProvisionSession(workID)
+	}()
+
+	sanitizedName := helpers.SanitizeResourceName(node.Name)
+	nodeFile := fmt.Sprintf("node-%s.tf", sanitizedName)
+	if _, err := os.Stat(filepath.Join(provSession.Orchestrator.WorkDir(), nodeFile)); err != nil {
+		return fmt.Errorf("node file %s not found in the GitOps repository", nodeFile)
+	}
+
+	if err := provSession.Orchestrator.Init(ctx); err != nil {
+		return fmt.Errorf("terraform init failed: %w", err)
+	}
+
+	if err := provSession.Orchestrator.ApplyTargets(ctx, "module."+sanitizedName); err != nil {
+		return err
+	}
+
+	instance, err := client.GetInstance(ctx, node.Zone, node.Name)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to read replaced instance", "node", node.Name, "error", err)
+		return nil
+	}
+
+	updates := map[string]interface{}{
+		"instance_id": fmt.Sprintf("%d", instance.Id),
+	}
+	if len(instance.NetworkInterfaces) > 0 {
+		updates["ip_address"] = instance.NetworkInterfaces[0].NetworkIP
+	}
+	if err := s.db.Model(node).Updates(updates).Error; err != nil {
+		s.logger.WarnContext(ctx, "failed to update node after replacement", "node", node.Name, "error", err)
+	}
+	return nil
+}
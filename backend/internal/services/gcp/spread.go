@@ -0,0 +1,165 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"github.com/stolos-cloud/stolos/backend/internal/models"
+)
+
+// Well-known node labels used by the scheduler for topology spreading
+const (
+	TopologyZoneLabel   = "topology.kubernetes.io/zone"
+	TopologyRegionLabel = "topology.kubernetes.io/region"
+)
+
+// MaxAdditionalRegions bounds the subnets carved out of 172.16.0.0/12, the range kubelets pick node IPs from
+const MaxAdditionalRegions = 15
+
+// ZoneRegion returns the region of a zone name or URL (us-central1-a -> us-central1)
+func ZoneRegion(zone string) string {
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// SubnetCIDR returns the range of the subnet of the i-th region (0 is the primary region)
+func SubnetCIDR(i int) string {
+	offset := i * 16
+	return fmt.Sprintf("172.%d.%d.0/20", 16+offset/256, offset%256)
+}
+
+// ResolveZones returns the zones a provision request spreads its nodes across.
+// Zones must be in the cached GCP resources, belong to a configured region and offer the machine type.
+func ResolveZones(req models.GCPNodeProvisionRequest, gcpConfig *models.GCPConfig, resources *config.GCPResources) ([]string, error) {
+	if resources == nil || len(resources.Zones) == 0 {
+		return nil, fmt.Errorf("no cached GCP zones, refresh the GCP resources first")
+	}
+
+	configuredRegions := gcpConfig.Regions()
+
+	var zones []string
+	expanded := false
+	switch {
+	case len(req.Zones) > 0:
+		zones = req.Zones
+	case len(req.Regions) > 0:
+		expanded = true
+		for _, region := range req.Regions {
+			if !slices.Contains(configuredRegions, region) {
+				return nil, fmt.Errorf("region %s is not configured, configured regions: %s", region, strings.Join(configuredRegions, ", "))
+			}
+			found := false
+			for _, zone := range resources.Zones {
+				if ZoneRegion(zone) == region {
+					zones = append(zones, zone)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no cached zones for region %s, refresh the GCP resources", region)
+			}
+		}
+	case req.Zone != "":
+		zones = []string{req.Zone}
+	default:
+		return nil, fmt.Errorf("one of zone, zones or regions is required")
+	}
+
+	var resolved []string
+	for _, zone := range zones {
+		if slices.Contains(resolved, zone) {
+			continue
+		}
+		if !slices.Contains(resources.Zones, zone) {
+			return nil, fmt.Errorf("zone %s is not available, refresh the GCP resources if it was added recently", zone)
+		}
+		if !slices.Contains(configuredRegions, ZoneRegion(zone)) {
+			return nil, fmt.Errorf("zone %s is in region %s which has no cluster subnet", zone, ZoneRegion(zone))
+		}
+		if machineTypes, ok := resources.MachineTypesByZone[zone]; ok {
+			if !slices.ContainsFunc(machineTypes, func(mt config.GCPMachineType) bool { return mt.Name == req.MachineType }) {
+				if !expanded {
+					return nil, fmt.Errorf("machine type %s is not available in zone %s", req.MachineType, zone)
+				}
+				// Skip zones of a region that lack the machine type
+				continue
+			}
+		}
+		resolved = append(resolved, zone)
+	}
+
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("machine type %s is not available in any zone of regions %s", req.MachineType, strings.Join(req.Regions, ", "))
+	}
+	return resolved, nil
+}
+
+// SpreadZones assigns a zone to each of count new nodes.
+// existing holds the number of nodes of the same role already in each zone, used by the balanced policy.
+func SpreadZones(zones []string, existing map[string]int, count int, policy string) ([]string, error) {
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("no zones to spread nodes across")
+	}
+
+	assigned := make([]string, 0, count)
+	switch policy {
+	case models.SpreadPolicyRoundRobin:
+		for i := 0; i < count; i++ {
+			assigned = append(assigned, zones[i%len(zones)])
+		}
+	case "", models.SpreadPolicyBalanced:
+		counts := make(map[string]int, len(zones))
+		for _, zone := range zones {
+			counts[zone] = existing[zone]
+		}
+		for i := 0; i < count; i++ {
+			// Ties go to the first zone in request order
+			best := zones[0]
+			for _, zone := range zones[1:] {
+				if counts[zone] < counts[best] {
+					best = zone
+				}
+			}
+			counts[best]++
+			assigned = append(assigned, best)
+		}
+	default:
+		return nil, fmt.Errorf("unknown spread policy %q, must be one of: %s, %s", policy, models.SpreadPolicyBalanced, models.SpreadPolicyRoundRobin)
+	}
+
+	return assigned, nil
+}
+
+// nodesPerZone counts the nodes of a role by zone, falling back to their topology zone label
+func nodesPerZone(nodes []models.Node, role string) map[string]int {
+	counts := make(map[string]int)
+	prefix := TopologyZoneLabel + "="
+	for _, node := range nodes {
+		if node.Role != role {
+			continue
+		}
+		if node.Zone != "" {
+			counts[node.Zone]++
+			continue
+		}
+		if node.Labels == "" {
+			continue
+		}
+		var labels []string
+		if err := json.Unmarshal([]byte(node.Labels), &labels); err != nil {
+			continue
+		}
+		for _, label := range labels {
+			if zone, ok := strings.CutPrefix(label, prefix); ok {
+				counts[zone]++
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,339 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/models"
+	"github.com/stolos-cloud/stolos/backend/pkg/gcp"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	"gorm.io/gorm"
+)
+
+// Remote state prefixes, must match the gcs backends of the terraform templates
+const (
+	InfrastructureStatePrefix = "infrastructure/state"
+	NodesStatePrefix          = "nodes/state"
+)
+
+var statePrefixes = map[string]string{
+	"infrastructure": InfrastructureStatePrefix,
+	"nodes":          NodesStatePrefix,
+}
+
+const redactedValue = "(sensitive)"
+
+// rawTerraformState is the on-disk (version 4) format of a terraform state
+type rawTerraformState struct {
+	Version          int    `json:"version"`
+	TerraformVersion string `json:"terraform_version"`
+	Serial           int64  `json:"serial"`
+	Lineage          string `json:"lineage"`
+	Resources        []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Provider  string `json:"provider"`
+		Instances []struct {
+			IndexKey            any               `json:"index_key"`
+			Attributes          map[string]any    `json:"attributes"`
+			SensitiveAttributes []json.RawMessage `json:"sensitive_attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+type GCPStateService struct {
+	db                  *gorm.DB
+	gcpService          *GCPService
+	provisioningService *ProvisioningService
+	logger              *slog.Logger
+}
+
+func NewGCPStateService(db *gorm.DB, gcpService *GCPService, provisioningService *ProvisioningService, logs *logging.Logging) *GCPStateService {
+	return &GCPStateService{
+		db:                  db,
+		gcpService:          gcpService,
+		provisioningService: provisioningService,
+		logger:              logs.Logger(logging.SubsystemGCP),
+	}
+}
+
+// GetState reads a remote terraform state ("nodes" or "infrastructure") from the GCS backend
+func (s *GCPStateService) GetState(ctx context.Context, state string) (*models.TerraformStateSummary, error) {
+	prefix, ok := statePrefixes[state]
+	if !ok {
+		return nil, fmt.Errorf("unknown state %q, must be one of: infrastructure, nodes", state)
+	}
+
+	backendConfig, err := s.gcpService.GetTerraformBackendConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	gcpConfig, err := s.gcpService.GetCurrentConfigWithCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP config: %w", err)
+	}
+
+	client, err := storage.NewClient(ctx, option.WithCredentialsJSON([]byte(gcpConfig.ServiceAccountKeyJSON)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	defer client.Close()
+
+	objectName := path.Join(prefix, "default.tfstate")
+	summary := &models.TerraformStateSummary{
+		State:     state,
+		Bucket:    backendConfig["bucket"],
+		Object:    objectName,
+		Resources: []models.TerraformStateResource{},
+	}
+
+	reader, err := client.Bucket(backendConfig["bucket"]).Object(objectName).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		// Nothing applied yet
+		return summary, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s: %w", objectName, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s: %w", objectName, err)
+	}
+
+	var raw rawTerraformState
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", objectName, err)
+	}
+
+	summary.TerraformVersion = raw.TerraformVersion
+	summary.Serial = raw.Serial
+	summary.Lineage = raw.Lineage
+
+	for _, resource := range raw.Resources {
+		for _, instance := range resource.Instances {
+			attributes := redactSensitiveAttributes(resource.Type, instance.Attributes, instance.SensitiveAttributes)
+			id, _ := attributes["id"].(string)
+			summary.Resources = append(summary.Resources, models.TerraformStateResource{
+				Address:    stateAddress(resource.Module, resource.Mode, resource.Type, resource.Name, instance.IndexKey),
+				Module:     resource.Module,
+				Mode:       resource.Mode,
+				Type:       resource.Type,
+				Name:       resource.Name,
+				Provider:   resource.Provider,
+				ID:         id,
+				Attributes: attributes,
+			})
+		}
+	}
+
+	return summary, nil
+}
+
+// ListStateResources returns the resources of a state without their attributes
+func (s *GCPStateService) ListStateResources(ctx context.Context, state string) (*models.TerraformStateSummary, error) {
+	summary, err := s.GetState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	for i := range summary.Resources {
+		summary.Resources[i].Attributes = nil
+	}
+	return summary, nil
+}
+
+// GetStateResource returns a single resource of a state with its attributes
+func (s *GCPStateService) GetStateResource(ctx context.Context, state, address string) (*models.TerraformStateResource, error) {
+	summary, err := s.GetState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range summary.Resources {
+		if resource.Address == address {
+			return &resource, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// CompareInstances compares the instances in the nodes state with node records and live GCP instances
+func (s *GCPStateService) CompareInstances(ctx context.Context) ([]models.GCPInstanceComparison, error) {
+	summary, err := s.GetState(ctx, "nodes")
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.gcpService.GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP client: %w", err)
+	}
+
+	instancesByZone, err := client.ListAllInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	var nodes []models.Node
+	if err := s.db.Where("provider = ?", "gcp").Find(&nodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	comparisons := make(map[string]*models.GCPInstanceComparison)
+	get := func(name string) *models.GCPInstanceComparison {
+		if c, ok := comparisons[name]; ok {
+			return c
+		}
+		c := &models.GCPInstanceComparison{Name: name}
+		comparisons[name] = c
+		return c
+	}
+
+	for _, resource := range summary.Resources {
+		if resource.Type != "google_compute_instance" || resource.Mode != "managed" {
+			continue
+		}
+		name, _ := resource.Attributes["name"].(string)
+		if name == "" {
+			continue
+		}
+		c := get(name)
+		c.InState = true
+		c.StateAddress = resource.Address
+		if zone, ok := resource.Attributes["zone"].(string); ok {
+			c.Zone = zone
+		}
+	}
+
+	for _, node := range nodes {
+		c := get(node.Name)
+		c.InDatabase = true
+		nodeID := node.ID
+		c.NodeID = &nodeID
+	}
+
+	for zone, instances := range instancesByZone {
+		for _, instance := range instances {
+			c := get(instance.Name)
+			c.Live = true
+			c.Zone = zone
+			c.LiveStatus = instance.Status
+			c.MachineType = path.Base(instance.MachineType)
+			c.IsTalos = IsTalosInstance(ctx, client, zone, instance)
+		}
+	}
+
+	result := make([]models.GCPInstanceComparison, 0, len(comparisons))
+	for _, c := range comparisons {
+		switch {
+		case !c.Live:
+			c.Status = models.InstanceMissing
+		case !c.InState:
+			c.Status = models.InstanceUnmanaged
+			c.Importable = c.IsTalos
+		case !c.InDatabase:
+			c.Status = models.InstanceUntracked
+		default:
+			c.Status = models.InstanceManaged
+		}
+		result = append(result, *c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// IsTalosInstance reports whether the instance boots from a Talos image
+func IsTalosInstance(ctx context.Context, client *gcp.Client, zone string, instance *compute.Instance) bool {
+	for _, attached := range instance.Disks {
+		if !attached.Boot {
+			continue
+		}
+		for _, license := range attached.Licenses {
+			if strings.Contains(strings.ToLower(license), "talos") {
+				return true
+			}
+		}
+		disk, err := client.GetDisk(ctx, zone, path.Base(attached.Source))
+		if err != nil {
+			return false
+		}
+		return strings.Contains(strings.ToLower(disk.SourceImage), "talos")
+	}
+	return false
+}
+
+// stateAddress builds the terraform address of a resource instance
+func stateAddress(module, mode, resourceType, name string, indexKey any) string {
+	address := fmt.Sprintf("%s.%s", resourceType, name)
+	if mode == "data" {
+		address = "data." + address
+	}
+	if module != "" {
+		address = module + "." + address
+	}
+	switch key := indexKey.(type) {
+	case string:
+		address += fmt.Sprintf("[%q]", key)
+	case float64:
+		address += fmt.Sprintf("[%d]", int(key))
+	}
+	return address
+}
+
+// redactSensitiveAttributes hides top-level attributes marked sensitive and Talos machine configs
+func redactSensitiveAttributes(resourceType string, attributes map[string]any, sensitive []json.RawMessage) map[string]any {
+	result := make(map[string]any, len(attributes))
+	for key, value := range attributes {
+		result[key] = value
+	}
+
+	for _, rawPath := range sensitive {
+		var steps []struct {
+			Type  string `json:"type"`
+			Value any    `json:"value"`
+		}
+		if err := json.Unmarshal(rawPath, &steps); err != nil || len(steps) == 0 {
+			continue
+		}
+		if key, ok := steps[0].Value.(string); ok {
+			if _, exists := result[key]; exists {
+				result[key] = redactedValue
+			}
+		}
+	}
+
+	// Machine configs contain cluster secrets
+	if resourceType == "google_storage_bucket_object_content" {
+		if _, ok := result["content"]; ok {
+			result["content"] = redactedValue
+		}
+	}
+	if metadata, ok := result["metadata"].(map[string]any); ok {
+		if _, ok := metadata["user-data"]; ok {
+			copied := make(map[string]any, len(metadata))
+			for key, value := range metadata {
+				copied[key] = value
+			}
+			copied["user-data"] = redactedValue
+			result["metadata"] = copied
+		}
+	}
+
+	return result
+}
@@ -3,25 +3,31 @@ package gitops
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v74/github"
 	"github.com/google/uuid"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	githubpkg "github.com/stolos-cloud/stolos/backend/pkg/github"
 	"gorm.io/gorm"
 )
 
 type GitOpsService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db     *gorm.DB
+	cfg    *config.Config
+	logger *slog.Logger
 }
 
-func NewGitOpsService(db *gorm.DB, cfg *config.Config) *GitOpsService {
+func NewGitOpsService(db *gorm.DB, cfg *config.Config, logs *logging.Logging) *GitOpsService {
 	return &GitOpsService{
-		db:  db,
-		cfg: cfg,
+		db:     db,
+		cfg:    cfg,
+		logger: logs.Logger(logging.SubsystemGitOps),
 	}
 }
 
@@ -258,7 +264,9 @@ func GetDefaultBranchHeadRef(ctx context.Context, gh *github.Client, owner, repo
 
 // DuplicateDirectory copies all blobs under srcPrefix -> dstPrefix by making a single commit.
 // If overwrite is false, it aborts if any destination path already exists.
-func (s *GitOpsService) DuplicateDirectory(srcPrefix, dstPrefix string, overwrite bool) error {
+func (s *GitOpsService) DuplicateDirectory(srcPrefix, dstPrefix string, overwrite bool) (err error) {
+	defer metrics.ObserveGitOpsCommit("duplicate_directory", time.Now(), &err)
+
 	ctx := context.Background()
 	gitOpsConfig, _ := s.GetConfigOrDefault()
 	owner := gitOpsConfig.RepoOwner
@@ -3,8 +3,10 @@ package gitops
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/go-github/v74/github"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 )
 
@@ -37,7 +39,9 @@ func (s *GitOpsService) CreateNamespaceDirectory(ctx context.Context, namespaceN
 }
 
 // commitFilesToGitHub commits multiple files to GitHub in a single commit using Git API
-func (s *GitOpsService) commitFilesToGitHub(ctx context.Context, ghClient *github.Client, owner, repo, branch string, files map[string]string, message string, config any) error {
+func (s *GitOpsService) commitFilesToGitHub(ctx context.Context, ghClient *github.Client, owner, repo, branch string, files map[string]string, message string, config any) (err error) {
+	defer metrics.ObserveGitOpsCommit("commit_files", time.Now(), &err)
+
 	// Get the latest commit SHA for the branch
 	ref, _, err := ghClient.Git.GetRef(ctx, owner, repo, "refs/heads/"+branch)
 	if err != nil {
@@ -112,7 +116,7 @@ func (s *GitOpsService) commitFilesToGitHub(ctx context.Context, ghClient *githu
 		return fmt.Errorf("failed to update ref: %w", err)
 	}
 
-	fmt.Printf("Successfully committed namespace manifests to %s/%s (branch: %s)\n", owner, repo, branch)
+	s.logger.InfoContext(ctx, "committed files to GitOps repo", "repo", owner+"/"+repo, "branch", branch, "files", len(files))
 	return nil
 }
 
@@ -143,7 +147,9 @@ func (s *GitOpsService) DeleteNamespaceManifests(ctx context.Context, namespaceN
 }
 
 // deleteDirectoryFromGitHub recursively deletes a directory from GitHub
-func (s *GitOpsService) deleteDirectoryFromGitHub(ctx context.Context, ghClient *github.Client, owner, repo, branch, path, message string, config *models.GitOpsConfig) error {
+func (s *GitOpsService) deleteDirectoryFromGitHub(ctx context.Context, ghClient *github.Client, owner, repo, branch, path, message string, config *models.GitOpsConfig) (err error) {
+	defer metrics.ObserveGitOpsCommit("delete_directory", time.Now(), &err)
+
 	// Get the latest commit SHA for the branch
 	ref, _, err := ghClient.Git.GetRef(ctx, owner, repo, "refs/heads/"+branch)
 	if err != nil {
@@ -213,7 +219,7 @@ func (s *GitOpsService) deleteDirectoryFromGitHub(ctx context.Context, ghClient
 		return fmt.Errorf("failed to update ref: %w", err)
 	}
 
-	fmt.Printf("Successfully deleted namespace directory %s from %s/%s (branch: %s)\n", path, owner, repo, branch)
+	s.logger.InfoContext(ctx, "deleted directory from GitOps repo", "path", path, "repo", owner+"/"+repo, "branch", branch)
 	return nil
 }
 
@@ -255,7 +261,7 @@ func (s *GitOpsService) CreateDeploymentFile(ctx context.Context, namespace, dep
 		return fmt.Errorf("failed to commit deployment file: %w", err)
 	}
 
-	fmt.Printf("Successfully created deployment file %s\n", filePath)
+	s.logger.InfoContext(ctx, "created deployment file", "path", filePath)
 	return nil
 }
 
@@ -281,12 +287,14 @@ func (s *GitOpsService) DeleteDeploymentFile(ctx context.Context, namespace, dep
 		return fmt.Errorf("failed to delete deployment file: %w", err)
 	}
 
-	fmt.Printf("Successfully deleted deployment file %s\n", filePath)
+	s.logger.InfoContext(ctx, "deleted deployment file", "path", filePath)
 	return nil
 }
 
 // deleteFileFromGitHub deletes a single file from GitHub
-func (s *GitOpsService) deleteFileFromGitHub(ctx context.Context, ghClient *github.Client, owner, repo, branch, filePath, message string, config *models.GitOpsConfig) error {
+func (s *GitOpsService) deleteFileFromGitHub(ctx context.Context, ghClient *github.Client, owner, repo, branch, filePath, message string, config *models.GitOpsConfig) (err error) {
+	defer metrics.ObserveGitOpsCommit("delete_file", time.Now(), &err)
+
 	// Get the file to obtain its SHA
 	fileContent, _, _, err := ghClient.Repositories.GetContents(ctx, owner, repo, filePath, &github.RepositoryContentGetOptions{
 		Ref: branch,
@@ -3,17 +3,22 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	tfpkg "github.com/stolos-cloud/stolos-bootstrap/pkg/terraform"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
 	"github.com/stolos-cloud/stolos/backend/internal/helpers"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	gcpservices "github.com/stolos-cloud/stolos/backend/internal/services/gcp"
 	gitopsservices "github.com/stolos-cloud/stolos/backend/internal/services/gitops"
 	talosservices "github.com/stolos-cloud/stolos/backend/internal/services/talos"
 	githubpkg "github.com/stolos-cloud/stolos/backend/pkg/github"
-	tfpkg "github.com/stolos-cloud/stolos/backend/pkg/terraform"
 	"gorm.io/gorm"
 )
 
@@ -23,6 +28,7 @@ type InfrastructureService struct {
 	providerManager *ProviderManager
 	gitopsService   *gitopsservices.GitOpsService
 	talosService    *talosservices.TalosService
+	logger          *slog.Logger
 }
 
 type NodeConfig struct {
@@ -32,13 +38,14 @@ type NodeConfig struct {
 	Architecture string
 }
 
-func NewInfrastructureService(db *gorm.DB, cfg *config.Config, providerManager *ProviderManager, gitopsService *gitopsservices.GitOpsService, talosService *talosservices.TalosService) *InfrastructureService {
+func NewInfrastructureService(db *gorm.DB, cfg *config.Config, providerManager *ProviderManager, gitopsService *gitopsservices.GitOpsService, talosService *talosservices.TalosService, logs *logging.Logging) *InfrastructureService {
 	return &InfrastructureService{
 		db:              db,
 		cfg:             cfg,
 		providerManager: providerManager,
 		gitopsService:   gitopsService,
 		talosService:    talosService,
+		logger:          logs.Logger(logging.SubsystemInfrastructure),
 	}
 }
 
@@ -80,21 +87,15 @@ func (s *InfrastructureService) InitializeInfrastructure(ctx context.Context, pr
 	}
 
 	orchestrator, err := tfpkg.NewOrchestrator(tfpkg.OrchestratorConfig{
-		WorkDir:         workDir,
-		TemplateBaseDir: "terraform-templates",
-		EnvVars:         gcpConfig.TerraformEnvVars(),
+		WorkDir: workDir,
+		EnvVars: gcpConfig.TerraformEnvVars(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
 	}
 
 	// Render infrastructure template
-	templateData := InfrastructureTemplateData{
-		ClusterName: helpers.SanitizeResourceName(cluster.Name),
-		BucketName:  backendConfig["bucket"],
-		ProjectID:   gcpConfig.ProjectID,
-		Region:      gcpConfig.Region,
-	}
+	templateData := newInfrastructureTemplateData(cluster.Name, backendConfig["bucket"], gcpConfig)
 
 	if err := orchestrator.RenderTemplateToFile("gcp/infrastructure.tf.tmpl", "main.tf", templateData); err != nil {
 		return fmt.Errorf("failed to render infrastructure template: %w", err)
@@ -115,7 +116,7 @@ func (s *InfrastructureService) InitializeInfrastructure(ctx context.Context, pr
 		if err := orchestrator.Apply(ctx); err != nil {
 			return fmt.Errorf("terraform apply failed: %w", err)
 		}
-		fmt.Println("Infrastructure provisioned successfully")
+		s.logger.InfoContext(ctx, "infrastructure provisioned", "provider", providerName)
 
 		// Commit terraform files to repository
 		gitopsConfig, err := s.gitopsService.GetConfigOrDefault()
@@ -128,14 +129,17 @@ func (s *InfrastructureService) InitializeInfrastructure(ctx context.Context, pr
 			return fmt.Errorf("failed to create GitHub client: %w", err)
 		}
 
-		if _, err := orchestrator.CommitToGitOps(ctx, ghClient.Client, tfpkg.GitOpsConfig{
+		commitStart := time.Now()
+		_, err = orchestrator.CommitToGitOps(ctx, ghClient.Client, tfpkg.GitOpsConfig{
 			Owner:    gitopsConfig.RepoOwner,
 			Repo:     gitopsConfig.RepoName,
 			Branch:   gitopsConfig.Branch,
 			BasePath: filepath.Join(gitopsConfig.WorkingDir, providerName),
 			Username: gitopsConfig.Username,
 			Email:    gitopsConfig.Email,
-		}, "Update infrastructure terraform configuration"); err != nil {
+		}, "Update infrastructure terraform configuration")
+		metrics.ObserveGitOpsCommit("terraform_infrastructure", commitStart, &err)
+		if err != nil {
 			return fmt.Errorf("failed to commit to repository: %w", err)
 		}
 
@@ -144,17 +148,49 @@ func (s *InfrastructureService) InitializeInfrastructure(ctx context.Context, pr
 			return fmt.Errorf("failed to publish node module: %w", err)
 		}
 	} else {
-		fmt.Println("No infrastructure changes required - skipping GitOps updates")
+		s.logger.InfoContext(ctx, "no infrastructure changes required, skipping GitOps updates", "provider", providerName)
 	}
 
 	return nil
 }
 
 type InfrastructureTemplateData struct {
-	ClusterName string
-	BucketName  string
-	ProjectID   string
-	Region      string
+	ClusterName       string
+	BucketName        string
+	ProjectID         string
+	Region            string
+	AdditionalSubnets []SubnetTemplateData
+	// TalosAPISourceRanges are the CIDRs allowed to reach the Talos API, any address when empty
+	TalosAPISourceRanges []string
+}
+
+// SubnetTemplateData describes the subnet of an additional region
+type SubnetTemplateData struct {
+	ResourceName string
+	Name         string
+	Region       string
+	CIDR         string
+}
+
+func newInfrastructureTemplateData(clusterName, bucketName string, gcpConfig *models.GCPConfig) InfrastructureTemplateData {
+	sanitizedName := helpers.SanitizeResourceName(clusterName)
+	data := InfrastructureTemplateData{
+		ClusterName: sanitizedName,
+		BucketName:  bucketName,
+		ProjectID:   gcpConfig.ProjectID,
+		Region:      gcpConfig.Region,
+	}
+
+	// Ranges follow the order regions were added in so existing subnets keep theirs
+	for i, region := range gcpConfig.Regions()[1:] {
+		data.AdditionalSubnets = append(data.AdditionalSubnets, SubnetTemplateData{
+			ResourceName: "subnet_" + strings.ReplaceAll(region, "-", "_"),
+			Name:         gcpConfig.SubnetName(sanitizedName, region),
+			Region:       region,
+			CIDR:         gcpservices.SubnetCIDR(i + 1),
+		})
+	}
+	return data
 }
 
 func (s *InfrastructureService) DestroyInfrastructure(ctx context.Context, providerName string) error {
@@ -194,21 +230,15 @@ func (s *InfrastructureService) DestroyInfrastructure(ctx context.Context, provi
 	}
 
 	orchestrator, err := tfpkg.NewOrchestrator(tfpkg.OrchestratorConfig{
-		WorkDir:         workDir,
-		TemplateBaseDir: "terraform-templates",
-		EnvVars:         gcpConfig.TerraformEnvVars(),
+		WorkDir: workDir,
+		EnvVars: gcpConfig.TerraformEnvVars(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
 	}
 
 	// Render infrastructure template
-	templateData := InfrastructureTemplateData{
-		ClusterName: helpers.SanitizeResourceName(cluster.Name),
-		BucketName:  backendConfig["bucket"],
-		ProjectID:   gcpConfig.ProjectID,
-		Region:      gcpConfig.Region,
-	}
+	templateData := newInfrastructureTemplateData(cluster.Name, backendConfig["bucket"], gcpConfig)
 
 	if err := orchestrator.RenderTemplateToFile("gcp/infrastructure.tf.tmpl", "main.tf", templateData); err != nil {
 		return fmt.Errorf("failed to render infrastructure template: %w", err)
@@ -224,7 +254,7 @@ func (s *InfrastructureService) DestroyInfrastructure(ctx context.Context, provi
 		return fmt.Errorf("terraform destroy failed: %w", err)
 	}
 
-	fmt.Println("Infrastructure destroyed successfully")
+	s.logger.InfoContext(ctx, "infrastructure destroyed", "provider", providerName)
 	return nil
 }
 
@@ -257,11 +287,17 @@ func (s *InfrastructureService) GetInfrastructureStatus(ctx context.Context, pro
 
 	sanitizedName := helpers.SanitizeResourceName(cluster.Name)
 
+	subnets := make(map[string]string)
+	for _, region := range gcpConfig.Regions() {
+		subnets[region] = gcpConfig.SubnetName(sanitizedName, region)
+	}
+
 	return map[string]any{
-		"status": gcpConfig.InfrastructureStatus,
-		"vpc":    sanitizedName + "-vpc",
-		"subnet": sanitizedName + "-subnet",
-		"region": gcpConfig.Region,
+		"status":  gcpConfig.InfrastructureStatus,
+		"vpc":     sanitizedName + "-vpc",
+		"subnet":  sanitizedName + "-subnet",
+		"region":  gcpConfig.Region,
+		"subnets": subnets,
 	}, nil
 }
 
@@ -307,9 +343,8 @@ func (s *InfrastructureService) PublishNodeModuleToRepo(ctx context.Context, pro
 	defer os.RemoveAll(workDir)
 
 	orchestrator, err := tfpkg.NewOrchestrator(tfpkg.OrchestratorConfig{
-		WorkDir:         workDir,
-		TemplateBaseDir: "terraform-templates",
-		EnvVars:         nil,
+		WorkDir: workDir,
+		EnvVars: nil,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
@@ -340,6 +375,7 @@ func (s *InfrastructureService) PublishNodeModuleToRepo(ctx context.Context, pro
 
 	// Commit to GitOps repository
 	moduleBasePath := filepath.Join(gitopsConfig.WorkingDir, providerName, "modules", "node")
+	commitStart := time.Now()
 	committed, err := orchestrator.CommitToGitOps(ctx, ghClient.Client, tfpkg.GitOpsConfig{
 		Owner:    gitopsConfig.RepoOwner,
 		Repo:     gitopsConfig.RepoName,
@@ -348,15 +384,15 @@ func (s *InfrastructureService) PublishNodeModuleToRepo(ctx context.Context, pro
 		Username: gitopsConfig.Username,
 		Email:    gitopsConfig.Email,
 	}, fmt.Sprintf("Publish Terraform node module for %s", providerName))
+	metrics.ObserveGitOpsCommit("terraform_node_module", commitStart, &err)
 	if err != nil {
 		return fmt.Errorf("failed to commit to repository: %w", err)
 	}
 
 	if committed {
-		fmt.Printf("Published node module to %s/%s (branch: %s)\n", gitopsConfig.RepoOwner, gitopsConfig.RepoName, gitopsConfig.Branch)
-		fmt.Printf("  Module directory: %s\n", moduleBasePath)
+		s.logger.InfoContext(ctx, "published node module", "repo", gitopsConfig.RepoOwner+"/"+gitopsConfig.RepoName, "branch", gitopsConfig.Branch, "path", moduleBasePath)
 	} else {
-		fmt.Printf("Node module already up-to-date in %s/%s (branch: %s)\n", gitopsConfig.RepoOwner, gitopsConfig.RepoName, gitopsConfig.Branch)
+		s.logger.InfoContext(ctx, "node module already up to date", "repo", gitopsConfig.RepoOwner+"/"+gitopsConfig.RepoName, "branch", gitopsConfig.Branch)
 	}
 
 	return nil
@@ -400,9 +436,8 @@ func (s *InfrastructureService) ForceUnlockState(ctx context.Context, providerNa
 	}
 
 	orchestrator, err := tfpkg.NewOrchestrator(tfpkg.OrchestratorConfig{
-		WorkDir:         workDir,
-		TemplateBaseDir: "terraform-templates",
-		EnvVars:         gcpConfig.TerraformEnvVars(),
+		WorkDir: workDir,
+		EnvVars: gcpConfig.TerraformEnvVars(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
@@ -428,6 +463,6 @@ func (s *InfrastructureService) ForceUnlockState(ctx context.Context, providerNa
 		return fmt.Errorf("terraform force-unlock failed: %w", err)
 	}
 
-	fmt.Printf("State lock removed successfully (Lock ID: %s)\n", lockID)
+	s.logger.InfoContext(ctx, "state lock removed", "provider", providerName, "lock_id", lockID)
 	return nil
 }
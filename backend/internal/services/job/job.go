@@ -1,19 +1,26 @@
 package job
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"reflect"
+	"time"
 
 	"github.com/NVIDIA/gontainer/v2"
 	"github.com/go-co-op/gocron/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
+	"github.com/stolos-cloud/stolos/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type JobService struct {
-	rs    *gontainer.Resolver
-	sched gocron.Scheduler
+	rs     *gontainer.Resolver
+	sched  gocron.Scheduler
+	logger *slog.Logger
 
 	jobRegistry map[string]*StolosJob
 }
@@ -29,18 +36,20 @@ type StolosJob struct {
 	Job   gocron.Job
 }
 
-func NewJobService(rs *gontainer.Resolver) (*JobService, error) {
+func NewJobService(rs *gontainer.Resolver, logs *logging.Logging) (*JobService, error) {
+	logger := logs.Logger(logging.SubsystemJobs)
 
 	s, err := gocron.NewScheduler()
 	if err != nil {
-		log.Print("Failed to start scheduler:", err)
+		logger.Error("failed to start scheduler", "error", err)
 		return nil, err
 	}
 
-	log.Printf("Started JobService Scheduler.")
+	logger.Info("started job scheduler")
 	svc := &JobService{
 		rs:          rs,
 		sched:       s,
+		logger:      logger,
 		jobRegistry: make(map[string]*StolosJob),
 	}
 
@@ -48,6 +57,7 @@ func NewJobService(rs *gontainer.Resolver) (*JobService, error) {
 		ClusterHealthCheckJob,
 		NodeInfoReconciler,
 		NodeStatusUpdateJob,
+		SpotPreemptionJob,
 	)
 
 	return svc, nil
@@ -65,8 +75,6 @@ func (s *JobService) RegisterJob(job *StolosJob) (*StolosJob, error) {
 	//}
 
 	wrappedJobFunc := func() {
-		log.Printf("[JobService] Starting job %s\n", job.Name)
-
 		// Resolve args dynamically using gontainer
 		args := make([]reflect.Value, len(job.JobArgs))
 		for i, arg := range job.JobArgs {
@@ -84,7 +92,8 @@ func (s *JobService) RegisterJob(job *StolosJob) (*StolosJob, error) {
 
 			// Call resolver with &ptr (type **T)
 			if err := s.rs.Resolve(ptrPtr.Interface()); err != nil {
-				log.Printf("[JobService] Failed to resolve arg %v: %v", typ, err)
+				s.logger.Error("failed to resolve job argument", "job", job.Name, "type", typ.String(), "error", err)
+				metrics.JobRuns.WithLabelValues(job.Name, "error").Inc()
 				return
 			}
 
@@ -92,11 +101,8 @@ func (s *JobService) RegisterJob(job *StolosJob) (*StolosJob, error) {
 			args[i] = reflect.ValueOf(ptrPtr.Elem().Interface())
 		}
 
-		// Invoke the JobFunc via reflection
-		fval := reflect.ValueOf(job.JobFunc)
-		fval.Call(args)
-
-		log.Printf("[JobService] Finished job %s\n", job.Name)
+		// Invoke the JobFunc via reflection, the run is logged by callJobFunc
+		_ = s.callJobFunc(job.Name, reflect.ValueOf(job.JobFunc), args)
 	}
 
 	job.Options = append(job.Options, gocron.WithName(job.Name))
@@ -114,7 +120,7 @@ func (s *JobService) RegisterJob(job *StolosJob) (*StolosJob, error) {
 	job.Job = cronJob
 	s.jobRegistry[job.Name] = job
 
-	log.Printf("[JobService] Registered job %s (ID: %s)", job.Name, job.JobID)
+	s.logger.Info("registered job", "job", job.Name, "job_id", job.JobID.String())
 	return job, nil
 }
 
@@ -122,7 +128,7 @@ func (s *JobService) RegisterJobs(jobs ...*StolosJob) {
 	for _, job := range jobs {
 		_, err := s.RegisterJob(job)
 		if err != nil {
-			log.Print("[RegisterAllJobs] Failed to register job:", err)
+			s.logger.Error("failed to register job", "job", job.Name, "error", err)
 		}
 	}
 }
@@ -195,8 +201,56 @@ func (s *JobService) invokeJobFunc(job *StolosJob) error {
 		}
 	}
 
-	fv.Call(args)
-	return nil
+	return s.callJobFunc(job.Name, fv, args)
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	loggerType  = reflect.TypeOf((*slog.Logger)(nil))
+)
+
+// callJobFunc calls a job function in a span and records its run. A job fails when it returns an error as its last
+// result or panics. The context.Context parameters of the function receive the context of the span, tagged with the
+// job and run ID, and the *slog.Logger parameters the logger of the jobs.
+func (s *JobService) callJobFunc(name string, fv reflect.Value, args []reflect.Value) (err error) {
+	ctx, span := tracing.Start(context.Background(), "job "+name, attribute.String("job.name", name))
+	ctx = logging.WithAttrs(ctx, slog.String("job", name), slog.String("job_run_id", uuid.NewString()))
+	s.logger.DebugContext(ctx, "job started")
+
+	start := time.Now()
+	defer func() {
+		metrics.JobDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if r := recover(); r != nil {
+			metrics.JobRuns.WithLabelValues(name, "error").Inc()
+			s.logger.ErrorContext(ctx, "job panicked", "panic", r)
+			tracing.End(span, fmt.Errorf("job panicked: %v", r))
+			panic(r)
+		}
+		metrics.JobRuns.WithLabelValues(name, metrics.Result(err)).Inc()
+		tracing.End(span, err)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "job failed", "error", err, "duration_ms", time.Since(start).Milliseconds())
+		} else {
+			s.logger.DebugContext(ctx, "job finished", "duration_ms", time.Since(start).Milliseconds())
+		}
+	}()
+
+	for i := range args {
+		switch fv.Type().In(i) {
+		case contextType:
+			args[i] = reflect.ValueOf(ctx)
+		case loggerType:
+			args[i] = reflect.ValueOf(s.logger)
+		}
+	}
+
+	results := fv.Call(args)
+	if len(results) > 0 {
+		if resultErr, ok := results[len(results)-1].Interface().(error); ok {
+			err = resultErr
+		}
+	}
+	return err
 }
 
 func (s *JobService) ExecuteJobSync(name string) error {
@@ -205,7 +259,7 @@ func (s *JobService) ExecuteJobSync(name string) error {
 		return fmt.Errorf("job %s not found", name)
 	}
 
-	log.Printf("[JobService] Executing job %s synchronously", name)
+	s.logger.Info("executing job synchronously", "job", name)
 	return s.invokeJobFunc(job)
 }
 
@@ -215,11 +269,9 @@ func (s *JobService) ExecuteJobAsync(name string) error {
 		return fmt.Errorf("job %s not found", name)
 	}
 
-	log.Printf("[JobService] Executing job %s asynchronously", name)
+	s.logger.Info("executing job asynchronously", "job", name)
 	go func() {
-		if err := s.invokeJobFunc(job); err != nil {
-			log.Printf("[JobService] Async job %s failed: %v", name, err)
-		}
+		_ = s.invokeJobFunc(job)
 	}()
 
 	return nil
@@ -4,14 +4,16 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
 	clusterapi "github.com/siderolabs/talos/pkg/machinery/api/cluster"
 	machineryClient "github.com/siderolabs/talos/pkg/machinery/client"
 	clusterres "github.com/siderolabs/talos/pkg/machinery/resources/cluster"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
+	gcpservices "github.com/stolos-cloud/stolos/backend/internal/services/gcp"
 	"github.com/stolos-cloud/stolos/backend/internal/services/k8s"
 	"github.com/stolos-cloud/stolos/backend/internal/services/node"
 	"github.com/stolos-cloud/stolos/backend/internal/services/talos"
@@ -28,8 +30,8 @@ import (
 var ClusterHealthCheckJob = &StolosJob{
 	Name:       "ClusterHealthCheckJob",
 	Definition: gocron.DurationJob(1 * time.Minute),
-	JobFunc: func(ts *talos.TalosService, db *gorm.DB) {
-		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
+	JobFunc: func(ctx context.Context, logger *slog.Logger, ts *talos.TalosService, db *gorm.DB) {
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Minute)
 		defer cancel()
 
 		// --- Get first active node ---
@@ -38,36 +40,36 @@ var ClusterHealthCheckJob = &StolosJob{
 			Model(&models.Node{}).
 			Where("status = ?", models.StatusActive).
 			First(&node).Error; err != nil {
-			log.Printf("no active node found: %v", err)
+			logger.WarnContext(ctx, "no active node found", "error", err)
 			return
 		}
 
 		if node.IPAddress == "" {
-			log.Println("node has no IP address, skipping health check")
+			logger.WarnContext(ctx, "node has no IP address, skipping health check", "node", node.Name)
 			return
 		}
 
 		// --- Create Talos machinery client ---
 		cli, err := ts.GetMachineryClient(node.IPAddress)
 		if err != nil {
-			log.Printf("failed to create machinery client for %s: %v", node.IPAddress, err)
+			logger.ErrorContext(ctx, "failed to create machinery client", "ip", node.IPAddress, "error", err)
 			return
 		}
 
 		// --- Start cluster health check ---
 		healthCheckClient, err := cli.ClusterHealthCheck(ctx, 20*time.Minute, &clusterapi.ClusterInfo{})
 		if err != nil {
-			log.Printf("failed to start health check: %v", err)
+			logger.ErrorContext(ctx, "failed to start health check", "error", err)
 			return
 		}
 
 		// --- Ensure CloseSend won't panic ---
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("recovered from panic during CloseSend: %v", r)
+				logger.ErrorContext(ctx, "recovered from panic during CloseSend", "panic", r)
 			}
 			if err := healthCheckClient.CloseSend(); err != nil {
-				log.Printf("error closing stream: %v", err)
+				logger.WarnContext(ctx, "error closing stream", "error", err)
 			}
 		}()
 
@@ -77,22 +79,24 @@ var ClusterHealthCheckJob = &StolosJob{
 			if err != nil {
 				// graceful exit cases
 				if err == io.EOF || machineryClient.StatusCode(err) == codes.Canceled {
-					log.Println("health check stream closed gracefully")
+					logger.DebugContext(ctx, "health check stream closed gracefully")
 					break
 				}
 
 				// network / transport errors
-				log.Printf("recv error: %v", err)
+				logger.ErrorContext(ctx, "health check stream failed", "error", err)
 				break
 			}
 
 			// handle message errors
 			if metaErr := msg.GetMetadata().GetError(); metaErr != "" {
-				log.Printf("cluster health check failed: %s", metaErr)
+				logger.ErrorContext(ctx, "cluster health check failed", "error", metaErr)
 			}
 		}
 	},
 	JobArgs: []any{
+		nil,                        // context of the job span
+		nil,                        // logger of the job
 		(*talos.TalosService)(nil), // types to be resolved dynamically
 		(*gorm.DB)(nil),
 	},
@@ -104,24 +108,24 @@ var ClusterHealthCheckJob = &StolosJob{
 var NodeStatusUpdateJob *StolosJob = &StolosJob{
 	Name:       "NodeStatusUpdateJob",
 	Definition: gocron.DurationJob(30 * time.Second),
-	JobFunc: func(ts *talos.TalosService, db *gorm.DB, wsManager *wsservices.Manager, k8sClient *k8s.K8sClient) {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	JobFunc: func(ctx context.Context, logger *slog.Logger, ts *talos.TalosService, db *gorm.DB, wsManager *wsservices.Manager, k8sClient *k8s.K8sClient) {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		defer cancel()
 
 		var dbNodes []models.Node
 		if err := db.Find(&dbNodes).Error; err != nil {
-			log.Printf("NodeStatusUpdateJob: failed to load nodes: %v", err)
+			logger.ErrorContext(ctx, "failed to load nodes", "error", err)
 			return
 		}
 
 		if k8sClient == nil || k8sClient.Clientset == nil {
-			log.Printf("NodeStatusUpdateJob: Kubernetes client not available")
+			logger.WarnContext(ctx, "Kubernetes client not available")
 			return
 		}
 
 		k8sNodes, err := k8sClient.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 		if err != nil {
-			log.Printf("NodeStatusUpdateJob: failed to list Kubernetes nodes: %v", err)
+			logger.ErrorContext(ctx, "failed to list Kubernetes nodes", "error", err)
 			return
 		}
 
@@ -156,12 +160,14 @@ var NodeStatusUpdateJob *StolosJob = &StolosJob{
 			if err := db.Model(&models.Node{}).
 				Where("id = ?", node.ID).
 				Update("status", desiredStatus).Error; err != nil {
-				log.Printf("NodeStatusUpdateJob: failed to update status for node %s: %v", node.Name, err)
+				logger.ErrorContext(ctx, "failed to update node status", "node", node.Name, "error", err)
 				continue
 			}
 			node.Status = desiredStatus
 		}
 
+		recordNodeStatuses(dbNodes)
+
 		if wsManager != nil {
 			wsManager.BroadcastToSessionType(wsservices.SessionTypeEvent, wsservices.Message{
 				Type: "NodeStatusUpdated",
@@ -173,6 +179,8 @@ var NodeStatusUpdateJob *StolosJob = &StolosJob{
 		}
 	},
 	JobArgs: []any{
+		nil,
+		nil,
 		(*talos.TalosService)(nil),
 		(*gorm.DB)(nil),
 		(*wsservices.Manager)(nil),
@@ -181,6 +189,43 @@ var NodeStatusUpdateJob *StolosJob = &StolosJob{
 	Options: nil,
 }
 
+// SpotPreemptionJob recovers preempted GCP spot nodes.
+// It relies on NodeStatusUpdateJob marking NotReady nodes as failed and confirms with the instance status.
+var SpotPreemptionJob = &StolosJob{
+	Name:       "SpotPreemptionJob",
+	Definition: gocron.DurationJob(1 * time.Minute),
+	JobFunc: func(ctx context.Context, logger *slog.Logger, spotService *gcpservices.SpotService, wsManager *wsservices.Manager) {
+		// Replacing a deleted instance runs a terraform apply
+		ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
+		defer cancel()
+
+		preemptions, err := spotService.CheckPreemptions(ctx)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to check preemptions", "error", err)
+			return
+		}
+
+		if wsManager != nil && len(preemptions) > 0 {
+			wsManager.BroadcastToSessionType(wsservices.SessionTypeEvent, wsservices.Message{
+				Type: "NodePreempted",
+				Payload: map[string]any{
+					"preemptions": preemptions,
+					"updatedAt":   time.Now().UTC(),
+				},
+			})
+		}
+	},
+	JobArgs: []any{
+		nil,
+		nil,
+		(*gcpservices.SpotService)(nil),
+		(*wsservices.Manager)(nil),
+	},
+	Options: []gocron.JobOption{
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	},
+}
+
 // NodeInfoReconciler
 //  1. list Affiliates from any reachable ACTIVE node
 //  2. if node's IP unchanged -> skip
@@ -190,6 +235,8 @@ var NodeInfoReconciler = &StolosJob{
 	Name:       "NodeInfoReconciler",
 	Definition: gocron.DurationJob(2 * time.Minute),
 	JobArgs: []any{
+		nil,
+		nil,
 		(*talos.TalosService)(nil),
 		(*node.NodeService)(nil),
 		(*gorm.DB)(nil),
@@ -198,18 +245,18 @@ var NodeInfoReconciler = &StolosJob{
 	Options: []gocron.JobOption{
 		gocron.WithSingletonMode(gocron.LimitModeWait),
 	},
-	JobFunc: func(ts *talos.TalosService, ns *node.NodeService, db *gorm.DB, wsManager *wsservices.Manager) {
-		ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	JobFunc: func(ctx context.Context, logger *slog.Logger, ts *talos.TalosService, ns *node.NodeService, db *gorm.DB, wsManager *wsservices.Manager) {
+		ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
 		defer cancel()
 
 		seedCli, seedNode, err := ts.GetReachableMachineryClient(ctx)
 		if err != nil {
-			log.Printf("NodeInfoReconciler: no reachable node found: %v", err)
+			logger.WarnContext(ctx, "no reachable node found", "error", err)
 			return
 		}
 
 		if seedNode == nil || seedNode.Provider != "onprem" {
-			log.Printf("NodeInfoReconciler: no reachable onprem node available for seed selection")
+			logger.WarnContext(ctx, "no reachable onprem node available for seed selection")
 			return
 		}
 
@@ -218,11 +265,11 @@ var NodeInfoReconciler = &StolosJob{
 			ctx, seedCli, clusterres.NamespaceName, clusterres.AffiliateType,
 		)
 		if err != nil {
-			log.Printf("NodeInfoReconciler: get Affiliates failed: %v", err)
+			logger.ErrorContext(ctx, "failed to get affiliates", "error", err)
 			return
 		}
 		if affs.Len() == 0 {
-			log.Printf("NodeInfoReconciler: no Affiliates returned")
+			logger.WarnContext(ctx, "no affiliates returned")
 			return
 		}
 
@@ -262,7 +309,7 @@ var NodeInfoReconciler = &StolosJob{
 			err := db.Where("name = ?", hostname).First(&existing).Error
 			notFound := errors.Is(err, gorm.ErrRecordNotFound)
 			if err != nil && !notFound {
-				log.Printf("NodeInfoReconciler: db read %s failed: %v", hostname, err)
+				logger.ErrorContext(ctx, "failed to read node", "node", hostname, "error", err)
 				return
 			}
 
@@ -282,7 +329,7 @@ var NodeInfoReconciler = &StolosJob{
 			if effectiveIP != "" {
 				if cli, err := ts.GetMachineryClient(effectiveIP); err == nil {
 					if ipChanged || existing.MACAddress == "" {
-						if iface := talos.GetMachineBestExternalNetworkInterface(ctx, cli); iface != nil {
+						if iface := talos.GetMachineBestExternalNetworkInterface(ctx, logger, cli); iface != nil {
 							mac = iface.Mac
 						}
 					}
@@ -293,7 +340,7 @@ var NodeInfoReconciler = &StolosJob{
 						}
 					}
 				} else {
-					log.Printf("NodeInfoReconciler: client err %s (%s): %v", hostname, effectiveIP, err)
+					logger.WarnContext(ctx, "failed to create machinery client", "node", hostname, "ip", effectiveIP, "error", err)
 				}
 			}
 
@@ -321,7 +368,7 @@ var NodeInfoReconciler = &StolosJob{
 				MACAddress:   mac,
 				Architecture: arch,
 			}).Error; err != nil {
-				log.Printf("NodeInfoReconciler: upsert %s failed: %v", hostname, err)
+				logger.ErrorContext(ctx, "failed to upsert node", "node", hostname, "error", err)
 				return
 			}
 		})
@@ -329,19 +376,19 @@ var NodeInfoReconciler = &StolosJob{
 		// Removed this section as nodes which are shutdown are removed from affiliates. Final logic TBD.
 		// Remove nodes no longer present in affiliates
 		// if len(hostnameList) == 0 {
-		// 	log.Printf("NodeInfoReconciler: warning - no affiliates returned; skipping stale node cleanup")
+		// 	logger.WarnContext(ctx, "no affiliates returned, skipping stale node cleanup")
 		// } else {
 		// 	if err := db.Where("provider = ?", "onprem").Where("name NOT IN ?", hostnameList).
 		// 		Where("status NOT IN ?", []models.NodeStatus{models.StatusPending, models.StatusProvisioning}).
 		// 		Delete(&models.Node{}).Error; err != nil {
-		// 		log.Printf("NodeInfoReconciler: failed to delete stale nodes: %v", err)
+		// 		logger.ErrorContext(ctx, "failed to delete stale nodes", "error", err)
 		// 	}
 		// }
 
 		if wsManager != nil {
 			var nodes []models.Node
 			if err := db.Find(&nodes).Error; err != nil {
-				log.Printf("NodeInfoReconciler: failed to load nodes for broadcast: %v", err)
+				logger.ErrorContext(ctx, "failed to load nodes for broadcast", "error", err)
 			} else {
 				wsManager.BroadcastToSessionType(wsservices.SessionTypeEvent, wsservices.Message{
 					Type: "NodeStatusUpdated",
@@ -355,6 +402,20 @@ var NodeInfoReconciler = &StolosJob{
 	},
 }
 
+// recordNodeStatuses sets the node gauges from the nodes
+func recordNodeStatuses(nodes []models.Node) {
+	type key struct{ status, provider string }
+	counts := make(map[key]int)
+	for _, node := range nodes {
+		counts[key{string(node.Status), node.Provider}]++
+	}
+
+	metrics.Nodes.Reset()
+	for k, count := range counts {
+		metrics.Nodes.WithLabelValues(k.status, k.provider).Set(float64(count))
+	}
+}
+
 func mapK8sNodeStatus(node *corev1.Node) models.NodeStatus {
 	if node == nil {
 		return models.StatusFailed
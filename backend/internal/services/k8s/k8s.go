@@ -3,9 +3,11 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,11 +35,13 @@ type K8sClient struct {
 	ApiExtensionClient *apiextensionsclient.Clientset
 	DynamicClient      *dynamic.DynamicClient
 	Clientset          *kubernetes.Clientset
+
+	logger *slog.Logger
 }
 
-func NewK8sClient() (*K8sClient, error) {
+func NewK8sClient(logs *logging.Logging) (*K8sClient, error) {
 	var err error
-	k8sClient := K8sClient{}
+	k8sClient := K8sClient{logger: logs.Logger(logging.SubsystemKubernetes)}
 
 	// Try in-cluster config first
 	k8sClient.Config, err = rest.InClusterConfig()
@@ -67,9 +71,9 @@ func NewK8sClient() (*K8sClient, error) {
 	return &k8sClient, err
 }
 
-func (k8sClient K8sClient) ApplyCR(crd map[string]interface{}, gvr schema.GroupVersionResource, onlyDryRun bool) error {
+func (k8sClient K8sClient) ApplyCR(ctx context.Context, crd map[string]interface{}, gvr schema.GroupVersionResource, onlyDryRun bool) error {
 
-	fmt.Printf("Applying CRD %s.%s/%s\n", gvr.Resource, gvr.Group, gvr.Version)
+	k8sClient.logger.InfoContext(ctx, "applying custom resource", "resource", gvr.Resource, "group", gvr.Group, "version", gvr.Version, "dry_run", onlyDryRun)
 	name := crd["metadata"].(map[string]interface{})["name"].(string)
 	unstructuredCrd := &unstructured.Unstructured{
 		Object: crd,
@@ -83,13 +87,13 @@ func (k8sClient K8sClient) ApplyCR(crd map[string]interface{}, gvr schema.GroupV
 	}
 
 	_, err := k8sClient.DynamicClient.Resource(gvr).Namespace(unstructuredCrd.GetNamespace()).
-		Apply(context.Background(), name, unstructuredCrd, applyOptions)
+		Apply(ctx, name, unstructuredCrd, applyOptions)
 
 	return err
 }
 
-func (K8sClient K8sClient) GetAllResourcesWithFilter(filter K8sResourceFilter) ([]unstructured.Unstructured, error) {
-	fmt.Printf("Getting all resources with filter %+v\n", filter)
+func (K8sClient K8sClient) GetAllResourcesWithFilter(ctx context.Context, filter K8sResourceFilter) ([]unstructured.Unstructured, error) {
+	K8sClient.logger.DebugContext(ctx, "getting all resources", "namespace", filter.Namespace, "kind", filter.Kind, "group", filter.Group, "api_version", filter.ApiVersion)
 	disc, _ := discovery.NewDiscoveryClientForConfig(K8sClient.Config)
 	var resources []unstructured.Unstructured
 
@@ -103,7 +107,7 @@ func (K8sClient K8sClient) GetAllResourcesWithFilter(filter K8sResourceFilter) (
 			return nil, err
 		}
 
-		return K8sClient.getAllFromGvrsList(gvrs, filter.Namespace)
+		return K8sClient.getAllFromGvrsList(ctx, gvrs, filter.Namespace)
 	}
 
 	if filter.Group != "" {
@@ -112,7 +116,7 @@ func (K8sClient K8sClient) GetAllResourcesWithFilter(filter K8sResourceFilter) (
 			if err != nil {
 				return nil, err
 			}
-			allResources, err := K8sClient.findFromResourcesGv(resourcesGv, filter.Namespace)
+			allResources, err := K8sClient.findFromResourcesGv(ctx, resourcesGv, filter.Namespace)
 			if err != nil {
 				return nil, err
 			}
@@ -124,14 +128,14 @@ func (K8sClient K8sClient) GetAllResourcesWithFilter(filter K8sResourceFilter) (
 				return nil, err
 			}
 
-			return K8sClient.getAllFromGvrsList(gvrs, filter.Namespace)
+			return K8sClient.getAllFromGvrsList(ctx, gvrs, filter.Namespace)
 		}
 	}
 
 	return resources, nil
 }
 
-func (K8sClient K8sClient) findFromResourcesGv(resourcesGv *metav1.APIResourceList, namespace string) ([]unstructured.Unstructured, error) {
+func (K8sClient K8sClient) findFromResourcesGv(ctx context.Context, resourcesGv *metav1.APIResourceList, namespace string) ([]unstructured.Unstructured, error) {
 	resources := []unstructured.Unstructured{}
 	var err error
 	for _, r := range resourcesGv.APIResources {
@@ -148,9 +152,9 @@ func (K8sClient K8sClient) findFromResourcesGv(resourcesGv *metav1.APIResourceLi
 
 		var list *unstructured.UnstructuredList
 		if namespace != "" {
-			list, err = K8sClient.DynamicClient.Resource(gvr).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+			list, err = K8sClient.DynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
 		} else {
-			list, err = K8sClient.DynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+			list, err = K8sClient.DynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
 		}
 		if err != nil {
 			// Some kinds may not support LIST (e.g., scale subresources)
@@ -199,20 +203,20 @@ func findAllGroupVersions(dc discovery.DiscoveryInterface, group string, kind st
 	return gvrs, nil
 }
 
-func (K8sClient K8sClient) getAllFromGvrsList(gvrs []schema.GroupVersionResource, namespace string) ([]unstructured.Unstructured, error) {
+func (K8sClient K8sClient) getAllFromGvrsList(ctx context.Context, gvrs []schema.GroupVersionResource, namespace string) ([]unstructured.Unstructured, error) {
 	resources := []unstructured.Unstructured{}
 	for _, gvr := range gvrs {
-		fmt.Printf("Getting resources %+v\n", gvr)
+		K8sClient.logger.DebugContext(ctx, "getting resources", "resource", gvr.Resource, "group", gvr.Group, "version", gvr.Version)
 		res := K8sClient.DynamicClient.Resource(gvr)
 
 		if namespace != "" {
-			result, err := res.Namespace(K8sNamespacePrefix+namespace).List(context.Background(), metav1.ListOptions{})
+			result, err := res.Namespace(K8sNamespacePrefix+namespace).List(ctx, metav1.ListOptions{})
 			if err != nil {
 				return nil, err
 			}
 			resources = append(resources, result.Items...)
 		} else {
-			result, err := res.List(context.Background(), metav1.ListOptions{})
+			result, err := res.List(ctx, metav1.ListOptions{})
 			if err != nil {
 				return nil, err
 			}
@@ -239,7 +243,7 @@ func (k8sClient K8sClient) CreateNamespace(ctx context.Context, namespaceName st
 		return fmt.Errorf("failed to create namespace %s: %w", namespaceName, err)
 	}
 
-	fmt.Printf("Successfully created namespace %s\n", namespaceName)
+	k8sClient.logger.InfoContext(ctx, "created namespace", "namespace", namespaceName)
 	return nil
 }
 
@@ -250,6 +254,6 @@ func (k8sClient K8sClient) DeleteNamespace(ctx context.Context, namespaceName st
 		return fmt.Errorf("failed to delete namespace %s: %w", namespaceName, err)
 	}
 
-	fmt.Printf("Successfully deleted namespace %s\n", namespaceName)
+	k8sClient.logger.InfoContext(ctx, "deleted namespace", "namespace", namespaceName)
 	return nil
 }
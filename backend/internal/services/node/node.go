@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/google/uuid"
 	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
@@ -14,6 +14,7 @@ import (
 	machineconf "github.com/siderolabs/talos/pkg/machinery/config/machine"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
 	"github.com/stolos-cloud/stolos/backend/internal/helpers"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	"github.com/stolos-cloud/stolos/backend/internal/services"
 	gcpservices "github.com/stolos-cloud/stolos/backend/internal/services/gcp"
@@ -26,14 +27,16 @@ type NodeService struct {
 	cfg             *config.Config
 	providerManager *services.ProviderManager
 	ts              *talos.TalosService
+	logger          *slog.Logger
 }
 
-func NewNodeService(db *gorm.DB, cfg *config.Config, providerManager *services.ProviderManager, talosService *talos.TalosService) *NodeService {
+func NewNodeService(db *gorm.DB, cfg *config.Config, providerManager *services.ProviderManager, talosService *talos.TalosService, logs *logging.Logging) *NodeService {
 	return &NodeService{
 		db:              db,
 		cfg:             cfg,
 		providerManager: providerManager,
 		ts:              talosService,
+		logger:          logs.Logger(logging.SubsystemNodes),
 	}
 }
 
@@ -62,9 +65,9 @@ func (s *NodeService) QueryGCPInstances(ctx context.Context) error {
 
 	// Log the results
 	for zone, instances := range allInstances {
-		fmt.Printf("Zone %s: %d instances\n", zone, len(instances))
+		s.logger.InfoContext(ctx, "GCP instances in zone", "zone", zone, "count", len(instances))
 		for _, instance := range instances {
-			fmt.Printf("  - %s (%s)\n", instance.Name, instance.Status)
+			s.logger.InfoContext(ctx, "GCP instance", "zone", zone, "instance", instance.Name, "status", instance.Status)
 		}
 	}
 
@@ -182,7 +185,7 @@ func (s *NodeService) UpdateActiveNodesConfig(updates []NodeConfigUpdate) ([]mod
 // ProvisionNodes provisions multiple on-prem nodes by updating their role and labels,
 // then applying Talos machine configuration. It continues processing all nodes even if
 // some fail, returning a result list with per-node success/error details.
-func (s *NodeService) ProvisionNodes(configs []models.OnPremNodeProvisionConfig) ([]models.NodeProvisionResult, error) {
+func (s *NodeService) ProvisionNodes(ctx context.Context, configs []models.OnPremNodeProvisionConfig) ([]models.NodeProvisionResult, error) {
 	results := make([]models.NodeProvisionResult, 0, len(configs))
 
 	for _, cfg := range configs {
@@ -248,10 +251,10 @@ func (s *NodeService) ProvisionNodes(configs []models.OnPremNodeProvisionConfig)
 		}
 
 		// get talos api client for node
-		log.Printf("ProvisionNodes: Attempting to connect to node %s (%s)", cfg.NodeID, node.IPAddress)
-		cli, err := talos.GetInsecureMachineryClient(context.Background(), node.IPAddress)
+		s.logger.InfoContext(ctx, "connecting to node", "node_id", cfg.NodeID, "ip", node.IPAddress)
+		cli, err := talos.GetInsecureMachineryClient(ctx, node.IPAddress)
 		if err != nil {
-			log.Printf("ProvisionNodes: Failed to get Talos client for %s: %v", node.IPAddress, err)
+			s.logger.ErrorContext(ctx, "failed to get Talos client", "ip", node.IPAddress, "error", err)
 			result.Error = fmt.Sprintf("failed to get Talos client for %s: %v", node.IPAddress, err)
 			results = append(results, result)
 			continue
@@ -320,7 +323,7 @@ func (s *NodeService) ProvisionNodes(configs []models.OnPremNodeProvisionConfig)
 		var nodeLabels []string
 		if node.Labels != "" {
 			if err := json.Unmarshal([]byte(node.Labels), &nodeLabels); err != nil {
-				log.Printf("ProvisionNodes: failed to parse labels for node %s: %v", node.Name, err)
+				s.logger.WarnContext(ctx, "failed to parse node labels", "node", node.Name, "error", err)
 			}
 		}
 
@@ -351,19 +354,19 @@ func (s *NodeService) ProvisionNodes(configs []models.OnPremNodeProvisionConfig)
 		patched := helpers.RemoveDiskSelector(baseConfig)
 
 		// Send talos ApplyConfiguration request
-		log.Printf("ProvisionNodes: Applying configuration to node %s with name %s", node.IPAddress, nodeName)
-		_, err = cli.ApplyConfiguration(context.Background(), &machineapi.ApplyConfigurationRequest{
+		s.logger.InfoContext(ctx, "applying configuration to node", "ip", node.IPAddress, "node", nodeName)
+		_, err = cli.ApplyConfiguration(ctx, &machineapi.ApplyConfigurationRequest{
 			Data:   patched,
 			Mode:   machineapi.ApplyConfigurationRequest_AUTO,
 			DryRun: false,
 		})
 		if err != nil {
-			log.Printf("ProvisionNodes: Failed to apply configuration to %s: %v", node.IPAddress, err)
+			s.logger.ErrorContext(ctx, "failed to apply configuration", "ip", node.IPAddress, "error", err)
 			result.Error = fmt.Sprintf("failed to apply configuration: %v", err)
 			results = append(results, result)
 			continue
 		}
-		log.Printf("ProvisionNodes: Successfully applied configuration to node %s", node.IPAddress)
+		s.logger.InfoContext(ctx, "applied configuration to node", "ip", node.IPAddress, "node", nodeName)
 
 		// Set node to "provisioning" status
 		node.Status = models.StatusProvisioning
@@ -1,10 +1,12 @@
 package services_test
 
 import (
+	"io"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	"github.com/stolos-cloud/stolos/backend/internal/services/node"
 	talosservice "github.com/stolos-cloud/stolos/backend/internal/services/talos"
@@ -27,10 +29,20 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	return db
 }
 
+func setupTestLogging(t *testing.T) *logging.Logging {
+	logs, err := logging.New(config.LoggingConfig{}, io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to setup test logging: %v", err)
+	}
+
+	return logs
+}
+
 func TestNodeService_CreateNode(t *testing.T) {
 	db := setupTestDB(t)
+	logs := setupTestLogging(t)
 	cfg := &config.Config{}
-	service := node.NewNodeService(db, cfg, nil, talosservice.NewTalosService(db, cfg, nil))
+	service := node.NewNodeService(db, cfg, nil, talosservice.NewTalosService(db, cfg, nil, logs), logs)
 
 	clusterID := uuid.New()
 
@@ -62,8 +74,9 @@ func TestNodeService_CreateNode(t *testing.T) {
 
 func TestNodeService_GetNode(t *testing.T) {
 	db := setupTestDB(t)
+	logs := setupTestLogging(t)
 	cfg := &config.Config{}
-	service := node.NewNodeService(db, cfg, nil, talosservice.NewTalosService(db, cfg, nil))
+	service := node.NewNodeService(db, cfg, nil, talosservice.NewTalosService(db, cfg, nil, logs), logs)
 
 	clusterID := uuid.New()
 
@@ -86,8 +99,9 @@ func TestNodeService_GetNode(t *testing.T) {
 
 func TestNodeService_GetNode_NotFound(t *testing.T) {
 	db := setupTestDB(t)
+	logs := setupTestLogging(t)
 	cfg := &config.Config{}
-	service := node.NewNodeService(db, cfg, nil, talosservice.NewTalosService(db, cfg, nil))
+	service := node.NewNodeService(db, cfg, nil, talosservice.NewTalosService(db, cfg, nil, logs), logs)
 
 	randomID := uuid.New()
 
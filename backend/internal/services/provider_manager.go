@@ -2,16 +2,17 @@ package services
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/google/uuid"
+	tfpkg "github.com/stolos-cloud/stolos-bootstrap/pkg/terraform"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	gcpservices "github.com/stolos-cloud/stolos/backend/internal/services/gcp"
 	gitopsservices "github.com/stolos-cloud/stolos/backend/internal/services/gitops"
 	talosservices "github.com/stolos-cloud/stolos/backend/internal/services/talos"
 	wsservices "github.com/stolos-cloud/stolos/backend/internal/services/websocket"
-	tfpkg "github.com/stolos-cloud/stolos/backend/pkg/terraform"
 	"gorm.io/gorm"
 )
 
@@ -25,6 +26,7 @@ type ProviderManager struct {
 	gitopsService         *gitopsservices.GitOpsService
 	infrastructureService *InfrastructureService
 	wsManager             *wsservices.Manager
+	logger                *slog.Logger
 }
 
 func NewProviderManager(
@@ -35,6 +37,7 @@ func NewProviderManager(
 	talosService *talosservices.TalosService,
 	gitopsService *gitopsservices.GitOpsService,
 	wsManager *wsservices.Manager,
+	logs *logging.Logging,
 ) *ProviderManager {
 	return &ProviderManager{
 		db:                  db,
@@ -45,6 +48,7 @@ func NewProviderManager(
 		talosService:        talosService,
 		gitopsService:       gitopsService,
 		wsManager:           wsManager,
+		logger:              logs.Logger(logging.SubsystemInfrastructure),
 	}
 }
 
@@ -56,16 +60,16 @@ func (pm *ProviderManager) SetInfrastructureService(infrastructureService *Infra
 func (pm *ProviderManager) InitializeProviders(ctx context.Context) error {
 
 	if err := tfpkg.CheckTerraformInstalled(); err != nil {
-		log.Printf("Terraform not installed - cloud provider features will be unavailable: %v", err)
+		pm.logger.WarnContext(ctx, "Terraform not installed, cloud provider features will be unavailable", "error", err)
 
 		if err := pm.initializeGitOps(ctx); err != nil {
-			log.Printf("Warning: GitOps initialization failed: %v", err)
+			pm.logger.WarnContext(ctx, "GitOps initialization failed", "error", err)
 		}
 		return nil
 	}
 
 	if err := pm.initializeGitOps(ctx); err != nil {
-		log.Printf("Warning: GitOps initialization failed: %v", err)
+		pm.logger.WarnContext(ctx, "GitOps initialization failed", "error", err)
 	}
 
 	if err := pm.initializeGCP(ctx); err != nil {
@@ -87,23 +91,23 @@ func (pm *ProviderManager) initializeGCP(ctx context.Context) error {
 	}
 
 	if gcpConfig != nil {
-		log.Printf("GCP initialized successfully with project: %s", gcpConfig.ProjectID)
+		pm.logger.InfoContext(ctx, "GCP initialized", "project", gcpConfig.ProjectID)
 		pm.providers["gcp"] = pm.gcpService
 
 		// Load GCP resources into config (zones, machine types, etc)
 		if err := pm.gcpResourcesService.LoadIntoConfig(pm.cfg); err != nil {
-			log.Printf("Warning: Failed to load GCP resources: %v", err)
+			pm.logger.WarnContext(ctx, "failed to load GCP resources", "error", err)
 		}
 
 		// Only initialize infrastructure if not already ready
 		if gcpConfig.InfrastructureStatus != "ready" {
-			log.Printf("Infrastructure status: %s - starting initialization", gcpConfig.InfrastructureStatus)
+			pm.logger.InfoContext(ctx, "starting infrastructure initialization", "provider", "gcp", "status", gcpConfig.InfrastructureStatus)
 			go pm.initializeGCPInfrastructure(ctx, gcpConfig.ID)
 		} else {
-			log.Println("Infrastructure already ready - skipping initialization")
+			pm.logger.InfoContext(ctx, "infrastructure already ready, skipping initialization", "provider", "gcp")
 		}
 	} else {
-		log.Println("GCP not configured. Skipping initialization")
+		pm.logger.InfoContext(ctx, "GCP not configured, skipping initialization")
 	}
 
 	return nil
@@ -120,12 +124,12 @@ func (pm *ProviderManager) initializeGCPInfrastructure(ctx context.Context, conf
 		pm.wsManager.BroadcastInfrastructureStatus("initializing", "gcp")
 	}
 
-	log.Println("Starting GCP infrastructure initialization...")
+	pm.logger.InfoContext(ctx, "starting GCP infrastructure initialization")
 
 	// Get GCP config with credentials
 	var gcpConfig models.GCPConfig
 	if err := pm.db.Where("id = ?", configID).First(&gcpConfig).Error; err != nil {
-		log.Printf("Failed to get GCP config: %v", err)
+		pm.logger.ErrorContext(ctx, "failed to get GCP config", "error", err)
 		pm.db.Model(&models.GCPConfig{}).
 			Where("id = ?", configID).
 			Update("infrastructure_status", "failed")
@@ -136,9 +140,9 @@ func (pm *ProviderManager) initializeGCPInfrastructure(ctx context.Context, conf
 	}
 
 	// Ensure Talos images are uploaded and registered
-	log.Println("Checking Talos GCP images...")
+	pm.logger.InfoContext(ctx, "checking Talos GCP images")
 	if err := pm.talosService.EnsureTalosGCPImages(ctx, &gcpConfig); err != nil {
-		log.Printf("Failed to initialize Talos images: %v", err)
+		pm.logger.ErrorContext(ctx, "failed to initialize Talos images", "error", err)
 		pm.db.Model(&models.GCPConfig{}).
 			Where("id = ?", configID).
 			Update("infrastructure_status", "failed")
@@ -148,9 +152,9 @@ func (pm *ProviderManager) initializeGCPInfrastructure(ctx context.Context, conf
 		return
 	}
 
-	log.Println("Initializing GCP infrastructure (VPC, subnet)...")
+	pm.logger.InfoContext(ctx, "initializing GCP infrastructure (VPC, subnet)")
 	if err := pm.infrastructureService.InitializeInfrastructure(ctx, "gcp"); err != nil {
-		log.Printf("Failed to initialize GCP infrastructure: %v", err)
+		pm.logger.ErrorContext(ctx, "failed to initialize GCP infrastructure", "error", err)
 		pm.db.Model(&models.GCPConfig{}).
 			Where("id = ?", configID).
 			Update("infrastructure_status", "failed")
@@ -169,7 +173,7 @@ func (pm *ProviderManager) initializeGCPInfrastructure(ctx context.Context, conf
 		pm.wsManager.BroadcastInfrastructureStatus("ready", "gcp")
 	}
 
-	log.Println("GCP infrastructure initialized successfully")
+	pm.logger.InfoContext(ctx, "GCP infrastructure initialized")
 }
 
 func (pm *ProviderManager) GetProvider(name string) (Provider, bool) {
@@ -192,10 +196,9 @@ func (pm *ProviderManager) initializeGitOps(ctx context.Context) error {
 	}
 
 	if gitopsConfig != nil {
-		log.Printf("GitOps initialized successfully: %s/%s (branch: %s, workdir: %s)",
-			gitopsConfig.RepoOwner, gitopsConfig.RepoName, gitopsConfig.Branch, gitopsConfig.WorkingDir)
+		pm.logger.InfoContext(ctx, "GitOps initialized", "repo", gitopsConfig.RepoOwner+"/"+gitopsConfig.RepoName, "branch", gitopsConfig.Branch, "working_dir", gitopsConfig.WorkingDir)
 	} else {
-		log.Println("GitOps not configured. Will use environment variables if available")
+		pm.logger.InfoContext(ctx, "GitOps not configured, environment variables will be used if available")
 	}
 
 	return nil
@@ -0,0 +1,301 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
+	coreconfig "github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	configres "github.com/siderolabs/talos/pkg/machinery/resources/config"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+	"github.com/stolos-cloud/stolos/backend/internal/models"
+)
+
+// Phases of a control plane endpoint conversion
+const (
+	EndpointPhaseCertificates = "certificates"
+	EndpointPhaseEndpoint     = "endpoint"
+	EndpointPhaseTemplates    = "templates"
+	EndpointPhaseDone         = "done"
+	EndpointPhaseFailed       = "failed"
+)
+
+const (
+	nodeReadyTimeout     = 10 * time.Minute
+	endpointReadyTimeout = 5 * time.Minute
+	endpointPollInterval = 5 * time.Second
+)
+
+var ErrEndpointConversionRunning = errors.New("a control plane endpoint conversion is already running")
+
+// patchFunc builds the patches of a node from its current config
+type patchFunc func(current coreconfig.Provider) ([]configpatcher.Patch, error)
+
+// GetControlPlaneEndpoint returns the endpoint, VIP and cert SANs of the stored control plane config
+func (s *TalosService) GetControlPlaneEndpoint() (*models.ControlPlaneEndpointStatus, error) {
+	cpConfig, _, err := s.GetMachineConfigsFromDB()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := configloader.NewFromBytes(cpConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse controlplane config: %w", err)
+	}
+
+	status := &models.ControlPlaneEndpointStatus{
+		Endpoint: provider.Cluster().Endpoint().String(),
+		CertSANs: provider.Machine().Security().CertSANs(),
+	}
+	raw := provider.RawV1Alpha1()
+	if raw != nil && raw.ClusterConfig != nil && raw.ClusterConfig.APIServerConfig != nil {
+		status.CertSANs = raw.ClusterConfig.APIServerConfig.CertSANs
+	}
+	if raw != nil && raw.MachineConfig != nil && raw.MachineConfig.MachineNetwork != nil {
+		for _, device := range raw.MachineConfig.MachineNetwork.NetworkInterfaces {
+			if device.DeviceVIPConfig != nil && device.DeviceVIPConfig.SharedIP != "" {
+				status.VIP = device.DeviceVIPConfig.SharedIP
+			}
+		}
+	}
+
+	s.endpointMu.Lock()
+	if s.endpointConversion != nil {
+		conversion := *s.endpointConversion
+		status.Conversion = &conversion
+	}
+	s.endpointMu.Unlock()
+
+	return status, nil
+}
+
+// StartControlPlaneEndpointConversion validates the request and converts the cluster in the background.
+// Only one conversion runs at a time.
+func (s *TalosService) StartControlPlaneEndpointConversion(req *models.ControlPlaneEndpointRequest) (*models.EndpointConversion, error) {
+	info := &talos.TalosInfo{ControlPlaneVIP: req.VIP, ControlPlaneVIPInterface: req.Interface, ControlPlaneEndpoint: req.Endpoint}
+	if err := info.ValidateEndpoint(); err != nil {
+		return nil, err
+	}
+	host := info.ControlPlaneEndpointHost("")
+	if host == "" {
+		return nil, fmt.Errorf("vip or endpoint is required")
+	}
+
+	s.endpointMu.Lock()
+	defer s.endpointMu.Unlock()
+	if c := s.endpointConversion; c != nil && c.FinishedAt == nil {
+		return nil, fmt.Errorf("%w (to %s)", ErrEndpointConversionRunning, c.Endpoint)
+	}
+	s.endpointConversion = &models.EndpointConversion{
+		Endpoint:  talos.ClusterEndpointURL(host),
+		Phase:     EndpointPhaseCertificates,
+		StartedAt: time.Now(),
+	}
+	conversion := *s.endpointConversion
+
+	go func() {
+		err := s.ConvertControlPlaneEndpoint(context.Background(), req)
+		now := time.Now()
+		s.endpointMu.Lock()
+		defer s.endpointMu.Unlock()
+		s.endpointConversion.FinishedAt = &now
+		s.endpointConversion.Node = ""
+		if err != nil {
+			s.logger.Error("control plane endpoint conversion failed", "endpoint", conversion.Endpoint, "error", err)
+			s.endpointConversion.Phase = EndpointPhaseFailed
+			s.endpointConversion.Error = err.Error()
+			return
+		}
+		s.endpointConversion.Phase = EndpointPhaseDone
+	}()
+
+	return &conversion, nil
+}
+
+// ConvertControlPlaneEndpoint moves an existing cluster to a VIP or external endpoint, one node at a time:
+//  1. control planes get the new cert SANs (and the VIP), the old endpoint keeps working
+//  2. once the new endpoint answers, every node is pointed at it
+//  3. the stored machine config templates are updated so new nodes join through it
+func (s *TalosService) ConvertControlPlaneEndpoint(ctx context.Context, req *models.ControlPlaneEndpointRequest) error {
+	info := &talos.TalosInfo{ControlPlaneVIP: req.VIP, ControlPlaneVIPInterface: req.Interface, ControlPlaneEndpoint: req.Endpoint}
+	host := info.ControlPlaneEndpointHost("")
+	endpoint := talos.ClusterEndpointURL(host)
+	sans := talos.EndpointCertSANs(host, req.BaseDomain)
+
+	var nodes []models.Node
+	if err := s.db.Where("status = ? AND ip_address <> ''", models.StatusActive).Order("name").Find(&nodes).Error; err != nil {
+		return fmt.Errorf("failed to load nodes: %w", err)
+	}
+	var controlPlanes, workers []models.Node
+	for _, node := range nodes {
+		if node.Role == "control-plane" {
+			controlPlanes = append(controlPlanes, node)
+		} else {
+			workers = append(workers, node)
+		}
+	}
+	if len(controlPlanes) == 0 {
+		return fmt.Errorf("no active control plane nodes")
+	}
+
+	controlPlanePatches := func(current coreconfig.Provider) ([]configpatcher.Patch, error) {
+		patches := []configpatcher.Patch{talos.CreateCertSANsPatch(current.RawV1Alpha1(), sans, true)}
+		if req.VIP != "" {
+			patches = append(patches, talos.CreateVIPPatch(current.RawV1Alpha1(), req.VIP, req.Interface))
+		}
+		return patches, nil
+	}
+	endpointPatches := func(coreconfig.Provider) ([]configpatcher.Patch, error) {
+		patch, err := talos.CreateEndpointPatch(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return []configpatcher.Patch{patch}, nil
+	}
+
+	for _, node := range controlPlanes {
+		s.setEndpointPhase(EndpointPhaseCertificates, node.Name)
+		if err := s.patchNodeAndWait(ctx, node.IPAddress, controlPlanePatches); err != nil {
+			return fmt.Errorf("failed to add cert SANs to %s: %w", node.Name, err)
+		}
+	}
+
+	if err := waitEndpointReachable(ctx, host); err != nil {
+		return err
+	}
+
+	for _, node := range append(controlPlanes, workers...) {
+		s.setEndpointPhase(EndpointPhaseEndpoint, node.Name)
+		if err := s.patchNodeAndWait(ctx, node.IPAddress, endpointPatches); err != nil {
+			return fmt.Errorf("failed to switch %s to %s: %w", node.Name, endpoint, err)
+		}
+	}
+
+	s.setEndpointPhase(EndpointPhaseTemplates, "")
+	return s.patchStoredConfigs(controlPlanePatches, endpointPatches)
+}
+
+func (s *TalosService) setEndpointPhase(phase, node string) {
+	s.endpointMu.Lock()
+	defer s.endpointMu.Unlock()
+	if s.endpointConversion != nil {
+		s.endpointConversion.Phase = phase
+		s.endpointConversion.Node = node
+	}
+	if node != "" {
+		s.logger.Info("control plane endpoint conversion", "phase", phase, "node", node)
+	}
+}
+
+// patchNodeAndWait patches the running config of a node and waits for it to be ready again
+func (s *TalosService) patchNodeAndWait(ctx context.Context, nodeIP string, patches patchFunc) error {
+	cli, err := s.GetMachineryClientWithCtx(ctx, nodeIP)
+	if err != nil {
+		return fmt.Errorf("failed to get Talos client: %w", err)
+	}
+	defer cli.Close()
+
+	active, err := GetTypedTalosResource[*configres.MachineConfig](ctx, cli, configres.NamespaceName, configres.MachineConfigType, configres.ActiveID)
+	if err != nil {
+		return fmt.Errorf("failed to read machine config: %w", err)
+	}
+	current := active.Provider()
+
+	nodePatches, err := patches(current)
+	if err != nil {
+		return err
+	}
+	patched, err := configpatcher.Apply(configpatcher.WithConfig(current), nodePatches)
+	if err != nil {
+		return fmt.Errorf("failed to patch machine config: %w", err)
+	}
+	data, err := patched.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to serialize machine config: %w", err)
+	}
+
+	if _, err := cli.ApplyConfiguration(ctx, &machineapi.ApplyConfigurationRequest{
+		Data: data,
+		Mode: machineapi.ApplyConfigurationRequest_AUTO,
+	}); err != nil {
+		return fmt.Errorf("failed to apply configuration: %w", err)
+	}
+
+	deadline := time.Now().Add(nodeReadyTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(endpointPollInterval)
+		status, err := GetMachineStatus(cli)
+		if err == nil && status.Stage == runtime.MachineStageRunning && status.Status.Ready {
+			return nil
+		}
+	}
+	return fmt.Errorf("node %s not ready after %s", nodeIP, nodeReadyTimeout)
+}
+
+// waitEndpointReachable waits for the Kubernetes API to answer on the new endpoint
+func waitEndpointReachable(ctx context.Context, host string) error {
+	address := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		address = net.JoinHostPort(host, talos.KubernetesAPIPort)
+	}
+
+	deadline := time.Now().Add(endpointReadyTimeout)
+	dialer := net.Dialer{Timeout: endpointPollInterval}
+	for time.Now().Before(deadline) {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(endpointPollInterval)
+	}
+	return fmt.Errorf("new endpoint %s not reachable after %s, nodes still use the old endpoint", address, endpointReadyTimeout)
+}
+
+// patchStoredConfigs updates the machine config templates used to provision new nodes
+func (s *TalosService) patchStoredConfigs(controlPlanePatches, endpointPatches patchFunc) error {
+	var cluster models.Cluster
+	if err := s.db.First(&cluster).Error; err != nil {
+		return fmt.Errorf("no cluster found in database: %w", err)
+	}
+	if len(cluster.ControlPlaneConfig) == 0 || len(cluster.WorkerConfig) == 0 {
+		s.logger.Warn("no machine config templates in database, new nodes will use the old endpoint")
+		return nil
+	}
+
+	cpConfig, err := patchStoredConfig(cluster.ControlPlaneConfig, controlPlanePatches, endpointPatches)
+	if err != nil {
+		return fmt.Errorf("failed to patch controlplane config: %w", err)
+	}
+	workerConfig, err := patchStoredConfig(cluster.WorkerConfig, endpointPatches)
+	if err != nil {
+		return fmt.Errorf("failed to patch worker config: %w", err)
+	}
+
+	return s.StoreTalosConfig(cluster.ID, cluster.TalosVersion, cluster.KubeVersion, cluster.TalosConfig, cpConfig, workerConfig, cluster.ConfigBundle)
+}
+
+func patchStoredConfig(data []byte, patches ...patchFunc) ([]byte, error) {
+	current, err := configloader.NewFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	var all []configpatcher.Patch
+	for _, patch := range patches {
+		p, err := patch(current)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, p...)
+	}
+	patched, err := configpatcher.Apply(configpatcher.WithConfig(current), all)
+	if err != nil {
+		return nil, err
+	}
+	return patched.Bytes()
+}
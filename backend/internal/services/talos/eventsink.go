@@ -3,13 +3,13 @@ package talos
 import (
 	"context"
 	"fmt"
-	"log"
 	"slices"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/siderolabs/siderolink/pkg/events"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+	"github.com/stolos-cloud/stolos/backend/internal/metrics"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	wsservices "github.com/stolos-cloud/stolos/backend/internal/services/websocket"
 	"gorm.io/gorm"
@@ -19,11 +19,11 @@ import (
 func (s *TalosService) StartEventSink() {
 	// Skip if event sink hostname is not configured
 	if s.cfg.Talos.EventSinkHostname == "" {
-		log.Println("Talos event sink hostname not configured, skipping event sink startup")
+		s.logger.Info("Talos event sink hostname not configured, skipping event sink startup")
 		return
 	}
 
-	log.Printf("Starting Talos event sink on %s:%s", s.cfg.Talos.EventSinkBindHostname, s.cfg.Talos.EventSinkPort)
+	s.logger.Info("starting Talos event sink", "hostname", s.cfg.Talos.EventSinkBindHostname, "port", s.cfg.Talos.EventSinkPort)
 
 	// Prepare talosInfo struct for EventSink (use bind hostname for actual binding)
 	talosInfo := &talos.TalosInfo{
@@ -33,7 +33,7 @@ func (s *TalosService) StartEventSink() {
 
 	// Start EventSink
 	go func() {
-		err := talos.EventSink(talosInfo, func(ctx context.Context, event events.Event) error {
+		handleEvent := func(ctx context.Context, event events.Event) error {
 			// Extract IP from event.Node
 			ip := strings.Split(event.Node, ":")[0]
 
@@ -61,16 +61,16 @@ func (s *TalosService) StartEventSink() {
 
 				if err != nil {
 					//ignoredNodesCache = append(ignoredNodesCache, ip)
-					log.Printf("Ignoring node %s: %v", ip, err)
+					s.logger.WarnContext(ctx, "ignoring node", "ip", ip, "error", err)
 					return errors.Wrapf(err, "Error connecting to node %s, skipping", ip)
 				}
 
 				var mac string
-				if iface := GetMachineBestExternalNetworkInterface(ctx, cli); iface != nil {
+				if iface := GetMachineBestExternalNetworkInterface(ctx, s.logger, cli); iface != nil {
 					mac = iface.Mac
 				}
 
-				log.Printf("Found machine at %s with stage %s", ip, status.Stage.String())
+				s.logger.InfoContext(ctx, "found machine", "ip", ip, "stage", status.Stage.String())
 
 				var cluster models.Cluster
 				s.db.First(&cluster, models.Cluster{})
@@ -87,11 +87,11 @@ func (s *TalosService) StartEventSink() {
 				}
 
 				if err := s.db.Create(&node).Error; err != nil {
-					log.Printf("Failed to auto-register node %s: %v", ip, err)
+					s.logger.ErrorContext(ctx, "failed to auto-register node", "ip", ip, "error", err)
 					return err
 				}
 
-				log.Printf("Auto-registered new on-prem node: %s (IP: %s)", node.Name, ip)
+				s.logger.InfoContext(ctx, "auto-registered new on-prem node", "node", node.Name, "ip", ip)
 
 				if s.wsManager != nil {
 					s.wsManager.BroadcastToSessionType(wsservices.SessionTypeEvent, wsservices.Message{
@@ -102,19 +102,24 @@ func (s *TalosService) StartEventSink() {
 					})
 				}
 			} else if err != nil {
-				log.Printf("Error checking node existence for IP %s: %v", ip, err)
+				s.logger.ErrorContext(ctx, "failed to check node existence", "ip", ip, "error", err)
 				return err
 			} else {
-				log.Printf("Node with IP %s already registered", ip)
+				s.logger.DebugContext(ctx, "node already registered", "ip", ip)
 			}
 
 			return nil
-		})
+		}
 
+		err := talos.EventSink(talosInfo, func(ctx context.Context, event events.Event) error {
+			err := handleEvent(ctx, event)
+			metrics.ObserveTalosEvent(event.TypeURL, err)
+			return err
+		})
 		if err != nil {
-			log.Printf("Talos event sink error: %v", err)
+			s.logger.Error("Talos event sink failed", "error", err)
 		}
 	}()
 
-	log.Printf("Talos event sink started successfully")
+	s.logger.Info("Talos event sink started")
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -22,15 +21,15 @@ import (
 // EnsureTalosGCPImages ensures Talos images are uploaded and registered in GCP
 // This is called during provider initialization
 func (s *TalosService) EnsureTalosGCPImages(ctx context.Context, gcpConfig *models.GCPConfig) error {
-	log.Printf("Checking Talos images for GCP (version: %s)", gcpConfig.TalosVersion)
+	s.logger.InfoContext(ctx, "checking Talos images for GCP", "talos_version", gcpConfig.TalosVersion)
 
 	// Check if AMD64 image already exists (we skip ARM64 for now..)
 	if gcpConfig.TalosImageAMD64 != "" {
-		log.Println("Talos images already configured")
+		s.logger.InfoContext(ctx, "Talos images already configured")
 		return nil
 	}
 
-	log.Println("Talos AMD64 image not found, starting upload process...")
+	s.logger.InfoContext(ctx, "Talos AMD64 image not found, starting upload")
 
 	// Process AMD64 image if missing
 	if gcpConfig.TalosImageAMD64 == "" {
@@ -46,14 +45,14 @@ func (s *TalosService) EnsureTalosGCPImages(ctx context.Context, gcpConfig *mode
 			Update("talos_image_amd64", imageName).Error; err != nil {
 			return fmt.Errorf("failed to update AMD64 image name in database: %w", err)
 		}
-		log.Printf("AMD64 image registered: %s", imageName)
+		s.logger.InfoContext(ctx, "AMD64 image registered", "image", imageName)
 	}
 
 	// Process ARM64 image if missing
 	// For now, skip ARM64 to save time during initialization
-	log.Println("Skipping ARM64 image")
+	s.logger.InfoContext(ctx, "skipping ARM64 image")
 
-	log.Println("Talos GCP images configured successfully")
+	s.logger.InfoContext(ctx, "Talos GCP images configured")
 	return nil
 }
 
@@ -64,7 +63,7 @@ func (s *TalosService) uploadAndRegisterTalosImage(ctx context.Context, gcpConfi
 		version = "v1.11.1"
 	}
 
-	log.Printf("Processing Talos %s image for %s...", version, arch)
+	s.logger.InfoContext(ctx, "processing Talos image", "talos_version", version, "arch", arch)
 
 	localPath, err := s.downloadTalosGCPImage(ctx, version, arch)
 	if err != nil {
@@ -72,7 +71,7 @@ func (s *TalosService) uploadAndRegisterTalosImage(ctx context.Context, gcpConfi
 	}
 	defer os.RemoveAll(filepath.Dir(localPath)) // Clean up temp directory
 
-	log.Printf("Downloaded Talos image to: %s", localPath)
+	s.logger.InfoContext(ctx, "downloaded Talos image", "path", localPath)
 
 	// Upload to GCS bucket
 	gcsPath := fmt.Sprintf("talos-images/talos-%s-%s.raw.tar.gz", version, arch)
@@ -80,7 +79,7 @@ func (s *TalosService) uploadAndRegisterTalosImage(ctx context.Context, gcpConfi
 		return "", fmt.Errorf("failed to upload to GCS: %w", err)
 	}
 
-	log.Printf("Uploaded to GCS: gs://%s/%s", gcpConfig.BucketName, gcsPath)
+	s.logger.InfoContext(ctx, "uploaded Talos image to GCS", "bucket", gcpConfig.BucketName, "object", gcsPath)
 
 	// Register as GCP compute image with cluster name prefix
 	// Format: <cluster>-talos-<version>-<arch> (e.g., prod-talos-1-11-1-amd64)
@@ -92,7 +91,7 @@ func (s *TalosService) uploadAndRegisterTalosImage(ctx context.Context, gcpConfi
 		return "", fmt.Errorf("failed to register image: %w", err)
 	}
 
-	log.Printf("Registered GCP image: %s", imageName)
+	s.logger.InfoContext(ctx, "registered GCP image", "image", imageName)
 
 	return imageName, nil
 }
@@ -105,12 +104,12 @@ func (s *TalosService) downloadTalosGCPImage(ctx context.Context, version, arch
 		return "", fmt.Errorf("failed to create schematic: %w", err)
 	}
 
-	log.Printf("Created schematic: %s", schematicID)
+	s.logger.InfoContext(ctx, "created schematic", "schematic_id", schematicID)
 
 	// Format: https://factory.talos.dev/image/{schematicID}/{version}/gcp-{arch}.raw.tar.gz
 	url := fmt.Sprintf("https://factory.talos.dev/image/%s/%s/gcp-%s.raw.tar.gz", schematicID, version, arch)
 
-	log.Printf("Downloading from Image Factory: %s", url)
+	s.logger.InfoContext(ctx, "downloading from Image Factory", "url", url)
 
 	tempDir, err := os.MkdirTemp("", "talos-image-*")
 	if err != nil {
@@ -148,7 +147,7 @@ func (s *TalosService) downloadTalosGCPImage(ctx context.Context, version, arch
 		return "", fmt.Errorf("failed to write image data: %w", err)
 	}
 
-	log.Printf("Downloaded %d bytes", size)
+	s.logger.InfoContext(ctx, "downloaded Talos image", "bytes", size)
 
 	return localPath, nil
 }
@@ -175,7 +174,7 @@ func (s *TalosService) uploadToGCS(ctx context.Context, gcpConfig *models.GCPCon
 		return fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	log.Printf("Uploading %d bytes to GCS...", stat.Size())
+	s.logger.InfoContext(ctx, "uploading Talos image to GCS", "bytes", stat.Size())
 
 	// Create object writer
 	bucket := client.Bucket(gcpConfig.BucketName)
@@ -193,7 +192,7 @@ func (s *TalosService) uploadToGCS(ctx context.Context, gcpConfig *models.GCPCon
 		return fmt.Errorf("failed to finalize upload: %w", err)
 	}
 
-	log.Println("Upload to GCS completed")
+	s.logger.InfoContext(ctx, "upload to GCS completed")
 
 	return nil
 }
@@ -210,7 +209,7 @@ func (s *TalosService) createDefaultSchematic() schematic.Schematic {
 
 // registerGCPImage creates a GCP compute image from a GCS tarball
 func (s *TalosService) registerGCPImage(ctx context.Context, gcpConfig *models.GCPConfig, imageName, gcsPath string) error {
-	log.Printf("Registering GCP image: %s", imageName)
+	s.logger.InfoContext(ctx, "registering GCP image", "image", imageName)
 
 	// Create compute client with service account credentials
 	client, err := compute.NewImagesRESTClient(ctx, option.WithCredentialsJSON([]byte(gcpConfig.ServiceAccountKeyJSON)))
@@ -225,7 +224,7 @@ func (s *TalosService) registerGCPImage(ctx context.Context, gcpConfig *models.G
 		Image:   imageName,
 	})
 	if err == nil {
-		log.Printf("Image %s already exists, skipping registration", imageName)
+		s.logger.InfoContext(ctx, "GCP image already exists, skipping registration", "image", imageName)
 		return nil
 	}
 
@@ -248,7 +247,7 @@ func (s *TalosService) registerGCPImage(ctx context.Context, gcpConfig *models.G
 		},
 	}
 
-	log.Println("Creating GCP compute image (this may take several minutes)...")
+	s.logger.InfoContext(ctx, "creating GCP compute image, this may take several minutes", "image", imageName)
 
 	op, err := client.Insert(ctx, req)
 	if err != nil {
@@ -260,7 +259,7 @@ func (s *TalosService) registerGCPImage(ctx context.Context, gcpConfig *models.G
 		return fmt.Errorf("image creation failed: %w", err)
 	}
 
-	log.Printf("GCP image %s created successfully", imageName)
+	s.logger.InfoContext(ctx, "GCP image created", "image", imageName)
 
 	return nil
 }
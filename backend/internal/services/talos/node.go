@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"strings"
 	"time"
@@ -19,14 +19,14 @@ import (
 )
 
 // BuildNodeModelFromResources inspects MachineStatus (stage) & LinkStatus (MAC) and builds a minimal Node.
-func BuildNodeModelFromResources(ctx context.Context, c *machineryClient.Client, nodeIP string) (*models.Node, error) {
+func BuildNodeModelFromResources(ctx context.Context, logger *slog.Logger, c *machineryClient.Client, nodeIP string) (*models.Node, error) {
 	node := &models.Node{
 		IPAddress: nodeIP,
 		Provider:  "onprem",
 		Status:    models.StatusPending,
 	}
 
-	if iface := GetMachineBestExternalNetworkInterface(ctx, c); iface != nil {
+	if iface := GetMachineBestExternalNetworkInterface(ctx, logger, c); iface != nil {
 		node.MACAddress = iface.Mac
 	}
 
@@ -94,10 +94,10 @@ type NodeNetworkIface struct {
 }
 
 // GetMachineBestExternalNetworkInterface tries to find the external Mac address of primary net interface
-func GetMachineBestExternalNetworkInterface(ctx context.Context, c *machineryClient.Client) *NodeNetworkIface {
+func GetMachineBestExternalNetworkInterface(ctx context.Context, logger *slog.Logger, c *machineryClient.Client) *NodeNetworkIface {
 	linkList, err := GetTypedTalosResourceList[*netres.LinkStatus](ctx, c, netres.NamespaceName, "Link")
 	if err != nil {
-		log.Printf("GetMachineBestExternalNetworkInterface: failed to get link list: %v", err)
+		logger.WarnContext(ctx, "failed to get link list", "error", err)
 		return nil
 	}
 
@@ -134,7 +134,7 @@ func GetMachineBestExternalNetworkInterface(ctx context.Context, c *machineryCli
 		if best.Score > 0 {
 			return &best
 		}
-		log.Printf("GetMachineBestExternalNetworkInterface: no suitable network interface found")
+		logger.WarnContext(ctx, "no suitable network interface found")
 	}
 	return nil
 }
@@ -5,10 +5,12 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 	factoryClient "github.com/siderolabs/image-factory/pkg/client"
@@ -23,8 +25,10 @@ import (
 	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
 	"github.com/stolos-cloud/stolos/backend/internal/config"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 	"github.com/stolos-cloud/stolos/backend/internal/models"
 	wsservices "github.com/stolos-cloud/stolos/backend/internal/services/websocket"
+	"github.com/stolos-cloud/stolos/backend/internal/tracing"
 	"gorm.io/gorm"
 )
 
@@ -35,6 +39,10 @@ type TalosService struct {
 	cfg           *config.Config
 	factoryClient *factoryClient.Client
 	wsManager     *wsservices.Manager
+	logger        *slog.Logger
+
+	endpointMu         sync.Mutex
+	endpointConversion *models.EndpointConversion
 }
 
 // MachineConfigRequest represents parameters for generating machine configs
@@ -45,13 +53,14 @@ type MachineConfigRequest struct {
 	ControlPlaneIP    string `json:"control_plane_ip"`
 }
 
-func NewTalosService(db *gorm.DB, cfg *config.Config, wsManager *wsservices.Manager) *TalosService {
+func NewTalosService(db *gorm.DB, cfg *config.Config, wsManager *wsservices.Manager, logs *logging.Logging) *TalosService {
 	factory := talos.CreateFactoryClient()
 	return &TalosService{
 		db:            db,
 		cfg:           cfg,
 		factoryClient: factory,
 		wsManager:     wsManager,
+		logger:        logs.Logger(logging.SubsystemTalos),
 	}
 }
 
@@ -182,6 +191,7 @@ func (s *TalosService) GetMachineryClient(nodeIP string) (*machineryClient.Clien
 		context.Background(),
 		machineryClient.WithConfig(talosconfig), // talosconfig provides certs
 		machineryClient.WithEndpoints(endpoint),
+		machineryClient.WithGRPCDialOptions(tracing.GRPCDialOption()),
 	)
 }
 
@@ -212,6 +222,7 @@ func (s *TalosService) GetMachineryClientWithCtx(ctx context.Context, endpointNo
 		ctx,
 		machineryClient.WithConfig(talosconfig), // talosconfig provides certs
 		machineryClient.WithEndpoints(endpoint),
+		machineryClient.WithGRPCDialOptions(tracing.GRPCDialOption()),
 	)
 }
 
@@ -246,6 +257,7 @@ func GetInsecureMachineryClient(ctx context.Context, nodeIP string) (*machineryC
 	return machineryClient.New(context.Background(),
 		machineryClient.WithEndpoints(endpoint),
 		machineryClient.WithTLSConfig(tlsCfg),
+		machineryClient.WithGRPCDialOptions(tracing.GRPCDialOption()),
 	)
 }
 
@@ -345,7 +357,7 @@ func (s *TalosService) CreateExistingNodeFromIP(ctx context.Context, nodeIP stri
 	// version, err := GetTypedTalosResource[*runtime.Version](ctx, cli, runtime.NamespaceName, runtime.VersionType, "runtime")
 	// node.Architecture = version.TypedSpec().Version
 
-	if iface := GetMachineBestExternalNetworkInterface(ctx, cli); iface != nil {
+	if iface := GetMachineBestExternalNetworkInterface(ctx, s.logger, cli); iface != nil {
 		node.MACAddress = iface.Mac
 	}
 
@@ -477,13 +489,19 @@ func (s *TalosService) MigrateTalosConfigFromFiles() error {
 		return fmt.Errorf("failed to store configs in database: %w", err)
 	}
 
-	fmt.Printf("Successfully migrated Talos configs from %s to database\n", s.cfg.TalosFolder)
+	s.logger.Info("migrated Talos configs to database", "folder", s.cfg.TalosFolder)
 	return nil
 }
 
 // CreateMachineConfigPatch creates a machine config patch for a node.
 // It applies hostname, disk, network settings, and node labels (including static subnets for hybrid cloud).
 func CreateMachineConfigPatch(hostname, installDisk string, labels []string) (configpatcher.Patch, error) {
+	return CreateMachineConfigPatchWithTaints(hostname, installDisk, labels, nil)
+}
+
+// CreateMachineConfigPatchWithTaints creates a machine config patch for a node that registers with taints.
+// Taints map a key to "value:Effect" (e.g. "true:NoSchedule").
+func CreateMachineConfigPatchWithTaints(hostname, installDisk string, labels []string, taints map[string]string) (configpatcher.Patch, error) {
 	kubeletConfig := &v1alpha1.KubeletConfig{
 		KubeletNodeIP: &v1alpha1.KubeletNodeIPConfig{
 			// Use static private network subnets for node IP selection
@@ -514,7 +532,8 @@ func CreateMachineConfigPatch(hostname, installDisk string, labels []string) (co
 				// Explicitly set diskSelector to nil to remove hardware-specific busPath
 				InstallDiskSelector: nil,
 			},
-			MachineKubelet: kubeletConfig,
+			MachineKubelet:    kubeletConfig,
+			MachineNodeTaints: taints,
 		},
 	}
 
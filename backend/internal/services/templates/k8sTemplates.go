@@ -41,7 +41,6 @@ func ListTemplates(client *k8s.K8sClient, groupFilter string) ([]Template, error
 	// List all CRDs
 	crdList, err := client.ApiExtensionClient.ApiextensionsV1().CustomResourceDefinitions().List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		//log.Fatalf("Failed to list CRDs: %v", err)
 		return nil, err
 	}
 
@@ -71,8 +70,8 @@ func GetTemplate(client *k8s.K8sClient, name string) (Template, error) {
 	return Template{}, fmt.Errorf("template %s not found", name)
 }
 
-func ListDeploymentsForFilter(client *k8s.K8sClient, filter k8s.K8sResourceFilter) ([]Deployment, error) {
-	allCRs, err := client.GetAllResourcesWithFilter(filter)
+func ListDeploymentsForFilter(ctx context.Context, client *k8s.K8sClient, filter k8s.K8sResourceFilter) ([]Deployment, error) {
+	allCRs, err := client.GetAllResourcesWithFilter(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
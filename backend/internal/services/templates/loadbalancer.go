@@ -0,0 +1,47 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stolos-cloud/stolos/backend/internal/services/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var ipAddressPoolGVR = schema.GroupVersionResource{Group: "metallb.io", Version: "v1beta1", Resource: "ipaddresspools"}
+
+// LoadBalancerPool is a MetalLB address pool, the templates request one for their LoadBalancer services
+type LoadBalancerPool struct {
+	Name       string   `json:"name"`
+	Addresses  []string `json:"addresses"`
+	AutoAssign bool     `json:"auto_assign"`
+	Namespaces []string `json:"namespaces,omitempty"` // only these namespaces can use the pool when set
+}
+
+// ListLoadBalancerPools lists the MetalLB address pools, none when MetalLB is not installed
+func ListLoadBalancerPools(ctx context.Context, client *k8s.K8sClient) ([]LoadBalancerPool, error) {
+	list, err := client.DynamicClient.Resource(ipAddressPoolGVR).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return []LoadBalancerPool{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list address pools: %w", err)
+	}
+
+	pools := make([]LoadBalancerPool, 0, len(list.Items))
+	for _, item := range list.Items {
+		addresses, _, _ := unstructured.NestedStringSlice(item.Object, "spec", "addresses")
+		namespaces, _, _ := unstructured.NestedStringSlice(item.Object, "spec", "allocateTo", "namespaces")
+		autoAssign, found, _ := unstructured.NestedBool(item.Object, "spec", "autoAssign")
+		pools = append(pools, LoadBalancerPool{
+			Name:       item.GetName(),
+			Addresses:  addresses,
+			AutoAssign: autoAssign || !found, // MetalLB defaults to true
+			Namespaces: namespaces,
+		})
+	}
+	return pools, nil
+}
@@ -33,9 +33,8 @@ func ParsePlanJSON(planJSON []byte) ([]models.TerraformResourceUpdate, error) {
 		var action string
 
 		if change.Change != nil && len(change.Change.Actions) > 0 {
-			if len(change.Change.Actions) == 2 &&
-				change.Change.Actions[0] == tfjson.ActionCreate &&
-				change.Change.Actions[1] == tfjson.ActionDelete {
+			// Both [create, delete] and the default [delete, create] ordering are replacements
+			if change.Change.Actions.Replace() {
 				action = "replace"
 			} else {
 				action = string(change.Change.Actions[0])
@@ -97,24 +96,84 @@ func extractResourceDetails(change *tfjson.ResourceChange) map[string]any {
 		}
 
 		if ok {
-			// Extract commonly useful fields
-			if name, ok := sourceMap["name"].(string); ok {
-				details["name"] = name
-			}
-			if zone, ok := sourceMap["zone"].(string); ok {
-				details["zone"] = zone
+			extractKeyFields(sourceMap, details)
+		}
+
+		// For updates and replacements keep the previous values so cost deltas can be computed
+		if change.Change.After != nil && change.Change.Before != nil {
+			if beforeMap, ok := change.Change.Before.(map[string]any); ok {
+				before := make(map[string]any)
+				extractKeyFields(beforeMap, before)
+				details["before"] = before
 			}
-			if region, ok := sourceMap["region"].(string); ok {
-				details["region"] = region
+		}
+	}
+
+	return details
+}
+
+// extractKeyFields copies commonly useful fields, including those needed for cost estimation
+func extractKeyFields(sourceMap map[string]any, details map[string]any) {
+	if name, ok := sourceMap["name"].(string); ok {
+		details["name"] = name
+	}
+	if zone, ok := sourceMap["zone"].(string); ok {
+		details["zone"] = zone
+	}
+	if region, ok := sourceMap["region"].(string); ok {
+		details["region"] = region
+	}
+	if machineType, ok := sourceMap["machine_type"].(string); ok {
+		details["machine_type"] = machineType
+	}
+	if size, ok := sourceMap["size"].(float64); ok {
+		details["size"] = size
+	}
+	if diskType, ok := sourceMap["type"].(string); ok {
+		details["disk_type"] = diskType
+	}
+	if addressType, ok := sourceMap["address_type"].(string); ok {
+		details["address_type"] = addressType
+	}
+
+	// Boot disk of an instance: boot_disk[0].initialize_params[0]
+	if bootDisks, ok := sourceMap["boot_disk"].([]any); ok && len(bootDisks) > 0 {
+		if bootDisk, ok := bootDisks[0].(map[string]any); ok {
+			if params, ok := bootDisk["initialize_params"].([]any); ok && len(params) > 0 {
+				if p, ok := params[0].(map[string]any); ok {
+					if size, ok := p["size"].(float64); ok {
+						details["boot_disk_size"] = size
+					}
+					if diskType, ok := p["type"].(string); ok {
+						details["boot_disk_type"] = diskType
+					}
+				}
 			}
-			if machineType, ok := sourceMap["machine_type"].(string); ok {
-				details["machine_type"] = machineType
+		}
+	}
+
+	// Spot VMs are priced with the spot rates: scheduling[0].provisioning_model, or the older preemptible flag
+	if schedulings, ok := sourceMap["scheduling"].([]any); ok && len(schedulings) > 0 {
+		if scheduling, ok := schedulings[0].(map[string]any); ok {
+			if model, ok := scheduling["provisioning_model"].(string); ok && model != "" {
+				details["provisioning_model"] = model
 			}
-			if size, ok := sourceMap["size"].(float64); ok {
-				details["size"] = size
+			if preemptible, ok := scheduling["preemptible"].(bool); ok && preemptible {
+				details["provisioning_model"] = models.ProvisioningModelSpot
 			}
 		}
 	}
 
-	return details
+	// An access_config block on a network interface means an ephemeral external IP
+	if nics, ok := sourceMap["network_interface"].([]any); ok {
+		for _, n := range nics {
+			nic, ok := n.(map[string]any)
+			if !ok {
+				continue
+			}
+			if accessConfigs, ok := nic["access_config"].([]any); ok && len(accessConfigs) > 0 {
+				details["external_ip"] = true
+			}
+		}
+	}
 }
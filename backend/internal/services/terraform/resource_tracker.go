@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -19,12 +19,14 @@ type ResourceTracker struct {
 	session   *wsservices.ApprovalSession
 	resources map[string]*models.TerraformResourceUpdate
 	workflow  *models.TerraformWorkflowUpdate
+	logger    *slog.Logger
 }
 
 // NewResourceTracker creates a new resource tracker
-func NewResourceTracker(session *wsservices.ApprovalSession) *ResourceTracker {
+func NewResourceTracker(session *wsservices.ApprovalSession, logger *slog.Logger) *ResourceTracker {
 	return &ResourceTracker{
 		session:   session,
+		logger:    logger,
 		resources: make(map[string]*models.TerraformResourceUpdate),
 		workflow: &models.TerraformWorkflowUpdate{
 			Resources: []models.TerraformResourceUpdate{},
@@ -77,18 +79,18 @@ func (rt *ResourceTracker) StreamApplyJSON(ctx context.Context, r io.Reader) err
 			continue
 		}
 
-		if err := rt.processApplyMessage(msg); err != nil {
-			log.Printf("Error processing apply message: %v", err)
+		if err := rt.processApplyMessage(ctx, msg); err != nil {
+			rt.logger.WarnContext(ctx, "failed to process apply message", "error", err)
 		}
 	}
 
-	rt.sendWorkflowUpdate()
+	rt.sendWorkflowUpdate(ctx)
 
 	return scanner.Err()
 }
 
 // processApplyMessage handles messages during the apply phase
-func (rt *ResourceTracker) processApplyMessage(msg TerraformJSONMessage) error {
+func (rt *ResourceTracker) processApplyMessage(ctx context.Context, msg TerraformJSONMessage) error {
 	switch msg.Type {
 	case "apply_start":
 		if msg.Hook != nil {
@@ -102,7 +104,7 @@ func (rt *ResourceTracker) processApplyMessage(msg TerraformJSONMessage) error {
 				}
 
 				resourceType := fmt.Sprintf("%v", resourceData["type"])
-				rt.startResourceOperation(addr, resourceType)
+				rt.startResourceOperation(ctx, addr, resourceType)
 			}
 		}
 
@@ -116,7 +118,7 @@ func (rt *ResourceTracker) processApplyMessage(msg TerraformJSONMessage) error {
 					return nil
 				}
 
-				rt.completeResourceOperation(addr)
+				rt.completeResourceOperation(ctx, addr)
 			}
 		}
 
@@ -124,7 +126,7 @@ func (rt *ResourceTracker) processApplyMessage(msg TerraformJSONMessage) error {
 		if msg.Hook != nil {
 			if resourceData, ok := msg.Hook["resource"].(map[string]any); ok {
 				addr := fmt.Sprintf("%v", resourceData["addr"])
-				rt.failResourceOperation(addr, msg.Diagnostic)
+				rt.failResourceOperation(ctx, addr, msg.Diagnostic)
 			}
 		}
 
@@ -140,7 +142,7 @@ func (rt *ResourceTracker) processApplyMessage(msg TerraformJSONMessage) error {
 		if msg.Hook != nil {
 			if outputs, ok := msg.Hook["outputs"].(map[string]any); ok {
 				rt.workflow.Outputs = outputs
-				rt.sendWorkflowUpdate()
+				rt.sendWorkflowUpdate(ctx)
 			}
 		}
 	}
@@ -149,7 +151,7 @@ func (rt *ResourceTracker) processApplyMessage(msg TerraformJSONMessage) error {
 }
 
 // startResourceOperation marks a resource as being created/modified/deleted
-func (rt *ResourceTracker) startResourceOperation(addr string, resourceType string) {
+func (rt *ResourceTracker) startResourceOperation(ctx context.Context, addr string, resourceType string) {
 	now := time.Now()
 
 	resource, exists := rt.resources[addr]
@@ -176,11 +178,11 @@ func (rt *ResourceTracker) startResourceOperation(addr string, resourceType stri
 
 	resource.StartedAt = &now
 
-	rt.sendResourceUpdate(resource)
+	rt.sendResourceUpdate(ctx, resource)
 }
 
 // completeResourceOperation marks a resource operation as complete
-func (rt *ResourceTracker) completeResourceOperation(addr string) {
+func (rt *ResourceTracker) completeResourceOperation(ctx context.Context, addr string) {
 	resource, exists := rt.resources[addr]
 	if !exists {
 		return
@@ -195,11 +197,11 @@ func (rt *ResourceTracker) completeResourceOperation(addr string) {
 		resource.Duration = duration.Round(time.Second).String()
 	}
 
-	rt.sendResourceUpdate(resource)
+	rt.sendResourceUpdate(ctx, resource)
 }
 
 // failResourceOperation marks a resource operation as failed
-func (rt *ResourceTracker) failResourceOperation(addr string, diagnostic *TerraformDiagnostic) {
+func (rt *ResourceTracker) failResourceOperation(ctx context.Context, addr string, diagnostic *TerraformDiagnostic) {
 	resource, exists := rt.resources[addr]
 	if !exists {
 		return
@@ -221,18 +223,18 @@ func (rt *ResourceTracker) failResourceOperation(addr string, diagnostic *Terraf
 		resource.Duration = duration.Round(time.Second).String()
 	}
 
-	rt.sendResourceUpdate(resource)
+	rt.sendResourceUpdate(ctx, resource)
 }
 
 // sendResourceUpdate sends a resource update via WebSocket
-func (rt *ResourceTracker) sendResourceUpdate(resource *models.TerraformResourceUpdate) {
+func (rt *ResourceTracker) sendResourceUpdate(ctx context.Context, resource *models.TerraformResourceUpdate) {
 	if err := rt.session.SendResourceUpdate(*resource); err != nil {
-		log.Printf("Failed to send resource update: %v", err)
+		rt.logger.WarnContext(ctx, "failed to send resource update", "resource", resource.ID, "error", err)
 	}
 }
 
 // sendWorkflowUpdate sends a workflow update via WebSocket
-func (rt *ResourceTracker) sendWorkflowUpdate() {
+func (rt *ResourceTracker) sendWorkflowUpdate(ctx context.Context) {
 	// Update resource list
 	rt.workflow.Resources = make([]models.TerraformResourceUpdate, 0, len(rt.resources))
 	for _, resource := range rt.resources {
@@ -240,7 +242,7 @@ func (rt *ResourceTracker) sendWorkflowUpdate() {
 	}
 
 	if err := rt.session.SendWorkflowUpdate(*rt.workflow); err != nil {
-		log.Printf("Failed to send workflow update: %v", err)
+		rt.logger.WarnContext(ctx, "failed to send workflow update", "error", err)
 	}
 }
 
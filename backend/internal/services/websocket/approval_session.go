@@ -53,12 +53,18 @@ func (as *ApprovalSession) HandleMessage(msgType string, data map[string]any) er
 
 // SendPlan sends terraform plan output
 func (as *ApprovalSession) SendPlan(plan string) error {
-	return as.client.SendPlan(plan)
+	if client := as.getClient(); client != nil {
+		return client.SendPlan(plan)
+	}
+	return nil
 }
 
 // SendApprovalRequest sends an approval request to the client
 func (as *ApprovalSession) SendApprovalRequest(summary string) error {
-	return as.client.SendApprovalRequest(summary)
+	if client := as.getClient(); client != nil {
+		return client.SendApprovalRequest(summary)
+	}
+	return nil
 }
 
 // WaitForApproval waits for user approval with timeout
@@ -88,15 +94,27 @@ func (as *ApprovalSession) WaitForApprovalCtx(ctx context.Context, timeout time.
 
 // SendResourceUpdate sends a resource update to the client
 func (as *ApprovalSession) SendResourceUpdate(resource any) error {
-	return as.client.SendResourceUpdate(resource)
+	if client := as.getClient(); client != nil {
+		return client.SendResourceUpdate(resource)
+	}
+	return nil
 }
 
 // SendWorkflowUpdate sends a workflow update to the client
 func (as *ApprovalSession) SendWorkflowUpdate(workflow any) error {
-	return as.client.SendWorkflowUpdate(workflow)
+	if client := as.getClient(); client != nil {
+		return client.SendWorkflowUpdate(workflow)
+	}
+	return nil
+}
+
+// Reattach moves the approval session to a new client so a running workflow can be watched
+// and approved from a new connection
+func (as *ApprovalSession) Reattach(client *Client) {
+	as.BaseSession.Reattach(client, as)
 }
 
-// Close cleans up approval session resources
+// Close is called when the client disconnects.
+// The approval channel is left open so the workflow keeps waiting and can be reattached.
 func (as *ApprovalSession) Close() {
-	close(as.approvalChan)
 }
@@ -1,5 +1,7 @@
 package websocket
 
+import "sync"
+
 // Session represents a WebSocket session for a specific use case
 type Session interface {
 	// GetRequestID returns the unique identifier for this session
@@ -16,6 +18,9 @@ type Session interface {
 	Close()
 }
 
+// maxLogHistory is the number of log lines kept for replay when a client reattaches
+const maxLogHistory = 500
+
 const (
 	SessionTypeGeneric  = "generic"
 	SessionTypeApproval = "approval"
@@ -28,6 +33,10 @@ type BaseSession struct {
 	requestID   string
 	sessionType string
 	client      *Client
+	mu          sync.RWMutex
+	logHistory  []string
+	lastStatus  string
+	traceID     string
 }
 
 // NewBaseSession creates a new base session with generic session type
@@ -59,29 +68,94 @@ func (bs *BaseSession) GetType() string {
 	return bs.sessionType
 }
 
+// SetTraceID sets the trace of the workflow, sent with the messages of the session
+func (bs *BaseSession) SetTraceID(traceID string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.traceID = traceID
+}
+
+// GetTraceID returns the trace ID of the workflow
+func (bs *BaseSession) GetTraceID() string {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	return bs.traceID
+}
+
+// getClient returns the currently attached client, which may be nil when no one is watching
+func (bs *BaseSession) getClient() *Client {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	return bs.client
+}
+
+// Reattach moves the session to a new client and replays the log history and last status
+func (bs *BaseSession) Reattach(client *Client, session Session) {
+	bs.mu.Lock()
+	bs.client = client
+	history := append([]string(nil), bs.logHistory...)
+	status := bs.lastStatus
+	bs.mu.Unlock()
+
+	if client == nil {
+		return
+	}
+	client.attachSession(session)
+
+	for _, message := range history {
+		client.SendLog(message)
+	}
+	if status != "" {
+		client.SendStatus(status)
+	}
+}
+
 // SendLog sends a log message
 func (bs *BaseSession) SendLog(message string) error {
-	return bs.client.SendLog(message)
+	bs.mu.Lock()
+	bs.logHistory = append(bs.logHistory, message)
+	if len(bs.logHistory) > maxLogHistory {
+		bs.logHistory = bs.logHistory[len(bs.logHistory)-maxLogHistory:]
+	}
+	bs.mu.Unlock()
+
+	if client := bs.getClient(); client != nil {
+		return client.SendLog(message)
+	}
+	return nil
 }
 
 // SendStatus sends a status update
 func (bs *BaseSession) SendStatus(status string) error {
-	return bs.client.SendStatus(status)
+	bs.mu.Lock()
+	bs.lastStatus = status
+	bs.mu.Unlock()
+
+	if client := bs.getClient(); client != nil {
+		return client.SendStatus(status)
+	}
+	return nil
 }
 
 // SendError sends an error message
 func (bs *BaseSession) SendError(err error) error {
-	return bs.client.SendError(err.Error())
+	return bs.SendErrorString(err.Error())
 }
 
 // SendErrorString sends an error message string
 func (bs *BaseSession) SendErrorString(errMsg string) error {
-	return bs.client.SendError(errMsg)
+	if client := bs.getClient(); client != nil {
+		return client.SendError(errMsg)
+	}
+	return nil
 }
 
 // SendComplete sends a completion message with optional data
 func (bs *BaseSession) SendComplete(data any) error {
-	return bs.client.SendComplete(data)
+	if client := bs.getClient(); client != nil {
+		return client.SendComplete(data)
+	}
+	return nil
 }
 
 // HandleMessage default implementation - does nothing
@@ -1,10 +1,11 @@
 package websocket
 
 import (
-	"log"
+	"log/slog"
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/stolos-cloud/stolos/backend/internal/logging"
 )
 
 // Message types sent over WebSocket
@@ -24,6 +25,7 @@ const (
 type Message struct {
 	Type    string `json:"type"`
 	Payload any    `json:"payload"`
+	TraceID string `json:"trace_id,omitempty"` // trace of the workflow of the session
 }
 
 // ApprovalResponse represents a user's response to an approval request
@@ -50,11 +52,13 @@ type Manager struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+	logger     *slog.Logger
 }
 
 // NewManager creates a new WebSocket manager
-func NewManager() *Manager {
+func NewManager(logs *logging.Logging) *Manager {
 	return &Manager{
+		logger:     logs.Logger(logging.SubsystemWebSocket),
 		clients:    make(map[string]*Client),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
@@ -67,16 +71,21 @@ func (m *Manager) Run() {
 		select {
 		case client := <-m.register:
 			m.mu.Lock()
+			// A reconnecting client replaces the previous connection for the same request
+			if previous, ok := m.clients[client.ID]; ok && previous != client {
+				close(previous.send)
+			}
 			m.clients[client.ID] = client
 			m.mu.Unlock()
-			log.Printf("WebSocket client registered: %s", client.ID)
+			m.logger.Info("WebSocket client registered", "client_id", client.ID, "session_type", client.SessionType())
 
 		case client := <-m.unregister:
 			m.mu.Lock()
-			if _, ok := m.clients[client.ID]; ok {
+			// Only remove the client if it was not replaced by a reattached one
+			if current, ok := m.clients[client.ID]; ok && current == client {
 				delete(m.clients, client.ID)
 				close(client.send)
-				log.Printf("WebSocket client unregistered: %s", client.ID)
+				m.logger.Info("WebSocket client unregistered", "client_id", client.ID, "session_type", client.SessionType())
 			}
 			m.mu.Unlock()
 		}
@@ -107,24 +116,43 @@ func (m *Manager) RegisterClient(requestID string, conn *websocket.Conn, session
 
 // SendMessage sends a message to a specific provision request's WebSocket
 func (m *Manager) SendMessage(requestID string, message Message) error {
+	// The send happens under the read lock: Run closes the channel of a replaced or unregistered client under the
+	// write lock, so the channel can't be closed while sending to it
 	m.mu.RLock()
 	client, ok := m.clients[requestID]
-	m.mu.RUnlock()
-
 	if !ok {
+		m.mu.RUnlock()
 		return nil // Client not connected, skip silently
 	}
 
+	if message.TraceID == "" {
+		message.TraceID = client.traceID()
+	}
+
 	select {
 	case client.send <- message:
+		m.mu.RUnlock()
 		return nil
 	default:
+		m.mu.RUnlock()
 		// Channel full, close client
 		m.unregister <- client
 		return nil
 	}
 }
 
+// ClientCounts returns the number of connected clients per session type
+func (m *Manager) ClientCounts() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, client := range m.clients {
+		counts[client.SessionType()]++
+	}
+	return counts
+}
+
 // BroadcastToSessionType sends a message to all clients with the provided session type.
 func (m *Manager) BroadcastToSessionType(sessionType string, message Message) {
 	m.mu.RLock()
@@ -158,7 +186,7 @@ func (c *Client) writePump() {
 		c.mu.Unlock()
 
 		if err != nil {
-			log.Printf("WebSocket write error for %s: %v", c.ID, err)
+			c.manager.logger.Warn("WebSocket write failed", "client_id", c.ID, "error", err)
 			return
 		}
 	}
@@ -179,7 +207,7 @@ func (c *Client) readPump() {
 		err := c.conn.ReadJSON(&msg)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket read error for %s: %v", c.ID, err)
+				c.manager.logger.Warn("WebSocket read failed", "client_id", c.ID, "error", err)
 			}
 			break
 		}
@@ -191,7 +219,7 @@ func (c *Client) readPump() {
 				msgType = t
 			}
 			if err := c.session.HandleMessage(msgType, msg); err != nil {
-				log.Printf("Session %s message handling error: %v", c.ID, err)
+				c.manager.logger.Error("failed to handle session message", "client_id", c.ID, "session_type", c.SessionType(), "message_type", msgType, "error", err)
 			}
 		}
 	}
@@ -222,6 +250,18 @@ func (c *Client) SessionType() string {
 	return c.sessionType
 }
 
+// traceID is the trace ID of the client's session, if it has one
+func (c *Client) traceID() string {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+
+	if traced, ok := session.(interface{ GetTraceID() string }); ok {
+		return traced.GetTraceID()
+	}
+	return ""
+}
+
 func (c *Client) attachSession(session Session) {
 	c.mu.Lock()
 	c.session = session
@@ -11,15 +11,18 @@ import (
 	gcpconfig "github.com/stolos-cloud/stolos-bootstrap/pkg/gcp"
 	"github.com/stolos-cloud/stolos/backend/internal/helpers"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/cloudbilling/v1"
 	compute "google.golang.org/api/compute/v1"
 	"google.golang.org/api/option"
 	"google.golang.org/api/storage/v1"
 )
 
 type Client struct {
-	config        *gcpconfig.GCPConfig
-	computeClient *compute.Service
-	storageClient *storage.Service
+	config            *gcpconfig.GCPConfig
+	additionalRegions []string
+	computeClient     *compute.Service
+	storageClient     *storage.Service
+	billingClient     *cloudbilling.APIService
 }
 
 func NewClientFromEnv() (*Client, error) {
@@ -48,6 +51,11 @@ func NewClientFromConfig(config *gcpconfig.GCPConfig) (*Client, error) {
 		return nil, fmt.Errorf("failed to create storage service: %w", err)
 	}
 
+	billingService, err := cloudbilling.NewService(ctx, option.WithCredentials(credentials))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud billing service: %w", err)
+	}
+
 	if config.ServiceAccountEmail == "" {
 		config.ServiceAccountEmail = extractServiceAccountEmail(config.ServiceAccountJSON)
 	}
@@ -56,6 +64,7 @@ func NewClientFromConfig(config *gcpconfig.GCPConfig) (*Client, error) {
 		config:        config,
 		computeClient: computeService,
 		storageClient: storageService,
+		billingClient: billingService,
 	}, nil
 }
 
@@ -87,6 +96,31 @@ func (c *Client) GetProjectInfo() (projectID, region string) {
 	return c.config.ProjectID, c.config.Region
 }
 
+// SetAdditionalRegions sets the regions, besides the configured one, whose zones are listed
+func (c *Client) SetAdditionalRegions(regions []string) {
+	c.additionalRegions = regions
+}
+
+// Regions returns the configured region followed by the additional regions
+func (c *Client) Regions() []string {
+	regions := []string{c.config.Region}
+	for _, region := range c.additionalRegions {
+		if region != "" && region != c.config.Region {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// GetRegion checks that a region exists in the project
+func (c *Client) GetRegion(ctx context.Context, region string) (*compute.Region, error) {
+	resp, err := c.computeClient.Regions.Get(c.config.ProjectID, region).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get region %s: %w", region, err)
+	}
+	return resp, nil
+}
+
 func (c *Client) GetComputeService() *compute.Service {
 	return c.computeClient
 }
@@ -125,12 +159,17 @@ func (c *Client) ListAllInstances(ctx context.Context) (map[string][]*compute.In
 	return result, nil
 }
 
+// getZonesInRegion lists the zones of the configured region and of the additional regions
 func (c *Client) getZonesInRegion(ctx context.Context) ([]*compute.Zone, error) {
-	resp, err := c.computeClient.Zones.List(c.config.ProjectID).Context(ctx).Filter(fmt.Sprintf("region eq https://www.googleapis.com/compute/v1/projects/%s/regions/%s", c.config.ProjectID, c.config.Region)).Do()
-	if err != nil {
-		return nil, err
+	var zones []*compute.Zone
+	for _, region := range c.Regions() {
+		resp, err := c.computeClient.Zones.List(c.config.ProjectID).Context(ctx).Filter(fmt.Sprintf("region eq https://www.googleapis.com/compute/v1/projects/%s/regions/%s", c.config.ProjectID, region)).Do()
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, resp.Items...)
 	}
-	return resp.Items, nil
+	return zones, nil
 }
 
 func (c *Client) CreateTerraformBucket(ctx context.Context, clusterName string) (string, error) {
@@ -227,3 +266,81 @@ func (c *Client) ListAllMachineTypesByZone(ctx context.Context) (map[string][]*c
 
 	return result, nil
 }
+
+func (c *Client) ListDisksInZone(ctx context.Context, zone string) ([]*compute.Disk, error) {
+	resp, err := c.computeClient.Disks.List(c.config.ProjectID, zone).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disks in zone %s: %w", zone, err)
+	}
+	return resp.Items, nil
+}
+
+func (c *Client) ListAllDisks(ctx context.Context) (map[string][]*compute.Disk, error) {
+	zones, err := c.getZonesInRegion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
+
+	result := make(map[string][]*compute.Disk)
+	for _, zone := range zones {
+		disks, err := c.ListDisksInZone(ctx, zone.Name)
+		if err != nil {
+			return nil, err
+		}
+		result[zone.Name] = disks
+	}
+
+	return result, nil
+}
+
+// computeEngineServiceID is the Cloud Billing Catalog ID of the Compute Engine service.
+const computeEngineServiceID = "services/6F81-5844-456A"
+
+// ListComputeSKUs returns every public Compute Engine SKU from the Cloud Billing Catalog.
+func (c *Client) ListComputeSKUs(ctx context.Context, currency string) ([]*cloudbilling.Sku, error) {
+	var skus []*cloudbilling.Sku
+	call := c.billingClient.Services.Skus.List(computeEngineServiceID)
+	if currency != "" {
+		call = call.CurrencyCode(currency)
+	}
+	err := call.Pages(ctx, func(resp *cloudbilling.ListSkusResponse) error {
+		skus = append(skus, resp.Skus...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compute SKUs: %w", err)
+	}
+	return skus, nil
+}
+
+func (c *Client) GetInstance(ctx context.Context, zone, name string) (*compute.Instance, error) {
+	instance, err := c.computeClient.Instances.Get(c.config.ProjectID, zone, name).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance %s in zone %s: %w", name, zone, err)
+	}
+	return instance, nil
+}
+
+// StartInstance starts a stopped instance and waits for the operation to finish
+func (c *Client) StartInstance(ctx context.Context, zone, name string) error {
+	op, err := c.computeClient.Instances.Start(c.config.ProjectID, zone, name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to start instance %s in zone %s: %w", name, zone, err)
+	}
+	op, err = c.computeClient.ZoneOperations.Wait(c.config.ProjectID, zone, op.Name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to wait for start of instance %s: %w", name, err)
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return fmt.Errorf("failed to start instance %s: %s", name, op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+func (c *Client) GetDisk(ctx context.Context, zone, name string) (*compute.Disk, error) {
+	disk, err := c.computeClient.Disks.Get(c.config.ProjectID, zone, name).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk %s in zone %s: %w", name, zone, err)
+	}
+	return disk, nil
+}
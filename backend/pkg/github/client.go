@@ -4,12 +4,20 @@ import (
 	"context"
 	"crypto/rsa"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/go-github/v74/github"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// newGitHubClient creates a client authenticated with token, its API calls are traced
+func newGitHubClient(token string) *github.Client {
+	httpClient := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	return github.NewClient(httpClient).WithAuthToken(token)
+}
+
 type Config struct {
 	AppID          int64  `json:"app_id"`
 	PrivateKey     string `json:"private_key"`
@@ -39,7 +47,7 @@ func NewClientFromConfig(appID, installationID int64, privateKey, repoOwner, rep
 		return nil, fmt.Errorf("failed to generate installation token: %w", err)
 	}
 
-	githubClient := github.NewClient(nil).WithAuthToken(token)
+	githubClient := newGitHubClient(token)
 
 	return &Client{
 		Client: githubClient,
@@ -93,7 +101,7 @@ func (c *Config) createJWTToken(privateKey *rsa.PrivateKey) (string, error) {
 }
 
 func (c *Config) getInstallationAccessToken(jwtToken string) (string, error) {
-	client := github.NewClient(nil).WithAuthToken(jwtToken)
+	client := newGitHubClient(jwtToken)
 
 	installationToken, _, err := client.Apps.CreateInstallationToken(
 		context.Background(),
@@ -113,6 +121,6 @@ func (c *Client) RefreshToken() error {
 		return fmt.Errorf("failed to generate new token: %w", err)
 	}
 
-	c.Client = github.NewClient(nil).WithAuthToken(token)
+	c.Client = newGitHubClient(token)
 	return nil
 }
\ No newline at end of file
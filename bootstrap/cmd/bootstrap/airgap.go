@@ -0,0 +1,345 @@
+// airgap.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/airgap"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/helm"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/marshal"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	yokeK8s "github.com/yokecd/yoke/pkg/flight/wasi/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	_airgapDir           = "airgap"
+	_defaultBundleFile   = "stolos-airgap-bundle.tar.gz"
+	_talosFactoryURL     = "https://factory.talos.dev"
+	_atcInstallerFlight  = "oci://ghcr.io/yokecd/atc-installer:0.16.0"
+	_atcImage            = "ghcr.io/yokecd/atc:latest" // deployed by the atc-installer flight
+	_stolosDatabaseImage = "ghcr.io/cloudnative-pg/postgresql:17.6"
+)
+
+// airgapServer serves the air-gap bundle for the rest of the bootstrap, nil without a bundle
+var airgapServer *airgap.Server
+
+// RunAirgapStep extracts the air-gap bundle and starts its registry and file server
+func RunAirgapStep(m *tui.Model, s *tui.Step) error {
+	talosInfo := &bootstrapInfos.TalosInfo
+	if !talosInfo.AirgapEnabled() || airgapServer != nil {
+		return nil
+	}
+
+	m.Logger.Infof("Extracting air-gap bundle %s...", talosInfo.AirgapBundle)
+	manifest, err := airgap.Extract(talosInfo.AirgapBundle, _airgapDir)
+	if err != nil {
+		return fmt.Errorf("failed to extract air-gap bundle: %w", err)
+	}
+	if manifest.TalosVersion != talosInfo.TalosVersion || manifest.TalosArchitecture != talosInfo.TalosArchitecture {
+		return fmt.Errorf("air-gap bundle is for Talos %s %s, the cluster uses %s %s", manifest.TalosVersion,
+			manifest.TalosArchitecture, talosInfo.TalosVersion, talosInfo.TalosArchitecture)
+	}
+
+	server, err := airgap.NewServer(_airgapDir, talosInfo.HTTPHostname, talosInfo.AirgapPort, m.Logger)
+	if err != nil {
+		return fmt.Errorf("invalid air-gap configuration: %w", err)
+	}
+	if err := server.Start(); err != nil {
+		return fmt.Errorf("failed to start air-gap server: %w", err)
+	}
+	airgapServer = server
+
+	talosInfo.AirgapRegistries = manifest.Registries
+	saveState.SchematicID = manifest.SchematicID
+	saveState.BootstrapInfo = *bootstrapInfos
+	if err := marshal.MarshalToFile(_bootstrapStateFile, saveState); err != nil {
+		m.Logger.Errorf("Error saving state: %s", err)
+	}
+	m.Logger.Successf("Serving %d images and the Talos images of schematic %s on %s", len(manifest.Images),
+		manifest.SchematicID, airgapServer.URL())
+	return nil
+}
+
+// talosFactoryURL returns the image factory the Talos images are downloaded from
+func talosFactoryURL() string {
+	if airgapServer != nil {
+		return airgapServer.URL()
+	}
+	return _talosFactoryURL
+}
+
+// ipxeBinariesURL returns the base URL of the iPXE binaries, empty for boot.ipxe.org
+func ipxeBinariesURL() string {
+	if airgapServer != nil {
+		return airgapServer.FileURL(airgap.IPXEDir)
+	}
+	return ""
+}
+
+// argoChartPath returns the ArgoCD chart archive of the bundle, empty to install it from its repository
+func argoChartPath() string {
+	if airgapServer != nil {
+		return airgapServer.LocalPath(airgapServer.Manifest().ArgoCDChart)
+	}
+	return ""
+}
+
+// flightPath returns the wasm file of a bundled flight, or the flight URL
+func flightPath(flight string) string {
+	if airgapServer != nil {
+		if file, ok := airgapServer.FlightFile(flight); ok {
+			return airgapServer.LocalPath(file)
+		}
+	}
+	return flight
+}
+
+// stolosAirwayURL returns the URL of the Stolos airway of the release
+func stolosAirwayURL(tag string) string {
+	return fmt.Sprintf("https://raw.githubusercontent.com/stolos-cloud/stolos/refs/tags/%s/stolos-yoke/airway.yml", tag)
+}
+
+// readBundledAirway reads the airway of the bundle, its flights are pointed at the air-gap server
+func readBundledAirway() (*unstructured.Unstructured, error) {
+	manifest := airgapServer.Manifest()
+	if manifest.Airway == "" {
+		return nil, fmt.Errorf("air-gap bundle has no airway")
+	}
+	airway, err := airgap.ReadAirway(airgapServer.LocalPath(manifest.Airway))
+	if err != nil {
+		return nil, err
+	}
+
+	wasmURLs, _, err := unstructured.NestedStringMap(airway.Object, "spec", "wasmUrls")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read airway wasm URLs: %w", err)
+	}
+	for key, url := range wasmURLs {
+		if file, ok := airgapServer.FlightFile(url); ok {
+			wasmURLs[key] = airgapServer.FileURL(file)
+		}
+	}
+	if err := unstructured.SetNestedStringMap(airway.Object, wasmURLs, "spec", "wasmUrls"); err != nil {
+		return nil, fmt.Errorf("failed to update airway wasm URLs: %w", err)
+	}
+	return airway, nil
+}
+
+func RunBundleCommand(args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return fmt.Errorf("usage: bundle create [-config file] [-o file] [-images file] [-image ref]...")
+	}
+
+	fs := flag.NewFlagSet("bundle create", flag.ExitOnError)
+	configPath := fs.String("config", _bootstrapConfigFile, "Bootstrap config of the cluster, the Talos images are built for its TalosInfo")
+	output := fs.String("o", _defaultBundleFile, "Output file")
+	imagesFile := fs.String("images", "", "File listing extra images to bundle, one per line")
+	var extraImages []string
+	fs.Func("image", "Extra image to bundle, can be repeated", func(image string) error {
+		extraImages = append(extraImages, image)
+		return nil
+	})
+	_ = fs.Parse(args[1:])
+
+	info, err := readBundleConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	bootstrapInfos = info
+	talosInfo := &bootstrapInfos.TalosInfo
+
+	if *imagesFile != "" {
+		listed, err := readImagesFile(*imagesFile)
+		if err != nil {
+			return err
+		}
+		extraImages = append(extraImages, listed...)
+	}
+
+	ctx := context.Background()
+	schematicID, err := CreateTalosSchematic(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create schematic: %w", err)
+	}
+	talosImages, err := talos.ListImages(talosInfo)
+	if err != nil {
+		return fmt.Errorf("failed to list Talos images: %w", err)
+	}
+
+	flightImages, flightCharts, flightManifests, err := platformFlightSources(talosInfo.StolosAirwayTag)
+	if err != nil {
+		return err
+	}
+
+	images := append(talosImages, _atcImage)
+	images = append(images, flightImages...)
+	err = airgap.Create(ctx, airgap.CreateOptions{
+		Output:            *output,
+		FactoryURL:        _talosFactoryURL,
+		SchematicID:       schematicID,
+		TalosVersion:      talosInfo.TalosVersion,
+		TalosArchitecture: talosInfo.TalosArchitecture,
+		FactoryFiles:      talos.FactoryFiles(talosInfo.TalosArchitecture),
+		Images:            append(images, extraImages...),
+		ArgoCDChart: airgap.Chart{
+			RepoURL: helm.ArgoChartRepoURL,
+			Name:    helm.ArgoChartName,
+			Version: helm.ArgoChartVersion,
+		},
+		Flights:         []string{_atcInstallerFlight},
+		AirwayURL:       stolosAirwayURL(talosInfo.StolosAirwayTag),
+		FlightCharts:    flightCharts,
+		FlightManifests: flightManifests,
+		Printf: func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format, args...)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s, set TalosInfo.AirgapBundle to its path to bootstrap without internet access\n", *output)
+	return nil
+}
+
+// platformFlightSources renders the platform flight for the input created by the bootstrap and returns the images
+// of its resources, and the charts and manifests of its ArgoCD applications, whose images are listed with the bundle.
+// The backend and frontend images are the ones of the release.
+func platformFlightSources(release string) (images []string, charts []airgap.Chart, manifests []string, err error) {
+	input := stolosPlatformInput()
+	input.Spec.StolosPlatform.UpgradeChannel = "pinned"
+	input.Spec.StolosPlatform.Version = release
+
+	utils.SetCluster(bundleCluster{})
+	result := render.Render(input)
+	for _, res := range append(slices.Clone(result.Resources), result.Workloads...) {
+		if app, ok := res.(*types.Application); ok {
+			sources := slices.Clone(app.Spec.Sources)
+			if app.Spec.Source != nil {
+				sources = append(sources, *app.Spec.Source)
+			}
+			for _, source := range sources {
+				switch manifest := githubManifestURL(source); {
+				case source.Chart != "" && !strings.HasPrefix(source.RepoURL, "oci://"):
+					charts = append(charts, airgap.Chart{RepoURL: source.RepoURL, Name: source.Chart, Version: source.TargetRevision})
+				case manifest != "":
+					manifests = append(manifests, manifest)
+				default:
+					fmt.Fprintf(os.Stderr, "The images of the %s application (%s) are not listed, add them with -images\n", app.Name, source.RepoURL)
+				}
+			}
+			continue
+		}
+
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(res)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to convert %s: %w", render.Identifier(res), err)
+		}
+		for _, image := range objectImages(object) {
+			if !slices.Contains(images, image) {
+				images = append(images, image)
+			}
+		}
+	}
+	return images, charts, manifests, nil
+}
+
+// githubManifestURL returns the raw URL of the single manifest of a GitHub directory source, empty for other sources
+func githubManifestURL(source types.ApplicationSource) string {
+	repository, ok := strings.CutPrefix(strings.TrimSuffix(source.RepoURL, ".git"), "https://github.com/")
+	if !ok || source.Directory == nil || source.Directory.Include == "" || strings.ContainsAny(source.Directory.Include, "*?[{") {
+		return ""
+	}
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", repository, source.TargetRevision, path.Join(source.Path, source.Directory.Include))
+}
+
+// objectImages returns the container images (image) and CNPG images (imageName) of an object
+func objectImages(value any) []string {
+	var images []string
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if image, ok := field.(string); ok && (key == "image" || key == "imageName") && image != "" {
+				images = append(images, image)
+				continue
+			}
+			images = append(images, objectImages(field)...)
+		}
+	case []any:
+		for _, item := range v {
+			images = append(images, objectImages(item)...)
+		}
+	}
+	return images
+}
+
+// bundleCluster is the cluster the platform flight is rendered against by bundle create: it serves every kind and
+// has no secrets, as for a new cluster
+type bundleCluster struct{}
+
+func (bundleCluster) RestMapping(string, string) error {
+	return nil
+}
+
+func (bundleCluster) Secret(name, _ string) (*corev1.Secret, error) {
+	return nil, yokeK8s.ErrorNotFound(fmt.Sprintf("secrets %q not found", name))
+}
+
+// readBundleConfig reads the TalosInfo of a bootstrap config. The bootstrap host address is baked into the
+// schematic kernel args, it is not defaulted to this host.
+func readBundleConfig(configPath string) (*BootstrapInfo, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	info := &BootstrapInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", configPath, err)
+	}
+
+	tui.FillStructDefaults(&info.TalosInfo)
+	if info.TalosInfo.HTTPHostname == "" {
+		return nil, fmt.Errorf("TalosInfo.HTTPHostname is required, the machines reach the bootstrap host with it")
+	}
+	if info.TalosInfo.StolosAirwayTag == "" {
+		info.TalosInfo.StolosAirwayTag = GetLatestStolosRelease()
+	}
+	return info, nil
+}
+
+// readImagesFile reads an images list, empty lines and # comments are skipped
+func readImagesFile(imagesPath string) ([]string, error) {
+	f, err := os.Open(imagesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read images list: %w", err)
+	}
+	defer f.Close()
+
+	var images []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			images = append(images, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", imagesPath, err)
+	}
+	return images, nil
+}
@@ -0,0 +1,313 @@
+// commands.go
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/encryption"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/k8s"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/marshal"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	_stateKeyFile        = "bootstrap-state.key"
+	_defaultExportFile   = "stolos-bootstrap-export.age"
+	_statePassphraseEnv  = "STOLOS_STATE_PASSPHRASE"
+	_stateIdentityEnv    = "STOLOS_STATE_IDENTITY"
+	_exportPassphraseEnv = "STOLOS_EXPORT_PASSPHRASE"
+)
+
+// command is a subcommand of the bootstrap CLI, the wizard runs when none is given
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"export": {"export [-o file]: write the state, config bundle and kubeconfig to a passphrase-encrypted archive", RunExportCommand},
+	"import": {"import [-force] file: restore an archive written by export, encrypted with the local key", RunImportCommand},
+	"wipe":   {"wipe [-force]: securely delete the local secrets once the cluster holds them", RunWipeCommand},
+
+	"status":               {"status [-timeout d]: show the machines and run the cluster health checks", RunStatusCommand},
+	"add-node":             {"add-node [-role worker|control-plane] [-disk disk] [-hostname name] [-force] ip: join a machine in maintenance mode with the stored config bundle", RunAddNodeCommand},
+	"rotate-ca":            {"rotate-ca [-talos] [-kubernetes] [-dry-run=false]: rotate the Talos and Kubernetes API CAs node by node", RunRotateCACommand},
+	"rotate-talos-secrets": {"rotate-talos-secrets: issue a new talosconfig client certificate and admin kubeconfig", RunRotateTalosSecretsCommand},
+	"recover-kubeconfig":   {"recover-kubeconfig [-kubeconfig file] [-force]: write a new admin kubeconfig, restoring the local files from the " + _talosSecretName + " secret", RunRecoverKubeconfigCommand},
+	"destroy":              {"destroy [-yes] [-keep-github] [-keep-gcp] [-keep-local]: reset every machine and delete the GitHub and GCP resources created by the bootstrap", RunDestroyCommand},
+
+	"bundle": {"bundle create [-config file] [-o file] [-images file] [-image ref]...: download everything the bootstrap needs into an air-gap bundle", RunBundleCommand},
+}
+
+// RunCommand runs a subcommand and returns the process exit code
+func RunCommand(name string, args []string) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q, available commands:\n", name)
+		PrintCommandsUsage()
+		return 2
+	}
+	if err := cmd.run(args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// PrintCommandsUsage lists the subcommands
+func PrintCommandsUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+// SetupStateEncryption loads the key encrypting the state and config bundle files.
+// Existing plaintext files stay readable and are encrypted on their next write.
+// The keyring mode falls back to the passphrase mode, or to the identity file of $STOLOS_STATE_IDENTITY,
+// when there is no OS keyring. Headless runs use the fallback whenever its variable is set.
+func SetupStateEncryption(mode string, headless bool) error {
+	switch mode {
+	case encryption.ModeNone:
+		return nil
+	case encryption.ModeKeyring:
+		if headless && (os.Getenv(_stateIdentityEnv) != "" || os.Getenv(_statePassphraseEnv) != "") {
+			return setupFallbackEncryption()
+		}
+		account, err := keyringAccount()
+		if err != nil {
+			return err
+		}
+		key, err := encryption.LoadKeyringKey(account)
+		if errors.Is(err, encryption.ErrKeyringUnavailable) {
+			fmt.Fprintf(os.Stderr, "Warning: %v, falling back to $%s or the state passphrase\n", err, _stateIdentityEnv)
+			return setupFallbackEncryption()
+		}
+		if err != nil {
+			return err
+		}
+		marshal.SetEncryptionKey(key)
+		return nil
+	case encryption.ModePassphrase:
+		return setupPassphraseEncryption()
+	}
+	return fmt.Errorf("unknown encryption mode %q (keyring, passphrase or none)", mode)
+}
+
+// setupFallbackEncryption loads the key of hosts without a keyring: the identity file of $STOLOS_STATE_IDENTITY,
+// or the passphrase key file
+func setupFallbackEncryption() error {
+	if path := os.Getenv(_stateIdentityEnv); path != "" {
+		key, err := encryption.LoadIdentityFile(path)
+		if err != nil {
+			return err
+		}
+		marshal.SetEncryptionKey(key)
+		return nil
+	}
+	return setupPassphraseEncryption()
+}
+
+// setupPassphraseEncryption loads the key file unlocked by $STOLOS_STATE_PASSPHRASE, or a prompted passphrase
+func setupPassphraseEncryption() error {
+	_, err := os.Stat(_stateKeyFile)
+	isNew := errors.Is(err, os.ErrNotExist)
+	prompt := "State passphrase"
+	if isNew {
+		prompt = "New state passphrase"
+	}
+	passphrase, err := encryption.ReadPassphrase(_statePassphraseEnv, prompt, isNew)
+	if err != nil {
+		return err
+	}
+	key, err := encryption.LoadPassphraseKey(_stateKeyFile, passphrase)
+	if err != nil {
+		return err
+	}
+	marshal.SetEncryptionKey(key)
+	return nil
+}
+
+// keyringAccount identifies the bootstrap directory in the OS keyring, each directory holds one cluster
+func keyringAccount() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return filepath.Abs(dir)
+}
+
+// secretFiles are the local files holding cluster secrets
+func secretFiles() []string {
+	return append([]string{_bootstrapStateFile, "kubeconfig"}, marshal.BundleFiles...)
+}
+
+func RunExportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", _defaultExportFile, "Archive to write")
+	_ = fs.Parse(args)
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	exported := 0
+	for _, name := range secretFiles() {
+		data, err := marshal.ReadFile(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		exported++
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if exported == 0 {
+		return fmt.Errorf("nothing to export, no bootstrap state in this directory")
+	}
+
+	passphrase, err := encryption.ReadPassphrase(_exportPassphraseEnv, "Export passphrase", true)
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryption.EncryptWithPassphrase(buf.Bytes(), passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *output, err)
+	}
+	fmt.Printf("Exported %d files to %s\n", exported, *output)
+	return nil
+}
+
+func RunImportCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	force := fs.Bool("force", false, "Overwrite an existing local state")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [-force] file")
+	}
+
+	if _, err := os.Stat(_bootstrapStateFile); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", _bootstrapStateFile)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", fs.Arg(0), err)
+	}
+	passphrase, err := encryption.ReadPassphrase(_exportPassphraseEnv, "Export passphrase", false)
+	if err != nil {
+		return err
+	}
+	archive, err := encryption.DecryptWithPassphrase(data, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s (wrong passphrase?): %w", fs.Arg(0), err)
+	}
+
+	allowed := secretFiles()
+	imported := 0
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+		if !slices.Contains(allowed, header.Name) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping unexpected file %s\n", header.Name)
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from archive: %w", header.Name, err)
+		}
+		// kubectl reads the kubeconfig, it stays plaintext
+		if header.Name == "kubeconfig" {
+			err = os.WriteFile(header.Name, content, 0600)
+		} else {
+			err = marshal.WriteFile(header.Name, content)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", header.Name, err)
+		}
+		imported++
+	}
+	fmt.Printf("Imported %d files, run the bootstrap again to resume\n", imported)
+	return nil
+}
+
+func RunWipeCommand(args []string) error {
+	fs := flag.NewFlagSet("wipe", flag.ExitOnError)
+	force := fs.Bool("force", false, "Wipe without checking the cluster holds the secrets")
+	_ = fs.Parse(args)
+
+	if !*force {
+		if err := checkSecretsHandedOff(); err != nil {
+			return fmt.Errorf("%w, export the state or use -force", err)
+		}
+	}
+
+	if err := wipeLocalSecrets(); err != nil {
+		return err
+	}
+	fmt.Println("Local bootstrap secrets wiped")
+	return nil
+}
+
+// wipeLocalSecrets deletes the secret files and the state encryption key
+func wipeLocalSecrets() error {
+	for _, name := range append(secretFiles(), _stateKeyFile) {
+		if err := encryption.WipeFile(name); err != nil {
+			return fmt.Errorf("failed to wipe %s: %w", name, err)
+		}
+	}
+	if account, err := keyringAccount(); err == nil {
+		if err := encryption.DeleteKeyringKey(account); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to delete the key from the OS keyring: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// checkSecretsHandedOff checks the cluster holds the Talos secrets created at the end of the bootstrap
+func checkSecretsHandedOff() error {
+	kubeconfigData, err := os.ReadFile("kubeconfig")
+	if err != nil {
+		return fmt.Errorf("no kubeconfig to check the cluster: %w", err)
+	}
+	k8sClient, err := k8s.NewClientFromKubeconfig(kubeconfigData)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := k8sClient.CoreV1().Secrets(_talosSecretNamespace).Get(ctx, _talosSecretName, metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("the cluster does not hold the %s secret: %w", _talosSecretName, err)
+	}
+	return nil
+}
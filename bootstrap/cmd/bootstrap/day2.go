@@ -0,0 +1,611 @@
+// day2.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	machineryClient "github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/gcp"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/github"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/k8s"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/marshal"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/oauth"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/platform_talos"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	_talosSecretNamespace  = "stolos-system"
+	_talosSecretName       = "stolos-talos-config"
+	_gcpServiceAccountName = "stolos-platform-sa"
+	_nodeJoinTimeout       = 15 * time.Minute
+)
+
+// cliLogger prints to the terminal, for subcommands running without the TUI
+type cliLogger struct{}
+
+func (cliLogger) Debug(s string)                { fmt.Fprintln(os.Stderr, s) }
+func (cliLogger) Info(s string)                 { fmt.Fprintln(os.Stderr, s) }
+func (cliLogger) Warn(s string)                 { fmt.Fprintln(os.Stderr, "Warning:", s) }
+func (cliLogger) Error(s string)                { fmt.Fprintln(os.Stderr, "Error:", s) }
+func (cliLogger) Success(s string)              { fmt.Fprintln(os.Stderr, s) }
+func (l cliLogger) Debugf(f string, a ...any)   { l.Debug(fmt.Sprintf(f, a...)) }
+func (l cliLogger) Infof(f string, a ...any)    { l.Info(fmt.Sprintf(f, a...)) }
+func (l cliLogger) Warnf(f string, a ...any)    { l.Warn(fmt.Sprintf(f, a...)) }
+func (l cliLogger) Errorf(f string, a ...any)   { l.Error(fmt.Sprintf(f, a...)) }
+func (l cliLogger) Successf(f string, a ...any) { l.Success(fmt.Sprintf(f, a...)) }
+
+// loadBootstrapState loads the local state and config bundle left by the bootstrap
+func loadBootstrapState() error {
+	if _, err := os.Stat(_bootstrapStateFile); err != nil {
+		return fmt.Errorf("no bootstrap state in this directory, run the bootstrap, import or recover-kubeconfig first: %w", err)
+	}
+	state, err := marshal.UnmarshalFromFile[SaveState](_bootstrapStateFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", _bootstrapStateFile, err)
+	}
+	saveState = state
+	bootstrapInfos = &saveState.BootstrapInfo
+	if saveState.MachinesCache.ControlPlanes == nil {
+		saveState.MachinesCache.ControlPlanes = make(map[string][]byte)
+	}
+	if saveState.MachinesCache.Workers == nil {
+		saveState.MachinesCache.Workers = make(map[string][]byte)
+	}
+	if saveState.MachinesDisks == nil {
+		saveState.MachinesDisks = make(talos.MachinesDisks)
+	}
+
+	ConfigBundle, err = marshal.ReadSplitConfigBundleFiles()
+	if err != nil {
+		return fmt.Errorf("failed to read the config bundle: %w", err)
+	}
+	if ConfigBundle.TalosCfg == nil {
+		return fmt.Errorf("no talosconfig in this directory")
+	}
+	kubeconfig, _ = os.ReadFile("kubeconfig")
+	return nil
+}
+
+// saveBootstrapState writes the state and config bundle back
+func saveBootstrapState() error {
+	if err := marshal.SaveSplitConfigBundleFiles(ConfigBundle); err != nil {
+		return fmt.Errorf("failed to save config bundle: %w", err)
+	}
+	if err := marshal.MarshalToFile(_bootstrapStateFile, saveState); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// refreshKubeconfig fetches a new admin kubeconfig from the Talos API
+func refreshKubeconfig(ctx context.Context, c *machineryClient.Client) error {
+	data, err := c.Kubeconfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
+	if err := os.WriteFile("kubeconfig", data, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+	kubeconfig = data
+	return nil
+}
+
+// refreshMachinesCache replaces the cached machine configs by the ones the nodes run
+func refreshMachinesCache(ctx context.Context, c *machineryClient.Client) {
+	refresh := func(machines map[string][]byte) {
+		for ip := range machines {
+			cfg, err := talos.GetActiveMachineConfig(ctx, c, ip)
+			if err != nil {
+				cliLogger{}.Warnf("%s, keeping the cached config", err)
+				continue
+			}
+			if data, err := cfg.Bytes(); err == nil {
+				machines[ip] = data
+			}
+		}
+	}
+	refresh(saveState.MachinesCache.ControlPlanes)
+	refresh(saveState.MachinesCache.Workers)
+}
+
+// syncClusterSecret updates the stolos-talos-config secret the backend reads with the local files
+func syncClusterSecret(ctx context.Context) error {
+	if len(kubeconfig) == 0 {
+		return fmt.Errorf("no kubeconfig, the %s secret was not updated", _talosSecretName)
+	}
+	k8sClient, err := k8s.NewClientFromKubeconfig(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	secretData, err := platform_talos.NewBootstrapSecret(saveState.MachinesCache)
+	if err != nil {
+		return err
+	}
+	if err := secretData.CreateOrUpdateSecret(ctx, k8sClient, _talosSecretNamespace, _talosSecretName); err != nil {
+		return fmt.Errorf("failed to update the %s secret: %w", _talosSecretName, err)
+	}
+	return nil
+}
+
+func RunStatusCommand(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 5*time.Minute, "Maximum duration of the cluster health check")
+	_ = fs.Parse(args)
+
+	if err := loadBootstrapState(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	c, err := talos.NewClient(ctx, ConfigBundle.TalosConfig())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	fmt.Printf("Cluster %s, endpoint %s\n\n", bootstrapInfos.TalosInfo.ClusterName, saveState.ClusterEndpoint)
+
+	machines := tablewriter.NewWriter(os.Stdout)
+	machines.SetHeader([]string{"IP", "Role", "Talos", "Status"})
+	addMachines := func(ips map[string][]byte, role string) {
+		for ip := range ips {
+			versionCtx, cancel := context.WithTimeout(machineryClient.WithNode(ctx, ip), 10*time.Second)
+			version, err := c.Version(versionCtx)
+			cancel()
+			if err != nil {
+				machines.Append([]string{ip, role, "-", "unreachable"})
+				continue
+			}
+			machines.Append([]string{ip, role, version.GetMessages()[0].GetVersion().GetTag(), "up"})
+		}
+	}
+	addMachines(saveState.MachinesCache.ControlPlanes, talos.RoleControlPlane)
+	addMachines(saveState.MachinesCache.Workers, talos.RoleWorker)
+	machines.Render()
+
+	if len(kubeconfig) > 0 {
+		if k8sClient, err := k8s.NewClientFromKubeconfig(kubeconfig); err == nil {
+			listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			nodes, err := k8sClient.CoreV1().Nodes().List(listCtx, metav1.ListOptions{})
+			cancel()
+			if err != nil {
+				cliLogger{}.Warnf("failed to list Kubernetes nodes: %s", err)
+			} else {
+				table := tablewriter.NewWriter(os.Stdout)
+				table.SetHeader([]string{"Node", "Ready", "Kubelet"})
+				for _, node := range nodes.Items {
+					ready := "false"
+					for _, condition := range node.Status.Conditions {
+						if condition.Type == corev1.NodeReady {
+							ready = string(condition.Status)
+						}
+					}
+					table.Append([]string{node.Name, ready, node.Status.NodeInfo.KubeletVersion})
+				}
+				fmt.Println()
+				table.Render()
+			}
+		}
+	}
+
+	fmt.Println("\nRunning cluster health checks...")
+	if err := talos.CheckClusterHealth(ctx, c, *timeout, func(msg string) { fmt.Println(" ", msg) }); err != nil {
+		return err
+	}
+	fmt.Println("Cluster is healthy")
+	return nil
+}
+
+func RunAddNodeCommand(args []string) error {
+	fs := flag.NewFlagSet("add-node", flag.ExitOnError)
+	role := fs.String("role", talos.RoleWorker, "Role of the machine: worker or control-plane")
+	disk := fs.String("disk", "", "Install disk, bus path or device name (defaults to the cluster TalosInstallDisk)")
+	hostname := fs.String("hostname", "", "Hostname (defaults to the next worker-N or control-plane-N)")
+	force := fs.Bool("force", false, "Apply the config even if pre-flight checks fail")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: add-node [-role worker|control-plane] [-disk disk] [-hostname name] [-force] ip")
+	}
+	ip := fs.Arg(0)
+	if *role != talos.RoleWorker && *role != talos.RoleControlPlane {
+		return fmt.Errorf("invalid role %q, expected %s or %s", *role, talos.RoleWorker, talos.RoleControlPlane)
+	}
+
+	if err := loadBootstrapState(); err != nil {
+		return err
+	}
+	if _, ok := saveState.MachinesCache.ControlPlanes[ip]; ok {
+		return fmt.Errorf("%s is already a control plane of the cluster", ip)
+	}
+	if _, ok := saveState.MachinesCache.Workers[ip]; ok {
+		return fmt.Errorf("%s is already a worker of the cluster", ip)
+	}
+
+	ctx := context.Background()
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	disks, err := talos.GetDisks(queryCtx, ip)
+	if err != nil {
+		return fmt.Errorf("failed to query %s, is it booted in maintenance mode? %w", ip, err)
+	}
+	installDisk := *disk
+	if installDisk == "" {
+		installDisk = bootstrapInfos.TalosInfo.TalosInstallDisk
+	}
+	selector := talos.DiskSelector{BusPath: installDisk}
+	if strings.HasPrefix(installDisk, "/dev/") {
+		selector = talos.DiskSelector{Name: installDisk}
+	}
+	selected, err := selector.Select(disks)
+	if err != nil {
+		return err
+	}
+
+	// the cluster-wide checks count the control planes of the cluster with this machine
+	saveState.MachinesDisks[ip] = selected.BusPath
+	controlPlanes := len(saveState.MachinesCache.ControlPlanes)
+	if *role == talos.RoleControlPlane {
+		controlPlanes++
+	}
+	report := runPreflightChecks(map[string]string{ip: *role}, controlPlanes)
+	report.Render(os.Stdout)
+	if report.HasErrors() && !*force {
+		return fmt.Errorf("pre-flight checks failed, fix the machine or use -force")
+	}
+
+	if *hostname == "" {
+		if *role == talos.RoleControlPlane {
+			*hostname = talos.NextHostname(saveState.MachinesCache.ControlPlanes, "control-plane", 1)
+		} else {
+			*hostname = talos.NextHostname(saveState.MachinesCache.Workers, "worker", 0)
+		}
+	}
+	machineConfig, err := talos.RenderNodeConfig(ConfigBundle, *role, *hostname, selected.BusPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Applying %s config to %s (%s, install disk %s)...\n", *role, ip, *hostname, selected.DeviceName)
+	if err := talos.ApplyMaintenanceConfig(ctx, ip, machineConfig); err != nil {
+		return err
+	}
+
+	if *role == talos.RoleControlPlane {
+		saveState.MachinesCache.ControlPlanes[ip] = machineConfig
+	} else {
+		saveState.MachinesCache.Workers[ip] = machineConfig
+	}
+	saveState.MachinesDisks[ip] = selected.BusPath
+	if err := saveBootstrapState(); err != nil {
+		return err
+	}
+
+	fmt.Println("Waiting for the machine to install Talos and join the cluster...")
+	c, err := talos.NewClient(ctx, ConfigBundle.TalosConfig())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	deadline := time.Now().Add(_nodeJoinTimeout)
+	for {
+		versionCtx, cancel := context.WithTimeout(machineryClient.WithNode(ctx, ip), 10*time.Second)
+		_, err := c.Version(versionCtx)
+		cancel()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not come back with its config after %s: %w", ip, _nodeJoinTimeout, err)
+		}
+		time.Sleep(10 * time.Second)
+	}
+
+	if err := syncClusterSecret(ctx); err != nil {
+		cliLogger{}.Warn(err.Error())
+	}
+	fmt.Printf("%s joined the cluster as %s\n", ip, *role)
+	return nil
+}
+
+func RunRotateCACommand(args []string) error {
+	fs := flag.NewFlagSet("rotate-ca", flag.ExitOnError)
+	rotateTalos := fs.Bool("talos", true, "Rotate the Talos API CA")
+	rotateKubernetes := fs.Bool("kubernetes", true, "Rotate the Kubernetes API CA")
+	dryRun := fs.Bool("dry-run", true, "Only print the changes, use -dry-run=false to rotate")
+	_ = fs.Parse(args)
+
+	if err := loadBootstrapState(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	c, err := talos.NewClient(ctx, ConfigBundle.TalosConfig())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	talosConfig, rotateErr := talos.RotateCA(ctx, c, &saveState.MachinesCache, ConfigBundle, talos.RotateCAOptions{
+		Talos:      *rotateTalos,
+		Kubernetes: *rotateKubernetes,
+		DryRun:     *dryRun,
+		Printf:     func(format string, args ...any) { fmt.Printf(format, args...) },
+	})
+	if *dryRun {
+		if rotateErr == nil {
+			fmt.Println("Dry-run, no changes were made to the cluster, run again with -dry-run=false to rotate")
+		}
+		return rotateErr
+	}
+	if talosConfig == nil {
+		return rotateErr
+	}
+
+	// the old CAs are rejected, save what changed even if the rotation did not finish
+	if err := saveBootstrapState(); err != nil {
+		return errors.Join(rotateErr, err)
+	}
+	if rotateErr != nil {
+		return rotateErr
+	}
+
+	newClient, err := talos.NewClient(ctx, talosConfig)
+	if err != nil {
+		return err
+	}
+	defer newClient.Close()
+	return finishSecretRotation(ctx, newClient)
+}
+
+func RunRotateTalosSecretsCommand(args []string) error {
+	fs := flag.NewFlagSet("rotate-talos-secrets", flag.ExitOnError)
+	_ = fs.Parse(args)
+
+	if err := loadBootstrapState(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	talosConfig, err := talos.RenewTalosconfig(ConfigBundle)
+	if err != nil {
+		return err
+	}
+	c, err := talos.NewClient(ctx, talosConfig)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err := c.Version(ctx); err != nil {
+		return fmt.Errorf("the renewed talosconfig is rejected, nothing was saved: %w", err)
+	}
+	if err := saveBootstrapState(); err != nil {
+		return err
+	}
+	if err := finishSecretRotation(ctx, c); err != nil {
+		return err
+	}
+	fmt.Println("Previous client certificates stay valid until they expire, use rotate-ca to revoke them")
+	return nil
+}
+
+// finishSecretRotation fetches a kubeconfig signed by the current CA and hands the new secrets to the cluster
+func finishSecretRotation(ctx context.Context, c *machineryClient.Client) error {
+	if err := refreshKubeconfig(ctx, c); err != nil {
+		return err
+	}
+	refreshMachinesCache(ctx, c)
+	if err := saveBootstrapState(); err != nil {
+		return err
+	}
+	if err := syncClusterSecret(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Wrote talosconfig and kubeconfig, the cluster secret is up to date")
+	return nil
+}
+
+func RunRecoverKubeconfigCommand(args []string) error {
+	fs := flag.NewFlagSet("recover-kubeconfig", flag.ExitOnError)
+	kubeconfigPath := fs.String("kubeconfig", os.Getenv("KUBECONFIG"), "Kubeconfig able to read the "+_talosSecretName+" secret, the local talosconfig is used when empty")
+	force := fs.Bool("force", false, "Overwrite the local state and config bundle with the secret content")
+	_ = fs.Parse(args)
+
+	ctx := context.Background()
+	if *kubeconfigPath == "" {
+		// no cluster access, the local talosconfig still works
+		if err := loadBootstrapState(); err != nil {
+			return err
+		}
+		c, err := talos.NewClient(ctx, ConfigBundle.TalosConfig())
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if err := refreshKubeconfig(ctx, c); err != nil {
+			return err
+		}
+		fmt.Println("Wrote ./kubeconfig")
+		return nil
+	}
+
+	accessKubeconfig, err := os.ReadFile(*kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *kubeconfigPath, err)
+	}
+	k8sClient, err := k8s.NewClientFromKubeconfig(accessKubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	secret, err := k8sClient.CoreV1().Secrets(_talosSecretNamespace).Get(ctx, _talosSecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read the %s secret: %w", _talosSecretName, err)
+	}
+	secretData, err := platform_talos.FromSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	c, err := secretData.GetTalosClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := refreshKubeconfig(ctx, c); err != nil {
+		return err
+	}
+
+	// restore the local files the secret holds, the kubeconfig was just written
+	for name, content := range secretData.BootstrapFiles {
+		if name == "kubeconfig" {
+			continue
+		}
+		if _, err := os.Stat(name); err == nil && !*force {
+			continue
+		}
+		if err := marshal.WriteFile(name, content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if _, err := os.Stat(_bootstrapStateFile); errors.Is(err, os.ErrNotExist) || *force {
+		saveState = SaveState{
+			MachinesCache: secretData.MachinesCache,
+			MachinesDisks: make(talos.MachinesDisks),
+		}
+		if err := marshal.MarshalToFile(_bootstrapStateFile, saveState); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+
+	secretData.BootstrapFiles["kubeconfig"] = kubeconfig
+	if err := secretData.CreateOrUpdateSecret(ctx, k8sClient, _talosSecretNamespace, _talosSecretName); err != nil {
+		cliLogger{}.Warnf("failed to store the new kubeconfig in the %s secret: %s", _talosSecretName, err)
+	}
+	fmt.Println("Wrote ./kubeconfig and restored the talosconfig and config bundle from the cluster")
+	return nil
+}
+
+func RunDestroyCommand(args []string) error {
+	fs := flag.NewFlagSet("destroy", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	keepGitHub := fs.Bool("keep-github", false, "Keep the GitHub repository and App installation")
+	keepGCP := fs.Bool("keep-gcp", false, "Keep the GCP service account")
+	keepLocal := fs.Bool("keep-local", false, "Keep the local state and secrets")
+	_ = fs.Parse(args)
+
+	if err := loadBootstrapState(); err != nil {
+		return err
+	}
+	clusterName := bootstrapInfos.TalosInfo.ClusterName
+	if !*yes {
+		fmt.Printf("This resets every machine of %s and deletes what the bootstrap created. Type the cluster name to confirm: ", clusterName)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != clusterName {
+			return fmt.Errorf("confirmation does not match, nothing was destroyed")
+		}
+	}
+
+	ctx := context.Background()
+	log := cliLogger{}
+	var errs []error
+
+	c, err := talos.NewClient(ctx, ConfigBundle.TalosConfig())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	// workers first, control planes keep serving the Talos API for the others.
+	// Reset machines leave the state so a rerun only retries the others.
+	var notReset []string
+	for _, machines := range []map[string][]byte{saveState.MachinesCache.Workers, saveState.MachinesCache.ControlPlanes} {
+		for ip := range machines {
+			log.Infof("Resetting %s...", ip)
+			resetCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+			err := talos.ResetNode(resetCtx, c, ip)
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to reset %s: %w", ip, err))
+				notReset = append(notReset, ip)
+				continue
+			}
+			delete(machines, ip)
+		}
+	}
+
+	if !*keepGitHub && saveState.GitHubApp.ID != 0 && saveState.GitHubAppInstallResult.InstallationID != "" {
+		app := saveState.GitHubApp
+		installationID := saveState.GitHubAppInstallResult.InstallationID
+		if saveState.GitHubRepoCreated {
+			log.Infof("Deleting GitHub repository %s/%s...", bootstrapInfos.GitHubInfo.RepoOwner, bootstrapInfos.GitHubInfo.RepoName)
+			client, err := github.NewClientFromApp(ctx, app.ID, app.PEM, installationID)
+			if err == nil {
+				_, err = client.Repositories.Delete(ctx, bootstrapInfos.GitHubInfo.RepoOwner, bootstrapInfos.GitHubInfo.RepoName)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete the GitHub repository: %w", err))
+			} else {
+				saveState.GitHubRepoCreated = false
+			}
+		}
+		log.Info("Uninstalling the GitHub App...")
+		if err := github.DeleteAppInstallation(ctx, app.ID, app.PEM, installationID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to uninstall the GitHub App: %w", err))
+		} else {
+			saveState.GitHubAppInstallResult.InstallationID = ""
+		}
+		log.Warnf("GitHub does not allow deleting Apps through the API, delete %s from its settings (%s)", app.Name, app.HTMLURL)
+	}
+
+	if !*keepGCP && gcpEnabled && bootstrapInfos.GCPInfo.ProjectID != "" {
+		log.Infof("Deleting GCP service account %s, sign in to GCP to continue...", _gcpServiceAccountName)
+		if err := deleteGCPServiceAccount(ctx, log); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch {
+	case len(notReset) > 0:
+		// the talosconfig and state are the only way to reach the machines that were not reset
+		if err := saveBootstrapState(); err != nil {
+			errs = append(errs, err)
+		}
+		log.Warnf("%s could not be reset, the local state and secrets are kept: rerun destroy once they are reachable (-keep-gcp if the service account is already deleted)",
+			strings.Join(notReset, ", "))
+	case !*keepLocal:
+		if err := wipeLocalSecrets(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	log.Successf("Cluster %s destroyed", clusterName)
+	return nil
+}
+
+// deleteGCPServiceAccount signs in to GCP with the bootstrap OAuth client and deletes the platform service account
+func deleteGCPServiceAccount(ctx context.Context, log cliLogger) error {
+	oauth.CreateServerIfNotExists("9999", log)
+	oauth.CurrentServer.RegisterProvider(oauth.NewGCPProvider(gcp.GCPClientId, gcp.GCPClientSecret))
+	if err := oauth.CurrentServer.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start OAuth server: %w", err)
+	}
+	defer oauth.CurrentServer.Stop(ctx)
+
+	token, err := oauth.CurrentServer.Authenticate(ctx, "GCP")
+	if err != nil {
+		return fmt.Errorf("GCP authentication failed: %w", err)
+	}
+	if err := gcp.DeleteServiceAccountWithOAuth(ctx, bootstrapInfos.GCPInfo.ProjectID, token, _gcpServiceAccountName); err != nil {
+		return fmt.Errorf("failed to delete the GCP service account: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// gcp.go
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/siderolabs/image-factory/pkg/schematic"
+	"github.com/siderolabs/talos/pkg/machinery/api/storage"
+	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/gcp/provision"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/marshal"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/terraform"
+)
+
+const (
+	_gcpTerraformDir          = "terraform"
+	_gcpProvisionTimeout      = 30 * time.Minute
+	_gcpMaintenanceAPITimeout = 10 * time.Minute
+	// _gcpOperatorAddressURL answers with the public IPv4 address of the caller, the instances are reached over IPv4
+	_gcpOperatorAddressURL = "https://api.ipify.org"
+)
+
+// gcpControlPlanes is the number of control planes to create in GCP, 0 when the cluster runs on bare-metal machines
+func gcpControlPlanes() int {
+	if !gcpEnabled {
+		return 0
+	}
+	count, _ := strconv.Atoi(bootstrapInfos.GCPInfo.ControlPlanes)
+	return max(count, 0)
+}
+
+// gcpControlPlaneInputs are the settings the control plane instances are created from
+func gcpControlPlaneInputs() any {
+	info := bootstrapInfos.GCPInfo
+	return []string{info.ProjectID, info.Region, info.ControlPlanes, info.ControlPlaneMachineType, info.ControlPlaneZone,
+		info.ControlPlaneDiskSizeGB, bootstrapInfos.TalosInfo.ClusterName, bootstrapInfos.TalosInfo.TalosVersion}
+}
+
+// RunGCPControlPlaneStep creates the network, the Talos image and the control plane instances in GCP with the backend
+// Terraform templates. The instances boot in maintenance mode and are added to the machines, the cluster bootstrap
+// then configures them like bare-metal machines. Until then anyone reaching their Talos API could configure them, so
+// it is only open to the public address of this host.
+func RunGCPControlPlaneStep(m *tui.Model, s *tui.Step) error {
+	count := gcpControlPlanes()
+	if count == 0 {
+		return nil
+	}
+	if gcpConfig == nil {
+		return fmt.Errorf("the GCP service account is required to create the control plane")
+	}
+	if err := terraform.CheckTerraformInstalled(); err != nil {
+		return err
+	}
+	info := bootstrapInfos.GCPInfo
+	diskSizeGB, err := strconv.Atoi(info.ControlPlaneDiskSizeGB)
+	if err != nil {
+		return fmt.Errorf("invalid control plane disk size %q: %w", info.ControlPlaneDiskSizeGB, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), _gcpProvisionTimeout)
+	defer cancel()
+
+	// The bucket name is global to GCP, it gets a random suffix and is kept for the next runs
+	if saveState.GCPBucket == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return fmt.Errorf("failed to generate bucket name: %w", err)
+		}
+		saveState.GCPBucket = fmt.Sprintf("%s-bootstrap-%s", provision.SanitizeName(bootstrapInfos.TalosInfo.ClusterName), hex.EncodeToString(suffix))
+		if err := marshal.MarshalToFile(_bootstrapStateFile, saveState); err != nil {
+			m.Logger.Errorf("Error saving state: %s", err)
+		}
+	}
+
+	talosInfo := bootstrapInfos.TalosInfo
+	schematicID, err := talos.CreateFactoryClient().SchematicCreate(ctx, schematic.Schematic{})
+	if err != nil {
+		return fmt.Errorf("failed to create schematic: %w", err)
+	}
+
+	operatorCIDR, err := provision.OperatorCIDR(ctx, _gcpOperatorAddressURL)
+	if err != nil {
+		return err
+	}
+
+	cloud, err := provision.NewGCPCloud(ctx, gcpConfig.ProjectID, gcpConfig.Region, gcpConfig.ServiceAccountJSON)
+	if err != nil {
+		return err
+	}
+	provisioner, err := provision.NewProvisioner(provision.Config{
+		ProjectID:            gcpConfig.ProjectID,
+		Region:               gcpConfig.Region,
+		Zone:                 info.ControlPlaneZone,
+		ServiceAccountJSON:   gcpConfig.ServiceAccountJSON,
+		ClusterName:          talosInfo.ClusterName,
+		BucketName:           saveState.GCPBucket,
+		ControlPlanes:        count,
+		MachineType:          info.ControlPlaneMachineType,
+		DiskSizeGB:           diskSizeGB,
+		TalosVersion:         talosInfo.TalosVersion,
+		Architecture:         talosInfo.TalosArchitecture,
+		TalosImageURL:        fmt.Sprintf("%s/image/%s/%s/gcp-%s.raw.tar.gz", _talosFactoryURL, schematicID, talosInfo.TalosVersion, talosInfo.TalosArchitecture),
+		WorkDir:              _gcpTerraformDir,
+		TalosAPISourceRanges: []string{operatorCIDR},
+	}, provision.TerraformRunner{}, cloud, m.Logger)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := provisioner.Provision(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range provision.AddToMachines(nodes, &saveState.MachinesCache) {
+		disks, err := waitForMaintenanceAPI(ctx, node.ExternalIP)
+		if err != nil {
+			return fmt.Errorf("instance %s did not reach maintenance mode: %w", node.Name, err)
+		}
+		installDisk, err := provision.InstallDisk(disks)
+		if err != nil {
+			return fmt.Errorf("instance %s: %w", node.Name, err)
+		}
+		saveState.MachinesDisks[node.ExternalIP] = installDisk
+		m.Logger.Successf("%s is in maintenance mode", node.Name)
+	}
+
+	saveState.BootstrapInfo = *bootstrapInfos
+	if err := marshal.MarshalToFile(_bootstrapStateFile, saveState); err != nil {
+		m.Logger.Errorf("Error saving state: %s", err)
+	}
+	s.SetOutput("bucket", saveState.GCPBucket)
+	return nil
+}
+
+// waitForMaintenanceAPI waits for the Talos maintenance API of a booting instance and returns its disks
+func waitForMaintenanceAPI(ctx context.Context, ip string) ([]*storage.Disk, error) {
+	ctx, cancel := context.WithTimeout(ctx, _gcpMaintenanceAPITimeout)
+	defer cancel()
+	for {
+		disks, err := talos.GetDisks(ctx, ip)
+		if err == nil {
+			return disks, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out, last error: %w", err)
+		case <-time.After(machineRetryInterval):
+		}
+	}
+}
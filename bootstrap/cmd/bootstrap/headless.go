@@ -0,0 +1,229 @@
+// headless.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/marshal"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/oauth"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+)
+
+// Exit codes of the non-interactive mode
+const (
+	exitStepFailed    = 1
+	exitInvalidConfig = 2
+)
+
+// machineRetryInterval is the delay before querying a discovered machine again when its maintenance API is not up yet
+const machineRetryInterval = 5 * time.Second
+
+// LoadHeadlessConfig reads and validates a non-interactive bootstrap config.
+// Empty fields take the default the wizard would have prefilled.
+func LoadHeadlessConfig(path string) (*BootstrapInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	info := &BootstrapInfo{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(info); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	tui.FillStructDefaults(&info.TalosInfo)
+	var missing []string
+	for _, name := range tui.MissingRequiredFields(&info.TalosInfo) {
+		missing = append(missing, "TalosInfo."+name)
+	}
+	if gitHubEnabled {
+		for _, name := range tui.MissingRequiredFields(&info.GitHubInfo) {
+			missing = append(missing, "GitHubInfo."+name)
+		}
+	}
+	if gcpEnabled {
+		tui.FillStructDefaults(&info.GCPInfo)
+		for _, name := range tui.MissingRequiredFields(&info.GCPInfo) {
+			missing = append(missing, "GCPInfo."+name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	if err := info.TalosInfo.ValidateEndpoint(); err != nil {
+		return nil, err
+	}
+
+	// Control planes created in GCP are the only machines of the cluster
+	if count, _ := strconv.Atoi(info.GCPInfo.ControlPlanes); gcpEnabled && count > 0 {
+		if len(info.Machines) > 0 {
+			return nil, fmt.Errorf("Machines can not be declared when GCPInfo.control_planes is set")
+		}
+		return info, nil
+	}
+	if len(info.Machines) == 0 {
+		return nil, fmt.Errorf("no Machines declared")
+	}
+	controlPlanes := 0
+	for i, machine := range info.Machines {
+		if err := machine.Validate(); err != nil {
+			return nil, fmt.Errorf("machine %d: %w", i, err)
+		}
+		if machine.Role == talos.RoleControlPlane {
+			controlPlanes++
+		}
+	}
+	if controlPlanes == 0 {
+		return nil, fmt.Errorf("at least one control-plane machine is required")
+	}
+
+	return info, nil
+}
+
+// exitHeadless reports a failure that happened before the steps could run
+func exitHeadless(code int, err error) {
+	_ = json.NewEncoder(os.Stdout).Encode(tui.ProgressEvent{
+		Time:    time.Now(),
+		Event:   tui.EventFailed,
+		Level:   tui.LevelError.String(),
+		Message: err.Error(),
+	})
+	os.Exit(code)
+}
+
+// RunWaitForExpectedMachinesStep replaces the server wait and configure steps in non-interactive mode.
+// Discovered machines are matched against the declared ones by IP, MAC or serial and get their role and install disk.
+// The step is done once every declared machine was matched.
+func RunWaitForExpectedMachinesStep(model *tui.Model, step *tui.Step) tea.Cmd {
+	if gcpControlPlanes() > 0 {
+		model.Logger.Info("Control planes are created in GCP, skipping server wait")
+		step.IsDone = true
+		return nil
+	}
+	if len(saveState.MachinesCache.ControlPlanes) > 0 || len(saveState.MachinesCache.Workers) > 0 {
+		model.Logger.Info("Machines already configured, skipping server wait")
+		step.IsDone = true
+		return nil
+	}
+
+	expected := bootstrapInfos.Machines
+	model.Logger.Infof("Cluster: %s, waiting for %d machines", bootstrapInfos.TalosInfo.ClusterName, len(expected))
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	matched := make(map[int]string) // expected machine index -> IP
+	discovered := make(chan string, len(expected)*4)
+
+	queue := func(ip string) {
+		go func() { discovered <- ip }()
+	}
+
+	StartDiscoverySink(model.Logger, func(ip string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if seen[ip] {
+			return
+		}
+		seen[ip] = true
+		model.Logger.Infof("Discovered machine %s", ip)
+		queue(ip)
+	})
+
+	go func() {
+		for ip := range discovered {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			identity, err := talos.GetMachineIdentity(ctx, ip)
+			cancel()
+			if err != nil {
+				model.Logger.Warnf("Machine %s is not ready yet, retrying: %v", ip, err)
+				time.AfterFunc(machineRetryInterval, func() { queue(ip) })
+				continue
+			}
+
+			index := -1
+			for i, machine := range expected {
+				if _, taken := matched[i]; !taken && machine.Matches(identity) {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				model.Logger.Warnf("Ignoring machine %s (serial %q, MACs %s), it matches no declared machine", ip, identity.Serial, strings.Join(identity.MACs, ", "))
+				continue
+			}
+			machine := expected[index]
+
+			ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+			disks, err := talos.GetDisks(ctx, ip)
+			cancel()
+			if err != nil {
+				model.Logger.Warnf("Failed to list disks of %s, retrying: %v", ip, err)
+				time.AfterFunc(machineRetryInterval, func() { queue(ip) })
+				continue
+			}
+			disk, err := machine.Disk.Select(disks)
+			if err != nil {
+				model.Logger.Errorf("Machine %s: %s", ip, err)
+				continue
+			}
+
+			matched[index] = ip
+			saveState.MachinesDisks[ip] = disk.BusPath
+			if machine.Role == talos.RoleControlPlane {
+				saveState.MachinesCache.ControlPlanes[ip] = make([]byte, 0)
+			} else {
+				saveState.MachinesCache.Workers[ip] = make([]byte, 0)
+			}
+			syncPXEState()
+			saveState.BootstrapInfo = *bootstrapInfos
+			if err := marshal.MarshalToFile(_bootstrapStateFile, saveState); err != nil {
+				model.Logger.Errorf("Error saving state: %s", err)
+			}
+			model.Logger.Successf("Machine %s is %s, install disk %s (%s, %d GB) [%d/%d]",
+				ip, machine.Role, disk.DeviceName, disk.BusPath, disk.Size/_gigabyte, len(matched), len(expected))
+
+			if len(matched) == len(expected) {
+				// Machines configured from here on, same as leaving the wizard's configure steps
+				ConfigBundle = nil
+				step.IsDone = true
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// RunHeadless runs the wizard steps without the TUI and returns the process exit code
+func RunHeadless(steps []*tui.Step, logFile *os.File, stepTimeout time.Duration) int {
+	model := tui.NewHeadless(steps, os.Stdout, logFile)
+	model.Checkpoints = saveState.Checkpoints
+	model.OnCheckpoint = SaveCheckpoint
+
+	if gitHubEnabled && saveState.GitHubApp.ID == 0 {
+		model.Logger.Warn("No GitHub App in the state file, creating and installing it requires a browser")
+	}
+
+	oauth.CreateServerIfNotExists("9999", model.Logger)
+	if gcpEnabled {
+		SetupGCP()
+	}
+
+	if err := model.RunHeadless(tui.HeadlessOptions{StepTimeout: stepTimeout}); err != nil {
+		return exitStepFailed
+	}
+	return 0
+}
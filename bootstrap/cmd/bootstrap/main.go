@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net"
@@ -23,6 +24,7 @@ import (
 	"github.com/siderolabs/talos/pkg/machinery/api/storage"
 	"github.com/stolos-cloud/stolos-bootstrap/internal/logging"
 	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/encryption"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/gcp"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/github"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/helm"
@@ -45,7 +47,7 @@ import (
 )
 
 var bootstrapInfos = &BootstrapInfo{}
-var doRestoreProgress = false
+var stolosPlatformGVR = schema.GroupVersionResource{Group: "stolos.cloud", Version: "v1alpha", Resource: "stolosplatforms"}
 var didReadBootstrapInfos = false
 
 // var tui.Steps []tui.Step
@@ -68,6 +70,29 @@ const _bootstrapConfigFile = "bootstrap-config.json"
 const _gigabyte = 1073741824
 
 func main() {
+	configPath := flag.String("config", "", "Run non-interactively from this bootstrap config, progress is written to stdout as JSON lines")
+	stepTimeout := flag.Duration("step-timeout", 45*time.Minute, "Maximum duration of a step in non-interactive mode (0 for none)")
+	encryptionMode := flag.String("encryption", encryption.ModeKeyring, "Encryption at rest of the state and config bundle: keyring, passphrase ($"+_statePassphraseEnv+" or prompt) or none. "+
+		"Without a keyring, and in non-interactive runs when set, the age identity file of $"+_stateIdentityEnv+" or the passphrase is used")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\nCommands:\n", os.Args[0])
+		PrintCommandsUsage()
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	headless := *configPath != ""
+
+	if err := SetupStateEncryption(*encryptionMode, headless); err != nil {
+		if headless {
+			exitHeadless(exitInvalidConfig, err)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if flag.NArg() > 0 {
+		os.Exit(RunCommand(flag.Arg(0), flag.Args()[1:]))
+	}
 
 	tui.RegisterDefaultFunc("GetOutboundIP", GetOutboundIP)
 	tui.RegisterDefaultFunc("GetLatestStolosRelease", GetLatestStolosRelease)
@@ -79,7 +104,6 @@ func main() {
 		if err == nil {
 			bootstrapInfos = &saveState.BootstrapInfo
 			didReadBootstrapInfos = true
-			doRestoreProgress = true
 		}
 		ConfigBundle, _ = marshal.ReadSplitConfigBundleFiles()
 	}
@@ -93,8 +117,13 @@ func main() {
 		}
 	}
 
-	_, err = os.Stat(_bootstrapConfigFile)
-	if !(errors.Is(err, os.ErrNotExist)) {
+	if headless {
+		bootstrapInfos, err = LoadHeadlessConfig(*configPath)
+		if err != nil {
+			exitHeadless(exitInvalidConfig, err)
+		}
+		didReadBootstrapInfos = true
+	} else if _, err = os.Stat(_bootstrapConfigFile); !(errors.Is(err, os.ErrNotExist)) {
 		readBootstrapInfos, err := marshal.UnmarshalFromFile[BootstrapInfo](_bootstrapConfigFile)
 		if err == nil {
 			bootstrapInfos = &readBootstrapInfos
@@ -134,6 +163,7 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
+		DependsOn:   []string{"GitHubInfo"},
 		OnEnter:     RunGitHubAuthStepInBackground,
 		OnExit: func(m *tui.Model, s *tui.Step) {
 			// TODO CHECK GH AUTH
@@ -147,7 +177,13 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter:     RunGitHubRepoStepWithApp,
+		DependsOn:   []string{"GitHubInstallApp"},
+		Run:         RunGitHubRepoStepWithApp,
+		Inputs: func() any {
+			info := bootstrapInfos.GitHubInfo
+			return []any{info.RepoOwner, info.RepoName, info.BaseDomain, info.LoadBalancerIP, saveState.GitHubApp.ID}
+		},
+		Restore: RestoreGitHubRepoStep,
 		//OnExit: //TODO validate,
 	}
 
@@ -157,60 +193,48 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter: func(m *tui.Model, s *tui.Step) tea.Cmd {
-			// Check if we should restore from saved state
-			if doRestoreProgress && saveState.GitHubApp.ID != 0 {
-				m.Logger.Info("State file found, restoring GitHub App from saved state")
-				gitHubAppManifest = &saveState.GitHubApp
-				// Restore the GitHub User from the saved state
-				ctx := context.Background()
-				var err error
-				gitHubUser, err = github.GetGitHubUser(ctx, bootstrapInfos.GitHubInfo.RepoOwner, nil)
-				if err != nil {
-					m.Logger.Warnf("Could not fetch GitHub User, but continuing with saved state: %v", err)
-				}
-				m.Logger.Successf("Restored GitHub App: %s (ID: %d)", gitHubAppManifest.Name, gitHubAppManifest.ID)
-				s.IsDone = true
-				s.AutoAdvance = true
-				return nil
+		DependsOn:   []string{"GitHubInfo"},
+		Inputs: func() any {
+			return []string{bootstrapInfos.GitHubInfo.RepoOwner, bootstrapInfos.GitHubInfo.BaseDomain}
+		},
+		Restore: func(m *tui.Model, s *tui.Step) error {
+			if saveState.GitHubApp.ID == 0 {
+				return fmt.Errorf("no GitHub App in the state")
 			}
-
+			gitHubAppManifest = &saveState.GitHubApp
+			m.Logger.Successf("Restored GitHub App: %s (ID: %d)", gitHubAppManifest.Name, gitHubAppManifest.ID)
+			return nil
+		},
+		Run: func(m *tui.Model, s *tui.Step) error {
 			m.Logger.Infof("Starting GitHub App Manifest Flow...")
-			go func() {
-				ctx := context.Background()
-				listenAddr := "127.0.0.1:19999"
-				remoteBaseUrl := "https://api." + bootstrapInfos.GitHubInfo.BaseDomain //URL when deployed
-				webhookEndpoint := "/api/v1/github_webhook"                            //webhook endpoint
-				callbacksEndpoint := "/api/v1/github_callback"                         // additional install callback for deployed URL
+			ctx := context.Background()
+			listenAddr := "127.0.0.1:19999"
+			remoteBaseUrl := "https://api." + bootstrapInfos.GitHubInfo.BaseDomain //URL when deployed
+			webhookEndpoint := "/api/v1/github_webhook"                            //webhook endpoint
+			callbacksEndpoint := "/api/v1/github_callback"                         // additional install callback for deployed URL
 
-				gitHubUser, err = github.GetGitHubUser(ctx, bootstrapInfos.GitHubInfo.RepoOwner, nil)
-
-				if err != nil {
-					m.Logger.Errorf("Failed to get GitHub User: %v", err)
-					return
-				}
+			gitHubUser, err = github.GetGitHubUser(ctx, bootstrapInfos.GitHubInfo.RepoOwner, nil)
+			if err != nil {
+				return fmt.Errorf("failed to get GitHub User: %w", err)
+			}
 
-				if gitHubUser.Type != "Organization" {
-					m.Logger.Errorf("You must login as an organization!")
-					return
-				}
+			if gitHubUser.Type != "Organization" {
+				return fmt.Errorf("you must login as an organization")
+			}
 
-				gitHubAppManifestParams = github.CreateGitHubManifestParameters(remoteBaseUrl, webhookEndpoint, callbacksEndpoint, listenAddr)
-				gitHubAppManifest, err = github.GitHubAppManifestFlow(ctx, listenAddr, m.Logger, gitHubAppManifestParams, *gitHubUser)
-				if err != nil {
-					m.Logger.Errorf("GitHub App Manifest Flow Error: %s", err.Error())
-					return
-				}
+			gitHubAppManifestParams = github.CreateGitHubManifestParameters(remoteBaseUrl, webhookEndpoint, callbacksEndpoint, listenAddr)
+			gitHubAppManifest, err = github.GitHubAppManifestFlow(ctx, listenAddr, m.Logger, gitHubAppManifestParams, *gitHubUser)
+			if err != nil {
+				return fmt.Errorf("GitHub App Manifest Flow failed: %w", err)
+			}
 
-				saveState.GitHubApp = *gitHubAppManifest
-				saveState.BootstrapInfo = *bootstrapInfos
-				err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
-				if err != nil {
-					m.Logger.Errorf("Error saving state: %s", err)
-				}
-				m.Logger.Successf("GitHub App created successfully! App name: %s, App ID: %d", gitHubAppManifest.Name, gitHubAppManifest.ID)
-				s.IsDone = true
-			}()
+			saveState.GitHubApp = *gitHubAppManifest
+			saveState.BootstrapInfo = *bootstrapInfos
+			err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
+			if err != nil {
+				m.Logger.Errorf("Error saving state: %s", err)
+			}
+			m.Logger.Successf("GitHub App created successfully! App name: %s, App ID: %d", gitHubAppManifest.Name, gitHubAppManifest.ID)
 			return nil
 		},
 	}
@@ -221,37 +245,39 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter: func(m *tui.Model, s *tui.Step) tea.Cmd {
-			// Check if we should restore from saved state
-			if doRestoreProgress && saveState.GitHubAppInstallResult.InstallationID != "" {
-				m.Logger.Info("State file found, restoring GitHub App installation from saved state")
-				m.Logger.Successf("Restored GitHub App Installation (ID: %s)", saveState.GitHubAppInstallResult.InstallationID)
-				s.IsDone = true
-				s.AutoAdvance = true
-				return nil
+		DependsOn:   []string{"GitHubApp"},
+		Inputs: func() any {
+			return []any{bootstrapInfos.GitHubInfo.RepoOwner, saveState.GitHubApp.ID}
+		},
+		Restore: func(m *tui.Model, s *tui.Step) error {
+			if saveState.GitHubAppInstallResult.InstallationID == "" {
+				return fmt.Errorf("no GitHub App installation in the state")
 			}
-
+			m.Logger.Successf("Restored GitHub App Installation (ID: %s)", saveState.GitHubAppInstallResult.InstallationID)
+			return nil
+		},
+		Run: func(m *tui.Model, s *tui.Step) error {
 			m.Logger.Infof("Opening the GitHub app install page...")
-
-			go func() {
-				ctx := context.Background()
-				listenAddr := "127.0.0.1:19999"
-				postInstallResult, err := github.GitHubAppInstallFlow(ctx, listenAddr, gitHubUser, gitHubAppManifest, m.Logger)
-				if err != nil {
-					m.Logger.Errorf("GitHub App Install Flow Error: %s", err.Error())
-					return
-				}
-				saveState.GitHubAppInstallResult = *postInstallResult
-				saveState.BootstrapInfo = *bootstrapInfos
-				err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
-				if err != nil {
-					m.Logger.Errorf("Error saving state: %s", err)
+			ctx := context.Background()
+			listenAddr := "127.0.0.1:19999"
+			// the GitHub App may have been restored from the state
+			if gitHubUser == nil {
+				var err error
+				if gitHubUser, err = github.GetGitHubUser(ctx, bootstrapInfos.GitHubInfo.RepoOwner, nil); err != nil {
+					return fmt.Errorf("failed to get GitHub User: %w", err)
 				}
-				m.Logger.Successf("GitHub App installed successfully! Installation ID: %s", postInstallResult.InstallationID)
-
-				s.IsDone = true
-			}()
-
+			}
+			postInstallResult, err := github.GitHubAppInstallFlow(ctx, listenAddr, gitHubUser, gitHubAppManifest, m.Logger)
+			if err != nil {
+				return fmt.Errorf("GitHub App Install Flow failed: %w", err)
+			}
+			saveState.GitHubAppInstallResult = *postInstallResult
+			saveState.BootstrapInfo = *bootstrapInfos
+			err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
+			if err != nil {
+				m.Logger.Errorf("Error saving state: %s", err)
+			}
+			m.Logger.Successf("GitHub App installed successfully! Installation ID: %s", postInstallResult.InstallationID)
 			return nil
 		},
 	}
@@ -288,6 +314,7 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
+		DependsOn:   []string{"GCPInfo"},
 		OnEnter: func(m *tui.Model, s *tui.Step) tea.Cmd {
 			if !gcpEnabled {
 				return nil
@@ -315,7 +342,21 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter:     RunGCPSAStepInBackground,
+		DependsOn:   []string{"GCPAuth"},
+		Run:         RunGCPSAStep,
+		AlwaysRun:   true, // the service account config is not kept in the state
+	}
+
+	gcpControlPlaneStep := tui.Step{
+		Name:        "GCPControlPlane",
+		Title:       "3.0.2) Create GCP control plane",
+		Kind:        tui.StepSpinner,
+		IsDone:      false,
+		AutoAdvance: true,
+		DependsOn:   []string{"GCPServiceAccount", "TalosInfo"},
+		Run:         RunGCPControlPlaneStep,
+		Inputs:      gcpControlPlaneInputs,
+		Retry:       tui.RetryPolicy{Attempts: 2, Backoff: 30 * time.Second},
 	}
 
 	// Only auto-advance TalosInfo step if we have actual TalosInfo data
@@ -329,7 +370,7 @@ func main() {
 		AutoAdvance: hasTalosInfo,
 		OnEnter: func(m *tui.Model, s *tui.Step) tea.Cmd {
 			// Only skip if TalosInfo was actually saved
-			if doRestoreProgress && bootstrapInfos.TalosInfo.ClusterName != "" {
+			if hasTalosInfo {
 				m.Logger.Info("State file found, skipping talos info form")
 				// Ensure StolosAirwayTag has a value
 				if bootstrapInfos.TalosInfo.StolosAirwayTag == "" {
@@ -358,13 +399,37 @@ func main() {
 		},
 	}
 
+	airgapStep := tui.Step{
+		Name:        "Airgap",
+		Title:       "3.0.1) Start air-gap registry",
+		Kind:        tui.StepSpinner,
+		IsDone:      false,
+		AutoAdvance: true,
+		DependsOn:   []string{"TalosInfo"},
+		Run:         RunAirgapStep,
+		AlwaysRun:   true, // serves for the rest of the bootstrap
+	}
+
 	talosISOStep := tui.Step{
 		Name:        "TalosISOStep",
 		Title:       "3.1) Download Talos ISO",
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter:     RunTalosISOStep,
+		DependsOn:   []string{"TalosInfo", "Airgap"},
+		Run:         RunTalosISOStep,
+		Inputs:      talosImageInputs,
+	}
+
+	pxeServerStep := tui.Step{
+		Name:        "PXEServer",
+		Title:       "3.1.1) Start PXE server",
+		Kind:        tui.StepSpinner,
+		IsDone:      false,
+		AutoAdvance: true,
+		DependsOn:   []string{"TalosISOStep"},
+		Run:         RunPXEServerStep,
+		AlwaysRun:   true, // serves for the rest of the bootstrap
 	}
 
 	waitforServersStep := tui.Step{
@@ -374,17 +439,35 @@ func main() {
 		IsDone:      true, // Set by server
 		AutoAdvance: false,
 		Body:        "Press enter when you see all servers below (min 4):\n",
+		DependsOn:   []string{"TalosISOStep", "PXEServer"},
 		OnEnter:     RunWaitForServersStep,
 		OnExit:      ExitWaitForServersStep,
 	}
 
+	preflightStep := tui.Step{
+		Name:        "Preflight",
+		Title:       "3.3) Pre-flight checks",
+		Kind:        tui.StepSpinner,
+		IsDone:      false,
+		AutoAdvance: false,
+		DependsOn:   []string{"WaitServersStep"},
+		Run:         RunPreflightStep,
+		AlwaysRun:   true,
+	}
+
 	clusterBootstrapStep := tui.Step{
 		Name:        "ClusterBootstrap",
 		Title:       "3.4) Bootstrap Kubernetes",
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter:     RunClusterBootstrapStepInBackground,
+		DependsOn:   []string{"Preflight", "GCPControlPlane"},
+		Run:         RunClusterBootstrapStep,
+		Inputs: func() any {
+			info := bootstrapInfos.TalosInfo
+			return []string{info.ClusterName, info.KubernetesVersion, info.TalosVersion}
+		},
+		Retry: tui.RetryPolicy{Attempts: 2, Backoff: 30 * time.Second},
 	}
 
 	deployArgoStep := tui.Step{
@@ -393,7 +476,12 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter:     RunArgoStepInBackground,
+		DependsOn:   []string{"ClusterBootstrap"},
+		Run:         RunArgoStep,
+		Inputs: func() any {
+			return []string{helm.ArgoChartVersion, saveState.ClusterEndpoint}
+		},
+		Retry: tui.RetryPolicy{Attempts: 3, Backoff: 10 * time.Second, MaxBackoff: time.Minute},
 	}
 
 	deployPortalStep := tui.Step{
@@ -402,7 +490,12 @@ func main() {
 		Kind:        tui.StepSpinner,
 		IsDone:      false,
 		AutoAdvance: true,
-		OnEnter:     RunPortalStepInBackground,
+		DependsOn:   []string{"DeployArgo", "GitHubRepo", "GCPServiceAccount"},
+		Run:         RunPortalStep,
+		Inputs: func() any {
+			return []any{bootstrapInfos.TalosInfo.ClusterName, bootstrapInfos.TalosInfo.StolosAirwayTag, bootstrapInfos.GitHubInfo, saveState.ClusterEndpoint}
+		},
+		Retry: tui.RetryPolicy{Attempts: 3, Backoff: 30 * time.Second, MaxBackoff: 2 * time.Minute},
 	}
 
 	endStep := tui.Step{
@@ -412,12 +505,14 @@ func main() {
 		Kind:        tui.StepPlain,
 		IsDone:      false,
 		AutoAdvance: false,
+		DependsOn:   []string{"DeployPortal"},
 	}
 
 	// Skip all gcp steps if not enabled
 	tui.DisableStep(&gcpInfoStep, !gcpEnabled)
 	tui.DisableStep(&gcpAuthStep, !gcpEnabled)
 	tui.DisableStep(&gcpSAStep, !gcpEnabled)
+	tui.DisableStep(&gcpControlPlaneStep, !gcpEnabled)
 
 	// Skip all github steps if not enabled
 	tui.DisableStep(&githubInfoStep, !gitHubEnabled)
@@ -427,7 +522,7 @@ func main() {
 	tui.DisableStep(&githubRepoStep, !gitHubEnabled)
 
 	// Attn. sa fait des copies ici !
-	tui.Steps = []*tui.Step{
+	steps := []*tui.Step{
 		&githubInfoStep,
 		&githubAuthStep,
 		&githubAppStep,
@@ -437,17 +532,44 @@ func main() {
 		&gcpAuthStep,
 		&gcpSAStep,
 		&talosInfoStep,
+		&airgapStep,
+		&gcpControlPlaneStep,
 		&talosISOStep,
+		&pxeServerStep,
 		&waitforServersStep,
+		&preflightStep,
 		&clusterBootstrapStep,
 		&deployArgoStep,
 		&deployPortalStep,
 		&endStep,
 	}
+	tui.Steps, err = tui.SortSteps(steps)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if saveState.Checkpoints == nil {
+		saveState.Checkpoints = make(tui.Checkpoints)
+	}
 
 	f, _ := os.OpenFile("./stolos.log", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	defer f.Close()
+
+	if headless {
+		// Machines are matched from the config instead of picked in the configure steps
+		waitforServersStep.IsDone = false
+		waitforServersStep.OnEnter = RunWaitForExpectedMachinesStep
+		waitforServersStep.OnExit = nil
+		if code := RunHeadless(tui.Steps, f, *stepTimeout); code != 0 {
+			f.Close()
+			os.Exit(code)
+		}
+		return
+	}
+
 	p, model := tui.NewWizard(tui.Steps, f)
+	model.Checkpoints = saveState.Checkpoints
+	model.OnCheckpoint = SaveCheckpoint
 
 	oauth.CreateServerIfNotExists("9999", model.Logger)
 	if gcpEnabled {
@@ -494,7 +616,7 @@ func SetupGCP() {
 	}
 }
 
-func RunGCPSAStepInBackground(m *tui.Model, s *tui.Step) tea.Cmd {
+func RunGCPSAStep(m *tui.Model, s *tui.Step) error {
 
 	if !gcpEnabled {
 		return nil
@@ -502,101 +624,89 @@ func RunGCPSAStepInBackground(m *tui.Model, s *tui.Step) tea.Cmd {
 
 	m.Logger.Infof("Read bootstrap infos from file, clusterName: %s", bootstrapInfos.TalosInfo.ClusterName)
 
-	go func() {
-		if gcp.GCPClientId != "" && gcp.GCPClientSecret != "" {
-			// Create GCP service account
-			var err error
-			gcpConfig, err = gcp.CreateServiceAccountWithOAuth(
-				context.Background(),
-				bootstrapInfos.GCPInfo.ProjectID,
-				bootstrapInfos.GCPInfo.Region,
-				gcpToken,
-				"stolos-platform-sa",
-			)
-			if err != nil {
-				m.Logger.Errorf("Failed to create GCP service account: %v", err)
-			} else {
-				m.Logger.Success("GCP service account created successfully")
-			}
-		} else {
-			m.Logger.Infof("GCP OAuth credentials not provided, skipping GCP service account creation")
-		}
+	if gcp.GCPClientId == "" || gcp.GCPClientSecret == "" {
+		m.Logger.Infof("GCP OAuth credentials not provided, skipping GCP service account creation")
+		return nil
+	}
 
-		s.IsDone = true
-	}()
+	// Create GCP service account
+	var err error
+	gcpConfig, err = gcp.CreateServiceAccountWithOAuth(
+		context.Background(),
+		bootstrapInfos.GCPInfo.ProjectID,
+		bootstrapInfos.GCPInfo.Region,
+		gcpToken,
+		_gcpServiceAccountName,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create GCP service account: %w", err)
+	}
+	m.Logger.Success("GCP service account created successfully")
 	return nil
 }
 
-func RunGitHubRepoStepWithApp(m *tui.Model, s *tui.Step) tea.Cmd {
-	// Check if we should restore from saved state
-	if doRestoreProgress && saveState.GitHubRepoCreated {
-		m.Logger.Info("State file found, GitHub repository already created")
-		// Restore the GitHub config
-		githubConfig = github.NewGithubAppConfig(
-			bootstrapInfos.GitHubInfo.RepoOwner,
-			bootstrapInfos.GitHubInfo.RepoName,
-			strconv.FormatInt(gitHubAppManifest.ID, 10),
-			gitHubAppManifest.PEM,
-			saveState.GitHubAppInstallResult.InstallationID,
-		)
-		m.Logger.Successf("Restored GitHub repository: https://github.com/%s/%s.git", bootstrapInfos.GitHubInfo.RepoOwner, bootstrapInfos.GitHubInfo.RepoName)
-		s.IsDone = true
-		s.AutoAdvance = true
-		return nil
+// RestoreGitHubRepoStep restores the GitHub config of a repository created in a previous run
+func RestoreGitHubRepoStep(m *tui.Model, s *tui.Step) error {
+	if !saveState.GitHubRepoCreated || gitHubAppManifest == nil {
+		return fmt.Errorf("no GitHub repository in the state")
 	}
+	githubConfig = github.NewGithubAppConfig(
+		bootstrapInfos.GitHubInfo.RepoOwner,
+		bootstrapInfos.GitHubInfo.RepoName,
+		strconv.FormatInt(gitHubAppManifest.ID, 10),
+		gitHubAppManifest.PEM,
+		saveState.GitHubAppInstallResult.InstallationID,
+	)
+	m.Logger.Successf("Restored GitHub repository: https://github.com/%s/%s.git", bootstrapInfos.GitHubInfo.RepoOwner, bootstrapInfos.GitHubInfo.RepoName)
+	return nil
+}
 
+func RunGitHubRepoStepWithApp(m *tui.Model, s *tui.Step) error {
 	m.Logger.Infof("Creating GitHub repository %s...", bootstrapInfos.GitHubInfo.RepoName)
+	ctx := context.Background()
+
+	// Create GitHub client using app credentials
+	githubClient, err := github.NewClientFromApp(
+		ctx,
+		gitHubAppManifest.ID,
+		gitHubAppManifest.PEM,
+		saveState.GitHubAppInstallResult.InstallationID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client from app: %w", err)
+	}
 
-	go func() {
-		ctx := context.Background()
-
-		// Create GitHub client using app credentials
-		githubClient, err := github.NewClientFromApp(
-			ctx,
-			gitHubAppManifest.ID,
-			gitHubAppManifest.PEM,
-			saveState.GitHubAppInstallResult.InstallationID,
-		)
-		if err != nil {
-			m.Logger.Errorf("Failed to create GitHub client from app: %v", err)
-			return
-		}
-
-		// Initialize repository
-		githubBootstrapInfo := &github.GitHubInfo{
-			RepoName:       bootstrapInfos.GitHubInfo.RepoName,
-			RepoOwner:      bootstrapInfos.GitHubInfo.RepoOwner,
-			BaseDomain:     bootstrapInfos.GitHubInfo.BaseDomain,
-			LoadBalancerIP: bootstrapInfos.GitHubInfo.LoadBalancerIP,
-		}
-
-		_, err = githubClient.InitRepo(githubBootstrapInfo, false)
-		if err != nil {
-			m.Logger.Errorf("Failed to initialize repository: %v", err)
-			return
-		}
+	// Initialize repository
+	githubBootstrapInfo := &github.GitHubInfo{
+		RepoName:       bootstrapInfos.GitHubInfo.RepoName,
+		RepoOwner:      bootstrapInfos.GitHubInfo.RepoOwner,
+		BaseDomain:     bootstrapInfos.GitHubInfo.BaseDomain,
+		LoadBalancerIP: bootstrapInfos.GitHubInfo.LoadBalancerIP,
+	}
 
-		// Create GitHub config with app credentials
-		githubConfig = github.NewGithubAppConfig(
-			bootstrapInfos.GitHubInfo.RepoOwner,
-			bootstrapInfos.GitHubInfo.RepoName,
-			strconv.FormatInt(gitHubAppManifest.ID, 10),
-			gitHubAppManifest.PEM,
-			saveState.GitHubAppInstallResult.InstallationID,
-		)
-
-		// Mark repo as created in state
-		saveState.GitHubRepoCreated = true
-		saveState.BootstrapInfo = *bootstrapInfos
-		err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
-		if err != nil {
-			m.Logger.Errorf("Error saving state: %s", err)
-		}
+	_, err = githubClient.InitRepo(githubBootstrapInfo, false)
+	if err != nil {
+		return fmt.Errorf("failed to initialize repository: %w", err)
+	}
 
-		m.Logger.Successf("Repository created: https://github.com/%s/%s.git", bootstrapInfos.GitHubInfo.RepoOwner, bootstrapInfos.GitHubInfo.RepoName)
-		s.IsDone = true
-	}()
+	// Create GitHub config with app credentials
+	githubConfig = github.NewGithubAppConfig(
+		bootstrapInfos.GitHubInfo.RepoOwner,
+		bootstrapInfos.GitHubInfo.RepoName,
+		strconv.FormatInt(gitHubAppManifest.ID, 10),
+		gitHubAppManifest.PEM,
+		saveState.GitHubAppInstallResult.InstallationID,
+	)
+
+	// Mark repo as created in state
+	saveState.GitHubRepoCreated = true
+	saveState.BootstrapInfo = *bootstrapInfos
+	err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
+	if err != nil {
+		m.Logger.Errorf("Error saving state: %s", err)
+	}
 
+	m.Logger.Successf("Repository created: https://github.com/%s/%s.git", bootstrapInfos.GitHubInfo.RepoOwner, bootstrapInfos.GitHubInfo.RepoName)
 	return nil
 }
 
@@ -641,74 +751,80 @@ func RunGitHubAuthStepInBackground(m *tui.Model, s *tui.Step) tea.Cmd {
 }
 
 func RunWaitForServersStep(model *tui.Model, step *tui.Step) tea.Cmd {
+	if gcpControlPlanes() > 0 {
+		model.Logger.Info("Control planes are created in GCP, skipping server wait")
+		step.IsDone = true
+		step.AutoAdvance = true
+		step.OnExit = nil
+		return nil
+	}
 
-	if doRestoreProgress {
-		// Only skip if machines have already been configured
-		if len(saveState.MachinesCache.ControlPlanes) > 0 || len(saveState.MachinesCache.Workers) > 0 {
-			model.Logger.Info("Machines already configured, skipping server wait")
-			step.IsDone = true
-			step.AutoAdvance = true
-			step.OnExit = nil
-			return nil
-		}
+	// Machines get a role in the configure steps, once they have one the wait is over
+	if len(saveState.MachinesCache.ControlPlanes) > 0 || len(saveState.MachinesCache.Workers) > 0 {
+		model.Logger.Info("Machines already configured, skipping server wait")
+		step.IsDone = true
+		step.AutoAdvance = true
+		step.OnExit = nil
+		return nil
+	}
+	for ip := range saveState.MachinesDisks {
+		step.Body = step.Body + fmt.Sprintf("\nNode: %s", ip)
+	}
 
-		model.Logger.Info("Machines not yet configured, restoring discovered machines")
-		for ip := range saveState.MachinesDisks {
+	model.Logger.Infof("Cluster: %s", bootstrapInfos.TalosInfo.ClusterName)
+	StartDiscoverySink(model.Logger, func(ip string) {
+		_, ok := saveState.MachinesDisks[ip]
+		if !ok {
+			saveState.MachinesDisks[ip] = ""
 			step.Body = step.Body + fmt.Sprintf("\nNode: %s", ip)
+			saveState.BootstrapInfo = *bootstrapInfos
+			err := marshal.MarshalToFile(_bootstrapStateFile, saveState)
+			if err != nil {
+				model.Logger.Errorf("Error saving state: %s", err)
+			}
 		}
-	}
+	})
 
-	model.Logger.Infof("Cluster: %s", bootstrapInfos.TalosInfo.ClusterName)
+	return nil
+}
+
+// StartDiscoverySink runs the Talos event sink in the background and calls onMachine with the IP of every event
+func StartDiscoverySink(logger *tui.UILogger, onMachine func(ip string)) {
 	addr := bootstrapInfos.TalosInfo.HTTPHostname + ":" + bootstrapInfos.TalosInfo.HTTPPort
-	model.Logger.Infof("Starting HTTP Receive Server on %s …", addr)
+	logger.Infof("Starting HTTP Receive Server on %s …", addr)
+	var ignored []string
+	for _, ip := range strings.Split(bootstrapInfos.TalosInfo.DiscoveryIgnoredIPs, ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ignored = append(ignored, ip)
+		}
+	}
 	go func() {
 		for i := 0; i < 5; i++ {
 			err := talos.EventSink(&bootstrapInfos.TalosInfo, func(ctx context.Context, event events.Event) error {
 				ip := strings.Split(event.Node, ":")[0]
 
-				//TODO WHY?
-				var blacklist = []string{
-					"192.168.2.67",
-					"192.168.2.68",
-					"192.168.2.69",
-					"192.168.2.70",
-				}
-
-				if slices.Contains(blacklist, ip) {
+				if slices.Contains(ignored, ip) {
 					return nil
 				}
 
-				_, ok := saveState.MachinesDisks[ip]
-				if !ok {
-					saveState.MachinesDisks[ip] = ""
-					step.Body = step.Body + fmt.Sprintf("\nNode: %s", ip)
-					saveState.BootstrapInfo = *bootstrapInfos
-					err := marshal.MarshalToFile(_bootstrapStateFile, saveState)
-					if err != nil {
-						model.Logger.Errorf("Error saving state: %s", err)
-					}
-				}
-
+				onMachine(ip)
 				return nil
 			})
 
 			if err != nil {
-				model.Logger.Errorf("Error with HTTP Receive Server, trying again...: %s", err)
+				logger.Errorf("Error with HTTP Receive Server, trying again...: %s", err)
 			} else {
-				model.Logger.Info("HTTP Receive Server stoped, restarting...")
+				logger.Info("HTTP Receive Server stoped, restarting...")
 			}
 
 			time.Sleep(5 * time.Second)
 		}
-		model.Logger.Error("HTTP Server failed too many times")
+		logger.Error("HTTP Server failed too many times")
 	}()
-
-	return nil
 }
 
 func ExitWaitForServersStep(model *tui.Model, step *tui.Step) {
-	// Once we're configuring machines, we're no longer in restore mode
-	doRestoreProgress = false
+	// The machines get new configs, from a new bundle
 	ConfigBundle = nil
 
 	i := 0
@@ -795,6 +911,7 @@ func ExitConfigureServer(serverIp string, disks *[]*storage.Disk) func(model *tu
 			model.Logger.Errorf("Invalid role: %d", config.Role)
 		}
 
+		syncPXEState()
 		saveState.BootstrapInfo = *bootstrapInfos
 		err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
 		if err != nil {
@@ -803,513 +920,571 @@ func ExitConfigureServer(serverIp string, disks *[]*storage.Disk) func(model *tu
 	}
 }
 
-func RunTalosISOStep(m *tui.Model, s *tui.Step) tea.Cmd {
-	// Check if we can skip - machines already configured
-	if doRestoreProgress &&
-		(len(saveState.MachinesCache.ControlPlanes) > 0 || len(saveState.MachinesCache.Workers) > 0) {
-		m.Logger.Info("Machines already configured, skipping ISO download")
-		s.IsDone = true
+func RunTalosISOStep(m *tui.Model, s *tui.Step) error {
+	if gcpControlPlanes() > 0 {
+		m.Logger.Info("Control planes are created in GCP, skipping ISO download")
 		return nil
 	}
 
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		//talosConfigArg := fmt.Sprintf("talos.config=http://%s:%s/machineconfig?m=${mac}&u=${uuid}", bootstrapInfos.TalosInfo.HTTPHostname, bootstrapInfos.TalosInfo.HTTPPort)
-		sinkConf := fmt.Sprintf("talos.events.sink=%s:%s", bootstrapInfos.TalosInfo.HTTPHostname, bootstrapInfos.TalosInfo.HTTPPort)
-		kernelArgs := []string{sinkConf, bootstrapInfos.TalosInfo.TalosExtraArgs}
-
-		m.Logger.Infof("Generating image with kernelParam: %s", sinkConf)
-
-		factory := talos.CreateFactoryClient()
-		sch := schematic.Schematic{
-			Overlay: schematic.Overlay{
-				Image: bootstrapInfos.TalosInfo.TalosOverlayImage,
-				Name:  bootstrapInfos.TalosInfo.TalosOverlayName,
-				// Options: nil, // ==> Extra YAML settings passed to overlay image.
-			},
-			Customization: schematic.Customization{
-				ExtraKernelArgs: kernelArgs,
-			},
-		}
-
-		schematicId, _ := factory.SchematicCreate(ctx, sch)
-		m.Logger.Infof("Generated schematicId: %s", schematicId)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-		talosImageFormat := "iso"
-		if bootstrapInfos.TalosInfo.TalosArchitecture == "arm64" {
-			talosImageFormat = "raw.xz"
-		}
-
-		talosImagePath := fmt.Sprintf("metal-%s.%s", bootstrapInfos.TalosInfo.TalosArchitecture, talosImageFormat)
-		isoExists := false
-		if _, err := os.Stat(talosImagePath); err == nil {
-			isoExists = true
-		}
+	m.Logger.Infof("Generating image with kernelParam: talos.events.sink=%s:%s", bootstrapInfos.TalosInfo.HTTPHostname, bootstrapInfos.TalosInfo.HTTPPort)
+	schematicId, err := CreateTalosSchematic(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create schematic: %w", err)
+	}
+	m.Logger.Infof("Generated schematicId: %s", schematicId)
 
-		if isoExists {
-			m.Logger.Infof("Deleting existing ISO: %s", talosImagePath)
-			err := os.Remove(talosImagePath)
-			if err != nil {
-				m.Logger.Errorf("Failed to delete old ISO: %s", err)
-			}
-		} else if !isoExists {
-			m.Logger.Infof("ISO does not exist, will download: %s", talosImagePath)
-		}
+	if bootstrapInfos.TalosInfo.PXEEnabled == "true" {
+		m.Logger.Info("PXE enabled, skipping ISO download")
+		return nil
+	}
 
-		talosImageUrl := fmt.Sprintf("https://factory.talos.dev/image/%s/%s/%s", schematicId, bootstrapInfos.TalosInfo.TalosVersion, talosImagePath)
-		//m.Logger.Infof("%s", talosImageUrl)
+	talosImagePath := talos.ImageFileName(bootstrapInfos.TalosInfo.TalosArchitecture)
+	isoExists := false
+	if _, err := os.Stat(talosImagePath); err == nil {
+		isoExists = true
+	}
 
-		m.Logger.Infof("Downloading image from %s...", talosImageUrl)
-		resp, err := grab.Get(".", talosImageUrl)
+	if isoExists {
+		m.Logger.Infof("Deleting existing ISO: %s", talosImagePath)
+		err := os.Remove(talosImagePath)
 		if err != nil {
-			panic(fmt.Sprintf("Failed to download (%s) image! %s", talosImageUrl, err))
+			m.Logger.Errorf("Failed to delete old ISO: %s", err)
 		}
+	} else if !isoExists {
+		m.Logger.Infof("ISO does not exist, will download: %s", talosImagePath)
+	}
 
-		m.Logger.Successf("Download saved to: %s", resp.Filename)
+	talosImageUrl := fmt.Sprintf("%s/image/%s/%s/%s", talosFactoryURL(), schematicId, bootstrapInfos.TalosInfo.TalosVersion, talosImagePath)
+	//m.Logger.Infof("%s", talosImageUrl)
 
-		err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
-		if err != nil {
-			m.Logger.Errorf("Failed to save schematic ID to state: %s", err)
-		}
+	m.Logger.Infof("Downloading image from %s...", talosImageUrl)
+	resp, err := grab.Get(".", talosImageUrl)
+	if err != nil {
+		return fmt.Errorf("failed to download (%s) image: %w", talosImageUrl, err)
+	}
 
-		s.IsDone = true
-	}()
+	m.Logger.Successf("Download saved to: %s", resp.Filename)
+	s.SetOutput("image", resp.Filename)
+
+	err = marshal.MarshalToFile(_bootstrapStateFile, saveState)
+	if err != nil {
+		m.Logger.Errorf("Failed to save state: %s", err)
+	}
 	return nil
 }
 
-func RunClusterBootstrapStepInBackground(m *tui.Model, s *tui.Step) tea.Cmd {
-	// If restoring progress and ConfigBundle files exist, skip this step
-	if doRestoreProgress && ConfigBundle != nil {
-		m.Logger.Info("ConfigBundle already exists, skipping cluster bootstrap")
-		s.IsDone = true
-		return nil
+// talosImageInputs are the settings the Talos images are built from, a change requires a new schematic and image
+func talosImageInputs() any {
+	info := bootstrapInfos.TalosInfo
+	return []string{info.TalosVersion, info.TalosArchitecture, info.TalosOverlayImage, info.TalosOverlayName,
+		info.TalosExtraArgs, info.HTTPHostname, info.HTTPPort, info.PXEEnabled, info.AirgapBundle}
+}
+
+// CreateTalosSchematic creates the image factory schematic of the Talos images and saves its ID to the state
+func CreateTalosSchematic(ctx context.Context) (string, error) {
+	// The air-gap bundle only has the images of the schematic it was created with
+	if airgapServer != nil {
+		saveState.SchematicID = airgapServer.Manifest().SchematicID
+		return saveState.SchematicID, nil
 	}
 
-	go func() {
-		var err error
-		m.Logger.Debug("RunClusterBootstrapStepInBackground")
-		m.Logger.Debug("Applying configs...")
+	// talos.config is only set by the PXE server, for machines it has a config for
+	sinkConf := fmt.Sprintf("talos.events.sink=%s:%s", bootstrapInfos.TalosInfo.HTTPHostname, bootstrapInfos.TalosInfo.HTTPPort)
+	kernelArgs := []string{sinkConf, bootstrapInfos.TalosInfo.TalosExtraArgs}
 
-		ConfigBundle, err = talos.ApplyConfigsToNodes(&saveState.MachinesCache, saveState.MachinesDisks, &bootstrapInfos.TalosInfo, ConfigBundle)
-		if err != nil {
-			m.Logger.Errorf("Failed to apply configs: %s", err)
-			return
-		}
-		if ConfigBundle == nil {
-			m.Logger.Errorf("ConfigBundle is nil after applying configs")
-			return
-		}
-		m.Logger.Debug("Configs applied")
+	factory := talos.CreateFactoryClient()
+	sch := schematic.Schematic{
+		Overlay: schematic.Overlay{
+			Image: bootstrapInfos.TalosInfo.TalosOverlayImage,
+			Name:  bootstrapInfos.TalosInfo.TalosOverlayName,
+			// Options: nil, // ==> Extra YAML settings passed to overlay image.
+		},
+		Customization: schematic.Customization{
+			ExtraKernelArgs: kernelArgs,
+		},
+	}
 
-		err = marshal.SaveSplitConfigBundleFiles(ConfigBundle)
-		if err != nil {
-			m.Logger.Errorf("Failed to save split config bundle files: %s", err)
-		}
-		endpoint := ConfigBundle.ControlPlaneCfg.Cluster().Endpoint() //get machineconfig cluster endpoint
-		talosApiClient := talos.CreateMachineryClientFromTalosconfig(ConfigBundle.TalosConfig())
-		m.Logger.Infof("Executing bootstrap with clustername %s and endpoint %s....", bootstrapInfos.TalosInfo.ClusterName, endpoint)
-		err = talos.ExecuteBootstrap(talosApiClient)
-		if err != nil {
-			m.Logger.Errorf("Failed to execute bootstrap: %s", err)
-		}
-		m.Logger.Success("Bootstrap request Succeeded!")
-		m.Logger.Info("Waiting for Kubernetes installation to finish and API to be available...")
+	schematicId, err := factory.SchematicCreate(ctx, sch)
+	if err != nil {
+		return "", err
+	}
+	saveState.SchematicID = schematicId
+	return schematicId, nil
+}
 
-		//RunDetailedClusterHealthCheck(talosApiClient, m.Logger)
-		time.Sleep(10 * time.Second)
-		var logger logging.Logger
-		logger = m.Logger
-		talos.RunBasicClusterHealthCheck(talosApiClient, &logger)
-		m.Logger.Success("Cluster health check succeeded!")
+func RunClusterBootstrapStep(m *tui.Model, s *tui.Step) error {
+	var err error
+	m.Logger.Debug("Applying configs...")
 
-		kubeconfig, err = talosApiClient.Kubeconfig(context.Background())
-		if err != nil {
-			m.Logger.Errorf("Failed to get kubeconfig: %v", err)
-			panic(err)
-		}
+	// Machines that already have a config are skipped, so a failed bootstrap can be run again
+	ConfigBundle, err = talos.ApplyConfigsToNodes(&saveState.MachinesCache, saveState.MachinesDisks, &bootstrapInfos.TalosInfo, bootstrapInfos.GitHubInfo.BaseDomain, ConfigBundle)
+	syncPXEState()
+	if err != nil {
+		return fmt.Errorf("failed to apply configs: %w", err)
+	}
+	if ConfigBundle == nil {
+		return fmt.Errorf("config bundle is nil after applying configs")
+	}
+	m.Logger.Debug("Configs applied")
 
-		err = os.WriteFile("kubeconfig", kubeconfig, 0600)
+	err = marshal.SaveSplitConfigBundleFiles(ConfigBundle)
+	if err != nil {
+		return fmt.Errorf("failed to save split config bundle files: %w", err)
+	}
+	endpoint := ConfigBundle.ControlPlaneCfg.Cluster().Endpoint() //get machineconfig cluster endpoint
+	saveState.ClusterEndpoint = endpoint.String()
+	if err := marshal.MarshalToFile(_bootstrapStateFile, saveState); err != nil {
+		m.Logger.Errorf("Error saving state: %s", err)
+	}
+	talosApiClient := talos.CreateMachineryClientFromTalosconfig(ConfigBundle.TalosConfig())
+	m.Logger.Infof("Executing bootstrap with clustername %s and endpoint %s....", bootstrapInfos.TalosInfo.ClusterName, endpoint)
+	err = talos.ExecuteBootstrap(talosApiClient)
+	if err != nil {
+		return fmt.Errorf("failed to execute bootstrap: %w", err)
+	}
+	m.Logger.Success("Bootstrap request Succeeded!")
+	m.Logger.Info("Waiting for Kubernetes installation to finish and API to be available...")
 
-		if err != nil {
-			m.Logger.Errorf("Failed to write kubeconfig: %v", err)
-			panic(err)
-		}
+	//RunDetailedClusterHealthCheck(talosApiClient, m.Logger)
+	time.Sleep(10 * time.Second)
+	if err := talos.CheckClusterHealth(context.Background(), &talosApiClient, 20*time.Minute, nil); err != nil {
+		return fmt.Errorf("cluster health check failed: %w", err)
+	}
+	m.Logger.Success("Cluster health check succeeded!")
 
-		m.Logger.Successf("Wrote kubeconfig to ./kubeconfig")
-		m.Logger.Success("Your cluster is ready! You may now use kubectl to interact with the cluster")
+	kubeconfig, err = talosApiClient.Kubeconfig(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
 
-		s.IsDone = true
-	}()
+	err = os.WriteFile("kubeconfig", kubeconfig, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+
+	m.Logger.Successf("Wrote kubeconfig to ./kubeconfig")
+	m.Logger.Success("Your cluster is ready! You may now use kubectl to interact with the cluster")
+	s.SetOutput("endpoint", saveState.ClusterEndpoint)
+	s.SetOutput("kubeconfig", "kubeconfig")
 	return nil
 }
 
-func RunArgoStepInBackground(m *tui.Model, s *tui.Step) tea.Cmd {
-	// If restoring progress and kubeconfig doesn't exist, skip
-	if doRestoreProgress && len(kubeconfig) == 0 {
-		_, err := os.Stat("kubeconfig")
-		if errors.Is(err, os.ErrNotExist) {
-			m.Logger.Info("Cluster not ready yet, skipping Argo deployment")
-			return nil
-		}
-		kubeconfig, _ = os.ReadFile("kubeconfig")
+// loadKubeconfig reads the kubeconfig written by the cluster bootstrap when it ran in a previous run
+func loadKubeconfig() error {
+	if len(kubeconfig) > 0 {
+		return nil
 	}
-
-	go func() {
-		m.Logger.Debug("RunArgoStepInBackground")
-		DeployArgoCD(m.Logger)
-		s.IsDone = true
-	}()
+	data, err := os.ReadFile("kubeconfig")
+	if err != nil {
+		return fmt.Errorf("failed to read kubeconfig, is the cluster bootstrapped? %w", err)
+	}
+	kubeconfig = data
 	return nil
 }
 
-func RunPortalStepInBackground(m *tui.Model, s *tui.Step) tea.Cmd {
-	// If restoring progress and kubeconfig doesn't exist, skip
-	if doRestoreProgress && len(kubeconfig) == 0 {
-		_, err := os.Stat("kubeconfig")
-		if errors.Is(err, os.ErrNotExist) {
-			m.Logger.Info("Cluster not ready yet, skipping Portal deployment")
-			return nil
-		}
-		kubeconfig, _ = os.ReadFile("kubeconfig")
+func RunArgoStep(m *tui.Model, s *tui.Step) error {
+	if err := loadKubeconfig(); err != nil {
+		return err
 	}
+	return DeployArgoCD(m.Logger)
+}
 
-	go func() {
-		m.Logger.Debug("RunPortalStepInBackground")
-		CreateBackendSecrets(m.Logger)
+func RunPortalStep(m *tui.Model, s *tui.Step) error {
+	if err := loadKubeconfig(); err != nil {
+		return err
+	}
 
-		k8sClient, err := k8s.NewClientFromKubeconfig(kubeconfig)
-		if err != nil {
-			m.Logger.Errorf("Failed to create k8s client: %v", err)
-			return
-		}
-		k8sDynamicClient, err := k8s.NewDynamicClientFromKubeconfig(kubeconfig)
-		if err != nil {
-			m.Logger.Errorf("Failed to create k8s dynamic client: %v", err)
-			return
-		}
+	if err := CreateBackendSecrets(m.Logger); err != nil {
+		return err
+	}
 
-		k8sClient.CoreV1().Namespaces().Create(context.Background(), &corev1.Namespace{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "atc",
-				Labels: map[string]string{
-					"pod-security.kubernetes.io/enforce": "privileged",
-				},
+	k8sClient, err := k8s.NewClientFromKubeconfig(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	k8sDynamicClient, err := k8s.NewDynamicClientFromKubeconfig(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s dynamic client: %w", err)
+	}
+
+	_, err = k8sClient.CoreV1().Namespaces().Create(context.Background(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "atc",
+			Labels: map[string]string{
+				"pod-security.kubernetes.io/enforce": "privileged",
 			},
-		}, metav1.CreateOptions{})
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace atc: %w", err)
+	}
 
-		cmdr, err := yoke.FromKubeConfig("kubeconfig")
-		if err != nil {
-			m.Logger.Errorf("Failed to load kubeconfig: %v", err)
-		}
+	cmdr, err := yoke.FromKubeConfig("kubeconfig")
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
 
-		// Create docker-registry secret for ATC to pull private flights from GHCR
-		// Using PAT from user input since GitHub App tokens don't work with GHCR
-		if err := k8s.CreateGHCRPullSecret(context.Background(), k8sClient, "atc", "ghcr-pull-secret", bootstrapInfos.GitHubInfo.PackagesPAT); err != nil {
-			m.Logger.Errorf("Failed to create GHCR pull secret: %v", err)
-		} else {
-			m.Logger.Success("Created GHCR pull secret for ATC")
-		}
+	// Create docker-registry secret for ATC to pull private flights from GHCR
+	// Using PAT from user input since GitHub App tokens don't work with GHCR
+	if err := k8s.CreateGHCRPullSecret(context.Background(), k8sClient, "atc", "ghcr-pull-secret", bootstrapInfos.GitHubInfo.PackagesPAT); err != nil {
+		m.Logger.Errorf("Failed to create GHCR pull secret: %v", err)
+	} else {
+		m.Logger.Success("Created GHCR pull secret for ATC")
+	}
 
-		err = cmdr.Takeoff(context.Background(), yoke.TakeoffParams{
-			Namespace: "atc",
-			Flight: yoke.FlightParams{
-				Path: "oci://ghcr.io/yokecd/atc-installer:0.16.0",
-				Args: []string{
-					"--skip-version-check", "true",
-				},
-				Input: strings.NewReader("dockerConfigSecretName: ghcr-pull-secret\n"),
+	err = cmdr.Takeoff(context.Background(), yoke.TakeoffParams{
+		Namespace: "atc",
+		Flight: yoke.FlightParams{
+			Path: flightPath(_atcInstallerFlight),
+			Args: []string{
+				"--skip-version-check", "true",
 			},
-			Release: "atc",
-		})
+			Input: strings.NewReader("dockerConfigSecretName: ghcr-pull-secret\n"),
+		},
+		Release: "atc",
+	})
 
-		if err != nil {
-			m.Logger.Errorf("Failed to deploy yoke: %v", err)
-		}
+	if err != nil {
+		return fmt.Errorf("failed to deploy yoke: %w", err)
+	}
 
-		time.Sleep(30 * time.Second)
+	watcher := newPortalWatcher(m, s, k8sDynamicClient, k8sClient)
+	if err := watcher.wait("ATC", _atcReadyTimeout, atcComponents()); err != nil {
+		return err
+	}
 
-		stolosAirwayUrl := fmt.Sprintf("https://raw.githubusercontent.com/stolos-cloud/stolos/refs/tags/%s/stolos-yoke/airway.yml", bootstrapInfos.TalosInfo.StolosAirwayTag)
-		resp, err := http.Get(stolosAirwayUrl)
-		if err != nil {
-			m.Logger.Errorf("Failed to download stolos airway url: %v", err)
-			return
-		}
+	stolosAirwayUnstructured, err := LoadStolosAirway()
+	if err != nil {
+		return fmt.Errorf("failed to load stolos airway: %w", err)
+	}
 
-		stolosAirwayYaml, err := io.ReadAll(resp.Body)
-		if err != nil {
-			m.Logger.Errorf("Failed to get stolos airway from response: %v", err)
-			return
-		}
+	_, err = k8sDynamicClient.Resource(schema.GroupVersionResource{
+		Group:    "yoke.cd",
+		Version:  "v1alpha1",
+		Resource: "airways",
+	}).Create(context.Background(), stolosAirwayUnstructured, metav1.CreateOptions{})
 
-		stolosAirwayUnstructured := &unstructured.Unstructured{}
-		dec := yaml.NewYAMLOrJSONDecoder(
-			bytes.NewReader(stolosAirwayYaml),
-			1024,
-		)
-		if err := dec.Decode(stolosAirwayUnstructured); err != nil {
-			m.Logger.Errorf("Failed to decode stolos airway yaml: %v", err)
-			return
-		}
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create stolos airway: %w", err)
+	}
+	if k8serrors.IsAlreadyExists(err) {
+		m.Logger.Info("Stolos airway already exists, skipping creation")
+	}
 
-		_, err = k8sDynamicClient.Resource(schema.GroupVersionResource{
-			Group:    "yoke.cd",
-			Version:  "v1alpha1",
-			Resource: "airways",
-		}).Create(context.Background(), stolosAirwayUnstructured, metav1.CreateOptions{})
+	// The airway controller creates the StolosPlatform CRD
+	if err := watcher.wait("Stolos airway", _airwayReadyTimeout, airwayComponents(stolosAirwayUnstructured.GetName())); err != nil {
+		return err
+	}
 
-		if err != nil && !k8serrors.IsAlreadyExists(err) {
-			m.Logger.Errorf("Failed to create stolos airway: %v", err)
-			return
-		}
-		if k8serrors.IsAlreadyExists(err) {
-			m.Logger.Info("Stolos airway already exists, skipping creation")
-		}
+	stolosCR := stolosPlatformInput()
 
-		// Wait for the StolosPlatform CRD to be created by the airway controller
-		m.Logger.Info("Waiting for StolosPlatform CRD to be ready...")
-		stolosPlatformGVR := schema.GroupVersionResource{
-			Group:    "stolos.cloud",
-			Version:  "v1alpha",
-			Resource: "stolosplatforms",
-		}
-		maxRetries := 30
-		for i := 0; i < maxRetries; i++ {
-			_, err := k8sDynamicClient.Resource(stolosPlatformGVR).List(context.Background(), metav1.ListOptions{Limit: 1})
-			if err == nil {
-				m.Logger.Success("StolosPlatform CRD is ready")
-				break
-			}
-			if i == maxRetries-1 {
-				m.Logger.Errorf("Timed out waiting for StolosPlatform CRD to be ready: %v", err)
-				return
-			}
-			time.Sleep(2 * time.Second)
-		}
+	mapStolosCR, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&stolosCR)
+	if err != nil {
+		return fmt.Errorf("failed to convert stolos CR to unstructured: %w", err)
+	}
+	unstructuredStolosCR := unstructured.Unstructured{Object: mapStolosCR}
+
+	githubClient, err := github.NewClientFromApp(
+		context.Background(),
+		gitHubAppManifest.ID,
+		gitHubAppManifest.PEM,
+		saveState.GitHubAppInstallResult.InstallationID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client from app: %w", err)
+	}
+
+	err = githubClient.CreateInitialConfig(&unstructuredStolosCR, &bootstrapInfos.GitHubInfo)
+	if err != nil {
+		return fmt.Errorf("failed to create stolos config on github: %w", err)
+	}
+	m.Logger.Success("Created initial GitOps configuration on GitHub")
+
+	k8sClientDyn, err := k8s.NewDynamicClientFromKubeconfig(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	m.Logger.Info("Creating StolosPlatform CR...")
+	_, err = k8sClientDyn.Resource(stolosPlatformGVR).Create(context.Background(), &unstructuredStolosCR, metav1.CreateOptions{})
+
+	if k8serrors.IsAlreadyExists(err) {
+		m.Logger.Info("StolosPlatform CR already exists, skipping creation")
+	} else if err != nil {
+		return fmt.Errorf("failed to create stolos platforms: %w", err)
+	} else {
+		m.Logger.Success("StolosPlatform CR created successfully")
+	}
+
+	if err := watcher.wait("Stolos platform", _portalReadyTimeout, platformComponents(stolosCR.Name, stolosCR.Spec)); err != nil {
+		return err
+	}
+	if err := waitForPortalHealth(m, stolosCR.Spec, bootstrapInfos.GitHubInfo.LoadBalancerIP); err != nil {
+		return err
+	}
+	printPortalAccess(m, stolosCR.Spec)
 
-		stolosCR := types.Stolos{
-			TypeMeta: metav1.TypeMeta{
-				Kind:       "StolosPlatform",
-				APIVersion: "stolos.cloud/v1alpha",
+	m.Logger.Info("Bootstrap complete!")
+	return nil
+}
+
+// stolosPlatformInput is the StolosPlatform CR created by the bootstrap, the input of the platform flight
+func stolosPlatformInput() types.Stolos {
+	return types.Stolos{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "StolosPlatform",
+			APIVersion: "stolos.cloud/v1alpha",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "stolos-platform",
+		},
+		Spec: types.StolosSpec{
+			ClusterName: bootstrapInfos.TalosInfo.ClusterName,
+			BaseDomain:  bootstrapInfos.GitHubInfo.BaseDomain,
+			LocalPathProvisioner: types.LocalPathProvisioner{
+				Deploy:    bootstrapInfos.TalosInfo.DeployLocalPathStorage == "true",
+				Namespace: "local-path-storage",
+				Version:   "v0.0.32",
 			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "stolos-platform",
+			MetalLB: types.MetalLB{
+				Deploy:       true,
+				Namespace:    "metallb-system",
+				Version:      "v0.15.2",
+				ArpIp:        bootstrapInfos.GitHubInfo.LoadBalancerIP,
+				ConfigureArp: true,
+			},
+			ArgoCD: types.ArgoCD{
+				Deploy:              true,
+				Namespace:           "argocd",
+				Version:             "7.8.26",
+				Subdomain:           "argocd",
+				ImageUpdaterVersion: "v0.13.1",
+				RepositoryOwner:     bootstrapInfos.GitHubInfo.RepoOwner,
+				RepositoryName:      bootstrapInfos.GitHubInfo.RepoName,
+				RepositoryRevision:  "main",
 			},
-			Spec: types.StolosSpec{
-				ClusterName: bootstrapInfos.TalosInfo.ClusterName,
-				BaseDomain:  bootstrapInfos.GitHubInfo.BaseDomain,
-				LocalPathProvisioner: types.LocalPathProvisioner{
-					Deploy:    bootstrapInfos.TalosInfo.DeployLocalPathStorage == "true",
-					Namespace: "local-path-storage",
-					Version:   "v0.0.32",
+			Contour: types.Contour{
+				Deploy:    true,
+				Namespace: "projectcontour",
+				Version:   "release-1.33",
+			},
+			Ingress: types.Ingress{
+				Provider: "contour",
+				Gateway: types.Gateway{
+					Implementation:      "envoy",
+					Name:                "stolos",
+					Namespace:           "stolos-gateway",
+					EnvoyGatewayVersion: "v1.5.0",
 				},
-				MetalLB: types.MetalLB{
-					Deploy:       true,
-					Namespace:    "metallb-system",
-					Version:      "v0.15.2",
-					ArpIp:        bootstrapInfos.GitHubInfo.LoadBalancerIP,
-					ConfigureArp: true,
+			},
+			CertManager: types.CertManager{
+				Deploy:               true,
+				Namespace:            "cert-manager",
+				Version:              "v1.18.2",
+				ClusterIssuerProd:    "letsencrypt-prod",
+				ClusterIssuerStaging: "letsencrypt-staging",
+				DefaultClusterIssuer: "letsencrypt-staging",
+				Email:                "stolos@stolos.cloud",
+				SelfSigned:           true,
+				DNS01: types.DNS01{
+					Cloudflare: types.Cloudflare{
+						APITokenSecret: "cloudflare-api-token",
+						APITokenKey:    "api-token",
+					},
+					RFC2136: types.RFC2136{
+						TSIGAlgorithm: "HMACSHA256",
+						TSIGSecret:    "rfc2136-tsig",
+						TSIGSecretKey: "tsig-secret",
+					},
 				},
-				ArgoCD: types.ArgoCD{
-					Deploy:              true,
-					Namespace:           "argocd",
-					Version:             "7.8.26",
-					Subdomain:           "argocd",
-					ImageUpdaterVersion: "v0.13.1",
-					RepositoryOwner:     bootstrapInfos.GitHubInfo.RepoOwner,
-					RepositoryName:      bootstrapInfos.GitHubInfo.RepoName,
-					RepositoryRevision:  "main",
+			},
+			ExternalDNS: types.ExternalDNS{
+				Deploy:    false,
+				Namespace: "external-dns",
+				Version:   "1.19.0",
+				Policy:    "upsert-only",
+			},
+			CNPG: types.CNPG{
+				Deploy:        true,
+				Namespace:     "cnpg-system",
+				Version:       "0.26.0",
+				BarmanVersion: "v0.6.0",
+			},
+			StolosPlatform: types.StolosPlatform{
+				Deploy:               true,
+				Namespace:            "stolos-system",
+				BackendSubdomain:     "api",
+				DefaultAdminEmail:    "admin@stolos.cloud",
+				DefaultAdminPassword: "Password1!",
+				FrontendSubdomain:    "k8s",
+				Database: types.CnpgDbConfig{
+					InstanceCount:   1,
+					SizeInGigabytes: 2,
+					Image:           _stolosDatabaseImage,
+					Backup: types.CnpgBackup{
+						Path:              "stolos",
+						CredentialsSecret: "stolos-database-backup",
+						Schedule:          "0 0 2 * * *",
+						Retention:         "30d",
+					},
+					Restore: types.CnpgRestore{
+						ServerName: "postgresql-stolos",
+					},
 				},
-				Contour: types.Contour{
-					Deploy:    true,
-					Namespace: "projectcontour",
-					Version:   "release-1.33",
+				PathToYaml:     "stolos/stolos-platform.yaml",
+				UpgradeChannel: "release",
+				Backend:        types.StolosComponent{Replicas: 2},
+				Frontend:       types.StolosComponent{Replicas: 2},
+			},
+			Monitoring: types.Monitoring{
+				Deploy:           true,
+				Namespace:        "monitoring",
+				GrafanaSubdomain: "grafana",
+				Components: types.MonitoringComponents{
+					Prometheus: true,
+					Mimir:      true,
+					Loki:       true,
+					Tempo:      true,
+					Grafana:    true,
+					Alloy:      true,
+					Pyroscope:  true,
+					Obi:        true,
 				},
-				CertManager: types.CertManager{
-					Deploy:               true,
-					Namespace:            "cert-manager",
-					Version:              "v1.18.2",
-					ClusterIssuerProd:    "letsencrypt-prod",
-					ClusterIssuerStaging: "letsencrypt-staging",
-					DefaultClusterIssuer: "letsencrypt-staging",
-					Email:                "stolos@stolos.cloud",
-					SelfSigned:           true,
+				Retention: types.MonitoringRetention{
+					Metrics: "15d",
+					Logs:    "24h",
+					Traces:  "24h",
 				},
-				CNPG: types.CNPG{
-					Deploy:        true,
-					Namespace:     "cnpg-system",
-					Version:       "0.26.0",
-					BarmanVersion: "v0.6.0",
-				},
-				StolosPlatform: types.StolosPlatform{
-					Deploy:               true,
-					Namespace:            "stolos-system",
-					BackendSubdomain:     "api",
-					DefaultAdminEmail:    "admin@stolos.cloud",
-					DefaultAdminPassword: "Password1!",
-					FrontendSubdomain:    "k8s",
-					Database: types.CnpgDbConfig{
-						InstanceCount:   1,
-						SizeInGigabytes: 2,
-						Image:           "ghcr.io/cloudnative-pg/postgresql:17.6",
-					},
-					PathToYaml: "stolos/stolos-platform.yaml",
+				Storage: types.MonitoringStorage{
+					Prometheus: "10Gi",
+					Mimir:      "20Gi",
+					Loki:       "10Gi",
+					Tempo:      "10Gi",
+					Grafana:    "2Gi",
+					Pyroscope:  "5Gi",
 				},
 			},
-		}
-
-		mapStolosCR, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&stolosCR)
-		if err != nil {
-			m.Logger.Errorf("Failed to convert stolos CR to unstructured: %v", err)
-		}
-		unstructuredStolosCR := unstructured.Unstructured{Object: mapStolosCR}
-
-		githubClient, err := github.NewClientFromApp(
-			context.Background(),
-			gitHubAppManifest.ID,
-			gitHubAppManifest.PEM,
-			saveState.GitHubAppInstallResult.InstallationID,
-		)
-		if err != nil {
-			m.Logger.Errorf("Failed to create GitHub client from app: %v", err)
-		}
-
-		err = githubClient.CreateInitialConfig(&unstructuredStolosCR, &bootstrapInfos.GitHubInfo)
-		if err != nil {
-			m.Logger.Errorf("Failed to create stolos config on github: %v", err)
-			return
-		}
-		m.Logger.Success("Created initial GitOps configuration on GitHub")
-
-		k8sClientDyn, err := k8s.NewDynamicClientFromKubeconfig(kubeconfig)
-		if err != nil {
-			m.Logger.Errorf("Failed to create Kubernetes client: %s", err)
-			return
-		}
+		},
+	}
+}
 
-		m.Logger.Info("Creating StolosPlatform CR...")
-		_, err = k8sClientDyn.Resource(schema.GroupVersionResource{
-			Group:    "stolos.cloud",
-			Version:  "v1alpha",
-			Resource: "stolosplatforms",
-		}).Create(context.Background(), &unstructuredStolosCR, metav1.CreateOptions{})
+// LoadStolosAirway downloads the Stolos airway of the release, or reads it from the air-gap bundle
+func LoadStolosAirway() (*unstructured.Unstructured, error) {
+	if airgapServer != nil {
+		return readBundledAirway()
+	}
 
-		if err != nil {
-			m.Logger.Errorf("Failed to create stolos platforms: %v", err)
-			return
-		}
-		m.Logger.Success("StolosPlatform CR created successfully")
+	resp, err := http.Get(stolosAirwayURL(bootstrapInfos.TalosInfo.StolosAirwayTag))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
 
-		m.Logger.Info("Bootstrap complete!")
+	stolosAirwayYaml, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
 
-		s.IsDone = true
-	}()
-	return nil
+	stolosAirwayUnstructured := &unstructured.Unstructured{}
+	dec := yaml.NewYAMLOrJSONDecoder(
+		bytes.NewReader(stolosAirwayYaml),
+		1024,
+	)
+	if err := dec.Decode(stolosAirwayUnstructured); err != nil {
+		return nil, fmt.Errorf("failed to decode yaml: %w", err)
+	}
+	return stolosAirwayUnstructured, nil
 }
 
-func DeployArgoCD(loggerRef *tui.UILogger) {
+func DeployArgoCD(loggerRef *tui.UILogger) error {
 	loggerRef.Info("Setting up helm...")
 	logger := logging.Logger(loggerRef)
 	helmClient, err := helm.SetupHelmClient(&logger, kubeconfig)
 	if err != nil {
-		loggerRef.Errorf("Failed to setup helm client: %s", err)
-		return
+		return fmt.Errorf("failed to setup helm client: %w", err)
 	}
 
 	loggerRef.Infof("Deploying ArgoCD...")
 
-	//manifests.ArgoValuesYaml
-
-	tmpDir, err := os.MkdirTemp("", "helm-values-*")
-	if err != nil {
-		panic(err)
-	}
-	defer os.RemoveAll(tmpDir)
-
-	release, err := helm.HelmInstallArgo(helmClient, "argocd", "argocd", []string{})
+	release, err := helm.HelmInstallArgo(helmClient, "argocd", "argocd", []string{}, argoChartPath())
 	if err != nil {
-		loggerRef.Errorf("Failed to deploy ArgoCD: %s", err)
-		return
+		return fmt.Errorf("failed to deploy ArgoCD: %w", err)
 	}
 	loggerRef.Successf("Successfully Installed release %s in namespace %s ; Notes:%s\n", release.Name, release.Namespace, release.Info.Notes)
+	return nil
 }
 
-func CreateBackendSecrets(loggerRef *tui.UILogger) {
+func CreateBackendSecrets(loggerRef *tui.UILogger) error {
 	// Apply backend secrets including providers configuration.
 	k8sClient, err := k8s.NewClientFromKubeconfig(kubeconfig)
 	if err != nil {
-		loggerRef.Errorf("Failed to create Kubernetes client: %s", err)
-	} else {
-		ctx := context.Background()
+		return fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	ctx := context.Background()
 
-		// Create namespace if it does not exists
-		_, err = k8sClient.CoreV1().Namespaces().Get(ctx, "stolos-system", metav1.GetOptions{})
-		if err != nil {
-			_, err = k8sClient.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "stolos-system",
-				},
-			}, metav1.CreateOptions{})
-			if err != nil {
-				loggerRef.Errorf("Failed to create namespace stolos-system: %s", err)
-				return
-			}
+	// Create namespace if it does not exists
+	_, err = k8sClient.CoreV1().Namespaces().Get(ctx, "stolos-system", metav1.GetOptions{})
+	if err != nil {
+		_, err = k8sClient.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "stolos-system",
+			},
+		}, metav1.CreateOptions{})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create namespace stolos-system: %w", err)
 		}
+	}
 
-		// Create platform talos/k8s secret
-		loggerRef.Info("Creating platform talos/k8s secret...")
-		talosSecretData, err := platform_talos.NewBootstrapSecret(saveState.MachinesCache)
-		if err != nil {
-			loggerRef.Errorf("Failed to read data for platform talos configuration secret: %s", err)
-		} else {
-			err = talosSecretData.CreateOrUpdateSecret(ctx, k8sClient, "stolos-system", "stolos-talos-config")
-			if err != nil {
-				loggerRef.Errorf("Failed to create platform config secret: %s", err)
-			} else {
-				loggerRef.Success("Platform configuration secret created successfully")
-			}
-		}
+	// Create platform talos/k8s secret
+	loggerRef.Info("Creating platform talos/k8s secret...")
+	talosSecretData, err := platform_talos.NewBootstrapSecret(saveState.MachinesCache)
+	if err != nil {
+		return fmt.Errorf("failed to read data for platform talos configuration secret: %w", err)
+	}
+	err = talosSecretData.CreateOrUpdateSecret(ctx, k8sClient, _talosSecretNamespace, _talosSecretName)
+	if err != nil {
+		return fmt.Errorf("failed to create platform talos secret: %w", err)
+	}
+	loggerRef.Success("Platform talos secret created successfully")
+
+	// Create platform configuration secret
+	loggerRef.Info("Creating platform configuration secret...")
+	platformConfig := platform.NewPlatformConfig(bootstrapInfos.TalosInfo.ClusterName, bootstrapInfos.GitHubInfo.BaseDomain)
+	err = platformConfig.CreateOrUpdateSecret(ctx, k8sClient, "stolos-system", "stolos-system-config")
+	if err != nil {
+		return fmt.Errorf("failed to create platform config secret: %w", err)
+	}
+	loggerRef.Success("Platform configuration secret created successfully")
 
-		// Create platform configuration secret
-		loggerRef.Info("Creating platform configuration secret...")
-		platformConfig := platform.NewPlatformConfig(bootstrapInfos.TalosInfo.ClusterName, bootstrapInfos.GitHubInfo.BaseDomain)
-		err = platformConfig.CreateOrUpdateSecret(ctx, k8sClient, "stolos-system", "stolos-system-config")
+	// Create GCP service account secret
+	if gcpConfig != nil {
+		loggerRef.Info("Creating GCP service account secret...")
+		err = gcpConfig.CreateOrUpdateSecret(ctx, k8sClient, "stolos-system", "stolos-system-config")
 		if err != nil {
-			loggerRef.Errorf("Failed to create platform config secret: %s", err)
-		} else {
-			loggerRef.Success("Platform configuration secret created successfully")
+			return fmt.Errorf("failed to create GCP secret: %w", err)
 		}
+		loggerRef.Success("GCP service account secret created successfully")
+	}
 
-		// Create GCP service account secret
-		if gcpConfig != nil {
-			loggerRef.Info("Creating GCP service account secret...")
-			err = gcpConfig.CreateOrUpdateSecret(ctx, k8sClient, "stolos-system", "stolos-system-config")
-			if err != nil {
-				loggerRef.Errorf("Failed to create GCP secret: %s", err)
-			} else {
-				loggerRef.Success("GCP service account secret created successfully")
-			}
+	// Create GitHub credentials secret
+	if githubConfig != nil {
+		loggerRef.Info("Creating GitHub credentials secret...")
+		err = githubConfig.CreateOrUpdateSecret(ctx, k8sClient, "stolos-system", "stolos-system-config")
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub secret: %w", err)
 		}
-
-		// Create GitHub credentials secret
-		if githubConfig != nil {
-			loggerRef.Info("Creating GitHub credentials secret...")
-			err = githubConfig.CreateOrUpdateSecret(ctx, k8sClient, "stolos-system", "stolos-system-config")
-			if err != nil {
-				loggerRef.Errorf("Failed to create GitHub secret: %s", err)
-			} else {
-				loggerRef.Success("GitHub credentials secret created successfully")
-			}
-			repoUrl := "https://github.com/" + bootstrapInfos.GitHubInfo.RepoOwner + "/" + bootstrapInfos.GitHubInfo.RepoName
-			err = github.CreateOrUpdateArgoCDGitHubSecrets(ctx, k8sClient, "argocd", "stolos-github-repo", strconv.FormatInt(gitHubAppManifest.ID, 10), gitHubAppManifest.PEM, repoUrl, saveState.GitHubAppInstallResult.InstallationID)
-			if err != nil {
-				loggerRef.Errorf("Failed to create GitHub Argo Repo secret: %s", err)
-			} else {
-				loggerRef.Success("GitHub Repo credentials secret created successfully")
-			}
+		loggerRef.Success("GitHub credentials secret created successfully")
+		repoUrl := "https://github.com/" + bootstrapInfos.GitHubInfo.RepoOwner + "/" + bootstrapInfos.GitHubInfo.RepoName
+		err = github.CreateOrUpdateArgoCDGitHubSecrets(ctx, k8sClient, "argocd", "stolos-github-repo", strconv.FormatInt(gitHubAppManifest.ID, 10), gitHubAppManifest.PEM, repoUrl, saveState.GitHubAppInstallResult.InstallationID)
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub Argo Repo secret: %w", err)
 		}
+		loggerRef.Success("GitHub Repo credentials secret created successfully")
 	}
+	return nil
 }
 
 // Utils
@@ -0,0 +1,182 @@
+// portal.go
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/k8s"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	_atcNamespace        = "atc"
+	_atcReadyTimeout     = 5 * time.Minute
+	_airwayReadyTimeout  = 5 * time.Minute
+	_portalReadyTimeout  = 20 * time.Minute
+	_portalHealthTimeout = 10 * time.Minute
+)
+
+// portalWatcher reports the readiness of the portal components in the step body and the logs
+type portalWatcher struct {
+	m         *tui.Model
+	s         *tui.Step
+	dynClient dynamic.Interface
+	client    kubernetes.Interface
+
+	mu     sync.Mutex
+	status map[string]string // component title : status line
+	order  []string
+}
+
+func newPortalWatcher(m *tui.Model, s *tui.Step, dynClient dynamic.Interface, client kubernetes.Interface) *portalWatcher {
+	return &portalWatcher{m: m, s: s, dynClient: dynClient, client: client, status: make(map[string]string)}
+}
+
+// wait waits for the components of a stage, the error lists what is not ready on timeout
+func (w *portalWatcher) wait(stage string, timeout time.Duration, components []k8s.Component) error {
+	w.m.Logger.Infof("Waiting for %s...", stage)
+	_, err := k8s.WaitForComponents(context.Background(), w.dynClient, w.client, components, k8s.WaitOptions{
+		Timeout:  timeout,
+		OnChange: w.report,
+	})
+	if err != nil {
+		return fmt.Errorf("%s is not ready: %w", stage, err)
+	}
+	w.m.Logger.Successf("%s is ready", stage)
+	return nil
+}
+
+func (w *portalWatcher) report(status k8s.ComponentStatus) {
+	title := status.Component.Title
+	switch {
+	case status.Ready:
+		w.m.Logger.Successf("%s: %s", title, status.Status)
+	default:
+		w.m.Logger.Infof("%s: %s", title, status.Status)
+	}
+	for _, event := range status.Events {
+		w.m.Logger.Warnf("%s: %s", title, event)
+	}
+
+	mark := "…"
+	if status.Ready {
+		mark = "✓"
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.status[title]; !ok {
+		w.order = append(w.order, title)
+	}
+	w.status[title] = fmt.Sprintf("%s %s: %s", mark, title, status.Status)
+	lines := make([]string, 0, len(w.order))
+	for _, t := range w.order {
+		lines = append(lines, w.status[t])
+	}
+	w.s.Body = "Deploying portal\n" + strings.Join(lines, "\n")
+}
+
+// atcComponents are the air traffic controller deployed by the atc-installer flight
+func atcComponents() []k8s.Component {
+	return []k8s.Component{
+		{Title: "Airway CRD", GVR: k8s.CRDsGVR, Name: "airways.yoke.cd", Check: k8s.ConditionReady("Established")},
+		{Title: "ATC", GVR: k8s.DeploymentsGVR, Namespace: _atcNamespace, Check: k8s.DeploymentReady},
+	}
+}
+
+// airwayComponents are the Stolos airway and the StolosPlatform CRD it creates
+func airwayComponents(airwayName string) []k8s.Component {
+	return []k8s.Component{
+		{Title: "Stolos airway", GVR: k8s.AirwaysGVR, Name: airwayName, Check: k8s.ConditionReady("Ready")},
+		{Title: "StolosPlatform CRD", GVR: k8s.CRDsGVR, Name: "stolosplatforms.stolos.cloud", Check: k8s.ConditionReady("Established")},
+	}
+}
+
+// platformComponents are the components the StolosPlatform flight deploys
+func platformComponents(name string, spec types.StolosSpec) []k8s.Component {
+	components := []k8s.Component{
+		{Title: "StolosPlatform", GVR: stolosPlatformGVR, Name: name, Check: k8s.ConditionReady("Ready")},
+	}
+	if spec.ArgoCD.Deploy {
+		components = append(components, k8s.Component{
+			Title: "ArgoCD applications", GVR: k8s.ApplicationsGVR, Namespace: spec.ArgoCD.Namespace, Check: k8s.ApplicationReady,
+		})
+	}
+	if spec.CertManager.Deploy {
+		for _, issuer := range []string{spec.CertManager.ClusterIssuerStaging, spec.CertManager.ClusterIssuerProd} {
+			components = append(components, k8s.Component{
+				Title: "ClusterIssuer " + issuer, GVR: k8s.ClusterIssuersGVR, Name: issuer, Check: k8s.ConditionReady("Ready"),
+			})
+		}
+	}
+	if spec.StolosPlatform.Deploy {
+		namespace := spec.StolosPlatform.Namespace
+		components = append(components,
+			k8s.Component{Title: "Database", GVR: k8s.CNPGClustersGVR, Namespace: namespace, Name: "postgresql-stolos", Check: k8s.CNPGClusterReady},
+			k8s.Component{Title: "Backend", GVR: k8s.DeploymentsGVR, Namespace: namespace, Name: "stolos-backend", Check: k8s.DeploymentReady},
+			k8s.Component{Title: "Frontend", GVR: k8s.DeploymentsGVR, Namespace: namespace, Name: "stolos-frontend", Check: k8s.DeploymentReady},
+			// ACME certificates need the domain to be reachable from the internet, they may be issued later
+			k8s.Component{Title: "Certificates", GVR: k8s.CertificatesGVR, Namespace: namespace, Check: k8s.ConditionReady("Ready"), Optional: true},
+		)
+	}
+	return components
+}
+
+// waitForPortalHealth waits for the backend health endpoint through the load balancer and the portal host, which
+// routes /api/v1 to the backend. The request is sent to the load balancer IP so it does not depend on DNS,
+// the certificate is not verified as it may not be issued yet.
+func waitForPortalHealth(m *tui.Model, spec types.StolosSpec, loadBalancerIP string) error {
+	host := spec.StolosPlatform.FrontendSubdomain + "." + spec.BaseDomain
+	healthURL := "https://" + host + "/api/v1/health"
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true, ServerName: host},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if loadBalancerIP != "" {
+				_, port, _ := net.SplitHostPort(addr)
+				addr = net.JoinHostPort(loadBalancerIP, port)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	m.Logger.Infof("Waiting for %s to respond...", healthURL)
+	deadline := time.Now().Add(_portalHealthTimeout)
+	lastErr := ""
+	for time.Now().Before(deadline) {
+		resp, err := client.Get(healthURL)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				m.Logger.Success("Backend is healthy")
+				return nil
+			}
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		if err.Error() != lastErr {
+			lastErr = err.Error()
+			m.Logger.Infof("Backend health: %s", lastErr)
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return fmt.Errorf("backend %s did not respond within %s, last error: %s\n    check that the load balancer IP %s is reachable and inspect: kubectl --kubeconfig kubeconfig -n %s logs deployment/stolos-backend",
+		healthURL, _portalHealthTimeout, lastErr, loadBalancerIP, spec.StolosPlatform.Namespace)
+}
+
+// printPortalAccess logs the portal URL and the admin credentials
+func printPortalAccess(m *tui.Model, spec types.StolosSpec) {
+	m.Logger.Successf("Portal ready: https://%s.%s", spec.StolosPlatform.FrontendSubdomain, spec.BaseDomain)
+	m.Logger.Successf("API: https://%s.%s/api/v1", spec.StolosPlatform.FrontendSubdomain, spec.BaseDomain)
+	m.Logger.Successf("Admin login: %s / %s (change the password after the first login)", spec.StolosPlatform.DefaultAdminEmail, spec.StolosPlatform.DefaultAdminPassword)
+}
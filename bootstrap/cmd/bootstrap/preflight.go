@@ -0,0 +1,106 @@
+// preflight.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+)
+
+// preflightMachineTimeout bounds the queries of a machine, measuring its clock skew waits for it to refresh its stats
+const preflightMachineTimeout = time.Minute
+
+// RunPreflightStep queries every machine about to be configured over the maintenance API and checks it.
+// The control plane count and endpoint are checked on the whole cluster, machines already configured included.
+// Errors fail the step unless PreflightMode is "warn", warnings are only reported.
+func RunPreflightStep(m *tui.Model, s *tui.Step) error {
+	pending := PendingMachines()
+	if len(pending) == 0 {
+		m.Logger.Info("No machine left to configure, skipping pre-flight checks")
+		s.AutoAdvance = true
+		return nil
+	}
+
+	m.Logger.Infof("Running pre-flight checks on %d machines...", len(pending))
+	report := runPreflightChecks(pending, len(saveState.MachinesCache.ControlPlanes))
+
+	body := &strings.Builder{}
+	report.Render(body)
+	s.Body = body.String()
+
+	for _, f := range report.Findings {
+		machine := f.Machine
+		if machine == "" {
+			machine = "cluster"
+		}
+		if f.Severity == talos.SeverityError {
+			m.Logger.Errorf("Pre-flight error [%s] %s: %s", machine, f.Check, f.Message)
+		} else {
+			m.Logger.Warnf("Pre-flight warning [%s] %s: %s", machine, f.Check, f.Message)
+		}
+	}
+
+	if report.HasErrors() {
+		if bootstrapInfos.TalosInfo.PreflightMode != "warn" {
+			return fmt.Errorf("pre-flight checks failed, fix the machines and retry, or set PreflightMode to warn")
+		}
+		m.Logger.Warn("Pre-flight checks failed, continuing as PreflightMode is warn")
+	} else {
+		m.Logger.Success("Pre-flight checks passed")
+	}
+	return nil
+}
+
+// runPreflightChecks queries the machines, by IP with their role, and checks them with the cluster-wide rules.
+// controlPlanes is the control plane count of the cluster once the machines are configured.
+func runPreflightChecks(machines map[string]string, controlPlanes int) *talos.PreflightReport {
+	inventories := make([]*talos.MachineInventory, len(machines))
+	var wg sync.WaitGroup
+	i := 0
+	for ip, role := range machines {
+		wg.Add(1)
+		go func(i int, ip, role string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), preflightMachineTimeout)
+			defer cancel()
+			inventories[i] = talos.GetMachineInventory(ctx, ip, role, saveState.MachinesDisks[ip])
+		}(i, ip, role)
+		i++
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	endpoint := talos.ProbeControlPlaneEndpoint(ctx, &bootstrapInfos.TalosInfo)
+	cancel()
+	wg.Wait()
+
+	minDiskSize, _ := strconv.ParseUint(bootstrapInfos.TalosInfo.PreflightMinDiskSizeGB, 10, 64)
+	return talos.RunPreflightChecks(inventories, talos.PreflightRules{
+		TalosVersion:  bootstrapInfos.TalosInfo.TalosVersion,
+		Arch:          bootstrapInfos.TalosInfo.TalosArchitecture,
+		MinDiskSizeGB: minDiskSize,
+		ControlPlanes: controlPlanes,
+		Endpoint:      endpoint,
+		Bootstrapped:  saveState.ClusterEndpoint != "",
+	})
+}
+
+// PendingMachines returns the role of every machine whose config was not applied yet, by IP
+func PendingMachines() map[string]string {
+	pending := make(map[string]string)
+	for ip, conf := range saveState.MachinesCache.ControlPlanes {
+		if len(conf) == 0 {
+			pending[ip] = talos.RoleControlPlane
+		}
+	}
+	for ip, conf := range saveState.MachinesCache.Workers {
+		if len(conf) == 0 {
+			pending[ip] = talos.RoleWorker
+		}
+	}
+	return pending
+}
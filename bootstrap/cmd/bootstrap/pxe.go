@@ -0,0 +1,179 @@
+// pxe.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/pxe"
+)
+
+const _pxeAssetsDir = "pxe"
+
+var pxeServer *pxe.Server
+
+// pxeState is the part of saveState the PXE server goroutines use. They never touch saveState,
+// syncPXEState copies it over from the bootstrap steps.
+var pxeState struct {
+	sync.Mutex
+	macs     map[string]string // IP : MAC of netbooted and declared machines
+	configs  map[string][]byte // IP : machine config
+	recorded map[string]string // IP : MAC recorded by the PXE server since the last sync
+}
+
+// RunPXEServerStep downloads the netboot assets of the schematic and starts the PXE server.
+// It keeps running for the rest of the bootstrap so machines can netboot at any time.
+func RunPXEServerStep(m *tui.Model, s *tui.Step) error {
+	if bootstrapInfos.TalosInfo.PXEEnabled != "true" || pxeServer != nil || gcpControlPlanes() > 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	serverIP, err := resolveIPv4(bootstrapInfos.TalosInfo.HTTPHostname)
+	if err != nil {
+		return fmt.Errorf("failed to resolve PXE server address %s: %w", bootstrapInfos.TalosInfo.HTTPHostname, err)
+	}
+
+	schematicId := saveState.SchematicID
+	if schematicId == "" {
+		schematicId, err = CreateTalosSchematic(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create schematic: %w", err)
+		}
+	}
+
+	m.Logger.Infof("Downloading netboot assets of schematic %s...", schematicId)
+	assets, err := pxe.DownloadAssets(ctx, _pxeAssetsDir, talosFactoryURL(), ipxeBinariesURL(), schematicId,
+		bootstrapInfos.TalosInfo.TalosVersion, bootstrapInfos.TalosInfo.TalosArchitecture)
+	if err != nil {
+		return fmt.Errorf("failed to download netboot assets: %w", err)
+	}
+
+	syncPXEState()
+	server, err := pxe.NewServer(pxe.Config{
+		ServerIP:     serverIP,
+		HTTPPort:     bootstrapInfos.TalosInfo.PXEPort,
+		Interface:    interfaceOf(serverIP),
+		Assets:       assets,
+		LookupConfig: LookupMachineConfigByMAC,
+		OnBoot:       RecordNetbootedMachine,
+	}, m.Logger)
+	if err != nil {
+		return fmt.Errorf("invalid PXE configuration: %w", err)
+	}
+	if err := server.Start(); err != nil {
+		return fmt.Errorf("failed to start PXE server (DHCP and TFTP need root): %w", err)
+	}
+	pxeServer = server
+
+	m.Logger.Success("PXE server ready, servers can now netboot into Talos")
+	return nil
+}
+
+// LookupMachineConfigByMAC returns the rendered machine config of a netbooted machine, if it booted with its IP.
+// Configs are rendered by ApplyConfigsToNodes: machines boot in maintenance mode until then,
+// after that reinstalled machines get theirs.
+func LookupMachineConfigByMAC(mac, ip string) ([]byte, bool) {
+	pxeState.Lock()
+	defer pxeState.Unlock()
+
+	known, ok := pxeState.recorded[ip]
+	if !ok {
+		known = pxeState.macs[ip]
+	}
+	if !strings.EqualFold(known, mac) {
+		return nil, false
+	}
+	config := pxeState.configs[ip]
+	return config, len(config) > 0
+}
+
+// RecordNetbootedMachine remembers the IP a MAC got from DHCP, to find its config when it netboots again.
+// It is saved to the state on the next syncPXEState.
+func RecordNetbootedMachine(mac, ip string) {
+	pxeState.Lock()
+	defer pxeState.Unlock()
+
+	if pxeState.recorded == nil {
+		pxeState.recorded = make(map[string]string)
+	}
+	pxeState.recorded[ip] = mac
+}
+
+// syncPXEState saves the MACs recorded by the PXE server to saveState, and hands it the machine configs.
+// It is called whenever the machine configs change, before the state is saved.
+func syncPXEState() {
+	pxeState.Lock()
+	defer pxeState.Unlock()
+
+	if saveState.MachinesMACs == nil {
+		saveState.MachinesMACs = make(map[string]string)
+	}
+	for ip, mac := range pxeState.recorded {
+		saveState.MachinesMACs[ip] = mac
+	}
+	pxeState.recorded = nil
+
+	pxeState.macs = make(map[string]string, len(saveState.MachinesMACs))
+	for ip, mac := range saveState.MachinesMACs {
+		pxeState.macs[ip] = mac
+	}
+	// Declared machines with a fixed IP
+	for _, machine := range bootstrapInfos.Machines {
+		if machine.IP != "" && machine.MAC != "" {
+			pxeState.macs[machine.IP] = machine.MAC
+		}
+	}
+
+	pxeState.configs = make(map[string][]byte)
+	for _, machines := range []map[string][]byte{saveState.MachinesCache.ControlPlanes, saveState.MachinesCache.Workers} {
+		for ip, config := range machines {
+			if len(config) > 0 {
+				pxeState.configs[ip] = config
+			}
+		}
+	}
+}
+
+// interfaceOf returns the name of the network interface with an IP, empty when none has it
+func interfaceOf(ip net.IP) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+func resolveIPv4(host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	addrs, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if addr.To4() != nil {
+			return addr, nil
+		}
+	}
+	return nil, &net.DNSError{Err: "no IPv4 address", Name: host}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"sync"
+
 	"github.com/siderolabs/talos/pkg/machinery/config/bundle"
+	"github.com/stolos-cloud/stolos-bootstrap/internal/tui"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/gcp"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/github"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/marshal"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
 )
 
@@ -11,6 +15,8 @@ type BootstrapInfo struct {
 	TalosInfo  talos.TalosInfo   `json:"TalosInfo" field_required:"true"`
 	GCPInfo    gcp.GCPConfig     `json:"GCPInfo" field_required:"false"`
 	GitHubInfo github.GitHubInfo `json:"GitHubInfo" field_required:"false"`
+	// Machines are only used by the non-interactive mode (--config), the wizard asks for roles and disks instead
+	Machines []talos.ExpectedMachine `json:"Machines,omitempty"`
 }
 
 type SaveState struct {
@@ -21,6 +27,19 @@ type SaveState struct {
 	GitHubApp              github.AppManifest              `json:"GitHubApp"`
 	GitHubAppInstallResult github.AppInstallCallbackResult `json:"GitHubAppInstallResult"`
 	GitHubRepoCreated      bool                            `json:"GitHubRepoCreated"`
+	SchematicID            string                          `json:"SchematicID,omitempty"`
+	MachinesMACs           map[string]string               `json:"MachinesMACs,omitempty"` // IP : MAC of netbooted machines
+	GCPBucket              string                          `json:"GCPBucket,omitempty"`    // Terraform state of the GCP control plane
+	Checkpoints            tui.Checkpoints                 `json:"Checkpoints,omitempty"`  // last run of each step, by step name
 }
 
 var ConfigBundle *bundle.Bundle
+
+var checkpointMu sync.Mutex
+
+// SaveCheckpoint persists the state once a step checkpoint changed, the model shares saveState.Checkpoints
+func SaveCheckpoint(name string, cp *tui.Checkpoint) error {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	return marshal.MarshalToFile(_bootstrapStateFile, saveState)
+}
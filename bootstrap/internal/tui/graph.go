@@ -0,0 +1,260 @@
+// graph.go
+package tui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Checkpoint statuses
+const (
+	CheckpointCompleted = "completed"
+	CheckpointFailed    = "failed"
+)
+
+// Checkpoint records the last run of a step, it is kept in the state file so a rerun resumes where it stopped
+type Checkpoint struct {
+	Status     string            `json:"Status"`
+	InputsHash string            `json:"InputsHash,omitempty"`
+	Outputs    map[string]string `json:"Outputs,omitempty"`
+	Attempts   int               `json:"Attempts"`
+	Error      string            `json:"Error,omitempty"`
+	UpdatedAt  time.Time         `json:"UpdatedAt"`
+}
+
+// Checkpoints by step name
+type Checkpoints map[string]*Checkpoint
+
+// stepDoneMsg carries the result of a step Run, steps and checkpoints are only updated by Update
+type stepDoneMsg struct {
+	step       *Step
+	checkpoint *Checkpoint
+	err        error
+}
+
+// RetryPolicy of a step Run. The backoff doubles after each failed attempt, up to MaxBackoff.
+type RetryPolicy struct {
+	Attempts   int // 0 or 1 runs once
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (r RetryPolicy) delay(attempt int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if r.MaxBackoff > 0 && d >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return d
+}
+
+// SortSteps orders the steps so every step comes after its dependencies, keeping the given order otherwise.
+// Unknown dependencies and cycles are errors.
+func SortSteps(steps []*Step) ([]*Step, error) {
+	index := make(map[string]int, len(steps))
+	for i, s := range steps {
+		if _, ok := index[s.Name]; ok {
+			return nil, fmt.Errorf("duplicate step %s", s.Name)
+		}
+		index[s.Name] = i
+	}
+	for _, s := range steps {
+		for _, dep := range s.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("step %s depends on unknown step %s", s.Name, dep)
+			}
+		}
+	}
+
+	sorted := make([]*Step, 0, len(steps))
+	placed := make(map[string]bool, len(steps))
+	for len(sorted) < len(steps) {
+		progressed := false
+		for _, s := range steps {
+			if placed[s.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range s.DependsOn {
+				ready = ready && placed[dep]
+			}
+			if ready {
+				sorted = append(sorted, s)
+				placed[s.Name] = true
+				progressed = true
+				break // restart from the top to keep the given order
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for _, s := range steps {
+				if !placed[s.Name] {
+					cycle = append(cycle, s.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between steps %s", strings.Join(cycle, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+// HashInputs returns the checkpoint hash of step inputs
+func HashInputs(inputs any) (string, error) {
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SetOutput records an output of the step in its checkpoint
+func (s *Step) SetOutput(key, value string) {
+	if s.Outputs == nil {
+		s.Outputs = make(map[string]string)
+	}
+	s.Outputs[key] = value
+}
+
+// Failed reports whether the last run of the step failed, it can then be retried with RetryStep
+func (s *Step) Failed() bool {
+	return !s.IsDone && !s.running && s.Err != nil
+}
+
+// Waiting returns the dependencies the step is waiting for, it is started again with RetryStep once they are done
+func (s *Step) Waiting() []string {
+	return s.waiting
+}
+
+// StepOutput returns an output of a step, including the outputs restored from its checkpoint
+func (m *Model) StepOutput(name, key string) string {
+	if _, step := FindStepByName(m, name); step != nil {
+		return step.Outputs[key]
+	}
+	return ""
+}
+
+// missingDependencies returns the dependencies of a step that are not done
+func (m *Model) missingDependencies(s *Step) []string {
+	var missing []string
+	for _, dep := range s.DependsOn {
+		if _, step := FindStepByName(m, dep); step == nil || !step.IsDone {
+			missing = append(missing, dep)
+		}
+	}
+	return missing
+}
+
+// startStep enters a step: legacy steps run their OnEnter hook, steps with a Run are skipped when their checkpoint
+// is completed with the same inputs, otherwise the returned command runs it in the background.
+func (m *Model) startStep(s *Step) tea.Cmd {
+	if s.Run == nil {
+		if s.OnEnter != nil {
+			return s.OnEnter(m, s)
+		}
+		return nil
+	}
+	if s.waiting = m.missingDependencies(s); len(s.waiting) > 0 {
+		return nil
+	}
+
+	inputsHash := ""
+	if !s.AlwaysRun {
+		var inputs any
+		if s.Inputs != nil {
+			inputs = s.Inputs()
+		}
+		var err error
+		if inputsHash, err = HashInputs(inputs); err != nil {
+			s.Err = fmt.Errorf("failed to hash inputs: %w", err)
+			return nil
+		}
+		if cp := m.Checkpoints[s.Name]; cp != nil && cp.Status == CheckpointCompleted && cp.InputsHash == inputsHash {
+			if err := m.restoreStep(s); err != nil {
+				m.Logger.Warnf("%s completed in a previous run but could not be restored, running it again: %s", s.Title, err)
+			} else {
+				m.Logger.Infof("%s completed in a previous run, skipping", s.Title)
+				s.Outputs = maps.Clone(cp.Outputs)
+				s.Skipped = true
+				s.AutoAdvance = true
+				s.IsDone = true
+				return nil
+			}
+		}
+	}
+
+	s.running = true
+	s.Err = nil
+	return func() tea.Msg {
+		return m.runStep(s, inputsHash)
+	}
+}
+
+// restoreStep runs the Restore hook of a step skipped from its checkpoint
+func (m *Model) restoreStep(s *Step) error {
+	if s.Restore == nil {
+		return nil
+	}
+	return s.Restore(m, s)
+}
+
+// RetryStep runs a failed or waiting step again
+func (m *Model) RetryStep(s *Step) tea.Cmd {
+	if !s.Failed() && len(s.waiting) == 0 {
+		return nil
+	}
+	m.Logger.Infof("Retrying %s...", s.Title)
+	return m.startStep(s)
+}
+
+// runStep runs a step with its retry policy, off the Update loop: it only reports the result
+func (m *Model) runStep(s *Step, inputsHash string) tea.Msg {
+	attempts := max(s.Retry.Attempts, 1)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = s.Run(m, s); err == nil {
+			return stepDoneMsg{step: s, checkpoint: &Checkpoint{Status: CheckpointCompleted, InputsHash: inputsHash, Outputs: maps.Clone(s.Outputs), Attempts: attempt}}
+		}
+		if attempt < attempts {
+			delay := s.Retry.delay(attempt)
+			m.Logger.Warnf("%s failed (attempt %d/%d), retrying in %s: %s", s.Title, attempt, attempts, delay, err)
+			time.Sleep(delay)
+		}
+	}
+	return stepDoneMsg{step: s, checkpoint: &Checkpoint{Status: CheckpointFailed, InputsHash: inputsHash, Attempts: attempts, Error: err.Error()}, err: err}
+}
+
+// finishStep records the result of a step Run
+func (m *Model) finishStep(msg stepDoneMsg) {
+	s := msg.step
+	s.running = false
+	m.saveCheckpoint(s, msg.checkpoint)
+	if msg.err != nil {
+		s.Err = msg.err
+		m.Logger.Errorf("%s failed: %s", s.Title, msg.err)
+		return
+	}
+	s.IsDone = true
+}
+
+func (m *Model) saveCheckpoint(s *Step, cp *Checkpoint) {
+	cp.UpdatedAt = time.Now()
+	if m.Checkpoints == nil {
+		m.Checkpoints = make(Checkpoints)
+	}
+	m.Checkpoints[s.Name] = cp
+	if m.OnCheckpoint != nil {
+		if err := m.OnCheckpoint(s.Name, cp); err != nil {
+			m.Logger.Warnf("Failed to save checkpoint of %s: %s", s.Title, err)
+		}
+	}
+}
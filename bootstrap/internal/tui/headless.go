@@ -0,0 +1,216 @@
+// headless.go
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Progress event types written by the headless runner.
+const (
+	EventLog           = "log"
+	EventStepStarted   = "step_started"
+	EventStepSkipped   = "step_skipped"
+	EventStepCompleted = "step_completed"
+	EventStepFailed    = "step_failed"
+	EventCompleted     = "completed"
+	EventFailed        = "failed"
+)
+
+// errorGracePeriod is how long a step may keep running after logging an error before it is failed.
+// Steps log errors and return without finishing, some recover (retries) and finish anyway.
+const errorGracePeriod = 30 * time.Second
+
+// ProgressEvent is one JSON line of headless output.
+type ProgressEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Level   string    `json:"level,omitempty"`
+	Step    string    `json:"step,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// HeadlessOptions configures RunHeadless.
+type HeadlessOptions struct {
+	StepTimeout  time.Duration // 0 waits forever
+	PollInterval time.Duration
+}
+
+// headlessSink writes progress events and remembers the last error logged by the running step.
+type headlessSink struct {
+	mu          sync.Mutex
+	enc         *json.Encoder
+	step        string
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (h *headlessSink) write(event ProgressEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_ = h.enc.Encode(event)
+}
+
+func (h *headlessSink) send(msg tea.Msg) {
+	l, ok := msg.(logMsg)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if l.Level == LevelError {
+		h.lastError, h.lastErrorAt = l.Text, l.At
+	}
+	_ = h.enc.Encode(ProgressEvent{Time: l.At, Event: EventLog, Level: l.Level.String(), Step: h.step, Message: l.Text})
+}
+
+func (h *headlessSink) enterStep(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.step = name
+	h.lastError, h.lastErrorAt = "", time.Time{}
+}
+
+func (h *headlessSink) stepError() (string, time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastError, h.lastErrorAt
+}
+
+// NewHeadless constructs a Model that runs the Steps without a Bubble Tea Program.
+// Logs and step progress are written to out as JSON lines.
+func NewHeadless(steps []*Step, out io.Writer, file *os.File) *Model {
+	m := newModel(steps)
+	sink := &headlessSink{enc: json.NewEncoder(out)}
+	m.Logger = &UILogger{send: sink.send, file: file, sync: true}
+	m.headless = sink
+	return &m
+}
+
+// RunHeadless runs the Steps in dependency order: OnEnter or Run, wait for IsDone, OnExit.
+// Steps already done and auto-advancing (disabled or restored from a checkpoint) are skipped, plain steps complete
+// on enter. A failed step does not stop the steps that do not depend on it, the run fails once they are done.
+// Legacy OnEnter steps fail when they time out or log an error and do not finish within the grace period.
+func (m *Model) RunHeadless(opts HeadlessOptions) error {
+	if m.headless == nil {
+		return fmt.Errorf("model was not created with NewHeadless")
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 500 * time.Millisecond
+	}
+
+	failed := map[string]bool{}
+	var firstErr error
+	// Steps may be inserted while running, so re-check the length each iteration.
+	for m.CurrentStepIndex = 0; m.CurrentStepIndex < len(m.Steps); m.CurrentStepIndex++ {
+		step := m.Steps[m.CurrentStepIndex]
+		m.headless.enterStep(step.Name)
+
+		if blocker := blockedBy(step, failed); blocker != "" {
+			failed[step.Name] = true
+			m.headless.write(ProgressEvent{Time: time.Now(), Event: EventStepSkipped, Step: step.Name, Title: step.Title, Message: "blocked by " + blocker})
+			continue
+		}
+		if step.IsDone && step.AutoAdvance {
+			m.headless.write(ProgressEvent{Time: time.Now(), Event: EventStepSkipped, Step: step.Name, Title: step.Title})
+			continue
+		}
+
+		m.headless.write(ProgressEvent{Time: time.Now(), Event: EventStepStarted, Step: step.Name, Title: step.Title})
+		started := time.Now()
+		step.isStarted = true
+		var done chan tea.Msg
+		if cmd := m.startStep(step); cmd != nil && step.Run != nil {
+			done = make(chan tea.Msg, 1)
+			go func() { done <- cmd() }()
+		}
+
+		if err := m.waitStep(step, started, opts, done); err != nil {
+			failed[step.Name] = true
+			if firstErr == nil {
+				firstErr = fmt.Errorf("step %s failed: %w", step.Name, err)
+			}
+			m.headless.write(ProgressEvent{Time: time.Now(), Event: EventStepFailed, Level: LevelError.String(), Step: step.Name, Title: step.Title, Message: err.Error()})
+			continue
+		}
+
+		if step.OnExit != nil {
+			step.OnExit(m, step)
+		}
+		if step.Skipped {
+			m.headless.write(ProgressEvent{Time: time.Now(), Event: EventStepSkipped, Step: step.Name, Title: step.Title, Message: "completed in a previous run"})
+			continue
+		}
+		m.headless.write(ProgressEvent{
+			Time:    time.Now(),
+			Event:   EventStepCompleted,
+			Step:    step.Name,
+			Title:   step.Title,
+			Message: fmt.Sprintf("completed in %s", time.Since(started).Round(time.Second)),
+		})
+	}
+
+	if firstErr != nil {
+		m.headless.write(ProgressEvent{Time: time.Now(), Event: EventFailed, Level: LevelError.String(), Message: firstErr.Error()})
+		return firstErr
+	}
+	m.headless.write(ProgressEvent{Time: time.Now(), Event: EventCompleted})
+	return nil
+}
+
+// blockedBy returns the failed dependency of a step, empty when none failed
+func blockedBy(step *Step, failed map[string]bool) string {
+	for _, dep := range step.DependsOn {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// waitStep waits for a step to be done, done receives the result of a graph step Run
+func (m *Model) waitStep(step *Step, started time.Time, opts HeadlessOptions, done <-chan tea.Msg) error {
+	if step.Kind == StepPlain && step.Run == nil {
+		return nil
+	}
+	for !step.IsDone {
+		if step.Run != nil {
+			// Graph steps report their own error once their retries are exhausted
+			if step.Failed() {
+				return step.Err
+			}
+			// nothing completes the dependencies of a waiting step in headless mode
+			if len(step.waiting) > 0 {
+				return fmt.Errorf("waiting for %s", strings.Join(step.waiting, ", "))
+			}
+			if opts.StepTimeout > 0 && time.Since(started) > opts.StepTimeout {
+				return fmt.Errorf("timed out after %s", opts.StepTimeout)
+			}
+			select {
+			case msg := <-done:
+				m.finishStep(msg.(stepDoneMsg))
+			case <-time.After(opts.PollInterval):
+			}
+			continue
+		}
+		if opts.StepTimeout > 0 && time.Since(started) > opts.StepTimeout {
+			if msg, _ := m.headless.stepError(); msg != "" {
+				return fmt.Errorf("timed out after %s, last error: %s", opts.StepTimeout, msg)
+			}
+			return fmt.Errorf("timed out after %s", opts.StepTimeout)
+		}
+		if msg, at := m.headless.stepError(); msg != "" && time.Since(at) > errorGracePeriod {
+			return fmt.Errorf("%s", msg)
+		}
+		time.Sleep(opts.PollInterval)
+	}
+	return nil
+}
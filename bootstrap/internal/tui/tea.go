@@ -47,6 +47,21 @@ type Step struct {
 	AutoAdvance bool
 	OnEnter     func(*Model, *Step) tea.Cmd // hook called when step is entered
 	OnExit      func(*Model, *Step)
+
+	// Graph steps: Run is called in the background once the DependsOn steps are done, a step is skipped when its
+	// checkpoint completed with the same Inputs (none when nil) unless it is AlwaysRun. Restore reloads what Run
+	// set up from the state when the step is skipped, the step runs again when it fails.
+	DependsOn []string
+	Run       func(*Model, *Step) error
+	Inputs    func() any
+	AlwaysRun bool
+	Restore   func(*Model, *Step) error
+	Retry     RetryPolicy
+	Outputs   map[string]string
+	Err       error // error of the last run
+	Skipped   bool  // restored from its checkpoint
+	running   bool
+	waiting   []string // DependsOn steps not done when the step was entered
 }
 
 // NewTextField constructs a text input field
@@ -68,6 +83,7 @@ func NewTextField(label, placeholder string, optional bool) Field {
 type UILogger struct {
 	send func(msg tea.Msg)
 	file *os.File
+	sync bool
 }
 
 // Info Logs an info line (non-blocking).
@@ -84,31 +100,19 @@ func (l *UILogger) Errorf(f string, a ...any)   { l.Error(fmt.Sprintf(f, a...))
 func (l *UILogger) Successf(f string, a ...any) { l.Success(fmt.Sprintf(f, a...)) }
 
 // emit always spawns a goroutine so the caller can't ever block on the UI thread.
+// Headless loggers write inline so lines keep their order.
 func (l *UILogger) emit(m tea.Msg) {
-	go func() {
-		l.send(m)
-		var level string
-		switch m.(logMsg).Level {
-		case LevelDebug:
-			level = "DEBUG"
-			break
-		case LevelInfo:
-			level = "INFO"
-			break
-		case LevelWarn:
-			level = "WARN"
-			break
-		case LevelError:
-			level = "ERROR"
-			break
-		case LevelSuccess:
-			level = "SUCCESS"
-			break
+	if l.sync {
+		l.write(m)
+		return
+	}
+	go l.write(m)
+}
 
-		}
-		_, _ = l.file.WriteString(fmt.Sprintf("[%s] [%s] %s\n", m.(logMsg).At.Format("2006-01-02 15:04:05"), level, m.(logMsg).Text))
-		_ = l.file.Sync()
-	}()
+func (l *UILogger) write(m tea.Msg) {
+	l.send(m)
+	_, _ = l.file.WriteString(fmt.Sprintf("[%s] [%s] %s\n", m.(logMsg).At.Format("2006-01-02 15:04:05"), m.(logMsg).Level, m.(logMsg).Text))
+	_ = l.file.Sync()
 }
 
 // NewWizard constructs the Bubble Tea Program + UILogger from the provided Steps.
@@ -132,6 +136,22 @@ const (
 	LevelSuccess
 )
 
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelSuccess:
+		return "SUCCESS"
+	}
+	return ""
+}
+
 type logMsg struct {
 	Level LogLevel
 	Text  string
@@ -154,6 +174,10 @@ type Model struct {
 	Logs              []logMsg
 	MaxLogs           int
 	Program           *tea.Program // Backref for internal Cmds that may need Send
+	headless          *headlessSink
+
+	Checkpoints  Checkpoints
+	OnCheckpoint func(name string, cp *Checkpoint) error // called when a step checkpoint changes, to persist it
 }
 
 func newModel(steps []*Step) Model {
@@ -204,6 +228,11 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 
+		case "r":
+			if m.getCurrentStep().Failed() || len(m.getCurrentStep().Waiting()) > 0 {
+				return m, tea.Batch(m.RetryStep(m.getCurrentStep()), m.Spinner.Tick)
+			}
+
 		case "tab", "down":
 			if m.getCurrentStep().Kind == StepForm && len(m.getCurrentStep().Fields) > 0 {
 				m.Steps[m.CurrentStepIndex].Fields[m.FocusedFieldIndex].Input.Blur()
@@ -236,6 +265,10 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.appendLog(msg)
 		return m, nil
 
+	case stepDoneMsg:
+		m.finishStep(msg)
+		return m, nil
+
 	case stepEnteredMsg:
 		step := &m.Steps[msg.idx]
 
@@ -250,9 +283,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if (*step).Kind == StepSpinner {
 			cmds = append(cmds, m.Spinner.Tick)
 		}
-		if (*step).OnEnter != nil {
-			cmds = append(cmds, (*step).OnEnter(m, *step))
-		}
+		cmds = append(cmds, m.startStep(*step))
 		return m, tea.Batch(cmds...)
 
 	case advanceMsg:
@@ -284,10 +315,14 @@ func (m *Model) View() string {
 	b.WriteString(title + "\n\n")
 
 	// Step body by kind
-	switch m.getCurrentStep().Kind {
-	case StepForm:
+	switch {
+	case m.getCurrentStep().Failed():
+		b.WriteString(m.renderFailedBody())
+	case len(m.getCurrentStep().Waiting()) > 0:
+		b.WriteString(m.renderWaitingBody())
+	case m.getCurrentStep().Kind == StepForm:
 		b.WriteString(m.renderForm())
-	case StepSpinner:
+	case m.getCurrentStep().Kind == StepSpinner:
 		b.WriteString(m.renderSpinnerBody())
 	default:
 		// Plain
@@ -426,6 +461,18 @@ func (m *Model) renderSpinnerBody() string {
 	return fmt.Sprintf("%s %s", sp, body)
 }
 
+func (m *Model) renderFailedBody() string {
+	failed := lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("Failed: " + m.getCurrentStep().Err.Error())
+	footer := lipgloss.NewStyle().Bold(true).Render("\n\n*** Press r to retry")
+	return m.wrap(failed, m.Width) + footer
+}
+
+func (m *Model) renderWaitingBody() string {
+	waiting := "Waiting for " + strings.Join(m.getCurrentStep().Waiting(), ", ")
+	footer := lipgloss.NewStyle().Bold(true).Render("\n\n*** Press r to check again")
+	return m.wrap(waiting, m.Width) + footer
+}
+
 // Custom Logging
 
 func (m *Model) appendLog(l logMsg) {
@@ -630,6 +677,58 @@ func safeCallDefault(fn DefaultFunc) (val string, ok bool) {
 	return fn(), true
 }
 
+// fieldDefault resolves the default value of a struct field from its tags
+func fieldDefault(sf reflect.StructField) string {
+	// 1) Try field_default_func (by name in registry)
+	def := ""
+	if fnName := sf.Tag.Get("field_default_func"); fnName != "" {
+		if fn, ok := DefaultFuncRegistry[fnName]; ok && fn != nil {
+			if v, ok2 := safeCallDefault(fn); ok2 {
+				def = v
+			}
+		}
+	}
+
+	// 2) Fallback to literal field_default if no value yet
+	if def == "" {
+		def = sf.Tag.Get("field_default")
+	}
+	return def
+}
+
+// FillStructDefaults sets the empty string fields of obj to their form default,
+// the same value the form would have been prefilled with.
+func FillStructDefaults[T any](obj *T) {
+	v := reflect.ValueOf(obj).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Tag.Get("field_label") == "" || sf.Type.Kind() != reflect.String {
+			continue
+		}
+		if v.Field(i).String() == "" {
+			v.Field(i).SetString(fieldDefault(sf))
+		}
+	}
+}
+
+// MissingRequiredFields returns the names of the required form fields of obj that are empty
+func MissingRequiredFields[T any](obj *T) []string {
+	var missing []string
+	v := reflect.ValueOf(obj).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Tag.Get("field_label") == "" || !strings.EqualFold(sf.Tag.Get("field_required"), "true") {
+			continue
+		}
+		if v.Field(i).IsZero() {
+			missing = append(missing, sf.Name)
+		}
+	}
+	return missing
+}
+
 func CreateFieldsForStruct[T any]() []Field {
 	formFields := []Field{}
 
@@ -638,20 +737,7 @@ func CreateFieldsForStruct[T any]() []Field {
 		sf := t.Field(i)
 
 		if sf.Tag.Get("field_label") != "" {
-			// 1) Try field_default_func (by name in registry)
-			def := ""
-			if fnName := sf.Tag.Get("field_default_func"); fnName != "" {
-				if fn, ok := DefaultFuncRegistry[fnName]; ok && fn != nil {
-					if v, ok2 := safeCallDefault(fn); ok2 {
-						def = v
-					}
-				}
-			}
-
-			// 2) Fallback to literal field_default if no value yet
-			if def == "" {
-				def = sf.Tag.Get("field_default")
-			}
+			def := fieldDefault(sf)
 
 			input := textinput.New()
 			input.Prompt = "? "
@@ -0,0 +1,118 @@
+package airgap
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeTarGz archives the content of dir, the file is written next to output and renamed once complete
+func writeTarGz(dir, output string) error {
+	tmp := output + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer os.Remove(tmp)
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name: filepath.ToSlash(rel),
+			Mode: 0644,
+			Size: info.Size(),
+		}); err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	return os.Rename(tmp, output)
+}
+
+// Extract extracts a bundle into dir and returns its manifest.
+// A bundle already extracted in dir is reused, the extraction is skipped.
+func Extract(bundlePath, dir string) (*Manifest, error) {
+	if manifest, err := ReadManifest(dir); err == nil {
+		return manifest, nil
+	}
+
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	// The manifest is written last, an interrupted extraction is restarted
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := header.Name
+		if name == ManifestFile {
+			name += ".partial"
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		if !strings.HasPrefix(dest, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("invalid path in bundle: %s", header.Name)
+		}
+		if err := writeFile(dest, tr); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", header.Name, err)
+		}
+	}
+
+	if err := os.Rename(filepath.Join(dir, ManifestFile+".partial"), filepath.Join(dir, ManifestFile)); err != nil {
+		return nil, fmt.Errorf("bundle has no manifest: %w", err)
+	}
+	return ReadManifest(dir)
+}
@@ -0,0 +1,414 @@
+package airgap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/pxe"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Layout annotation holding the reference an image was pulled from
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// Media type of the wasm layer of the yoke flights pushed with yoke stow
+const yokeWasmMediaType = "application/vnd.yoke.wasm.gzip"
+
+// Chart is a Helm chart of a chart repository
+type Chart struct {
+	RepoURL string
+	Name    string
+	Version string
+}
+
+// CreateOptions selects the content of a bundle
+type CreateOptions struct {
+	Output string
+
+	FactoryURL        string
+	SchematicID       string
+	TalosVersion      string
+	TalosArchitecture string
+	FactoryFiles      []string // file names under image/<schematic>/<version>/ of the image factory
+
+	Images      []string // the images of ArgoCDChart, FlightCharts and FlightManifests are added to them
+	ArgoCDChart Chart
+	Flights     []string // oci:// or http(s) URLs of the yoke flights run by the bootstrap
+	AirwayURL   string   // the flights of the airway are bundled too
+
+	FlightCharts    []Chart  // charts the flights deploy through ArgoCD, only their images are bundled
+	FlightManifests []string // URLs of the manifests the flights deploy through ArgoCD, only their images are bundled
+
+	Printf func(format string, args ...any)
+}
+
+// Create downloads everything listed in the options and writes the bundle to opts.Output.
+// Registry credentials are read from the Docker config, as for docker pull.
+func Create(ctx context.Context, opts CreateOptions) error {
+	printf := opts.Printf
+	if printf == nil {
+		printf = func(string, ...any) {}
+	}
+
+	dir, err := os.MkdirTemp("", "stolos-airgap-*")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := &Manifest{
+		TalosVersion:      opts.TalosVersion,
+		TalosArchitecture: opts.TalosArchitecture,
+		SchematicID:       opts.SchematicID,
+		Flights:           make(map[string]string),
+	}
+
+	printf("Downloading the image factory files of schematic %s...\n", opts.SchematicID)
+	factoryPath := path.Join("image", opts.SchematicID, opts.TalosVersion)
+	for _, file := range opts.FactoryFiles {
+		url := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(opts.FactoryURL, "/"), factoryPath, file)
+		if err := downloadFile(ctx, filepath.Join(dir, FactoryDir, factoryPath, file), url); err != nil {
+			return err
+		}
+	}
+
+	printf("Downloading the iPXE binaries...\n")
+	if err := pxe.DownloadIPXEBinaries(ctx, filepath.Join(dir, IPXEDir)); err != nil {
+		return err
+	}
+
+	printf("Downloading the %s chart %s...\n", opts.ArgoCDChart.Name, opts.ArgoCDChart.Version)
+	manifest.ArgoCDChart, err = downloadChart(ctx, dir, opts.ArgoCDChart)
+	if err != nil {
+		return err
+	}
+	chartImages, err := ChartImages(filepath.Join(dir, manifest.ArgoCDChart))
+	if err != nil {
+		return err
+	}
+	sourceImages, err := flightSourceImages(ctx, opts, printf)
+	if err != nil {
+		return err
+	}
+	chartImages = append(chartImages, sourceImages...)
+
+	flights := slices.Clone(opts.Flights)
+	if opts.AirwayURL != "" {
+		printf("Downloading the airway %s...\n", opts.AirwayURL)
+		if err := downloadFile(ctx, filepath.Join(dir, AirwayFile), opts.AirwayURL); err != nil {
+			return err
+		}
+		manifest.Airway = AirwayFile
+		airwayFlights, err := readAirwayFlights(filepath.Join(dir, AirwayFile))
+		if err != nil {
+			return err
+		}
+		flights = append(flights, airwayFlights...)
+	}
+	for _, flight := range flights {
+		printf("Downloading the flight %s...\n", flight)
+		file := path.Join(WasmDir, flightFileName(flight))
+		if err := downloadFlight(ctx, filepath.Join(dir, file), flight); err != nil {
+			return err
+		}
+		manifest.Flights[flight] = file
+	}
+
+	for _, image := range append(slices.Clone(opts.Images), chartImages...) {
+		normalized, err := NormalizeImage(image)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(manifest.Images, normalized) {
+			manifest.Images = append(manifest.Images, normalized)
+		}
+	}
+	slices.Sort(manifest.Images)
+	if manifest.Registries, err = imageRegistries(manifest.Images); err != nil {
+		return err
+	}
+
+	imageLayout, err := layout.Write(filepath.Join(dir, LayoutDir), empty.Index)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI layout: %w", err)
+	}
+	platform := v1.Platform{OS: "linux", Architecture: opts.TalosArchitecture}
+	for i, image := range manifest.Images {
+		printf("Pulling image %d/%d %s...\n", i+1, len(manifest.Images), image)
+		if err := pullImage(ctx, imageLayout, image, platform); err != nil {
+			return err
+		}
+	}
+
+	if err := writeManifest(dir, manifest); err != nil {
+		return fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+	printf("Writing %s...\n", opts.Output)
+	return writeTarGz(dir, opts.Output)
+}
+
+// pullImage appends an image to the layout. Tagged images are pulled for the platform only, images pinned by digest
+// are pulled with every platform as the digest of the index must be kept.
+func pullImage(ctx context.Context, imageLayout layout.Path, image string, platform v1.Platform) error {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return err
+	}
+	remoteOpts := []remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain)}
+	annotations := layout.WithAnnotations(map[string]string{refNameAnnotation: image})
+
+	if _, ok := ref.(name.Digest); ok {
+		desc, err := remote.Get(ref, remoteOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", image, err)
+		}
+		if desc.MediaType.IsIndex() {
+			index, err := desc.ImageIndex()
+			if err != nil {
+				return fmt.Errorf("failed to pull %s: %w", image, err)
+			}
+			if err := imageLayout.AppendIndex(index, annotations); err != nil {
+				return fmt.Errorf("failed to write %s: %w", image, err)
+			}
+			return nil
+		}
+	}
+
+	img, err := remote.Image(ref, append(remoteOpts, remote.WithPlatform(platform))...)
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %w", image, err)
+	}
+	if err := imageLayout.AppendImage(img, annotations); err != nil {
+		return fmt.Errorf("failed to write %s: %w", image, err)
+	}
+	return nil
+}
+
+// downloadChart downloads the chart archive into the charts directory and returns its bundle path
+func downloadChart(ctx context.Context, dir string, chart Chart) (string, error) {
+	chartURL, err := repo.FindChartInRepoURL(chart.RepoURL, chart.Name, chart.Version, "", "", "", getter.All(cli.New()))
+	if err != nil {
+		return "", fmt.Errorf("failed to find chart %s %s: %w", chart.Name, chart.Version, err)
+	}
+	file := path.Join(ChartsDir, fmt.Sprintf("%s-%s.tgz", chart.Name, chart.Version))
+	if err := downloadFile(ctx, filepath.Join(dir, file), chartURL); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+var imageLinePattern = regexp.MustCompile(`(?m)^\s*-?\s*image:\s*["']?([^"'\s]+)["']?\s*$`)
+
+// ChartImages renders a chart with its default values and returns the images of its manifests
+func ChartImages(chartPath string) ([]string, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", chartPath, err)
+	}
+	values, err := chartutil.ToRenderValues(chrt, map[string]interface{}{}, chartutil.ReleaseOptions{
+		Name:      chrt.Name(),
+		Namespace: chrt.Name(),
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute values of chart %s: %w", chartPath, err)
+	}
+	manifests, err := engine.Render(chrt, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart %s: %w", chartPath, err)
+	}
+
+	return manifestImages(slices.Collect(maps.Values(manifests))...), nil
+}
+
+// manifestImages returns the images of YAML manifests
+func manifestImages(manifests ...string) []string {
+	var images []string
+	for _, manifest := range manifests {
+		for _, match := range imageLinePattern.FindAllStringSubmatch(manifest, -1) {
+			if !slices.Contains(images, match[1]) {
+				images = append(images, match[1])
+			}
+		}
+	}
+	slices.Sort(images)
+	return images
+}
+
+// flightSourceImages downloads the charts and manifests the flights deploy and returns their images.
+// Only the images are bundled, the charts and manifests themselves are not.
+func flightSourceImages(ctx context.Context, opts CreateOptions, printf func(format string, args ...any)) ([]string, error) {
+	dir, err := os.MkdirTemp("", "stolos-airgap-sources-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var images []string
+	for _, chart := range opts.FlightCharts {
+		if chart == opts.ArgoCDChart {
+			continue
+		}
+		printf("Listing the images of the %s chart %s...\n", chart.Name, chart.Version)
+		file, err := downloadChart(ctx, dir, chart)
+		if err != nil {
+			return nil, err
+		}
+		chartImages, err := ChartImages(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, chartImages...)
+	}
+	for i, url := range opts.FlightManifests {
+		printf("Listing the images of %s...\n", url)
+		file := filepath.Join(dir, fmt.Sprintf("manifest-%d.yaml", i))
+		if err := downloadFile(ctx, file, url); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", url, err)
+		}
+		images = append(images, manifestImages(string(data))...)
+	}
+	return images, nil
+}
+
+// readAirwayFlights returns the wasm URLs of an airway
+func readAirwayFlights(airwayPath string) ([]string, error) {
+	airway, err := ReadAirway(airwayPath)
+	if err != nil {
+		return nil, err
+	}
+	wasmURLs, _, err := unstructured.NestedStringMap(airway.Object, "spec", "wasmUrls")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read airway wasm URLs: %w", err)
+	}
+	var flights []string
+	for _, url := range wasmURLs {
+		flights = append(flights, url)
+	}
+	slices.Sort(flights)
+	return flights, nil
+}
+
+// ReadAirway decodes an airway YAML file
+func ReadAirway(airwayPath string) (*unstructured.Unstructured, error) {
+	f, err := os.Open(airwayPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open airway: %w", err)
+	}
+	defer f.Close()
+
+	airway := &unstructured.Unstructured{}
+	if err := yaml.NewYAMLOrJSONDecoder(f, 1024).Decode(airway); err != nil {
+		return nil, fmt.Errorf("failed to decode airway: %w", err)
+	}
+	return airway, nil
+}
+
+// flightFileName names the wasm file of a flight URL after its repository or file name, and its tag
+func flightFileName(flight string) string {
+	if ociURL, ok := strings.CutPrefix(flight, "oci://"); ok {
+		if ref, err := name.ParseReference(ociURL); err == nil {
+			identifier := strings.ReplaceAll(ref.Identifier(), ":", "-")
+			return path.Base(ref.Context().RepositoryStr()) + "-" + identifier + ".wasm"
+		}
+	}
+	base := path.Base(flight)
+	if before, _, ok := strings.Cut(base, "?"); ok {
+		base = before
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(base, ".gz"), ".wasm") + ".wasm"
+}
+
+// downloadFlight writes the wasm of a flight, OCI artifacts are pulled like yoke does
+func downloadFlight(ctx context.Context, dest, flight string) error {
+	ociURL, ok := strings.CutPrefix(flight, "oci://")
+	if !ok {
+		return downloadFile(ctx, dest, flight)
+	}
+
+	ref, err := name.ParseReference(ociURL)
+	if err != nil {
+		return fmt.Errorf("invalid flight URL %s: %w", flight, err)
+	}
+	img, err := remote.Image(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return fmt.Errorf("failed to pull flight %s: %w", flight, err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("failed to read flight %s: %w", flight, err)
+	}
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil || mediaType != yokeWasmMediaType {
+			continue
+		}
+		wasm, err := layer.Uncompressed()
+		if err != nil {
+			return fmt.Errorf("failed to pull flight %s: %w", flight, err)
+		}
+		defer wasm.Close()
+		return writeFile(dest, wasm)
+	}
+	return fmt.Errorf("flight %s has no wasm layer", flight)
+}
+
+func downloadFile(ctx context.Context, dest, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	if err := writeFile(dest, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return nil
+}
+
+func writeFile(dest string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,99 @@
+// Package airgap bundles and serves everything the bootstrap downloads, for sites without internet access.
+//
+// A bundle is a gzipped tarball with the image factory files of a schematic, the iPXE binaries, the ArgoCD chart,
+// the yoke flights and the container images in an OCI layout. During the bootstrap the bundle is served by a
+// single HTTP server: an OCI registry under /v2/, used by Talos as a registry mirror, the image factory files
+// under /image/ and the other files under /files/.
+package airgap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Paths in a bundle
+const (
+	ManifestFile = "manifest.json"
+	LayoutDir    = "oci"
+	FilesDir     = "files"
+	FactoryDir   = "files/factory"
+	IPXEDir      = "files/ipxe"
+	ChartsDir    = "files/charts"
+	WasmDir      = "files/wasm"
+	AirwayFile   = "files/airway.yml"
+)
+
+// Manifest describes the content of a bundle
+type Manifest struct {
+	TalosVersion      string   `json:"TalosVersion"`
+	TalosArchitecture string   `json:"TalosArchitecture"`
+	SchematicID       string   `json:"SchematicID"`
+	Images            []string `json:"Images"`     // fully qualified references
+	Registries        []string `json:"Registries"` // registries of the images, mirrored by Talos
+	ArgoCDChart       string   `json:"ArgoCDChart"`
+	Airway            string   `json:"Airway,omitempty"`
+	// Flights maps the URL of a yoke flight to its wasm file
+	Flights map[string]string `json:"Flights"`
+}
+
+// ReadManifest reads the manifest of an extracted bundle
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle manifest: %w", err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func writeManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644)
+}
+
+// NormalizeImage returns the fully qualified reference of an image, Docker Hub images are qualified with docker.io
+func NormalizeImage(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	repository := registryHost(ref) + "/" + ref.Context().RepositoryStr()
+	if _, ok := ref.(name.Digest); ok {
+		return repository + "@" + ref.Identifier(), nil
+	}
+	return repository + ":" + ref.Identifier(), nil
+}
+
+// registryHost is the registry of a reference as Talos names it in the mirrors config
+func registryHost(ref name.Reference) string {
+	if host := ref.Context().RegistryStr(); host != name.DefaultRegistry {
+		return host
+	}
+	return "docker.io"
+}
+
+func imageRegistries(images []string) ([]string, error) {
+	var registries []string
+	for _, image := range images {
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			return nil, err
+		}
+		if host := registryHost(ref); !slices.Contains(registries, host) {
+			registries = append(registries, host)
+		}
+	}
+	slices.Sort(registries)
+	return registries, nil
+}
@@ -0,0 +1,168 @@
+package airgap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/logger"
+)
+
+// Server serves an extracted bundle: the OCI registry under /v2/, the image factory files under /image/
+// and the other files under /files/
+type Server struct {
+	dir      string
+	host     string
+	port     string
+	manifest *Manifest
+	logger   logger.Logger
+
+	http *http.Server
+}
+
+// NewServer creates the server of the bundle extracted in dir. host must be reachable by the machines.
+func NewServer(dir, host, port string, logger logger.Logger) (*Server, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("air-gap server host and port are required")
+	}
+	return &Server{dir: dir, host: host, port: port, manifest: manifest, logger: logger}, nil
+}
+
+// Start loads the image manifests in the registry and serves the bundle in the background
+func (s *Server) Start() error {
+	// Blobs are served in place from the OCI layout, manifests are kept in memory by the registry
+	registryHandler := registry.New(
+		registry.WithBlobHandler(registry.NewDiskBlobHandler(filepath.Join(s.dir, LayoutDir, "blobs"))),
+		registry.Logger(log.New(&logWriter{s.logger}, "", 0)),
+	)
+	if err := loadLayout(registryHandler, layout.Path(filepath.Join(s.dir, LayoutDir))); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/v2/", registryHandler)
+	mux.Handle("/image/", http.FileServer(http.Dir(filepath.Join(s.dir, FactoryDir))))
+	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(filepath.Join(s.dir, FilesDir)))))
+
+	listener, err := net.Listen("tcp", ":"+s.port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on air-gap port %s: %w", s.port, err)
+	}
+	s.http = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("Air-gap server stopped: %s", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Stop() {
+	if s.http != nil {
+		s.http.Close()
+	}
+}
+
+// Manifest returns the manifest of the bundle
+func (s *Server) Manifest() *Manifest {
+	return s.manifest
+}
+
+// URL is the base URL of the server, also the image factory URL of the bundled schematic
+func (s *Server) URL() string {
+	return fmt.Sprintf("http://%s", net.JoinHostPort(s.host, s.port))
+}
+
+// FileURL returns the URL of a bundle file
+func (s *Server) FileURL(file string) string {
+	return s.URL() + "/files/" + strings.TrimPrefix(path.Clean(file), FilesDir+"/")
+}
+
+// LocalPath returns the path of an extracted bundle file
+func (s *Server) LocalPath(file string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(file))
+}
+
+// FlightFile returns the bundle file of a flight URL
+func (s *Server) FlightFile(flight string) (string, bool) {
+	file, ok := s.manifest.Flights[flight]
+	return file, ok
+}
+
+// loadLayout puts the manifests of the layout images in the registry, under their original reference
+func loadLayout(handler http.Handler, imageLayout layout.Path) error {
+	index, err := imageLayout.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("failed to read OCI layout: %w", err)
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("failed to read OCI layout: %w", err)
+	}
+
+	for _, desc := range indexManifest.Manifests {
+		ref, err := name.ParseReference(desc.Annotations[refNameAnnotation])
+		if err != nil {
+			return fmt.Errorf("invalid image reference in OCI layout: %w", err)
+		}
+		repository := registryHost(ref) + "/" + ref.Context().RepositoryStr()
+		if err := putManifest(handler, imageLayout, repository, ref.Identifier(), desc); err != nil {
+			return fmt.Errorf("failed to load %s: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+// putManifest puts a manifest in the registry, the manifests of an index are put first by digest
+func putManifest(handler http.Handler, imageLayout layout.Path, repository, target string, desc v1.Descriptor) error {
+	data, err := imageLayout.Bytes(desc.Digest)
+	if err != nil {
+		return err
+	}
+	if desc.MediaType.IsIndex() {
+		children, err := v1.ParseIndexManifest(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for _, child := range children.Manifests {
+			if err := putManifest(handler, imageLayout, repository, child.Digest.String(), child); err != nil {
+				return err
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/v2/%s/manifests/%s", repository, target), bytes.NewReader(data))
+	req.Header.Set("Content-Type", string(desc.MediaType))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		return fmt.Errorf("registry returned %d: %s", resp.Code, resp.Body.String())
+	}
+	return nil
+}
+
+// logWriter forwards the registry logs as debug messages
+type logWriter struct {
+	logger logger.Logger
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.logger.Debug(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
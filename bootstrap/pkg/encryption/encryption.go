@@ -0,0 +1,243 @@
+// Package encryption encrypts the bootstrap state and config bundle at rest with age.
+//
+// Files are encrypted to an X25519 identity. The identity is either stored in the OS keyring,
+// in a key file itself encrypted with a passphrase, so the passphrase cost is only paid once per run,
+// or in an age identity file managed by the operator.
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// Key sources
+const (
+	ModeKeyring    = "keyring"
+	ModePassphrase = "passphrase"
+	ModeNone       = "none"
+)
+
+const keyringService = "stolos-bootstrap"
+
+// ageHeader starts every age encrypted file
+var ageHeader = []byte("age-encryption.org/v1\n")
+
+// ErrKeyringUnavailable is returned when there is no OS keyring, like on headless hosts without a session bus
+var ErrKeyringUnavailable = errors.New("OS keyring unavailable")
+
+// Key encrypts and decrypts files
+type Key struct {
+	identity *age.X25519Identity
+}
+
+// IsEncrypted reports whether data was encrypted by a Key
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, ageHeader)
+}
+
+// Encrypt encrypts data to the key
+func (k *Key) Encrypt(data []byte) ([]byte, error) {
+	return encrypt(data, k.identity.Recipient())
+}
+
+// Decrypt decrypts data encrypted to the key
+func (k *Key) Decrypt(data []byte) ([]byte, error) {
+	return decrypt(data, k.identity)
+}
+
+// LoadKeyringKey loads the key of an account from the OS keyring, creating it on first use
+func LoadKeyringKey(account string) (*Key, error) {
+	secret, err := keyring.Get(keyringService, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		if err := keyring.Set(keyringService, account, identity.String()); err != nil {
+			return nil, fmt.Errorf("%w, failed to store key: %w", ErrKeyringUnavailable, err)
+		}
+		return &Key{identity: identity}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w, failed to read key: %w", ErrKeyringUnavailable, err)
+	}
+
+	identity, err := age.ParseX25519Identity(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in OS keyring: %w", err)
+	}
+	return &Key{identity: identity}, nil
+}
+
+// DeleteKeyringKey removes the key of an account from the OS keyring
+func DeleteKeyringKey(account string) error {
+	if err := keyring.Delete(keyringService, account); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// LoadPassphraseKey loads the key stored in path, encrypted with a passphrase, creating it on first use
+func LoadPassphraseKey(path, passphrase string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		encrypted, err := EncryptWithPassphrase([]byte(identity.String()), passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, encrypted, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write key file %s: %w", path, err)
+		}
+		return &Key{identity: identity}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+
+	secret, err := DecryptWithPassphrase(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock key file %s (wrong passphrase?): %w", path, err)
+	}
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(string(secret)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return &Key{identity: identity}, nil
+}
+
+// LoadIdentityFile loads the first X25519 identity of an age identity file, as written by age-keygen
+func LoadIdentityFile(path string) (*Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity file %s: %w", path, err)
+	}
+	for _, identity := range identities {
+		if x25519, ok := identity.(*age.X25519Identity); ok {
+			return &Key{identity: x25519}, nil
+		}
+	}
+	return nil, fmt.Errorf("identity file %s has no X25519 identity", path)
+}
+
+// EncryptWithPassphrase encrypts data with a passphrase, used for key files and exports
+func EncryptWithPassphrase(data []byte, passphrase string) ([]byte, error) {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(data, recipient)
+}
+
+// DecryptWithPassphrase decrypts data encrypted by EncryptWithPassphrase
+func DecryptWithPassphrase(data []byte, passphrase string) ([]byte, error) {
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(data, identity)
+}
+
+// ReadPassphrase reads a passphrase from an environment variable, or prompts for it on the terminal.
+// confirm asks twice, for new passphrases.
+func ReadPassphrase(envVar, prompt string, confirm bool) (string, error) {
+	if passphrase := os.Getenv(envVar); passphrase != "" {
+		return passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to prompt for the passphrase, set %s", envVar)
+	}
+
+	fmt.Fprint(os.Stderr, prompt+": ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
+
+// WipeFile overwrites a file with random data before removing it. Missing files are ignored.
+// Journaling filesystems and SSDs may keep copies, this only makes recovery harder.
+func WipeFile(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(f, rand.Reader, info.Size()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to overwrite %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func encrypt(data []byte, recipient age.Recipient) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := age.Encrypt(buf, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decrypt(data []byte, identity age.Identity) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return io.ReadAll(r)
+}
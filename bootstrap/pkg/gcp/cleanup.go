@@ -0,0 +1,73 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/oauth2"
+	resourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+// DeleteServiceAccountWithOAuth removes the project IAM bindings and deletes the service account created by
+// CreateServiceAccountWithOAuth. A missing service account is not an error.
+func DeleteServiceAccountWithOAuth(ctx context.Context, projectID string, token *oauth2.Token, serviceAccountName string) error {
+	tokenSource := oauth2.StaticTokenSource(token)
+
+	iamService, err := iam.NewService(ctx, option.WithTokenSource(tokenSource))
+	if err != nil {
+		return fmt.Errorf("failed to create IAM service: %w", err)
+	}
+	rmService, err := resourcemanager.NewService(ctx, option.WithTokenSource(tokenSource))
+	if err != nil {
+		return fmt.Errorf("failed to create Resource Manager service: %w", err)
+	}
+
+	serviceAccountEmail := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", serviceAccountName, projectID)
+	if err := removeServiceAccountRoles(rmService, projectID, serviceAccountEmail); err != nil {
+		return fmt.Errorf("failed to remove IAM roles: %w", err)
+	}
+
+	serviceAccountResource := fmt.Sprintf("projects/%s/serviceAccounts/%s", projectID, serviceAccountEmail)
+	_, err = iamService.Projects.ServiceAccounts.Delete(serviceAccountResource).Context(ctx).Do()
+	if err != nil && !strings.Contains(err.Error(), "notFound") {
+		return fmt.Errorf("failed to delete service account: %w", err)
+	}
+	return nil
+}
+
+// removeServiceAccountRoles removes the service account from every project IAM binding
+func removeServiceAccountRoles(rmService *resourcemanager.Service, projectID, serviceAccountEmail string) error {
+	policy, err := rmService.Projects.GetIamPolicy(projectID, &resourcemanager.GetIamPolicyRequest{}).Do()
+	if err != nil {
+		return fmt.Errorf("failed to get IAM policy: %w", err)
+	}
+
+	serviceAccountMember := fmt.Sprintf("serviceAccount:%s", serviceAccountEmail)
+	changed := false
+	bindings := policy.Bindings[:0]
+	for _, binding := range policy.Bindings {
+		if i := slices.Index(binding.Members, serviceAccountMember); i >= 0 {
+			binding.Members = slices.Delete(binding.Members, i, i+1)
+			changed = true
+		}
+		if len(binding.Members) > 0 {
+			bindings = append(bindings, binding)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	policy.Bindings = bindings
+
+	_, err = rmService.Projects.SetIamPolicy(projectID, &resourcemanager.SetIamPolicyRequest{
+		Policy: policy,
+	}).Do()
+	if err != nil {
+		return fmt.Errorf("failed to set IAM policy: %w", err)
+	}
+	return nil
+}
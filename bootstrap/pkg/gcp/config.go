@@ -28,10 +28,14 @@ var GCPClientId string
 var GCPClientSecret string
 
 type GCPConfig struct {
-	ProjectID           string `json:"project_id" field_label:"GCP Project ID" field_required:"true" field_default:"cedille-464122"`
-	Region              string `json:"region" field_label:"GCP Region" field_required:"true" field_default:"us-central1"`
-	ServiceAccountJSON  string `json:"service_account_json"`
-	ServiceAccountEmail string `json:"service_account_email"`
+	ProjectID               string `json:"project_id" field_label:"GCP Project ID" field_required:"true" field_default:"cedille-464122"`
+	Region                  string `json:"region" field_label:"GCP Region" field_required:"true" field_default:"us-central1"`
+	ControlPlanes           string `json:"control_planes,omitempty" field_label:"GCP Control Planes (0 to use bare-metal machines)" field_default:"0"`
+	ControlPlaneMachineType string `json:"control_plane_machine_type,omitempty" field_label:"GCP Control Plane Machine Type" field_default:"e2-standard-4"`
+	ControlPlaneZone        string `json:"control_plane_zone,omitempty" field_label:"GCP Control Plane Zone (Optional, defaults to <region>-a)"`
+	ControlPlaneDiskSizeGB  string `json:"control_plane_disk_size_gb,omitempty" field_label:"GCP Control Plane Disk Size (GB)" field_default:"50"`
+	ServiceAccountJSON      string `json:"service_account_json"`
+	ServiceAccountEmail     string `json:"service_account_email"`
 }
 
 func NewConfig(projectID, region, serviceAccountJSON, serviceAccountEmail string) (*GCPConfig, error) {
@@ -200,6 +204,7 @@ func assignServiceAccountRoles(rmService *resourcemanager.Service, projectID, se
 		"roles/storage.admin",          // For bucket operations
 		"roles/compute.admin",          // For VM management
 		"roles/iam.serviceAccountUser", // For service account operations
+		"roles/dns.admin",              // For DNS-01 challenges and external-dns records in Cloud DNS
 	}
 
 	policy, err := rmService.Projects.GetIamPolicy(projectID, &resourcemanager.GetIamPolicyRequest{}).Do()
@@ -0,0 +1,188 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/terraform"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// TerraformRunner runs the terraform binary with the backend executor
+type TerraformRunner struct{}
+
+func (TerraformRunner) Apply(ctx context.Context, dir string, env map[string]string) error {
+	executor, err := terraform.NewExecutor(dir, env)
+	if err != nil {
+		return err
+	}
+	if err := executor.Init(ctx); err != nil {
+		return err
+	}
+	return executor.Apply(ctx)
+}
+
+func (TerraformRunner) Output(ctx context.Context, dir string, env map[string]string) (map[string]any, error) {
+	executor, err := terraform.NewExecutor(dir, env)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := executor.Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]any, len(outputs))
+	for key, meta := range outputs {
+		var value any
+		if err := json.Unmarshal(meta.Value, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse output %s: %w", key, err)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// GCPCloud is the Cloud of a project, authenticated with a service account
+type GCPCloud struct {
+	projectID string
+	region    string
+	storage   *storage.Service
+	compute   *compute.Service
+}
+
+func NewGCPCloud(ctx context.Context, projectID, region, serviceAccountJSON string) (*GCPCloud, error) {
+	credentials := option.WithCredentialsJSON([]byte(serviceAccountJSON))
+	storageService, err := storage.NewService(ctx, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage service: %w", err)
+	}
+	computeService, err := compute.NewService(ctx, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute service: %w", err)
+	}
+	return &GCPCloud{projectID: projectID, region: region, storage: storageService, compute: computeService}, nil
+}
+
+func (c *GCPCloud) EnsureBucket(ctx context.Context, bucket string) error {
+	_, err := c.storage.Buckets.Get(bucket).Context(ctx).Do()
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return err
+	}
+	_, err = c.storage.Buckets.Insert(c.projectID, &storage.Bucket{
+		Name:       bucket,
+		Location:   c.region,
+		Versioning: &storage.BucketVersioning{Enabled: true},
+		IamConfiguration: &storage.BucketIamConfiguration{
+			UniformBucketLevelAccess: &storage.BucketIamConfigurationUniformBucketLevelAccess{Enabled: true},
+		},
+	}).Context(ctx).Do()
+	return err
+}
+
+func (c *GCPCloud) PutObject(ctx context.Context, bucket, name string, data []byte) error {
+	_, err := c.storage.Objects.Insert(bucket, &storage.Object{Name: name}).Media(bytes.NewReader(data)).Context(ctx).Do()
+	return err
+}
+
+func (c *GCPCloud) EnsureImage(ctx context.Context, bucket, image, imageURL string) error {
+	_, err := c.compute.Images.Get(c.projectID, image).Context(ctx).Do()
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return err
+	}
+
+	// The tarball is streamed from the image factory to the bucket, compute images can only be created from GCS
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", imageURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", imageURL, resp.Status)
+	}
+	object := "talos-images/" + image + ".raw.tar.gz"
+	if _, err := c.storage.Objects.Insert(bucket, &storage.Object{Name: object}).Media(resp.Body).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to upload image: %w", err)
+	}
+
+	op, err := c.compute.Images.Insert(c.projectID, &compute.Image{
+		Name:            image,
+		RawDisk:         &compute.ImageRawDisk{Source: fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket, object)},
+		GuestOsFeatures: []*compute.GuestOsFeature{{Type: "VIRTIO_SCSI_MULTIQUEUE"}},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to create image: %w", err)
+	}
+	return c.waitGlobalOperation(ctx, op)
+}
+
+func (c *GCPCloud) waitGlobalOperation(ctx context.Context, op *compute.Operation) error {
+	name := op.Name
+	for op.Status != "DONE" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+		var err error
+		op, err = c.compute.GlobalOperations.Get(c.projectID, name).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to get operation %s: %w", name, err)
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return fmt.Errorf("operation %s failed: %s", name, op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// OperatorCIDR returns the /32 of the public address this host reaches the instances from, as answered in plain text
+// by the service at url
+func OperatorCIDR(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the public address of this host: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get the public address of this host: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the public address of this host: %w", err)
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(string(body)))
+	if err != nil || !addr.Is4() {
+		return "", fmt.Errorf("%s did not answer with an IPv4 address: %q", url, body)
+	}
+	return netip.PrefixFrom(addr, 32).String(), nil
+}
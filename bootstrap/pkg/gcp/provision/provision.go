@@ -0,0 +1,327 @@
+// Package provision creates a Talos control plane on GCP with the backend Terraform templates
+package provision
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/siderolabs/talos/pkg/machinery/api/storage"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/logger"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/terraform/templates"
+)
+
+// Config of the control plane
+type Config struct {
+	ProjectID          string
+	Region             string
+	Zone               string // defaults to <Region>-a
+	ServiceAccountJSON string
+	ClusterName        string
+	BucketName         string // Terraform state and Talos configs
+	ControlPlanes      int
+	MachineType        string
+	DiskSizeGB         int
+	DiskType           string
+	TalosVersion       string
+	Architecture       string
+	TalosImageURL      string // image factory gcp-<arch>.raw.tar.gz
+	WorkDir            string // rendered Terraform files
+	// TalosAPISourceRanges are the CIDRs allowed to reach the Talos API of the instances, see OperatorCIDR
+	TalosAPISourceRanges []string
+}
+
+// Node is a control plane instance
+type Node struct {
+	Name       string
+	InternalIP string
+	ExternalIP string
+}
+
+// Runner runs Terraform in a directory
+type Runner interface {
+	Apply(ctx context.Context, dir string, env map[string]string) error
+	Output(ctx context.Context, dir string, env map[string]string) (map[string]any, error)
+}
+
+// Cloud is the GCP API used around Terraform
+type Cloud interface {
+	EnsureBucket(ctx context.Context, bucket string) error
+	PutObject(ctx context.Context, bucket, name string, data []byte) error
+	// EnsureImage registers the image from the tarball at imageURL, through the bucket, unless it exists
+	EnsureImage(ctx context.Context, bucket, image, imageURL string) error
+}
+
+// Provisioner creates the network, the Talos image and the control plane instances
+type Provisioner struct {
+	cfg    Config
+	runner Runner
+	cloud  Cloud
+	logger logger.Logger
+}
+
+func NewProvisioner(cfg Config, runner Runner, cloud Cloud, logger logger.Logger) (*Provisioner, error) {
+	if cfg.ControlPlanes < 1 {
+		return nil, fmt.Errorf("at least one control plane is required")
+	}
+	if cfg.BucketName == "" || cfg.WorkDir == "" {
+		return nil, fmt.Errorf("bucket name and work directory are required")
+	}
+	// Anyone reaching the maintenance API of an instance could configure it before bootstrap does
+	if len(cfg.TalosAPISourceRanges) == 0 {
+		return nil, fmt.Errorf("the source ranges of the Talos API are required")
+	}
+	if cfg.Zone == "" {
+		cfg.Zone = cfg.Region + "-a"
+	}
+	if cfg.Architecture == "" {
+		cfg.Architecture = "amd64"
+	}
+	if cfg.DiskType == "" {
+		cfg.DiskType = "pd-balanced"
+	}
+	cfg.ClusterName = SanitizeName(cfg.ClusterName)
+	return &Provisioner{cfg: cfg, runner: runner, cloud: cloud, logger: logger}, nil
+}
+
+// TerraformEnvVars are the credentials of the Terraform Google provider
+func (c *Config) TerraformEnvVars() map[string]string {
+	return map[string]string{
+		"GOOGLE_CREDENTIALS": c.ServiceAccountJSON,
+		"GOOGLE_PROJECT":     c.ProjectID,
+	}
+}
+
+// ImageName is the compute image of the Talos version, named like the images of the backend
+func (c *Config) ImageName() string {
+	name := fmt.Sprintf("%s-talos-%s-%s", SanitizeName(c.ClusterName), strings.TrimPrefix(c.TalosVersion, "v"), c.Architecture)
+	return strings.ReplaceAll(name, ".", "-")
+}
+
+// NodeNames are the names of the control plane instances
+func (c *Config) NodeNames() []string {
+	names := make([]string, c.ControlPlanes)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-cp-%d", SanitizeName(c.ClusterName), i+1)
+	}
+	return names
+}
+
+// Provision creates the control plane and returns its instances. The instances boot without a machine config, in
+// Talos maintenance mode, so they are configured like bare-metal machines. Their Talos API is only reachable from the
+// TalosAPISourceRanges. Running it again only applies what changed.
+func (p *Provisioner) Provision(ctx context.Context) ([]Node, error) {
+	p.logger.Infof("Creating bucket %s...", p.cfg.BucketName)
+	if err := p.cloud.EnsureBucket(ctx, p.cfg.BucketName); err != nil {
+		return nil, fmt.Errorf("failed to create bucket: %w", err)
+	}
+
+	image := p.cfg.ImageName()
+	p.logger.Infof("Registering Talos image %s...", image)
+	if err := p.cloud.EnsureImage(ctx, p.cfg.BucketName, image, p.cfg.TalosImageURL); err != nil {
+		return nil, fmt.Errorf("failed to register Talos image: %w", err)
+	}
+
+	p.logger.Info("Creating network...")
+	infraDir := filepath.Join(p.cfg.WorkDir, "infrastructure")
+	if err := p.renderInfrastructure(infraDir); err != nil {
+		return nil, err
+	}
+	if err := p.runner.Apply(ctx, infraDir, p.cfg.TerraformEnvVars()); err != nil {
+		return nil, fmt.Errorf("failed to create network: %w", err)
+	}
+
+	names := p.cfg.NodeNames()
+	for _, name := range names {
+		// An empty user-data keeps the instance in maintenance mode until its config is applied
+		if err := p.cloud.PutObject(ctx, p.cfg.BucketName, "talos-configs/"+name+".yaml", nil); err != nil {
+			return nil, fmt.Errorf("failed to upload config of %s: %w", name, err)
+		}
+	}
+
+	p.logger.Infof("Creating %d control plane instance(s)...", len(names))
+	nodesDir := filepath.Join(p.cfg.WorkDir, "terraform", "gcp")
+	if err := p.renderNodes(nodesDir, image, names); err != nil {
+		return nil, err
+	}
+	if err := p.runner.Apply(ctx, nodesDir, p.cfg.TerraformEnvVars()); err != nil {
+		return nil, fmt.Errorf("failed to create instances: %w", err)
+	}
+
+	outputs, err := p.runner.Output(ctx, nodesDir, p.cfg.TerraformEnvVars())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get terraform outputs: %w", err)
+	}
+	nodes := make([]Node, 0, len(names))
+	for _, name := range names {
+		info, ok := outputs[name+"_info"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("output %s_info not found", name)
+		}
+		node := Node{Name: name, InternalIP: stringValue(info, "internal_ip"), ExternalIP: stringValue(info, "external_ip")}
+		if node.ExternalIP == "" {
+			return nil, fmt.Errorf("instance %s has no external IP", name)
+		}
+		p.logger.Successf("Instance %s: %s (internal %s)", name, node.ExternalIP, node.InternalIP)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (p *Provisioner) renderInfrastructure(dir string) error {
+	return renderTemplate("gcp/infrastructure.tf.tmpl", filepath.Join(dir, "main.tf"), struct {
+		ClusterName          string
+		BucketName           string
+		ProjectID            string
+		Region               string
+		AdditionalSubnets    []struct{ ResourceName, Name, Region, CIDR string }
+		TalosAPISourceRanges []string
+	}{
+		ClusterName:          p.cfg.ClusterName,
+		BucketName:           p.cfg.BucketName,
+		ProjectID:            p.cfg.ProjectID,
+		Region:               p.cfg.Region,
+		TalosAPISourceRanges: p.cfg.TalosAPISourceRanges,
+	})
+}
+
+// renderNodes renders the nodes in dir and the node module in ../../modules/node, where the node files look for it
+func (p *Provisioner) renderNodes(dir, image string, names []string) error {
+	moduleDir := filepath.Join(dir, "..", "..", "modules", "node")
+	moduleData := struct {
+		ClusterName       string
+		TalosImageProject string
+		TalosImageName    string
+	}{
+		ClusterName:       p.cfg.ClusterName,
+		TalosImageProject: p.cfg.ProjectID,
+		TalosImageName:    image,
+	}
+	for _, file := range []string{"main.tf", "variables.tf", "outputs.tf", "provider.tf"} {
+		if err := renderTemplate("gcp/modules/node/"+file+".tmpl", filepath.Join(moduleDir, file), moduleData); err != nil {
+			return err
+		}
+	}
+
+	if err := renderTemplate("gcp/nodes-main.tf.tmpl", filepath.Join(dir, "main.tf"), struct {
+		BucketName string
+		ProjectID  string
+		Region     string
+	}{
+		BucketName: p.cfg.BucketName,
+		ProjectID:  p.cfg.ProjectID,
+		Region:     p.cfg.Region,
+	}); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		err := renderTemplate("gcp/node.tf.tmpl", filepath.Join(dir, "node-"+name+".tf"), struct {
+			Name         string
+			Timestamp    string
+			BucketName   string
+			ClusterName  string
+			MachineType  string
+			Zone         string
+			Region       string
+			SubnetName   string
+			Role         string
+			Architecture string
+			DiskSizeGB   int
+			DiskType     string
+			Spot         bool
+			SpotAction   string
+		}{
+			Name:         name,
+			Timestamp:    time.Now().Format(time.RFC3339),
+			BucketName:   p.cfg.BucketName,
+			ClusterName:  p.cfg.ClusterName,
+			MachineType:  p.cfg.MachineType,
+			Zone:         p.cfg.Zone,
+			Region:       p.cfg.Region,
+			SubnetName:   p.cfg.ClusterName + "-subnet",
+			Role:         "control-plane",
+			Architecture: p.cfg.Architecture,
+			DiskSizeGB:   p.cfg.DiskSizeGB,
+			DiskType:     p.cfg.DiskType,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddToMachines hands the nodes over to the cluster bootstrap: they are added to the control planes of machines by
+// external IP, the address bootstrap reaches them on. It returns the nodes without a config, which are still in
+// maintenance mode until ApplyConfigsToNodes configures them.
+func AddToMachines(nodes []Node, machines *talos.Machines) []Node {
+	if machines.ControlPlanes == nil {
+		machines.ControlPlanes = make(map[string][]byte)
+	}
+	var unconfigured []Node
+	for _, node := range nodes {
+		// Machines that already have a config were configured by a previous run
+		if len(machines.ControlPlanes[node.ExternalIP]) > 0 {
+			continue
+		}
+		machines.ControlPlanes[node.ExternalIP] = make([]byte, 0)
+		unconfigured = append(unconfigured, node)
+	}
+	return unconfigured
+}
+
+// InstallDisk is the disk Talos is installed on: instances only have their boot disk
+func InstallDisk(disks []*storage.Disk) (string, error) {
+	if len(disks) == 0 {
+		return "", fmt.Errorf("no disk found")
+	}
+	if disks[0].BusPath != "" {
+		return disks[0].BusPath, nil
+	}
+	return disks[0].DeviceName, nil
+}
+
+func renderTemplate(templatePath, outputPath string, data any) error {
+	tmpl, err := template.ParseFS(templates.FS, templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to load template %s: %w", templatePath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	defer f.Close()
+	if err := tmpl.Execute(f, data); err != nil {
+		return fmt.Errorf("failed to execute template %s: %w", templatePath, err)
+	}
+	return nil
+}
+
+func stringValue(m map[string]any, key string) string {
+	if val, ok := m[key].(string); ok {
+		return val
+	}
+	return ""
+}
+
+// SanitizeName converts a name to a GCP resource name, like the backend does
+func SanitizeName(name string) string {
+	name = strings.ToLower(name)
+	name = regexp.MustCompile(`[^a-z0-9-]+`).ReplaceAllString(name, "-")
+	name = regexp.MustCompile(`^[^a-z]+`).ReplaceAllString(name, "")
+	name = strings.TrimRight(name, "-")
+	if name == "" {
+		return "stolos"
+	}
+	return name
+}
@@ -0,0 +1,254 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/siderolabs/talos/pkg/machinery/api/storage"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
+)
+
+type fakeRunner struct {
+	applied []string
+	outputs map[string]any
+	failDir string
+}
+
+func (r *fakeRunner) Apply(ctx context.Context, dir string, env map[string]string) error {
+	if env["GOOGLE_PROJECT"] == "" || env["GOOGLE_CREDENTIALS"] == "" {
+		return errors.New("missing credentials")
+	}
+	if dir == r.failDir {
+		return errors.New("apply failed")
+	}
+	r.applied = append(r.applied, dir)
+	return nil
+}
+
+func (r *fakeRunner) Output(ctx context.Context, dir string, env map[string]string) (map[string]any, error) {
+	return r.outputs, nil
+}
+
+type fakeCloud struct {
+	buckets []string
+	objects []string
+	images  []string
+}
+
+func (c *fakeCloud) EnsureBucket(ctx context.Context, bucket string) error {
+	c.buckets = append(c.buckets, bucket)
+	return nil
+}
+
+func (c *fakeCloud) PutObject(ctx context.Context, bucket, name string, data []byte) error {
+	c.objects = append(c.objects, bucket+"/"+name)
+	return nil
+}
+
+func (c *fakeCloud) EnsureImage(ctx context.Context, bucket, image, imageURL string) error {
+	c.images = append(c.images, image+" "+imageURL)
+	return nil
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string)            {}
+func (nopLogger) Debugf(string, ...any)   {}
+func (nopLogger) Info(string)             {}
+func (nopLogger) Infof(string, ...any)    {}
+func (nopLogger) Warn(string)             {}
+func (nopLogger) Warnf(string, ...any)    {}
+func (nopLogger) Error(string)            {}
+func (nopLogger) Errorf(string, ...any)   {}
+func (nopLogger) Success(string)          {}
+func (nopLogger) Successf(string, ...any) {}
+
+func testConfig(t *testing.T) Config {
+	return Config{
+		ProjectID:            "my-project",
+		Region:               "us-central1",
+		ServiceAccountJSON:   `{"project_id":"my-project"}`,
+		ClusterName:          "My_Cluster",
+		BucketName:           "my-cluster-bootstrap",
+		ControlPlanes:        2,
+		MachineType:          "e2-standard-4",
+		DiskSizeGB:           50,
+		TalosVersion:         "v1.11.1",
+		TalosImageURL:        "https://factory.example/gcp-amd64.raw.tar.gz",
+		WorkDir:              t.TempDir(),
+		TalosAPISourceRanges: []string{"203.0.113.7/32"},
+	}
+}
+
+func nodeOutput(internal, external string) map[string]any {
+	return map[string]any{"instance_name": "x", "internal_ip": internal, "external_ip": external}
+}
+
+func TestProvision(t *testing.T) {
+	cfg := testConfig(t)
+	runner := &fakeRunner{outputs: map[string]any{
+		"my-cluster-cp-1_info": nodeOutput("172.16.0.2", "34.1.1.1"),
+		"my-cluster-cp-2_info": nodeOutput("172.16.0.3", "34.1.1.2"),
+	}}
+	cloud := &fakeCloud{}
+	p, err := NewProvisioner(cfg, runner, cloud, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := p.Provision(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Node{
+		{Name: "my-cluster-cp-1", InternalIP: "172.16.0.2", ExternalIP: "34.1.1.1"},
+		{Name: "my-cluster-cp-2", InternalIP: "172.16.0.3", ExternalIP: "34.1.1.2"},
+	}
+	if !slices.Equal(nodes, want) {
+		t.Errorf("nodes = %v, want %v", nodes, want)
+	}
+
+	infraDir := filepath.Join(cfg.WorkDir, "infrastructure")
+	nodesDir := filepath.Join(cfg.WorkDir, "terraform", "gcp")
+	if !slices.Equal(runner.applied, []string{infraDir, nodesDir}) {
+		t.Errorf("applied %v, want the network then the instances", runner.applied)
+	}
+	if !slices.Equal(cloud.buckets, []string{cfg.BucketName}) {
+		t.Errorf("buckets = %v", cloud.buckets)
+	}
+	if !slices.Equal(cloud.images, []string{"my-cluster-talos-1-11-1-amd64 " + cfg.TalosImageURL}) {
+		t.Errorf("images = %v", cloud.images)
+	}
+	if !slices.Equal(cloud.objects, []string{
+		"my-cluster-bootstrap/talos-configs/my-cluster-cp-1.yaml",
+		"my-cluster-bootstrap/talos-configs/my-cluster-cp-2.yaml",
+	}) {
+		t.Errorf("objects = %v", cloud.objects)
+	}
+
+	assertContains(t, filepath.Join(infraDir, "main.tf"), `bucket = "my-cluster-bootstrap"`, `name                    = "my-cluster-vpc"`,
+		`source_ranges = ["203.0.113.7/32"]`)
+	assertContains(t, filepath.Join(nodesDir, "main.tf"), `prefix = "nodes/state"`, `project = "my-project"`)
+	assertContains(t, filepath.Join(nodesDir, "node-my-cluster-cp-1.tf"),
+		`role         = "control-plane"`, `zone         = "us-central1-a"`, `subnetwork_name = "my-cluster-subnet"`, `disk_size_gb = 50`)
+	assertContains(t, filepath.Join(cfg.WorkDir, "modules", "node", "main.tf"), `name    = "my-cluster-talos-1-11-1-amd64"`)
+}
+
+func TestProvisionErrors(t *testing.T) {
+	cfg := testConfig(t)
+	nodesDir := filepath.Join(cfg.WorkDir, "terraform", "gcp")
+
+	p, _ := NewProvisioner(cfg, &fakeRunner{failDir: nodesDir}, &fakeCloud{}, nopLogger{})
+	if _, err := p.Provision(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to create instances") {
+		t.Errorf("expected an instances error, got %v", err)
+	}
+
+	p, _ = NewProvisioner(cfg, &fakeRunner{outputs: map[string]any{
+		"my-cluster-cp-1_info": nodeOutput("172.16.0.2", ""),
+	}}, &fakeCloud{}, nopLogger{})
+	if _, err := p.Provision(context.Background()); err == nil || !strings.Contains(err.Error(), "no external IP") {
+		t.Errorf("expected an external IP error, got %v", err)
+	}
+
+	cfg.ControlPlanes = 0
+	if _, err := NewProvisioner(cfg, &fakeRunner{}, &fakeCloud{}, nopLogger{}); err == nil {
+		t.Error("expected an error without control planes")
+	}
+
+	cfg.ControlPlanes = 1
+	cfg.TalosAPISourceRanges = nil
+	if _, err := NewProvisioner(cfg, &fakeRunner{}, &fakeCloud{}, nopLogger{}); err == nil {
+		t.Error("expected an error without Talos API source ranges")
+	}
+}
+
+// TestAddToMachines covers the hand-off of the instances to ApplyConfigsToNodes and ExecuteBootstrap
+func TestAddToMachines(t *testing.T) {
+	machines := talos.Machines{ControlPlanes: map[string][]byte{"34.1.1.1": []byte("configured")}}
+	nodes := []Node{
+		{Name: "my-cluster-cp-1", InternalIP: "172.16.0.2", ExternalIP: "34.1.1.1"},
+		{Name: "my-cluster-cp-2", InternalIP: "172.16.0.3", ExternalIP: "34.1.1.2"},
+	}
+
+	unconfigured := AddToMachines(nodes, &machines)
+	if !slices.Equal(unconfigured, nodes[1:]) {
+		t.Errorf("unconfigured = %v, want only the node without a config", unconfigured)
+	}
+	if string(machines.ControlPlanes["34.1.1.1"]) != "configured" {
+		t.Error("the config of a configured node was replaced")
+	}
+	// ApplyConfigsToNodes configures the machines with an empty config
+	if config, ok := machines.ControlPlanes["34.1.1.2"]; !ok || len(config) > 0 {
+		t.Errorf("node without a config was not added for ApplyConfigsToNodes: %q, %v", config, ok)
+	}
+
+	// ExecuteBootstrap reaches the control planes through the talosconfig endpoints, the external IPs
+	bundle, err := talos.CreateMachineConfigBundle("34.1.1.2", &talos.TalosInfo{
+		ClusterName:       "my-cluster",
+		KubernetesVersion: "1.34.1",
+		TalosVersion:      "v1.11.1",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoints := bundle.TalosConfig().Contexts["my-cluster"].Endpoints; !slices.Contains(endpoints, "https://34.1.1.2:50000") {
+		t.Errorf("talosconfig endpoints = %v, want the external IP", endpoints)
+	}
+	if endpoint := bundle.ControlPlaneCfg.Cluster().Endpoint().String(); endpoint != "https://34.1.1.2:6443" {
+		t.Errorf("cluster endpoint = %s, want the external IP", endpoint)
+	}
+}
+
+func TestInstallDisk(t *testing.T) {
+	disk, err := InstallDisk([]*storage.Disk{{DeviceName: "/dev/sda", BusPath: "/pci0000:00/0000:00:03.0/virtio1/host0/target0:0:1/0:0:1:0"}})
+	if err != nil || disk != "/pci0000:00/0000:00:03.0/virtio1/host0/target0:0:1/0:0:1:0" {
+		t.Errorf("disk = %q, %v, want the bus path", disk, err)
+	}
+	if disk, _ := InstallDisk([]*storage.Disk{{DeviceName: "/dev/sda"}}); disk != "/dev/sda" {
+		t.Errorf("disk = %q, want the device name without a bus path", disk)
+	}
+	if _, err := InstallDisk(nil); err == nil {
+		t.Error("expected an error without disks")
+	}
+}
+
+func TestOperatorCIDR(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "203.0.113.7")
+	}))
+	defer server.Close()
+	cidr, err := OperatorCIDR(context.Background(), server.URL)
+	if err != nil || cidr != "203.0.113.7/32" {
+		t.Errorf("cidr = %q, %v", cidr, err)
+	}
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>")
+	}))
+	defer server.Close()
+	if _, err := OperatorCIDR(context.Background(), server.URL); err == nil {
+		t.Error("expected an error without an address")
+	}
+}
+
+func assertContains(t *testing.T, path string, substrings ...string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range substrings {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("%s does not contain %q:\n%s", filepath.Base(path), s, data)
+		}
+	}
+}
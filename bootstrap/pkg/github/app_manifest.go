@@ -405,6 +405,32 @@ func ListAppInstallations(ctx context.Context, appID int64, privateKeyPEM string
 	return installs, nil
 }
 
+// DeleteAppInstallation uninstalls the App, its installation tokens stop working
+func DeleteAppInstallation(ctx context.Context, appID int64, privateKeyPEM string, installationID string) error {
+	jwtToken, err := GenerateAppJWT(appID, privateKeyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, "https://api.github.com/app/installations/"+installationID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwtToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // GenerateAppJWT creates a signed JWT for authenticating as a GitHub App
 func GenerateAppJWT(appID int64, privateKeyPEM string) (string, error) {
 	block, _ := pem.Decode([]byte(privateKeyPEM))
@@ -28,24 +28,36 @@ func SetupHelmClient(logger *logging.Logger, kubeconfig []byte) (helmclient.Clie
 	return helmclient.NewClientFromKubeConf(&kubeclientOptions)
 }
 
-func HelmInstallArgo(helmClient helmclient.Client, releaseName string, namespace string, valuesFiles []string) (*release.Release, error) {
-	err := helmClient.AddOrUpdateChartRepo(repo.Entry{
-		Name: "argo",
-		URL:  "https://argoproj.github.io/argo-helm",
-	})
-	if err != nil {
-		return nil, err
+// ArgoCD chart installed by HelmInstallArgo
+const (
+	ArgoChartRepoURL = "https://argoproj.github.io/argo-helm"
+	ArgoChartName    = "argo-cd"
+	ArgoChartVersion = "8.5.2"
+)
+
+// HelmInstallArgo installs the ArgoCD chart from its repository, or from chartPath when set (air-gap bundle)
+func HelmInstallArgo(helmClient helmclient.Client, releaseName string, namespace string, valuesFiles []string, chartPath string) (*release.Release, error) {
+	chartName := chartPath
+	if chartName == "" {
+		err := helmClient.AddOrUpdateChartRepo(repo.Entry{
+			Name: "argo",
+			URL:  ArgoChartRepoURL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chartName = "argo/" + ArgoChartName
 	}
 
 	chartSpec := helmclient.ChartSpec{
 		ReleaseName: "argocd",
 		Description: "ArgoCD Deployed by Stolos Cloud",
-		ChartName:   "argo/argo-cd",
+		ChartName:   chartName,
 		Namespace:   namespace,
 		ValuesOptions: values.Options{
 			ValueFiles: valuesFiles,
 		},
-		Version:         "8.5.2",
+		Version:         ArgoChartVersion,
 		CreateNamespace: true,
 		DisableHooks:    false,
 		Wait:            true,
@@ -0,0 +1,291 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Resources whose readiness is tracked
+var (
+	DeploymentsGVR    = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	CRDsGVR           = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	ApplicationsGVR   = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+	ClusterIssuersGVR = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "clusterissuers"}
+	CertificatesGVR   = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+	CNPGClustersGVR   = schema.GroupVersionResource{Group: "postgresql.cnpg.io", Version: "v1", Resource: "clusters"}
+	AirwaysGVR        = schema.GroupVersionResource{Group: "yoke.cd", Version: "v1alpha1", Resource: "airways"}
+)
+
+// maxComponentEvents is the number of warning events kept per component
+const maxComponentEvents = 3
+
+// ReadyCheck reports whether an object is ready and a short status
+type ReadyCheck func(obj *unstructured.Unstructured) (bool, string)
+
+// Component is a resource whose readiness is waited for. An empty Name tracks every object of the resource in
+// Namespace, the component is then ready once there is at least one object and all of them are ready.
+type Component struct {
+	Title     string
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Name      string
+	Check     ReadyCheck
+	Optional  bool // reported, but not waited for
+}
+
+// ComponentStatus is the last observed state of a component
+type ComponentStatus struct {
+	Component *Component
+	Ready     bool
+	Status    string
+	Events    []string // latest warning events of the component
+}
+
+// WaitOptions configures WaitForComponents
+type WaitOptions struct {
+	Timeout  time.Duration
+	Interval time.Duration
+	// OnChange is called when the status or the events of a component change
+	OnChange func(ComponentStatus)
+}
+
+// WaitForComponents polls the components until the required ones are ready.
+// On timeout the error lists every component that is not ready with its status, its warning events and a command to
+// inspect it.
+func WaitForComponents(ctx context.Context, dynClient dynamic.Interface, client kubernetes.Interface, components []Component, opts WaitOptions) ([]ComponentStatus, error) {
+	if opts.Interval == 0 {
+		opts.Interval = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	statuses := make([]ComponentStatus, len(components))
+	for i := range components {
+		statuses[i] = ComponentStatus{Component: &components[i], Status: "pending"}
+	}
+
+	for {
+		allReady := true
+		for i := range statuses {
+			current := checkComponent(ctx, dynClient, client, statuses[i].Component)
+			if current.Status != statuses[i].Status || current.Ready != statuses[i].Ready || !slices.Equal(current.Events, statuses[i].Events) {
+				statuses[i] = current
+				if opts.OnChange != nil {
+					opts.OnChange(current)
+				}
+			}
+			allReady = allReady && (current.Ready || current.Component.Optional)
+		}
+		if allReady {
+			return statuses, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return statuses, fmt.Errorf("timed out after %s waiting for %s", opts.Timeout, Diagnose(statuses))
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// Diagnose describes the components that are not ready
+func Diagnose(statuses []ComponentStatus) string {
+	var b strings.Builder
+	for _, status := range statuses {
+		if status.Ready {
+			continue
+		}
+		c := status.Component
+		fmt.Fprintf(&b, "\n- %s: %s", c.Title, status.Status)
+		if c.Optional {
+			b.WriteString(" (optional)")
+		}
+		for _, event := range status.Events {
+			fmt.Fprintf(&b, "\n    event: %s", event)
+		}
+		fmt.Fprintf(&b, "\n    inspect: %s", c.describeCommand())
+	}
+	return b.String()
+}
+
+func (c *Component) describeCommand() string {
+	cmd := "kubectl --kubeconfig kubeconfig"
+	if c.Namespace != "" {
+		cmd += " -n " + c.Namespace
+	}
+	resource := c.GVR.Resource
+	if c.GVR.Group != "" {
+		resource += "." + c.GVR.Group
+	}
+	if c.Name == "" {
+		return fmt.Sprintf("%s get %s", cmd, resource)
+	}
+	return fmt.Sprintf("%s describe %s %s", cmd, resource, c.Name)
+}
+
+func checkComponent(ctx context.Context, dynClient dynamic.Interface, client kubernetes.Interface, c *Component) ComponentStatus {
+	status := ComponentStatus{Component: c}
+	resource := dynClient.Resource(c.GVR)
+	var ri dynamic.ResourceInterface = resource
+	if c.Namespace != "" {
+		ri = resource.Namespace(c.Namespace)
+	}
+
+	if c.Name != "" {
+		obj, err := ri.Get(ctx, c.Name, metav1.GetOptions{})
+		if err != nil {
+			status.Status = objectError(err)
+		} else {
+			status.Ready, status.Status = c.Check(obj)
+		}
+	} else {
+		list, err := ri.List(ctx, metav1.ListOptions{})
+		switch {
+		case err != nil:
+			status.Status = objectError(err)
+		case len(list.Items) == 0:
+			status.Status = "none created yet"
+		default:
+			var pending []string
+			for i := range list.Items {
+				if ready, msg := c.Check(&list.Items[i]); !ready {
+					pending = append(pending, fmt.Sprintf("%s %s", list.Items[i].GetName(), msg))
+				}
+			}
+			status.Ready = len(pending) == 0
+			status.Status = fmt.Sprintf("%d/%d ready", len(list.Items)-len(pending), len(list.Items))
+			if len(pending) > 0 {
+				status.Status += " (" + strings.Join(pending, ", ") + ")"
+			}
+		}
+	}
+
+	if !status.Ready && client != nil && c.Namespace != "" {
+		status.Events = warningEvents(ctx, client, c.Namespace, c.Name)
+	}
+	return status
+}
+
+// warningEvents returns the latest warning events of an object and of the objects it owns by name prefix
+// (the replica sets and pods of a deployment), of every object of the namespace when name is empty
+func warningEvents(ctx context.Context, client kubernetes.Interface, namespace, name string) []string {
+	events, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: "type=Warning"})
+	if err != nil {
+		return nil
+	}
+	var matching []corev1.Event
+	for _, event := range events.Items {
+		involved := event.InvolvedObject.Name
+		if name == "" || involved == name || strings.HasPrefix(involved, name+"-") {
+			matching = append(matching, event)
+		}
+	}
+	slices.SortFunc(matching, func(a, b corev1.Event) int {
+		return eventTime(b).Compare(eventTime(a))
+	})
+
+	var messages []string
+	for _, event := range matching {
+		msg := fmt.Sprintf("%s %s: %s", event.InvolvedObject.Name, event.Reason, strings.TrimSpace(event.Message))
+		if !slices.Contains(messages, msg) {
+			messages = append(messages, msg)
+		}
+		if len(messages) == maxComponentEvents {
+			break
+		}
+	}
+	return messages
+}
+
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+func objectError(err error) string {
+	if errors.IsNotFound(err) {
+		return "not created yet"
+	}
+	return err.Error()
+}
+
+// DeploymentReady checks that the up to date replicas of a deployment are available
+func DeploymentReady(obj *unstructured.Unstructured) (bool, string) {
+	generation := obj.GetGeneration()
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+	status := fmt.Sprintf("%d/%d available", available, replicas)
+	return observed >= generation && updated >= replicas && available >= replicas, status
+}
+
+// ConditionReady returns a check of a status condition, Ready for cert-manager and yoke, Established for CRDs
+func ConditionReady(conditionType string) ReadyCheck {
+	return func(obj *unstructured.Unstructured) (bool, string) {
+		conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]any)
+			if !ok || condition["type"] != conditionType {
+				continue
+			}
+			if condition["status"] == string(metav1.ConditionTrue) {
+				return true, conditionType
+			}
+			if msg, _ := condition["message"].(string); msg != "" {
+				return false, fmt.Sprintf("not %s: %s", conditionType, msg)
+			}
+			return false, fmt.Sprintf("not %s", conditionType)
+		}
+		return false, fmt.Sprintf("no %s condition yet", conditionType)
+	}
+}
+
+// ApplicationReady checks that an ArgoCD application is synced and healthy
+func ApplicationReady(obj *unstructured.Unstructured) (bool, string) {
+	sync, _, _ := unstructured.NestedString(obj.Object, "status", "sync", "status")
+	health, _, _ := unstructured.NestedString(obj.Object, "status", "health", "status")
+	if sync == "" {
+		sync = "Unknown"
+	}
+	if health == "" {
+		health = "Unknown"
+	}
+	status := sync + "/" + health
+	if health == "Degraded" {
+		if msg, _, _ := unstructured.NestedString(obj.Object, "status", "health", "message"); msg != "" {
+			status += ": " + msg
+		}
+	}
+	return sync == "Synced" && health == "Healthy", status
+}
+
+// CNPGClusterReady checks that every instance of a CloudNativePG cluster is ready
+func CNPGClusterReady(obj *unstructured.Unstructured) (bool, string) {
+	instances, _, _ := unstructured.NestedInt64(obj.Object, "spec", "instances")
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyInstances")
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase == "" {
+		phase = "pending"
+	}
+	return instances > 0 && ready >= instances, fmt.Sprintf("%s, %d/%d instances ready", phase, ready, instances)
+}
@@ -1,15 +1,58 @@
 package marshal
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/goccy/go-json"
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
 	"github.com/siderolabs/talos/pkg/machinery/config/bundle"
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/encryption"
 )
 
+// BundleFiles are the split config bundle files, init.yaml is optional
+var BundleFiles = []string{"init.yaml", "controlplane.yaml", "worker.yaml", "talosconfig"}
+
+// encryptionKey encrypts the files written by this package, nil writes plaintext
+var encryptionKey *encryption.Key
+
+// SetEncryptionKey enables encryption at rest of the state and config bundle files
+func SetEncryptionKey(key *encryption.Key) {
+	encryptionKey = key
+}
+
+// ReadFile reads a file written by WriteFile, decrypting it if needed. Plaintext files are returned as is.
+func ReadFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !encryption.IsEncrypted(data) {
+		return data, nil
+	}
+	if encryptionKey == nil {
+		return nil, fmt.Errorf("%s is encrypted and no encryption key is configured", filename)
+	}
+	return encryptionKey.Decrypt(data)
+}
+
+// WriteFile writes a secret file, encrypted when an encryption key is set
+func WriteFile(filename string, data []byte) error {
+	if encryptionKey != nil {
+		encrypted, err := encryptionKey.Encrypt(data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", filename, err)
+		}
+		data = encrypted
+	}
+	return os.WriteFile(filename, data, 0600)
+}
+
 func UnmarshalFromFile[T interface{}](filename string) (T, error) {
 	object := new(T)
-	configFile, err := os.ReadFile(filename)
+	configFile, err := ReadFile(filename)
 	if err != nil {
 		return *object, err
 	}
@@ -22,28 +65,67 @@ func MarshalToFile(filename string, object interface{}) error {
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(filename, jsonData, 0644)
+	err = WriteFile(filename, jsonData)
 	return err
 }
 
 // SaveSplitConfigBundleFiles take a config bundle and saves each composite part to individual files for later loading
 func SaveSplitConfigBundleFiles(configBundle *bundle.Bundle) error {
-	initBytes, err := configBundle.InitCfg.Bytes()
-	err = os.WriteFile("init.yaml", initBytes, 0644)
+	if configBundle.InitCfg != nil {
+		initBytes, _ := configBundle.InitCfg.Bytes()
+		if err := WriteFile("init.yaml", initBytes); err != nil {
+			return err
+		}
+	}
 	workerBytes, err := configBundle.WorkerCfg.Bytes()
-	err = os.WriteFile("worker.yaml", workerBytes, 0644)
+	err = WriteFile("worker.yaml", workerBytes)
 	controlPlaneBytes, err := configBundle.ControlPlaneCfg.Bytes()
-	err = os.WriteFile("controlplane.yaml", controlPlaneBytes, 0644)
+	err = WriteFile("controlplane.yaml", controlPlaneBytes)
 	talosBytes, err := configBundle.TalosCfg.Bytes()
-	err = os.WriteFile("talosconfig", talosBytes, 0644)
+	err = WriteFile("talosconfig", talosBytes)
 	return err
 }
 
 // ReadSplitConfigBundleFiles reconstructs multiple yaml configs into a ConfigBundle
 func ReadSplitConfigBundleFiles() (*bundle.Bundle, error) {
-	configBundleOpts := []bundle.Option{
-		bundle.WithExistingConfigs("./"),
+	configBundle := &bundle.Bundle{}
+
+	initBytes, err := ReadFile("init.yaml")
+	if err == nil {
+		if configBundle.InitCfg, err = configloader.NewFromBytes(initBytes); err != nil {
+			return nil, fmt.Errorf("failed to load init.yaml: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	controlPlaneBytes, err := ReadFile("controlplane.yaml")
+	if err != nil {
+		return nil, err
+	}
+	if configBundle.ControlPlaneCfg, err = configloader.NewFromBytes(controlPlaneBytes); err != nil {
+		return nil, fmt.Errorf("failed to load controlplane.yaml: %w", err)
+	}
+
+	workerBytes, err := ReadFile("worker.yaml")
+	if err != nil {
+		return nil, err
+	}
+	if configBundle.WorkerCfg, err = configloader.NewFromBytes(workerBytes); err != nil {
+		return nil, fmt.Errorf("failed to load worker.yaml: %w", err)
+	}
+
+	// talosconfig is optional
+	talosBytes, err := ReadFile("talosconfig")
+	if errors.Is(err, os.ErrNotExist) {
+		return configBundle, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if configBundle.TalosCfg, err = clientconfig.FromBytes(talosBytes); err != nil {
+		return nil, fmt.Errorf("failed to load talosconfig: %w", err)
 	}
 
-	return bundle.NewBundle(configBundleOpts...)
+	return configBundle, nil
 }
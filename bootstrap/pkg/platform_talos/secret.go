@@ -9,6 +9,7 @@ import (
 	machineryClient "github.com/siderolabs/talos/pkg/machinery/client"
 	"github.com/siderolabs/talos/pkg/machinery/client/config"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/k8s"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/marshal"
 	"github.com/stolos-cloud/stolos-bootstrap/pkg/talos"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -16,7 +17,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
-	"os"
 )
 
 type TalosSecretData struct {
@@ -38,7 +38,7 @@ func NewBootstrapSecret(machines talos.Machines) (*TalosSecretData, error) {
 	files := make(map[string][]byte)
 
 	for _, path := range secretFilePaths {
-		data, err := os.ReadFile(path)
+		data, err := marshal.ReadFile(path)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read %s: %w", path, err)
 		}
@@ -126,9 +126,9 @@ func (s *TalosSecretData) CreateOrUpdateSecret(ctx context.Context, client kuber
 
 // GetKubernetesClient creates a Kubernetes client from kubeconfig in Secret
 func (s *TalosSecretData) GetKubernetesClient() (kubernetes.Interface, error) {
-	kubeconfig, ok := s.BootstrapFiles["./kubeconfig.yaml"]
+	kubeconfig, ok := s.BootstrapFiles["kubeconfig"]
 	if !ok {
-		return nil, fmt.Errorf("kubeconfig.yaml not found in secret data")
+		return nil, fmt.Errorf("kubeconfig not found in secret data")
 	}
 
 	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
@@ -146,9 +146,9 @@ func (s *TalosSecretData) GetKubernetesClient() (kubernetes.Interface, error) {
 
 // GetTalosClient creates a Talos client from talosconfig in Secret
 func (s *TalosSecretData) GetTalosClient() (*machineryClient.Client, error) {
-	cfgData, ok := s.BootstrapFiles["./talosconfig.yaml"]
+	cfgData, ok := s.BootstrapFiles["talosconfig"]
 	if !ok {
-		return nil, fmt.Errorf("talosconfig.yaml not found in secret data")
+		return nil, fmt.Errorf("talosconfig not found in secret data")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,121 @@
+package pxe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cavaliergopher/grab/v3"
+)
+
+// iPXE binaries chained to from the firmware PXE ROM, by TFTP file name
+var ipxeBinaries = map[string]string{
+	"undionly.kpxe":  "https://boot.ipxe.org/undionly.kpxe",
+	"ipxe.efi":       "https://boot.ipxe.org/ipxe.efi",
+	"ipxe-arm64.efi": "https://boot.ipxe.org/arm64-efi/ipxe.efi",
+}
+
+// Assets are the netboot files of a Talos schematic
+type Assets struct {
+	Dir        string
+	Arch       string
+	Kernel     string // file name in Dir
+	Initramfs  string // file name in Dir
+	KernelArgs []string
+}
+
+// DownloadAssets downloads the kernel, initramfs and kernel cmdline of a schematic from the image factory,
+// and the iPXE binaries served over TFTP, from ipxeURL when set. Files already present are kept.
+func DownloadAssets(ctx context.Context, dir, factoryURL, ipxeURL, schematicID, talosVersion, arch string) (*Assets, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	// Assets are versioned by schematic, a new schematic downloads new files
+	prefix := fmt.Sprintf("%s-%s-", schematicID[:min(12, len(schematicID))], talosVersion)
+	assets := &Assets{
+		Dir:       dir,
+		Arch:      arch,
+		Kernel:    prefix + "kernel-" + arch,
+		Initramfs: prefix + "initramfs-" + arch + ".xz",
+	}
+
+	base := fmt.Sprintf("%s/image/%s/%s", strings.TrimSuffix(factoryURL, "/"), schematicID, talosVersion)
+	files := map[string]string{
+		assets.Kernel:    base + "/kernel-" + arch,
+		assets.Initramfs: base + "/initramfs-" + arch + ".xz",
+	}
+	for name, url := range ipxeBinaries {
+		if ipxeURL != "" {
+			url = strings.TrimSuffix(ipxeURL, "/") + "/" + name
+		}
+		files[name] = url
+	}
+
+	for name, url := range files {
+		if err := download(ctx, filepath.Join(dir, name), url); err != nil {
+			return nil, err
+		}
+	}
+
+	// The factory cmdline has the schematic's extra kernel args, ex: talos.events.sink
+	cmdline, err := fetchString(ctx, base+"/cmdline-metal-"+arch)
+	if err != nil {
+		return nil, err
+	}
+	assets.KernelArgs = strings.Fields(cmdline)
+
+	return assets, nil
+}
+
+// DownloadIPXEBinaries downloads the iPXE binaries from boot.ipxe.org, by TFTP file name
+func DownloadIPXEBinaries(ctx context.Context, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for name, url := range ipxeBinaries {
+		if err := download(ctx, filepath.Join(dir, name), url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func download(ctx context.Context, path, url string) error {
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		return nil
+	}
+	req, err := grab.NewRequest(path, url)
+	if err != nil {
+		return fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	resp := grab.DefaultClient.Do(req.WithContext(ctx))
+	if err := resp.Err(); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return nil
+}
+
+func fetchString(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,86 @@
+package pxe
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// pxeVendorOptions sets PXE_DISCOVERY_CONTROL to use the boot file name without a boot server discovery
+var pxeVendorOptions = []byte{6, 1, 8, 255}
+
+// handleDHCP answers PXE clients as a proxy DHCP server (RFC 4578): the offer carries no address,
+// only the boot file, the network's DHCP server keeps handing out the leases.
+func (s *Server) handleDHCP(conn net.PacketConn, peer net.Addr, m *dhcpv4.DHCPv4) {
+	if m.OpCode != dhcpv4.OpcodeBootRequest || !strings.HasPrefix(m.ClassIdentifier(), "PXEClient") {
+		return
+	}
+
+	var replyType dhcpv4.MessageType
+	switch m.MessageType() {
+	case dhcpv4.MessageTypeDiscover:
+		replyType = dhcpv4.MessageTypeOffer
+	case dhcpv4.MessageTypeRequest, dhcpv4.MessageTypeInform:
+		replyType = dhcpv4.MessageTypeAck
+	default:
+		return
+	}
+
+	bootFile := s.bootFile(m)
+	if bootFile == "" {
+		s.logger.Warnf("Ignoring PXE request of %s, unsupported client architecture %v", m.ClientHWAddr, m.ClientArch())
+		return
+	}
+
+	serverIP := s.config.ServerIP.To4()
+	reply, err := dhcpv4.NewReplyFromRequest(m,
+		dhcpv4.WithMessageType(replyType),
+		dhcpv4.WithServerIP(serverIP),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(serverIP)),
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXEClient")),
+		dhcpv4.WithOption(dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, pxeVendorOptions)),
+		dhcpv4.WithOptionCopied(m, dhcpv4.OptionClientMachineIdentifier),
+	)
+	if err != nil {
+		s.logger.Errorf("Failed to build PXE reply for %s: %v", m.ClientHWAddr, err)
+		return
+	}
+	reply.YourIPAddr = net.IPv4zero
+	reply.BootFileName = bootFile
+	reply.ServerHostName = serverIP.String()
+
+	// Clients without an address yet only see broadcasts
+	dest := peer
+	if udpPeer, ok := peer.(*net.UDPAddr); !ok || udpPeer.IP.IsUnspecified() || m.ClientIPAddr.IsUnspecified() {
+		dest = &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort}
+	}
+	if _, err := conn.WriteTo(reply.ToBytes(), dest); err != nil {
+		s.logger.Errorf("Failed to send PXE reply to %s: %v", m.ClientHWAddr, err)
+		return
+	}
+	s.logger.Debugf("Sent PXE %s to %s: %s", replyType, m.ClientHWAddr, bootFile)
+}
+
+// bootFile chains firmware PXE ROMs to iPXE over TFTP, and iPXE to the HTTP boot script
+func (s *Server) bootFile(m *dhcpv4.DHCPv4) string {
+	if slices.Contains(m.UserClass(), "iPXE") {
+		return s.baseURL() + "/boot.ipxe"
+	}
+
+	arch := iana.INTEL_X86PC
+	if archs := m.ClientArch(); len(archs) > 0 {
+		arch = archs[0]
+	}
+	switch arch {
+	case iana.INTEL_X86PC:
+		return "undionly.kpxe"
+	case iana.EFI_X86_64, iana.EFI_BC:
+		return "ipxe.efi"
+	case iana.EFI_ARM64:
+		return "ipxe-arm64.efi"
+	}
+	return ""
+}
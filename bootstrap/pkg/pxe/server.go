@@ -0,0 +1,263 @@
+// Package pxe netboots bare-metal servers into Talos.
+//
+// A proxy DHCP server answers the PXE requests of the firmware with an iPXE binary served over TFTP,
+// and iPXE with a script served over HTTP. The script boots the image factory kernel and initramfs,
+// with a talos.config kernel arg pointing back at the HTTP server for machines that have a config.
+// Machines without one boot in maintenance mode and are configured over the Talos API as usual,
+// which is always the case on their first boot: configs only exist once they are applied.
+//
+// Machine configs hold the cluster CA keys and are served over plain, unauthenticated HTTP.
+// The HTTP server only listens on ServerIP and only serves a config to the IP its machine booted with,
+// but the PXE network must still be trusted: anyone on it able to spoof that IP can fetch the config.
+package pxe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4/server4"
+	"github.com/pin/tftp"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/logger"
+)
+
+// Config of a PXE server
+type Config struct {
+	ServerIP  net.IP // advertised as next-server and in the iPXE URLs, must be reachable by the machines. The HTTP server listens on it.
+	HTTPPort  string
+	Interface string // optional, binds the proxy DHCP server to this interface
+	Assets    *Assets
+
+	// LookupConfig returns the machine config of a MAC address booted with an IP, machines without one boot in maintenance mode
+	LookupConfig func(mac, ip string) ([]byte, bool)
+	// OnBoot is called when a machine fetches its iPXE script, with the IP it got from DHCP
+	OnBoot func(mac, ip string)
+}
+
+type Server struct {
+	config Config
+	logger logger.Logger
+
+	mu   sync.Mutex
+	dhcp []*server4.Server
+	tftp *tftp.Server
+	http *http.Server
+}
+
+func NewServer(config Config, logger logger.Logger) (*Server, error) {
+	if config.ServerIP.To4() == nil {
+		return nil, fmt.Errorf("PXE server IP must be an IPv4 address, got %q", config.ServerIP)
+	}
+	if config.HTTPPort == "" {
+		return nil, fmt.Errorf("PXE server port is required")
+	}
+	if config.Assets == nil {
+		return nil, fmt.Errorf("PXE assets are required")
+	}
+	return &Server{config: config, logger: logger}, nil
+}
+
+// Start starts the proxy DHCP, TFTP and HTTP servers in the background.
+// DHCP and TFTP use privileged ports.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boot.ipxe", s.handleBootScript)
+	mux.HandleFunc("/ipxe", s.handleMachineScript)
+	mux.HandleFunc("/assets/", s.handleAsset)
+	mux.HandleFunc("/machineconfig", s.handleMachineConfig)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.config.ServerIP.String(), s.config.HTTPPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on HTTP port %s: %w", s.config.HTTPPort, err)
+	}
+	s.http = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("PXE HTTP server stopped: %v", err)
+		}
+	}()
+
+	tftpConn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: 69})
+	if err != nil {
+		s.shutdown()
+		return fmt.Errorf("failed to listen on TFTP port 69: %w", err)
+	}
+	s.tftp = tftp.NewServer(s.handleTFTP, nil)
+	s.tftp.SetTimeout(5 * time.Second)
+	go s.tftp.Serve(tftpConn)
+
+	// 67 gets the broadcast DISCOVER, 4011 the boot server REQUEST of some PXE ROMs
+	for _, port := range []int{67, 4011} {
+		srv, err := server4.NewServer(s.config.Interface, &net.UDPAddr{IP: net.IPv4zero, Port: port}, s.handleDHCP)
+		if err != nil {
+			s.shutdown()
+			return fmt.Errorf("failed to listen on DHCP port %d: %w", port, err)
+		}
+		s.dhcp = append(s.dhcp, srv)
+		go func() {
+			if err := srv.Serve(); err != nil && !errors.Is(err, net.ErrClosed) {
+				s.logger.Errorf("Proxy DHCP server on port %d stopped: %v", port, err)
+			}
+		}()
+	}
+
+	s.logger.Infof("PXE server listening (proxy DHCP 67/4011, TFTP 69, HTTP %s:%s)", s.config.ServerIP, s.config.HTTPPort)
+	return nil
+}
+
+// Stop stops all servers
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown()
+}
+
+func (s *Server) shutdown() {
+	for _, srv := range s.dhcp {
+		_ = srv.Close()
+	}
+	s.dhcp = nil
+	if s.tftp != nil {
+		s.tftp.Shutdown()
+		s.tftp = nil
+	}
+	if s.http != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = s.http.Shutdown(ctx)
+		s.http = nil
+	}
+}
+
+func (s *Server) baseURL() string {
+	return fmt.Sprintf("http://%s:%s", s.config.ServerIP, s.config.HTTPPort)
+}
+
+// handleTFTP serves the iPXE binaries
+func (s *Server) handleTFTP(filename string, rf io.ReaderFrom) error {
+	name := filepath.Base(filename)
+	if _, ok := ipxeBinaries[name]; !ok {
+		return fmt.Errorf("unknown file %s", filename)
+	}
+	f, err := os.Open(filepath.Join(s.config.Assets.Dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := rf.ReadFrom(f); err != nil {
+		s.logger.Warnf("TFTP transfer of %s failed: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+// handleBootScript is the first script iPXE runs, it chains to the script of the booting NIC
+func (s *Server) handleBootScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "#!ipxe\nchain %s/ipxe?mac=${netX/mac}&arch=${buildarch}\n", s.baseURL())
+}
+
+var machineScript = template.Must(template.New("ipxe").Parse(`#!ipxe
+kernel {{.BaseURL}}/assets/{{.Kernel}} {{.KernelArgs}}
+initrd {{.BaseURL}}/assets/{{.Initramfs}}
+boot
+`))
+
+// handleMachineScript boots Talos, with the machine's config when there is one
+func (s *Server) handleMachineScript(w http.ResponseWriter, r *http.Request) {
+	mac, err := net.ParseMAC(r.URL.Query().Get("mac"))
+	if err != nil {
+		http.Error(w, "invalid mac", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	if arch := ipxeArch(r.URL.Query().Get("arch")); arch != "" && arch != s.config.Assets.Arch {
+		s.logger.Warnf("Machine %s is %s but the Talos assets are %s, not booting it", mac, arch, s.config.Assets.Arch)
+		fmt.Fprintf(w, "#!ipxe\necho Stolos: no Talos image for %s\nexit\n", arch)
+		return
+	}
+
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if s.config.OnBoot != nil {
+		s.config.OnBoot(mac.String(), ip)
+	}
+
+	args := s.config.Assets.KernelArgs
+	if _, ok := s.lookupConfig(mac.String(), ip); ok {
+		args = append(args[:len(args):len(args)], fmt.Sprintf("talos.config=%s/machineconfig?m=%s", s.baseURL(), mac))
+		s.logger.Infof("Netbooting %s (%s) with its machine config", mac, ip)
+	} else {
+		s.logger.Infof("Netbooting %s (%s) in maintenance mode", mac, ip)
+	}
+
+	err = machineScript.Execute(w, map[string]string{
+		"BaseURL":    s.baseURL(),
+		"Kernel":     s.config.Assets.Kernel,
+		"Initramfs":  s.config.Assets.Initramfs,
+		"KernelArgs": strings.Join(args, " "),
+	})
+	if err != nil {
+		s.logger.Errorf("Failed to render iPXE script: %v", err)
+	}
+}
+
+func (s *Server) handleAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/assets/")
+	if name != s.config.Assets.Kernel && name != s.config.Assets.Initramfs {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(s.config.Assets.Dir, name))
+}
+
+// handleMachineConfig serves the machine config of a MAC address, the talos.config URL.
+// Only the IP the machine booted with gets it.
+func (s *Server) handleMachineConfig(w http.ResponseWriter, r *http.Request) {
+	mac, err := net.ParseMAC(r.URL.Query().Get("m"))
+	if err != nil {
+		http.Error(w, "invalid mac", http.StatusBadRequest)
+		return
+	}
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	config, ok := s.lookupConfig(mac.String(), ip)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.logger.Infof("Serving machine config of %s to %s", mac, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(config)
+}
+
+func (s *Server) lookupConfig(mac, ip string) ([]byte, bool) {
+	if s.config.LookupConfig == nil {
+		return nil, false
+	}
+	config, ok := s.config.LookupConfig(mac, ip)
+	return config, ok && len(config) > 0
+}
+
+// ipxeArch maps the iPXE ${buildarch} to a Talos architecture
+func ipxeArch(buildarch string) string {
+	switch buildarch {
+	// undionly.kpxe is a 32-bit build that boots 64-bit kernels
+	case "x86_64", "i386":
+		return "amd64"
+	case "arm64":
+		return "arm64"
+	}
+	return ""
+}
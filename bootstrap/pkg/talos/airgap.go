@@ -0,0 +1,84 @@
+package talos
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/siderolabs/talos/pkg/images"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	"github.com/siderolabs/talos/pkg/machinery/config/container"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+)
+
+// AirgapEnabled reports whether the bootstrap runs from an air-gap bundle
+func (t *TalosInfo) AirgapEnabled() bool {
+	return t.AirgapBundle != ""
+}
+
+// AirgapURL is the URL of the air-gap registry and file server, served by the bootstrap host
+func (t *TalosInfo) AirgapURL() string {
+	return fmt.Sprintf("http://%s", net.JoinHostPort(t.HTTPHostname, t.AirgapPort))
+}
+
+// ImageFileName returns the file name of the Talos boot image of an architecture: an ISO, or a disk image for arm64 SBCs
+func ImageFileName(arch string) string {
+	if arch == "arm64" {
+		return fmt.Sprintf("metal-%s.raw.xz", arch)
+	}
+	return fmt.Sprintf("metal-%s.iso", arch)
+}
+
+// FactoryFiles returns the image factory files of a schematic used by the bootstrap: the boot image and the
+// netboot assets
+func FactoryFiles(arch string) []string {
+	return []string{
+		ImageFileName(arch),
+		"kernel-" + arch,
+		"initramfs-" + arch + ".xz",
+		"cmdline-metal-" + arch,
+	}
+}
+
+// ListImages returns the images pulled by the nodes to install Talos and run Kubernetes
+func ListImages(talosInfos *TalosInfo) ([]string, error) {
+	configBundle, err := CreateMachineConfigBundle("127.0.0.1", talosInfos, "")
+	if err != nil {
+		return nil, err
+	}
+	versions := images.List(configBundle.ControlPlaneCfg)
+	return []string{
+		configBundle.ControlPlaneCfg.Machine().Install().Image(),
+		versions.Etcd,
+		versions.CoreDNS,
+		versions.Flannel,
+		versions.Kubelet,
+		versions.KubeAPIServer,
+		versions.KubeControllerManager,
+		versions.KubeProxy,
+		versions.KubeScheduler,
+		versions.Pause,
+	}, nil
+}
+
+// CreateRegistryMirrorsPatch creates a patch pulling the images of the registries from the air-gap registry.
+// The registry serves the images under their registry host, ex: /v2/ghcr.io/siderolabs/installer.
+func CreateRegistryMirrorsPatch(registryURL string, registries []string) configpatcher.Patch {
+	overridePath := true
+	mirrors := make(map[string]*v1alpha1.RegistryMirrorConfig, len(registries))
+	for _, registry := range registries {
+		mirrors[registry] = &v1alpha1.RegistryMirrorConfig{
+			MirrorEndpoints:    []string{fmt.Sprintf("%s/v2/%s", registryURL, registry)},
+			MirrorOverridePath: &overridePath,
+		}
+	}
+
+	cfg := &v1alpha1.Config{
+		ConfigVersion: "v1alpha1",
+		MachineConfig: &v1alpha1.MachineConfig{
+			MachineRegistries: v1alpha1.RegistriesConfig{
+				RegistryMirrors: mirrors,
+			},
+		},
+	}
+	return configpatcher.NewStrategicMergePatch(container.NewV1Alpha1(cfg))
+}
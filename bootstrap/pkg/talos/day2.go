@@ -0,0 +1,186 @@
+package talos
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cosi-project/runtime/pkg/safe"
+	"github.com/siderolabs/talos/pkg/cluster"
+	clusterapi "github.com/siderolabs/talos/pkg/machinery/api/cluster"
+	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
+	machineryClient "github.com/siderolabs/talos/pkg/machinery/client"
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/bundle"
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	"github.com/siderolabs/talos/pkg/machinery/config/container"
+	machineconf "github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	configres "github.com/siderolabs/talos/pkg/machinery/resources/config"
+	"google.golang.org/grpc/codes"
+)
+
+// NewClient creates a Talos API client authenticated with the talosconfig
+func NewClient(ctx context.Context, talosConfig *clientconfig.Config) (*machineryClient.Client, error) {
+	c, err := machineryClient.New(ctx, machineryClient.WithConfig(talosConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Talos client: %w", err)
+	}
+	return c, nil
+}
+
+// RenderNodeConfig renders the machine config of a new node from the bundle, without modifying the bundle
+func RenderNodeConfig(configBundle *bundle.Bundle, role, hostname, installDiskBusPath string) ([]byte, error) {
+	base := configBundle.WorkerCfg
+	if role == RoleControlPlane {
+		base = configBundle.ControlPlaneCfg
+	}
+	if base == nil {
+		return nil, fmt.Errorf("config bundle has no %s config", role)
+	}
+
+	patch := configpatcher.NewStrategicMergePatch(container.NewV1Alpha1(&v1alpha1.Config{
+		ConfigVersion: "v1alpha1",
+		MachineConfig: &v1alpha1.MachineConfig{
+			MachineNetwork: &v1alpha1.NetworkConfig{
+				NetworkHostname: hostname,
+			},
+			MachineInstall: &v1alpha1.InstallConfig{
+				InstallDiskSelector: &v1alpha1.InstallDiskSelector{
+					BusPath: installDiskBusPath,
+				},
+			},
+		},
+	}))
+	patched, err := configpatcher.Apply(configpatcher.WithConfig(base), []configpatcher.Patch{patch})
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch %s config: %w", role, err)
+	}
+	return patched.Bytes()
+}
+
+// NextHostname returns the first prefix-N hostname, from N = first, that none of the machine configs uses
+func NextHostname(machineConfigs map[string][]byte, prefix string, first int) string {
+	used := make(map[string]bool, len(machineConfigs))
+	for _, data := range machineConfigs {
+		if cfg, err := configloader.NewFromBytes(data); err == nil && cfg.Machine() != nil {
+			used[cfg.Machine().Network().Hostname()] = true
+		}
+	}
+	for i := first; ; i++ {
+		if hostname := fmt.Sprintf("%s-%d", prefix, i); !used[hostname] {
+			return hostname
+		}
+	}
+}
+
+// ApplyMaintenanceConfig applies a machine config to a machine in maintenance mode
+func ApplyMaintenanceConfig(ctx context.Context, ip string, machineConfig []byte) error {
+	c, err := machineryClient.New(ctx, machineryClient.WithTLSConfig(&tls.Config{
+		InsecureSkipVerify: true,
+	}), machineryClient.WithEndpoints(ip))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = c.ApplyConfiguration(ctx, &machineapi.ApplyConfigurationRequest{
+		Data: machineConfig,
+		Mode: machineapi.ApplyConfigurationRequest_AUTO,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply configuration to %s: %w", ip, err)
+	}
+	return nil
+}
+
+// GetActiveMachineConfig reads the machine config a node is running
+func GetActiveMachineConfig(ctx context.Context, c *machineryClient.Client, ip string) (config.Provider, error) {
+	active, err := safe.StateGet[*configres.MachineConfig](machineryClient.WithNode(ctx, ip), c.COSI,
+		configres.NewMachineConfigWithID(nil, configres.ActiveID).Metadata())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read machine config of %s: %w", ip, err)
+	}
+	return active.Provider(), nil
+}
+
+// CheckClusterHealth runs the Talos cluster health checks, progress messages are passed to onProgress
+func CheckClusterHealth(ctx context.Context, c *machineryClient.Client, timeout time.Duration, onProgress func(string)) error {
+	healthCheckClient, err := c.ClusterHealthCheck(ctx, timeout, &clusterapi.ClusterInfo{})
+	if err != nil {
+		return err
+	}
+	if err := healthCheckClient.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := healthCheckClient.Recv()
+		if err != nil {
+			if err == io.EOF || machineryClient.StatusCode(err) == codes.Canceled {
+				return nil
+			}
+			return err
+		}
+		if msg.GetMetadata().GetError() != "" {
+			return fmt.Errorf("cluster health check failed: %s", msg.GetMetadata().GetError())
+		}
+		if onProgress != nil && msg.GetMessage() != "" {
+			onProgress(msg.GetMessage())
+		}
+	}
+}
+
+// ResetNode wipes a node and reboots it, it boots back in maintenance mode from the ISO or PXE
+func ResetNode(ctx context.Context, c *machineryClient.Client, ip string) error {
+	return c.ResetGeneric(machineryClient.WithNode(ctx, ip), &machineapi.ResetRequest{
+		Graceful: false,
+		Reboot:   true,
+		Mode:     machineapi.ResetRequest_ALL,
+	})
+}
+
+// ClusterInfo describes the cluster topology from the machines cache, for the rotation helpers
+func (m *Machines) ClusterInfo() (cluster.Info, error) {
+	controlPlanes := make([]string, 0, len(m.ControlPlanes))
+	for ip := range m.ControlPlanes {
+		controlPlanes = append(controlPlanes, ip)
+	}
+	workers := make([]string, 0, len(m.Workers))
+	for ip := range m.Workers {
+		workers = append(workers, ip)
+	}
+
+	info := &machinesClusterInfo{}
+	var err error
+	if info.controlPlanes, err = cluster.IPsToNodeInfos(controlPlanes); err != nil {
+		return nil, err
+	}
+	if info.workers, err = cluster.IPsToNodeInfos(workers); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+type machinesClusterInfo struct {
+	controlPlanes []cluster.NodeInfo
+	workers       []cluster.NodeInfo
+}
+
+func (i *machinesClusterInfo) Nodes() []cluster.NodeInfo {
+	return append(append([]cluster.NodeInfo{}, i.controlPlanes...), i.workers...)
+}
+
+func (i *machinesClusterInfo) NodesByType(t machineconf.Type) []cluster.NodeInfo {
+	switch t {
+	case machineconf.TypeControlPlane:
+		return i.controlPlanes
+	case machineconf.TypeWorker:
+		return i.workers
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package talos
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	"github.com/siderolabs/talos/pkg/machinery/config/container"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+)
+
+// KubernetesAPIPort is the port of the Kubernetes API server on control planes
+const KubernetesAPIPort = "6443"
+
+// ValidateEndpoint checks the control plane endpoint options
+func (t *TalosInfo) ValidateEndpoint() error {
+	if t.ControlPlaneVIP != "" && t.ControlPlaneEndpoint != "" {
+		return fmt.Errorf("ControlPlaneVIP and ControlPlaneEndpoint are exclusive")
+	}
+	if t.ControlPlaneVIP != "" && net.ParseIP(t.ControlPlaneVIP) == nil {
+		return fmt.Errorf("ControlPlaneVIP %q is not an IP address", t.ControlPlaneVIP)
+	}
+	return nil
+}
+
+// ControlPlaneEndpointHost returns the host of the Kubernetes API endpoint: the VIP, the external endpoint,
+// or the first control plane when neither is set (single endpoint cluster).
+func (t *TalosInfo) ControlPlaneEndpointHost(firstControlPlaneIp string) string {
+	switch {
+	case t.ControlPlaneVIP != "":
+		return t.ControlPlaneVIP
+	case t.ControlPlaneEndpoint != "":
+		return t.ControlPlaneEndpoint
+	}
+	return firstControlPlaneIp
+}
+
+// ClusterEndpointURL returns the Kubernetes API URL of an endpoint host, which may include a port (load-balancer)
+func ClusterEndpointURL(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return "https://" + host
+	}
+	return "https://" + net.JoinHostPort(host, KubernetesAPIPort)
+}
+
+// EndpointHost returns the host of a cluster endpoint URL
+func EndpointHost(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	return u.Hostname(), nil
+}
+
+// EndpointCertSANs returns the cert SANs of an endpoint: its host and the base domain, when set
+func EndpointCertSANs(host, baseDomain string) []string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sans := []string{host}
+	if baseDomain != "" && baseDomain != host {
+		sans = append(sans, baseDomain)
+	}
+	return sans
+}
+
+// CreateVIPPatch creates a control plane patch announcing a shared virtual IP on an interface.
+// The VIP is merged into the interface's existing device config, its addressing (DHCP or static) is left as is.
+// Without an interface name, the VIP goes on the first configured device, or on the physical interface(s).
+func CreateVIPPatch(current *v1alpha1.Config, vip, iface string) configpatcher.Patch {
+	device := &v1alpha1.Device{
+		DeviceVIPConfig: &v1alpha1.DeviceVIPConfig{SharedIP: vip},
+	}
+	switch existing := existingDevice(current, iface); {
+	case existing != nil && existing.DeviceInterface != "":
+		device.DeviceInterface = existing.DeviceInterface
+	case existing != nil && existing.DeviceSelector != nil:
+		device.DeviceSelector = existing.DeviceSelector
+	case iface != "":
+		device.DeviceInterface = iface
+	default:
+		physical := true
+		device.DeviceSelector = &v1alpha1.NetworkDeviceSelector{NetworkDevicePhysical: &physical}
+	}
+
+	cfg := &v1alpha1.Config{
+		ConfigVersion: "v1alpha1",
+		MachineConfig: &v1alpha1.MachineConfig{
+			MachineNetwork: &v1alpha1.NetworkConfig{
+				NetworkInterfaces: v1alpha1.NetworkDeviceList{device},
+			},
+		},
+	}
+	return configpatcher.NewStrategicMergePatch(container.NewV1Alpha1(cfg))
+}
+
+// existingDevice returns the configured device of an interface, or the first device without an interface name
+func existingDevice(current *v1alpha1.Config, iface string) *v1alpha1.Device {
+	if current == nil || current.MachineConfig == nil || current.MachineConfig.MachineNetwork == nil {
+		return nil
+	}
+	for _, device := range current.MachineConfig.MachineNetwork.NetworkInterfaces {
+		if device == nil || device.Ignore() {
+			continue
+		}
+		if iface == "" || device.DeviceInterface == iface {
+			return device
+		}
+	}
+	return nil
+}
+
+// CreateCertSANsPatch creates a patch adding cert SANs to the Talos API and, for control planes, the Kubernetes API.
+// SANs already in the config are skipped, strategic merge appends lists.
+func CreateCertSANsPatch(current *v1alpha1.Config, sans []string, controlPlane bool) configpatcher.Patch {
+	var existingMachine, existingAPIServer []string
+	if current != nil && current.MachineConfig != nil {
+		existingMachine = current.MachineConfig.MachineCertSANs
+	}
+	if current != nil && current.ClusterConfig != nil && current.ClusterConfig.APIServerConfig != nil {
+		existingAPIServer = current.ClusterConfig.APIServerConfig.CertSANs
+	}
+
+	cfg := &v1alpha1.Config{
+		ConfigVersion: "v1alpha1",
+		MachineConfig: &v1alpha1.MachineConfig{},
+	}
+	for _, san := range sans {
+		if !slices.Contains(existingMachine, san) {
+			cfg.MachineConfig.MachineCertSANs = append(cfg.MachineConfig.MachineCertSANs, san)
+		}
+	}
+	if controlPlane {
+		apiServer := &v1alpha1.APIServerConfig{}
+		for _, san := range sans {
+			if !slices.Contains(existingAPIServer, san) {
+				apiServer.CertSANs = append(apiServer.CertSANs, san)
+			}
+		}
+		cfg.ClusterConfig = &v1alpha1.ClusterConfig{APIServerConfig: apiServer}
+	}
+	return configpatcher.NewStrategicMergePatch(container.NewV1Alpha1(cfg))
+}
+
+// CreateEndpointPatch creates a patch pointing a machine at a new cluster endpoint
+func CreateEndpointPatch(endpoint string) (configpatcher.Patch, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	cfg := &v1alpha1.Config{
+		ConfigVersion: "v1alpha1",
+		ClusterConfig: &v1alpha1.ClusterConfig{
+			ControlPlane: &v1alpha1.ControlPlaneConfig{
+				Endpoint: &v1alpha1.Endpoint{URL: u},
+			},
+		},
+	}
+	return configpatcher.NewStrategicMergePatch(container.NewV1Alpha1(cfg)), nil
+}
@@ -0,0 +1,127 @@
+package talos
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/cosi-project/runtime/pkg/safe"
+	"github.com/siderolabs/talos/pkg/machinery/api/storage"
+	machineryClient "github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+)
+
+const gigabyte = 1073741824
+
+// GetMachineIdentity reads the MAC addresses and SMBIOS serial of a machine in maintenance mode
+func GetMachineIdentity(ctx context.Context, ip string) (*MachineIdentity, error) {
+	c, err := machineryClient.New(ctx, machineryClient.WithTLSConfig(&tls.Config{
+		InsecureSkipVerify: true,
+	}), machineryClient.WithEndpoints(ip))
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	identity := &MachineIdentity{IP: ip}
+
+	links, err := safe.StateListAll[*network.LinkStatus](ctx, c.COSI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links of %s: %w", ip, err)
+	}
+	for link := range links.All() {
+		if !link.TypedSpec().Physical() {
+			continue
+		}
+		// Bonds rewrite the hardware address of their members, the permanent one is the burned-in MAC
+		for _, addr := range []net.HardwareAddr{net.HardwareAddr(link.TypedSpec().PermanentAddr), net.HardwareAddr(link.TypedSpec().HardwareAddr)} {
+			if len(addr) > 0 && !slices.Contains(identity.MACs, addr.String()) {
+				identity.MACs = append(identity.MACs, addr.String())
+			}
+		}
+	}
+
+	sysInfo, err := safe.StateGetByID[*hardware.SystemInformation](ctx, c.COSI, hardware.SystemInformationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system information of %s: %w", ip, err)
+	}
+	identity.Serial = sysInfo.TypedSpec().SerialNumber
+	identity.UUID = sysInfo.TypedSpec().UUID
+
+	return identity, nil
+}
+
+// Matches reports whether a discovered machine is this expected machine
+func (e ExpectedMachine) Matches(identity *MachineIdentity) bool {
+	if e.MAC == "" && e.IP == "" && e.Serial == "" {
+		return false
+	}
+	if e.IP != "" && e.IP != identity.IP {
+		return false
+	}
+	if e.Serial != "" && !strings.EqualFold(e.Serial, identity.Serial) {
+		return false
+	}
+	if e.MAC != "" {
+		found := false
+		for _, mac := range identity.MACs {
+			if strings.EqualFold(e.MAC, mac) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the role and identifiers of an expected machine
+func (e ExpectedMachine) Validate() error {
+	if e.MAC == "" && e.IP == "" && e.Serial == "" {
+		return fmt.Errorf("one of MAC, IP or Serial is required")
+	}
+	if e.MAC != "" {
+		if _, err := net.ParseMAC(e.MAC); err != nil {
+			return fmt.Errorf("invalid MAC %q: %w", e.MAC, err)
+		}
+	}
+	if e.IP != "" && net.ParseIP(e.IP) == nil {
+		return fmt.Errorf("invalid IP %q", e.IP)
+	}
+	if e.Role != RoleControlPlane && e.Role != RoleWorker {
+		return fmt.Errorf("role must be '%s' or '%s', got %q", RoleControlPlane, RoleWorker, e.Role)
+	}
+	return nil
+}
+
+// Select returns the first disk matching the selector
+func (s DiskSelector) Select(disks []*storage.Disk) (*storage.Disk, error) {
+	for _, disk := range disks {
+		if s.BusPath != "" && disk.BusPath != s.BusPath {
+			continue
+		}
+		if s.Name != "" && disk.DeviceName != s.Name {
+			continue
+		}
+		if s.Model != "" && !strings.EqualFold(disk.Model, s.Model) {
+			continue
+		}
+		if s.Serial != "" && !strings.EqualFold(disk.Serial, s.Serial) {
+			continue
+		}
+		if s.MinSizeGB > 0 && disk.Size/gigabyte < s.MinSizeGB {
+			continue
+		}
+		if s.MaxSizeGB > 0 && disk.Size/gigabyte > s.MaxSizeGB {
+			continue
+		}
+		return disk, nil
+	}
+	return nil, fmt.Errorf("no disk matches selector %+v among %d disks", s, len(disks))
+}
@@ -0,0 +1,397 @@
+package talos
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosi-project/runtime/pkg/resource"
+	"github.com/cosi-project/runtime/pkg/safe"
+	"github.com/cosi-project/runtime/pkg/state"
+	"github.com/olekukonko/tablewriter"
+	"github.com/siderolabs/talos/pkg/machinery/api/storage"
+	machineryClient "github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/perf"
+	timeres "github.com/siderolabs/talos/pkg/machinery/resources/time"
+)
+
+// Severity of a pre-flight finding
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Talos minimum requirements
+const (
+	minControlPlaneMemoryMiB = 2048
+	minWorkerMemoryMiB       = 1024
+)
+
+// Clock skew between a machine and the bootstrap host, which signs the cluster certificates
+const (
+	maxClockSkewWarning = 5 * time.Second
+	maxClockSkewError   = time.Minute
+)
+
+// MachineInventory is what the maintenance API tells about a machine before it is configured
+type MachineInventory struct {
+	IP           string
+	Role         string
+	InstallDisk  string // bus path
+	TalosVersion string
+	Arch         string
+	CPUs         uint32
+	MemoryMiB    uint64
+	Disks        []*storage.Disk
+	LinksUp      []string // physical links with carrier
+	DefaultRoute bool
+	DNSServers   []string
+	Addresses    []netip.Prefix // global addresses, the VIP must be in the subnet of one of them
+	TimeSynced   bool
+	ClockSkew    *time.Duration // machine clock minus the bootstrap host clock, nil when it could not be measured
+	Error        string         // set when the machine could not be queried
+}
+
+// PreflightRules are the requirements machines are checked against
+type PreflightRules struct {
+	TalosVersion  string
+	Arch          string
+	MinDiskSizeGB uint64
+	// ControlPlanes is the control plane count of the whole cluster, configured machines included.
+	// When zero, the control planes among the checked machines are counted.
+	ControlPlanes int
+	// Endpoint is the probe of the control plane VIP or external endpoint, nil when the first control plane is the endpoint
+	Endpoint *EndpointProbe
+	// Bootstrapped is set once the cluster runs, the VIP must then answer instead of being free
+	Bootstrapped bool
+}
+
+// EndpointProbe is what the bootstrap host found out about the control plane endpoint
+type EndpointProbe struct {
+	Host    string // VIP, or external endpoint host[:port]
+	VIP     bool
+	Error   string // set when the endpoint does not resolve
+	Answers bool   // the Kubernetes API port accepts connections
+}
+
+// PreflightFinding is one failed check
+type PreflightFinding struct {
+	Severity string
+	Machine  string // empty for cluster-wide checks
+	Check    string
+	Message  string
+}
+
+// PreflightReport is the result of RunPreflightChecks
+type PreflightReport struct {
+	Machines []*MachineInventory
+	Findings []PreflightFinding
+}
+
+// GetMachineInventory queries a machine in maintenance mode. Query errors are recorded in the inventory.
+func GetMachineInventory(ctx context.Context, ip, role, installDisk string) *MachineInventory {
+	inventory := &MachineInventory{IP: ip, Role: role, InstallDisk: installDisk}
+
+	c, err := machineryClient.New(ctx, machineryClient.WithTLSConfig(&tls.Config{
+		InsecureSkipVerify: true,
+	}), machineryClient.WithEndpoints(ip))
+	if err != nil {
+		inventory.Error = err.Error()
+		return inventory
+	}
+	defer c.Close()
+
+	version, err := c.Version(ctx)
+	if err != nil {
+		inventory.Error = fmt.Sprintf("maintenance API not reachable: %v", err)
+		return inventory
+	}
+	if msgs := version.GetMessages(); len(msgs) > 0 {
+		inventory.TalosVersion = msgs[0].GetVersion().GetTag()
+		inventory.Arch = msgs[0].GetVersion().GetArch()
+	}
+
+	disksRes, err := c.Disks(ctx)
+	if err == nil && len(disksRes.GetMessages()) > 0 {
+		inventory.Disks = disksRes.GetMessages()[0].Disks
+	}
+
+	if processors, err := safe.StateListAll[*hardware.Processor](ctx, c.COSI); err == nil {
+		for processor := range processors.All() {
+			inventory.CPUs += processor.TypedSpec().ThreadCount
+		}
+	}
+	if modules, err := safe.StateListAll[*hardware.MemoryModule](ctx, c.COSI); err == nil {
+		for module := range modules.All() {
+			inventory.MemoryMiB += uint64(module.TypedSpec().Size)
+		}
+	}
+
+	if links, err := safe.StateListAll[*network.LinkStatus](ctx, c.COSI); err == nil {
+		for link := range links.All() {
+			if link.TypedSpec().Physical() && link.TypedSpec().LinkState {
+				inventory.LinksUp = append(inventory.LinksUp, link.Metadata().ID())
+			}
+		}
+	}
+	if routes, err := safe.StateListAll[*network.RouteStatus](ctx, c.COSI); err == nil {
+		for route := range routes.All() {
+			spec := route.TypedSpec()
+			if spec.Family == nethelpers.FamilyInet4 && spec.Destination.Bits() == 0 && spec.Gateway.IsValid() {
+				inventory.DefaultRoute = true
+			}
+		}
+	}
+	if resolvers, err := safe.StateGetByID[*network.ResolverStatus](ctx, c.COSI, network.ResolverID); err == nil {
+		for _, server := range resolvers.TypedSpec().DNSServers {
+			inventory.DNSServers = append(inventory.DNSServers, server.String())
+		}
+	}
+	if addresses, err := safe.StateListAll[*network.AddressStatus](ctx, c.COSI); err == nil {
+		for address := range addresses.All() {
+			if spec := address.TypedSpec(); spec.Scope == nethelpers.ScopeGlobal {
+				inventory.Addresses = append(inventory.Addresses, spec.Address)
+			}
+		}
+	}
+	if status, err := safe.StateGetByID[*timeres.Status](ctx, c.COSI, timeres.StatusID); err == nil {
+		inventory.TimeSynced = status.TypedSpec().Synced
+	}
+	inventory.ClockSkew = measureClockSkew(ctx, c)
+
+	return inventory
+}
+
+// measureClockSkew compares the machine clock with the bootstrap host clock. The maintenance API has no time call:
+// the machine stamps its perf stats with its clock when it refreshes them, which is compared with the time they arrive.
+func measureClockSkew(ctx context.Context, c *machineryClient.Client) *time.Duration {
+	current, err := safe.StateGetByID[*perf.CPU](ctx, c.COSI, perf.CPUID)
+	if err != nil {
+		return nil
+	}
+	version := current.Metadata().Version()
+	refreshed, err := safe.StateWatchFor[*perf.CPU](ctx, c.COSI, perf.NewCPU().Metadata(), state.WithCondition(func(r resource.Resource) (bool, error) {
+		return !r.Metadata().Version().Equal(version), nil
+	}))
+	if err != nil {
+		return nil
+	}
+	skew := refreshed.Metadata().Updated().Sub(time.Now())
+	return &skew
+}
+
+// ProbeControlPlaneEndpoint checks the control plane VIP or external endpoint from the bootstrap host,
+// it returns nil when neither is set.
+func ProbeControlPlaneEndpoint(ctx context.Context, t *TalosInfo) *EndpointProbe {
+	probe := &EndpointProbe{Host: t.ControlPlaneEndpointHost("")}
+	if probe.Host == "" {
+		return nil
+	}
+	probe.VIP = t.ControlPlaneVIP != ""
+
+	host, port := probe.Host, KubernetesAPIPort
+	if h, p, err := net.SplitHostPort(probe.Host); err == nil {
+		host, port = h, p
+	}
+	if !probe.VIP {
+		if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+			probe.Error = err.Error()
+			return probe
+		}
+	}
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	if conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port)); err == nil {
+		probe.Answers = true
+		_ = conn.Close()
+	}
+	return probe
+}
+
+// RunPreflightChecks validates the machines against the rules and each other
+func RunPreflightChecks(machines []*MachineInventory, rules PreflightRules) *PreflightReport {
+	report := &PreflightReport{Machines: machines}
+	add := func(severity, machine, check, format string, args ...any) {
+		report.Findings = append(report.Findings, PreflightFinding{Severity: severity, Machine: machine, Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	controlPlanes := 0
+	versions := make(map[string][]string)
+	for _, m := range machines {
+		if m.Role == RoleControlPlane {
+			controlPlanes++
+		}
+		if m.Error != "" {
+			add(SeverityError, m.IP, "reachable", "%s", m.Error)
+			continue
+		}
+		versions[m.TalosVersion] = append(versions[m.TalosVersion], m.IP)
+
+		if rules.Arch != "" && m.Arch != "" && m.Arch != rules.Arch {
+			add(SeverityError, m.IP, "architecture", "machine is %s, the cluster image is %s", m.Arch, rules.Arch)
+		}
+		if rules.TalosVersion != "" && m.TalosVersion != rules.TalosVersion {
+			add(SeverityWarning, m.IP, "talos-version", "booted %s, %s will be installed", m.TalosVersion, rules.TalosVersion)
+		}
+
+		disk := findDisk(m.Disks, m.InstallDisk)
+		switch {
+		case disk == nil:
+			add(SeverityError, m.IP, "install-disk", "install disk %q not found", m.InstallDisk)
+		case rules.MinDiskSizeGB > 0 && disk.Size/gigabyte < rules.MinDiskSizeGB:
+			add(SeverityError, m.IP, "install-disk", "install disk %s is %d GB, at least %d GB required", disk.DeviceName, disk.Size/gigabyte, rules.MinDiskSizeGB)
+		}
+
+		minMemory := uint64(minWorkerMemoryMiB)
+		if m.Role == RoleControlPlane {
+			minMemory = minControlPlaneMemoryMiB
+		}
+		switch {
+		case m.MemoryMiB == 0:
+			add(SeverityWarning, m.IP, "memory", "memory size not reported by the firmware")
+		case m.MemoryMiB < minMemory:
+			add(SeverityError, m.IP, "memory", "%d MiB of memory, %s needs at least %d MiB", m.MemoryMiB, m.Role, minMemory)
+		}
+
+		if len(m.LinksUp) == 0 {
+			add(SeverityError, m.IP, "network", "no physical network link is up")
+		}
+		if !m.DefaultRoute {
+			add(SeverityWarning, m.IP, "network", "no default route, images cannot be pulled without a registry mirror")
+		}
+		if len(m.DNSServers) == 0 {
+			add(SeverityWarning, m.IP, "dns", "no DNS server")
+		}
+		if !m.TimeSynced {
+			add(SeverityWarning, m.IP, "time", "clock not synchronized, certificates may be rejected")
+		}
+		switch skew := m.ClockSkew; {
+		case skew == nil:
+			add(SeverityWarning, m.IP, "time", "clock skew with the bootstrap host could not be measured")
+		case skew.Abs() > maxClockSkewError:
+			add(SeverityError, m.IP, "time", "clock is %s off the bootstrap host, certificates will be rejected", skew.Round(time.Second))
+		case skew.Abs() > maxClockSkewWarning:
+			add(SeverityWarning, m.IP, "time", "clock is %s off the bootstrap host", skew.Round(time.Second))
+		}
+	}
+
+	if e := rules.Endpoint; e != nil {
+		switch {
+		case e.Error != "":
+			add(SeverityError, "", "endpoint", "control plane endpoint %s does not resolve: %s", e.Host, e.Error)
+		case e.VIP && e.Answers && !rules.Bootstrapped:
+			add(SeverityError, "", "endpoint", "control plane VIP %s is already in use", e.Host)
+		case e.Answers || !rules.Bootstrapped && e.VIP:
+			// reachable, or a free VIP the control planes will announce
+		case rules.Bootstrapped:
+			add(SeverityError, "", "endpoint", "control plane endpoint %s is not reachable", e.Host)
+		default:
+			add(SeverityWarning, "", "endpoint", "control plane endpoint %s does not accept connections yet, check the load-balancer forwards it to the control planes", e.Host)
+		}
+		if e.VIP {
+			checkVIPSubnet(machines, e.Host, add)
+		}
+	}
+
+	if rules.ControlPlanes > 0 {
+		controlPlanes = rules.ControlPlanes
+	}
+	switch {
+	case controlPlanes == 0:
+		add(SeverityError, "", "control-planes", "no control plane machine")
+	case controlPlanes%2 == 0:
+		add(SeverityWarning, "", "control-planes", "%d control planes, etcd needs an odd number to tolerate failures", controlPlanes)
+	}
+	if len(versions) > 1 {
+		var booted []string
+		for version, ips := range versions {
+			booted = append(booted, fmt.Sprintf("%s (%s)", version, strings.Join(ips, ", ")))
+		}
+		add(SeverityError, "", "talos-version", "machines booted different Talos versions: %s", strings.Join(booted, "; "))
+	}
+
+	return report
+}
+
+// checkVIPSubnet checks the VIP is in the subnet of every control plane, it cannot be announced otherwise
+func checkVIPSubnet(machines []*MachineInventory, vip string, add func(severity, machine, check, format string, args ...any)) {
+	addr, err := netip.ParseAddr(vip)
+	if err != nil {
+		return
+	}
+	for _, m := range machines {
+		if m.Role != RoleControlPlane || m.Error != "" || len(m.Addresses) == 0 {
+			continue
+		}
+		if !slices.ContainsFunc(m.Addresses, func(p netip.Prefix) bool { return p.Masked().Contains(addr) }) {
+			add(SeverityError, m.IP, "endpoint", "control plane VIP %s is not in the subnet of any address of the machine", vip)
+		}
+	}
+}
+
+// HasErrors reports whether a finding blocks the bootstrap
+func (r *PreflightReport) HasErrors() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Render writes the inventory and findings tables
+func (r *PreflightReport) Render(w io.Writer) {
+	machines := tablewriter.NewWriter(w)
+	machines.SetHeader([]string{"IP", "Role", "Talos", "Arch", "CPUs", "Memory (MiB)", "Install disk (GB)", "Links up", "Time synced", "Clock skew"})
+	for _, m := range r.Machines {
+		if m.Error != "" {
+			machines.Append([]string{m.IP, m.Role, "-", "-", "-", "-", "-", "-", "-", "-"})
+			continue
+		}
+		diskSize := "-"
+		if disk := findDisk(m.Disks, m.InstallDisk); disk != nil {
+			diskSize = strconv.FormatUint(disk.Size/gigabyte, 10)
+		}
+		clockSkew := "-"
+		if m.ClockSkew != nil {
+			clockSkew = m.ClockSkew.Round(100 * time.Millisecond).String()
+		}
+		machines.Append([]string{m.IP, m.Role, m.TalosVersion, m.Arch, strconv.FormatUint(uint64(m.CPUs), 10),
+			strconv.FormatUint(m.MemoryMiB, 10), diskSize, strings.Join(m.LinksUp, ","), strconv.FormatBool(m.TimeSynced), clockSkew})
+	}
+	machines.Render()
+
+	if len(r.Findings) == 0 {
+		fmt.Fprintln(w, "All pre-flight checks passed")
+		return
+	}
+	findings := tablewriter.NewWriter(w)
+	findings.SetHeader([]string{"Severity", "Machine", "Check", "Message"})
+	for _, f := range r.Findings {
+		machine := f.Machine
+		if machine == "" {
+			machine = "cluster"
+		}
+		findings.Append([]string{f.Severity, machine, f.Check, f.Message})
+	}
+	findings.Render()
+}
+
+// findDisk finds the install disk by bus path, or device name for machines without one
+func findDisk(disks []*storage.Disk, installDisk string) *storage.Disk {
+	for _, disk := range disks {
+		if disk.BusPath == installDisk || disk.DeviceName == installDisk {
+			return disk
+		}
+	}
+	return nil
+}
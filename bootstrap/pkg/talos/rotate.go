@@ -0,0 +1,156 @@
+package talos
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/siderolabs/crypto/x509"
+	"github.com/siderolabs/talos/pkg/machinery/client"
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/bundle"
+	"github.com/siderolabs/talos/pkg/machinery/config/encoder"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	"github.com/siderolabs/talos/pkg/machinery/role"
+	"github.com/siderolabs/talos/pkg/rotate/pki/kubernetes"
+	talosrotate "github.com/siderolabs/talos/pkg/rotate/pki/talos"
+)
+
+// RotateCAOptions selects the CAs rotated by RotateCA
+type RotateCAOptions struct {
+	Talos      bool
+	Kubernetes bool
+	DryRun     bool
+	Printf     func(format string, args ...any)
+}
+
+// RotateCA gracefully rotates the Talos API and/or Kubernetes API CAs of the cluster, node by node.
+// The config bundle is updated with the new CAs so nodes added later trust them, and the new talosconfig is returned.
+// It is also returned on errors after the Talos CA rotation, the bundle must be saved in every case.
+func RotateCA(ctx context.Context, c *client.Client, machines *Machines, configBundle *bundle.Bundle, opts RotateCAOptions) (*clientconfig.Config, error) {
+	clusterInfo, err := machines.ClusterInfo()
+	if err != nil {
+		return nil, err
+	}
+	newSecrets, err := secrets.NewBundle(secrets.NewFixedClock(time.Now()), config.TalosVersionCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate new CAs: %w", err)
+	}
+	encoderOpt := encoder.WithComments(encoder.CommentsDisabled)
+	talosConfig := configBundle.TalosConfig()
+
+	if opts.Talos {
+		newTalosConfig, err := talosrotate.Rotate(ctx, talosrotate.Options{
+			DryRun:        opts.DryRun,
+			CurrentClient: c,
+			ClusterInfo:   clusterInfo,
+			ContextName:   talosConfig.Context,
+			Endpoints:     c.GetEndpoints(),
+			NewTalosCA:    newSecrets.Certs.OS,
+			EncoderOption: encoderOpt,
+			Printf:        opts.Printf,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate Talos CA: %w", err)
+		}
+		if !opts.DryRun {
+			// the old talosconfig is rejected from now on, the bundle is updated before going further
+			if err := updateBundleCAs(configBundle, newSecrets.Certs.OS, nil); err != nil {
+				return nil, err
+			}
+			configBundle.TalosCfg = newTalosConfig
+			talosConfig = newTalosConfig
+
+			// the Kubernetes rotation talks to the nodes with the new Talos PKI
+			if c, err = NewClient(ctx, talosConfig); err != nil {
+				return talosConfig, err
+			}
+			defer c.Close()
+		}
+	}
+
+	if opts.Kubernetes {
+		err := kubernetes.Rotate(ctx, kubernetes.Options{
+			DryRun:          opts.DryRun,
+			TalosClient:     c,
+			ClusterInfo:     clusterInfo,
+			NewKubernetesCA: newSecrets.Certs.K8s,
+			EncoderOption:   encoderOpt,
+			Printf:          opts.Printf,
+		})
+		if err != nil {
+			return talosConfig, fmt.Errorf("failed to rotate Kubernetes CA: %w", err)
+		}
+		if !opts.DryRun {
+			if err := updateBundleCAs(configBundle, nil, newSecrets.Certs.K8s); err != nil {
+				return talosConfig, err
+			}
+		}
+	}
+
+	return talosConfig, nil
+}
+
+// updateBundleCAs replaces the CAs of the bundle configs, workers only get the certificates
+func updateBundleCAs(configBundle *bundle.Bundle, talosCA, kubernetesCA *x509.PEMEncodedCertificateAndKey) error {
+	patch := func(cfg config.Provider, withKeys bool) (config.Provider, error) {
+		if cfg == nil {
+			return nil, nil
+		}
+		return cfg.PatchV1Alpha1(func(c *v1alpha1.Config) error {
+			if talosCA != nil {
+				c.MachineConfig.MachineCA = caForRole(talosCA, withKeys)
+				c.MachineConfig.MachineAcceptedCAs = nil
+			}
+			if kubernetesCA != nil {
+				c.ClusterConfig.ClusterCA = caForRole(kubernetesCA, withKeys)
+				c.ClusterConfig.ClusterAcceptedCAs = nil
+			}
+			return nil
+		})
+	}
+
+	var err error
+	if configBundle.InitCfg, err = patch(configBundle.InitCfg, true); err != nil {
+		return fmt.Errorf("failed to update init config: %w", err)
+	}
+	if configBundle.ControlPlaneCfg, err = patch(configBundle.ControlPlaneCfg, true); err != nil {
+		return fmt.Errorf("failed to update control plane config: %w", err)
+	}
+	if configBundle.WorkerCfg, err = patch(configBundle.WorkerCfg, false); err != nil {
+		return fmt.Errorf("failed to update worker config: %w", err)
+	}
+	return nil
+}
+
+func caForRole(ca *x509.PEMEncodedCertificateAndKey, withKey bool) *x509.PEMEncodedCertificateAndKey {
+	if withKey {
+		return &x509.PEMEncodedCertificateAndKey{Crt: ca.Crt, Key: ca.Key}
+	}
+	return &x509.PEMEncodedCertificateAndKey{Crt: ca.Crt}
+}
+
+// RenewTalosconfig issues a new admin client certificate for the talosconfig from the Talos CA of the bundle
+func RenewTalosconfig(configBundle *bundle.Bundle) (*clientconfig.Config, error) {
+	if configBundle.ControlPlaneCfg == nil {
+		return nil, fmt.Errorf("config bundle has no control plane config")
+	}
+	secretsBundle := secrets.NewBundleFromConfig(secrets.NewFixedClock(time.Now()), configBundle.ControlPlaneCfg)
+	cert, err := secretsBundle.GenerateTalosAPIClientCertificate(role.MakeSet(role.Admin))
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue talosconfig certificate: %w", err)
+	}
+
+	talosConfig := configBundle.TalosConfig()
+	talosContext, ok := talosConfig.Contexts[talosConfig.Context]
+	if !ok {
+		return nil, fmt.Errorf("talosconfig has no context %q", talosConfig.Context)
+	}
+	talosContext.CA = base64.StdEncoding.EncodeToString(secretsBundle.Certs.OS.Crt)
+	talosContext.Crt = base64.StdEncoding.EncodeToString(cert.Crt)
+	talosContext.Key = base64.StdEncoding.EncodeToString(cert.Key)
+	return talosConfig, nil
+}
@@ -1,20 +1,30 @@
 package talos
 
 type TalosInfo struct {
-	ClusterName            string `json:"ClusterName" field_label:"Cluster Name" field_required:"true" field_default:"mycluster"`
-	KubernetesVersion      string `json:"KubernetesVersion" field_label:"Kubernetes versions" field_default:"1.34.1"`
-	TalosVersion           string `json:"TalosVersion" field_label:"Talos Version (Optional)" field_default:"v1.11.1"`
-	TalosArchitecture      string `json:"TalosArchitecture" field_label:"Talos architecture" field_default:"amd64" field_required:"true"`
-	TalosExtraArgs         string `json:"TalosExtraArgs" field_label:"Extra Linux cmdline args"`
-	TalosInstallDisk       string `json:"TalosInstallDisk" field_label:"Talos install disk" field_default:"/dev/sda" field_required:"true"`
-	TalosOverlayImage      string `json:"TalosOverlayImage" field_label:"Talos Overlay Image (For SBC, ex: siderolabs/sbc-rockchip)"`
-	TalosOverlayName       string `json:"TalosOverlayName" field_label:"Talos Overlay Name (For SBC, ex: turingrk1)"`
-	HTTPHostname           string `json:"HTTPHostname" field_label:"HTTP Machineconfig Server External Hostname" field_required:"true" field_default_func:"GetOutboundIP"`
-	HTTPPort               string `json:"HTTPPort" field_label:"HTTP Machineconfig Server Port" field_required:"true" field_default:"8082"`
-	PXEEnabled             string `json:"PXEEnabled" field_label:"PXE Server Enabled (true/false)" field_default:"false"`
-	PXEPort                string `json:"PXEPort" field_label:"PXE Server Port (Optional)"`
-	DeployLocalPathStorage string `json:"DeployLocalPathStorage" field_label:"Deploy Local-Path Provisioner? (true/false)" field_default:"false"`
-	StolosAirwayTag        string `json:"StolosAirwayTag" field_label:"Stolos Airway Version Tag" field_default_func:"GetLatestStolosRelease"`
+	ClusterName              string `json:"ClusterName" field_label:"Cluster Name" field_required:"true" field_default:"mycluster"`
+	KubernetesVersion        string `json:"KubernetesVersion" field_label:"Kubernetes versions" field_default:"1.34.1"`
+	TalosVersion             string `json:"TalosVersion" field_label:"Talos Version (Optional)" field_default:"v1.11.1"`
+	TalosArchitecture        string `json:"TalosArchitecture" field_label:"Talos architecture" field_default:"amd64" field_required:"true"`
+	TalosExtraArgs           string `json:"TalosExtraArgs" field_label:"Extra Linux cmdline args"`
+	TalosInstallDisk         string `json:"TalosInstallDisk" field_label:"Talos install disk" field_default:"/dev/sda" field_required:"true"`
+	TalosOverlayImage        string `json:"TalosOverlayImage" field_label:"Talos Overlay Image (For SBC, ex: siderolabs/sbc-rockchip)"`
+	TalosOverlayName         string `json:"TalosOverlayName" field_label:"Talos Overlay Name (For SBC, ex: turingrk1)"`
+	HTTPHostname             string `json:"HTTPHostname" field_label:"HTTP Machineconfig Server External Hostname" field_required:"true" field_default_func:"GetOutboundIP"`
+	HTTPPort                 string `json:"HTTPPort" field_label:"HTTP Machineconfig Server Port" field_required:"true" field_default:"8082"`
+	PXEEnabled               string `json:"PXEEnabled" field_label:"PXE Server Enabled (true/false)" field_default:"false"`
+	PXEPort                  string `json:"PXEPort" field_label:"PXE HTTP Server Port (iPXE scripts, netboot assets and machine configs)" field_default:"8083"`
+	ControlPlaneVIP          string `json:"ControlPlaneVIP" field_label:"Control Plane Shared VIP (Optional, free IP on the nodes' subnet)"`
+	ControlPlaneVIPInterface string `json:"ControlPlaneVIPInterface" field_label:"Control Plane VIP Interface (Optional, defaults to physical NICs)"`
+	ControlPlaneEndpoint     string `json:"ControlPlaneEndpoint" field_label:"Control Plane External Endpoint (Optional, DNS name or load-balancer host[:port])"`
+	PreflightMode            string `json:"PreflightMode" field_label:"Pre-flight check errors (block/warn)" field_default:"block"`
+	PreflightMinDiskSizeGB   string `json:"PreflightMinDiskSizeGB" field_label:"Minimum install disk size (GB)" field_default:"10"`
+	DiscoveryIgnoredIPs      string `json:"DiscoveryIgnoredIPs" field_label:"Ignored machine IPs (Optional, comma separated)"`
+	DeployLocalPathStorage   string `json:"DeployLocalPathStorage" field_label:"Deploy Local-Path Provisioner? (true/false)" field_default:"false"`
+	StolosAirwayTag          string `json:"StolosAirwayTag" field_label:"Stolos Airway Version Tag" field_default_func:"GetLatestStolosRelease"`
+	AirgapBundle             string `json:"AirgapBundle" field_label:"Air-gap bundle (Optional, made by 'bootstrap bundle create')"`
+	AirgapPort               string `json:"AirgapPort" field_label:"Air-gap registry and file server port" field_default:"5000"`
+	// AirgapRegistries are the registries mirrored by the air-gap registry, set from the bundle
+	AirgapRegistries []string `json:"AirgapRegistries,omitempty"`
 }
 
 type Machines struct {
@@ -28,3 +38,37 @@ type ServerConfig struct {
 }
 
 type MachinesDisks map[string]string
+
+// Roles of an ExpectedMachine
+const (
+	RoleControlPlane = "control-plane"
+	RoleWorker       = "worker"
+)
+
+// ExpectedMachine declares a machine of a non-interactive bootstrap.
+// A discovered machine matches when every identifier set here matches.
+type ExpectedMachine struct {
+	MAC    string       `json:"MAC,omitempty"`
+	IP     string       `json:"IP,omitempty"`
+	Serial string       `json:"Serial,omitempty"`
+	Role   string       `json:"Role"`
+	Disk   DiskSelector `json:"Disk"`
+}
+
+// DiskSelector picks the install disk of an ExpectedMachine, the first disk matching every set criteria is used.
+type DiskSelector struct {
+	BusPath   string `json:"BusPath,omitempty"`
+	Name      string `json:"Name,omitempty"` // device name, ex: /dev/sda
+	Model     string `json:"Model,omitempty"`
+	Serial    string `json:"Serial,omitempty"`
+	MinSizeGB uint64 `json:"MinSizeGB,omitempty"`
+	MaxSizeGB uint64 `json:"MaxSizeGB,omitempty"`
+}
+
+// MachineIdentity is what the maintenance API tells about a discovered machine
+type MachineIdentity struct {
+	IP     string
+	MACs   []string
+	Serial string
+	UUID   string
+}
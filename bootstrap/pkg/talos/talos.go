@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"io"
 	"net"
 	"strings"
 	"time"
@@ -12,7 +11,6 @@ import (
 	factoryClient "github.com/siderolabs/image-factory/pkg/client"
 	eventsapi "github.com/siderolabs/siderolink/api/events"
 	"github.com/siderolabs/siderolink/pkg/events"
-	clusterapi "github.com/siderolabs/talos/pkg/machinery/api/cluster"
 	"github.com/siderolabs/talos/pkg/machinery/api/machine"
 	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
 	"github.com/siderolabs/talos/pkg/machinery/api/storage"
@@ -28,6 +26,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	talosgen "github.com/siderolabs/talos/cmd/talosctl/cmd/mgmt/gen"
 	"github.com/siderolabs/talos/pkg/machinery/config/generate"
@@ -49,7 +48,7 @@ func (h *EventHandler) HandleEvent(ctx context.Context, event events.Event) erro
 	return h.HandleEventFunc(ctx, event)
 }
 
-func ApplyConfigsToNodes(machineCache *Machines, machinesDisks MachinesDisks, talosInfos *TalosInfo, configBundle *bundle.Bundle) (*bundle.Bundle, error) {
+func ApplyConfigsToNodes(machineCache *Machines, machinesDisks MachinesDisks, talosInfos *TalosInfo, baseDomain string, configBundle *bundle.Bundle) (*bundle.Bundle, error) {
 	var err error
 
 	// CONTROLPLANES
@@ -57,7 +56,7 @@ func ApplyConfigsToNodes(machineCache *Machines, machinesDisks MachinesDisks, ta
 	for ip, conf := range machineCache.ControlPlanes {
 
 		if configBundle == nil {
-			configBundle, err = CreateMachineConfigBundle(ip, talosInfos)
+			configBundle, err = CreateMachineConfigBundle(ip, talosInfos, baseDomain)
 			if err != nil {
 				return configBundle, err
 			}
@@ -209,9 +208,15 @@ func CreateMachineryClientFromTalosconfig(talosConfig *config.Config) machineryC
 	return *machinery
 }
 
-func CreateMachineConfigBundle(controlPlaneIp string, talosInfos *TalosInfo) (*bundle.Bundle, error) {
+// CreateMachineConfigBundle generates the cluster configs. The Kubernetes API endpoint is the control plane VIP or
+// external endpoint when set, otherwise the first control plane.
+func CreateMachineConfigBundle(controlPlaneIp string, talosInfos *TalosInfo, baseDomain string) (*bundle.Bundle, error) {
+	if err := talosInfos.ValidateEndpoint(); err != nil {
+		return nil, err
+	}
 
 	var secretsBundle *secrets.Bundle
+	endpointHost := talosInfos.ControlPlaneEndpointHost(controlPlaneIp)
 
 	genOptions := []generate.Option{
 		generate.WithSecretsBundle(secretsBundle),
@@ -219,7 +224,7 @@ func CreateMachineConfigBundle(controlPlaneIp string, talosInfos *TalosInfo) (*b
 			v1alpha1.WithKubeSpan(),
 		),
 		generate.WithInstallImage(fmt.Sprintf("ghcr.io/siderolabs/installer:%s", talosInfos.TalosVersion)),
-		// generate.WithAdditionalSubjectAltNames([]string{_____}),// TODO : Add the right SAN for external IP / DNS
+		generate.WithAdditionalSubjectAltNames(EndpointCertSANs(endpointHost, baseDomain)),
 		generate.WithPersist(true),
 		generate.WithClusterDiscovery(true),
 	}
@@ -227,7 +232,7 @@ func CreateMachineConfigBundle(controlPlaneIp string, talosInfos *TalosInfo) (*b
 	configBundle, err := talosgen.GenerateConfigBundle(
 		genOptions,
 		talosInfos.ClusterName,
-		fmt.Sprintf("https://%s:6443", controlPlaneIp),
+		ClusterEndpointURL(endpointHost),
 		talosInfos.KubernetesVersion,
 		[]string{},
 		[]string{},
@@ -237,9 +242,24 @@ func CreateMachineConfigBundle(controlPlaneIp string, talosInfos *TalosInfo) (*b
 		return nil, err
 	}
 
+	// The VIP only moves the Kubernetes API, Talos API clients keep talking to the nodes
 	talosConfig := configBundle.TalosConfig().Contexts[talosInfos.ClusterName]
 	talosConfig.Endpoints = append(talosConfig.Endpoints, fmt.Sprintf("https://%s:50000", controlPlaneIp))
 
+	if talosInfos.ControlPlaneVIP != "" {
+		vipPatch := CreateVIPPatch(configBundle.ControlPlaneCfg.RawV1Alpha1(), talosInfos.ControlPlaneVIP, talosInfos.ControlPlaneVIPInterface)
+		if err := configBundle.ApplyPatches([]configpatcher.Patch{vipPatch}, true, false); err != nil {
+			return nil, fmt.Errorf("failed to add control plane VIP: %w", err)
+		}
+	}
+
+	if talosInfos.AirgapEnabled() {
+		mirrorsPatch := CreateRegistryMirrorsPatch(talosInfos.AirgapURL(), talosInfos.AirgapRegistries)
+		if err := configBundle.ApplyPatches([]configpatcher.Patch{mirrorsPatch}, true, true); err != nil {
+			return nil, fmt.Errorf("failed to add air-gap registry mirrors: %w", err)
+		}
+	}
+
 	return configBundle, nil
 }
 
@@ -248,6 +268,7 @@ func CreateFactoryClient() *factoryClient.Client {
 	return factory
 }
 
+// ExecuteBootstrap bootstraps etcd, a cluster that is already bootstrapped is not an error
 func ExecuteBootstrap(talosApiClient machineryClient.Client) error {
 
 	bootrapRequest := machine.BootstrapRequest{
@@ -258,6 +279,9 @@ func ExecuteBootstrap(talosApiClient machineryClient.Client) error {
 	for {
 		err := talosApiClient.Bootstrap(context.Background(), &bootrapRequest)
 		if err != nil {
+			if status.Code(err) == codes.AlreadyExists {
+				return nil
+			}
 			if !strings.Contains(err.Error(), "connection refused") {
 				return err
 			}
@@ -271,29 +295,10 @@ func ExecuteBootstrap(talosApiClient machineryClient.Client) error {
 }
 
 func RunBasicClusterHealthCheck(talosApiClient machineryClient.Client, loggerRef *logging.Logger) {
-	healthCheckClient, err := talosApiClient.ClusterHealthCheck(context.Background(), 20*time.Minute, &clusterapi.ClusterInfo{})
-	if err != nil {
+	if err := CheckClusterHealth(context.Background(), &talosApiClient, 20*time.Minute, nil); err != nil {
+		(*loggerRef).Errorf("Cluster health check failed: %s", err)
 		panic(err)
 	}
-
-	if err := healthCheckClient.CloseSend(); err != nil {
-		panic(err)
-	}
-
-	for {
-		msg, err := healthCheckClient.Recv()
-		if err != nil {
-			if err == io.EOF || machineryClient.StatusCode(err) == codes.Canceled {
-				break
-			}
-			panic(err)
-		}
-
-		if msg.GetMetadata().GetError() != "" {
-			(*loggerRef).Errorf("Cluster health check failed: %s", msg.GetMetadata().GetError())
-			panic(msg.GetMetadata().GetError())
-		}
-	}
 }
 
 func GetDisks(ctx context.Context, ip string) ([]*storage.Disk, error) {
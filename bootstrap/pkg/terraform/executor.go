@@ -10,8 +10,14 @@ import (
 	"strings"
 
 	"github.com/hashicorp/terraform-exec/tfexec"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const tracerName = "github.com/stolos-cloud/stolos-bootstrap/pkg/terraform"
+
 // CheckTerraformInstalled verifies that Terraform is installed and available
 func CheckTerraformInstalled() error {
 	_, err := exec.LookPath("terraform")
@@ -56,15 +62,21 @@ func NewExecutor(workDir string, envVars map[string]string) (*Executor, error) {
 	}, nil
 }
 
-func (e *Executor) Init(ctx context.Context) error {
+func (e *Executor) Init(ctx context.Context) (err error) {
+	ctx, span := e.startSpan(ctx, "init")
+	defer func() { endSpan(span, err) }()
+
 	if err := e.tf.Init(ctx); err != nil {
 		return fmt.Errorf("terraform init failed: %w", err)
 	}
 	return nil
 }
 
-func (e *Executor) Plan(ctx context.Context) (bool, error) {
-	hasChanges, err := e.tf.Plan(ctx)
+func (e *Executor) Plan(ctx context.Context) (hasChanges bool, err error) {
+	ctx, span := e.startSpan(ctx, "plan")
+	defer func() { endSpan(span, err) }()
+
+	hasChanges, err = e.tf.Plan(ctx)
 	if err != nil {
 		return false, fmt.Errorf("terraform plan failed: %w", err)
 	}
@@ -72,16 +84,19 @@ func (e *Executor) Plan(ctx context.Context) (bool, error) {
 }
 
 // PlanWithOutput runs terraform plan and returns the plan output as a string
-func (e *Executor) PlanWithOutput(ctx context.Context) (bool, string, error) {
+func (e *Executor) PlanWithOutput(ctx context.Context) (hasChanges bool, planOutput string, err error) {
+	ctx, span := e.startSpan(ctx, "plan")
+	defer func() { endSpan(span, err) }()
+
 	// Use Plan with output redirect
 	planFile := "tfplan.out"
-	hasChanges, err := e.tf.Plan(ctx, tfexec.Out(planFile))
+	hasChanges, err = e.tf.Plan(ctx, tfexec.Out(planFile))
 	if err != nil {
 		return false, "", fmt.Errorf("terraform plan failed: %w", err)
 	}
 
 	// Show the plan in human-readable format
-	planOutput, err := e.tf.ShowPlanFileRaw(ctx, planFile)
+	planOutput, err = e.tf.ShowPlanFileRaw(ctx, planFile)
 	if err != nil {
 		return hasChanges, "", fmt.Errorf("terraform show failed: %w", err)
 	}
@@ -90,7 +105,10 @@ func (e *Executor) PlanWithOutput(ctx context.Context) (bool, string, error) {
 }
 
 // GetPlanJSON returns the JSON representation of the last plan
-func (e *Executor) GetPlanJSON(ctx context.Context) ([]byte, error) {
+func (e *Executor) GetPlanJSON(ctx context.Context) (_ []byte, err error) {
+	ctx, span := e.startSpan(ctx, "show")
+	defer func() { endSpan(span, err) }()
+
 	planFile := "tfplan.out"
 	plan, err := e.tf.ShowPlanFile(ctx, planFile)
 	if err != nil {
@@ -107,37 +125,89 @@ func (e *Executor) GetPlanJSON(ctx context.Context) ([]byte, error) {
 }
 
 // PlanJSON runs terraform plan with JSON output for machine-readable resource tracking
-func (e *Executor) PlanJSON(ctx context.Context, w io.Writer) (bool, error) {
-	hasChanges, err := e.tf.PlanJSON(ctx, w)
+func (e *Executor) PlanJSON(ctx context.Context, w io.Writer) (hasChanges bool, err error) {
+	ctx, span := e.startSpan(ctx, "plan")
+	defer func() { endSpan(span, err) }()
+
+	hasChanges, err = e.tf.PlanJSON(ctx, w)
 	if err != nil {
 		return false, fmt.Errorf("terraform plan failed: %w", err)
 	}
 	return hasChanges, nil
 }
 
-func (e *Executor) Apply(ctx context.Context) error {
+func (e *Executor) Apply(ctx context.Context) (err error) {
+	ctx, span := e.startSpan(ctx, "apply")
+	defer func() { endSpan(span, err) }()
+
 	if err := e.tf.Apply(ctx); err != nil {
 		return fmt.Errorf("terraform apply failed: %w", err)
 	}
 	return nil
 }
 
+// ApplyTargets runs terraform apply limited to the given resource or module addresses
+func (e *Executor) ApplyTargets(ctx context.Context, targets ...string) (err error) {
+	ctx, span := e.startSpan(ctx, "apply")
+	defer func() { endSpan(span, err) }()
+
+	opts := make([]tfexec.ApplyOption, 0, len(targets))
+	for _, target := range targets {
+		opts = append(opts, tfexec.Target(target))
+	}
+	if err := e.tf.Apply(ctx, opts...); err != nil {
+		return fmt.Errorf("terraform apply failed: %w", err)
+	}
+	return nil
+}
+
 // ApplyJSON runs terraform apply with JSON output for machine-readable resource tracking
-func (e *Executor) ApplyJSON(ctx context.Context, w io.Writer) error {
+func (e *Executor) ApplyJSON(ctx context.Context, w io.Writer) (err error) {
+	ctx, span := e.startSpan(ctx, "apply")
+	defer func() { endSpan(span, err) }()
+
 	if err := e.tf.ApplyJSON(ctx, w); err != nil {
 		return fmt.Errorf("terraform apply failed: %w", err)
 	}
 	return nil
 }
 
-func (e *Executor) Destroy(ctx context.Context) error {
+func (e *Executor) Destroy(ctx context.Context) (err error) {
+	ctx, span := e.startSpan(ctx, "destroy")
+	defer func() { endSpan(span, err) }()
+
 	if err := e.tf.Destroy(ctx); err != nil {
 		return fmt.Errorf("terraform destroy failed: %w", err)
 	}
 	return nil
 }
 
-func (e *Executor) ForceUnlock(ctx context.Context, lockID string) error {
+// Import imports an existing infrastructure object into the terraform state
+func (e *Executor) Import(ctx context.Context, address, id string) (err error) {
+	ctx, span := e.startSpan(ctx, "import")
+	defer func() { endSpan(span, err) }()
+
+	if err := e.tf.Import(ctx, address, id); err != nil {
+		return fmt.Errorf("terraform import failed: %w", err)
+	}
+	return nil
+}
+
+// StateRm removes a resource from the terraform state without destroying it
+func (e *Executor) StateRm(ctx context.Context, address string) (err error) {
+	ctx, span := e.startSpan(ctx, "state rm")
+	defer func() { endSpan(span, err) }()
+
+	if err := e.tf.StateRm(ctx, address); err != nil {
+		return fmt.Errorf("terraform state rm failed: %w", err)
+	}
+	return nil
+}
+
+func (e *Executor) ForceUnlock(ctx context.Context, lockID string) (err error) {
+	ctx, span := e.startSpan(ctx, "force-unlock")
+	defer func() { endSpan(span, err) }()
+
 	if err := e.tf.ForceUnlock(ctx, lockID); err != nil {
 		return fmt.Errorf("terraform force-unlock failed: %w", err)
 	}
@@ -147,7 +217,10 @@ func (e *Executor) ForceUnlock(ctx context.Context, lockID string) error {
 // Output retrieves the outputs of the Terraform state in JSON format
 // Had to do a workaround since tfexec.Outputs() does not return outputs
 // properly. Would always run into an error like: Unexpected EOF.
-func (e *Executor) Output(ctx context.Context) (map[string]tfexec.OutputMeta, error) {
+func (e *Executor) Output(ctx context.Context) (_ map[string]tfexec.OutputMeta, err error) {
+	ctx, span := e.startSpan(ctx, "output")
+	defer func() { endSpan(span, err) }()
+
 	env := os.Environ()
 	if e.envVars != nil {
 		for k, v := range e.envVars {
@@ -176,6 +249,22 @@ func (e *Executor) Output(ctx context.Context) (map[string]tfexec.OutputMeta, er
 	return outputs, nil
 }
 
+// startSpan starts the span of a terraform command
+func (e *Executor) startSpan(ctx context.Context, command string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "terraform "+command, trace.WithAttributes(
+		attribute.String("terraform.command", command),
+		attribute.String("terraform.work_dir", e.workDir),
+	))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 func (e *Executor) WorkDir() string {
 	return e.workDir
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +13,7 @@ import (
 	"time"
 
 	"github.com/google/go-github/v74/github"
+	"github.com/stolos-cloud/stolos-bootstrap/pkg/terraform/templates"
 )
 
 // terraform workflows including:
@@ -18,14 +21,14 @@ import (
 // Terraform execution
 // GitOps integration
 type Orchestrator struct {
-	executor        *Executor
-	templateBaseDir string
+	executor  *Executor
+	templates fs.FS
 }
 
 type OrchestratorConfig struct {
-	WorkDir         string
-	TemplateBaseDir string
-	EnvVars         map[string]string
+	WorkDir   string
+	Templates fs.FS // templates.FS unless set
+	EnvVars   map[string]string
 }
 
 func NewOrchestrator(config OrchestratorConfig) (*Orchestrator, error) {
@@ -34,17 +37,18 @@ func NewOrchestrator(config OrchestratorConfig) (*Orchestrator, error) {
 		return nil, fmt.Errorf("failed to create executor: %w", err)
 	}
 
+	if config.Templates == nil {
+		config.Templates = templates.FS
+	}
 	return &Orchestrator{
-		executor:        executor,
-		templateBaseDir: config.TemplateBaseDir,
+		executor:  executor,
+		templates: config.Templates,
 	}, nil
 }
 
 // loads a template file and renders it with the given data
 func (o *Orchestrator) RenderTemplate(templatePath string, data any) (string, error) {
-	fullPath := filepath.Join(o.templateBaseDir, templatePath)
-
-	tmpl, err := template.ParseFiles(fullPath)
+	tmpl, err := template.ParseFS(o.templates, filepath.ToSlash(templatePath))
 	if err != nil {
 		return "", fmt.Errorf("failed to load template %s: %w", templatePath, err)
 	}
@@ -88,6 +92,10 @@ func (o *Orchestrator) Apply(ctx context.Context) error {
 	return o.executor.Apply(ctx)
 }
 
+func (o *Orchestrator) ApplyTargets(ctx context.Context, targets ...string) error {
+	return o.executor.ApplyTargets(ctx, targets...)
+}
+
 func (o *Orchestrator) PlanJSON(ctx context.Context, w io.Writer) (bool, error) {
 	return o.executor.PlanJSON(ctx, w)
 }
@@ -104,6 +112,14 @@ func (o *Orchestrator) Destroy(ctx context.Context) error {
 	return o.executor.Destroy(ctx)
 }
 
+func (o *Orchestrator) Import(ctx context.Context, address, id string) error {
+	return o.executor.Import(ctx, address, id)
+}
+
+func (o *Orchestrator) StateRm(ctx context.Context, address string) error {
+	return o.executor.StateRm(ctx, address)
+}
+
 func (o *Orchestrator) ForceUnlock(ctx context.Context, lockID string) error {
 	return o.executor.ForceUnlock(ctx, lockID)
 }
@@ -218,7 +234,7 @@ func (o *Orchestrator) CommitToGitOps(ctx context.Context, ghClient *github.Clie
 	// If the branch moved since we started, use the latest commit as parent
 	parentSHA := baseCommitSHA
 	if latestCommitSHA != baseCommitSHA {
-		fmt.Printf("Branch moved during operation (was %s, now %s) - using latest as parent\n",
+		log.Printf("Branch moved during operation (was %s, now %s) - using latest as parent",
 			baseCommitSHA[:7], latestCommitSHA[:7])
 		parentSHA = latestCommitSHA
 	}
@@ -249,7 +265,7 @@ func (o *Orchestrator) CommitToGitOps(ctx context.Context, ghClient *github.Clie
 		return false, fmt.Errorf("failed to update branch ref: %w", err)
 	}
 
-	fmt.Printf("Successfully committed to %s/%s (branch: %s)\n", config.Owner, config.Repo, config.Branch)
+	log.Printf("Successfully committed to %s/%s (branch: %s)", config.Owner, config.Repo, config.Branch)
 	return true, nil
 }
 
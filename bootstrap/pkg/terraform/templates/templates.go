@@ -0,0 +1,7 @@
+// Package templates embeds the Terraform templates rendered by the backend and the bootstrap
+package templates
+
+import "embed"
+
+//go:embed gcp
+var FS embed.FS
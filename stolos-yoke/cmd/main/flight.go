@@ -1,28 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"time"
 
-	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/argocd"
-	cert_manager "github.com/stolos-cloud/stolos/stolos-yoke/pkg/cert-manager"
-	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/cnpg"
-	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/contour"
-	localpathprovisioner "github.com/stolos-cloud/stolos/stolos-yoke/pkg/local-path-provisioner"
-	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/metallb"
-	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/stolos"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render"
 	types "github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
 	stolos_yoke "github.com/stolos-cloud/stolos/yoke-base/pkg/stolos-yoke"
 	airway "github.com/yokecd/yoke/pkg/apis/airway/v1alpha1"
-	"github.com/yokecd/yoke/pkg/flight"
-	k8s "github.com/yokecd/yoke/pkg/flight/wasi/k8s"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
-	k8sruntime "k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/yaml"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/ptr"
 )
 
@@ -47,7 +38,10 @@ func main() {
 
 }
 
+var dryRun = flag.Bool("dry-run", false, "print the resources the flight would apply and the ones it would skip, and why")
+
 func run() ([]byte, error) {
+	flag.Parse()
 
 	var input types.Stolos
 	err := yaml.NewYAMLToJSONDecoder(os.Stdin).Decode(&input)
@@ -55,63 +49,22 @@ func run() ([]byte, error) {
 		return nil, err
 	}
 
-	argoApp := &types.Application{}
-	scheme.Scheme.AddKnownTypes(argoApp.GetObjectKind().GroupVersionKind().GroupVersion(), argoApp)
-	resources := []flight.Resource{}
-	if input.Spec.ArgoCD.Deploy {
-		resources = append(resources, argocd.AllArgoCD(input)...)
-	}
-
-	if input.Spec.LocalPathProvisioner.Deploy {
-		resources = append(resources, localpathprovisioner.AllLocalPathProvisioner(input)...)
-	}
-
-	if input.Spec.MetalLB.Deploy {
-		resources = append(resources, metallb.AllMetalLB(input)...)
-	}
-
-	if input.Spec.CertManager.Deploy {
-		resources = append(resources, cert_manager.AllCertManager(input)...)
-	}
-
-	if input.Spec.CNPG.Deploy {
-		resources = append(resources, cnpg.AllCnpg(input)...)
-	}
+	result := render.Render(input)
 
-	if input.Spec.Contour.Deploy {
-		resources = append(resources, contour.AllContour(input)...)
-	}
-
-	if input.Spec.StolosPlatform.Deploy {
-		resources = append(resources, stolos.AllStolos(input)...)
+	if *dryRun {
+		var report bytes.Buffer
+		if err := render.Report(&report, result); err != nil {
+			return nil, err
+		}
+		return report.Bytes(), nil
 	}
 
-	// resources = append(resources, selfArgoApp(input))
-
-	resultResources := []flight.Resource{}
-	coreScheme := k8sruntime.NewScheme()
-	scheme.AddToScheme(coreScheme)
-	allTypes := coreScheme.AllKnownTypes()
-	existingCrds := map[schema.GroupVersionKind]bool{}
-
-	for _, res := range resources {
-		_, isCoreRes := allTypes[res.GroupVersionKind()]
-		_, crdExists := existingCrds[res.GroupVersionKind()]
-		if isCoreRes || crdExists {
-			resultResources = append(resultResources, res)
-		} else {
-
-			_, err := k8s.GetRestMapping(res.GroupVersionKind().GroupVersion().String(), res.GroupVersionKind().Kind)
-			if err == nil {
-				resultResources = append(resultResources, res)
-				existingCrds[res.GroupVersionKind()] = true
-			} else {
-				fmt.Fprint(os.Stderr, err.Error())
-			}
-		}
+	for _, skipped := range result.Skipped {
+		fmt.Fprintf(os.Stderr, "skipping %s\n", skipped)
 	}
 
-	return json.Marshal(resultResources)
+	// Yoke waits for the first stage, including the migration job of the new version, before rolling out the workloads
+	return json.Marshal(result.Stages())
 }
 
 // func selfArgoApp(input types.Stolos) *types.Application {
@@ -4,11 +4,9 @@ import (
 	_ "embed"
 	"fmt"
 
-	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
-	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
-	contourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	types "github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
 	"github.com/yokecd/yoke/pkg/flight"
 	"github.com/yokecd/yoke/pkg/helm"
 	appsv1 "k8s.io/api/apps/v1"
@@ -43,10 +41,9 @@ func AllArgoCD(input types.Stolos) []flight.Resource {
 	all := []flight.Resource{
 		CreateArgoNamespace(input),
 		DeployArgoHelm(input),
-		DeployArgocdProxy(input),
-		DeployArgocdCert(input),
 		DeploySystemApps(input),
 	}
+	all = append(all, routing.Resources(utils.IngressConfig(input), ArgocdRoute(input))...)
 	all = append(all, DeployArgoCDImageUpdaterResources(input)...)
 
 	//_, err := k8s.Lookup[types.Application](k8s.ResourceIdentifier{
@@ -145,77 +142,17 @@ func DeployArgoHelm(input types.Stolos) *types.Application {
 	return &app
 }
 
-func DeployArgocdProxy(input types.Stolos) *contourv1.HTTPProxy {
-	return &contourv1.HTTPProxy{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "HTTPProxy",
-			APIVersion: "projectcontour.io/v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "argocd",
-			Namespace: input.Spec.ArgoCD.Namespace,
-			Annotations: map[string]string{
-				"cert-manager.io/cluster-issuer": input.Spec.CertManager.DefaultClusterIssuer,
-			},
-		},
-		Spec: contourv1.HTTPProxySpec{
-			VirtualHost: &contourv1.VirtualHost{
-				Fqdn: input.Spec.ArgoCD.Subdomain + "." + input.Spec.BaseDomain,
-				TLS: &contourv1.TLS{
-					SecretName: "argocd-tls",
-				},
-			},
-			Routes: []contourv1.Route{
-				{
-					Conditions: []contourv1.MatchCondition{
-						{
-							Header: &contourv1.HeaderMatchCondition{
-								Name:     "Content-Type",
-								Contains: "application/grpc",
-							},
-						},
-					},
-					Services: []contourv1.Service{
-						{
-							Name:     "argocd-server",
-							Port:     80,
-							Protocol: utils.PtrTo("h2c"),
-						},
-					},
-				},
-				{
-					Services: []contourv1.Service{
-						{
-							Name: "argocd-server",
-							Port: 80,
-						},
-					},
-				},
-			},
-		},
-	}
-}
-
-func DeployArgocdCert(input types.Stolos) *certmanagerv1.Certificate {
-	return &certmanagerv1.Certificate{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Certificate",
-			APIVersion: "cert-manager.io/v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "argocd-tls",
-			Namespace: input.Spec.ArgoCD.Namespace,
-		},
-		Spec: certmanagerv1.CertificateSpec{
-			SecretName: "argocd-tls",
-			IssuerRef: certmanagermetav1.ObjectReference{
-				Name: input.Spec.CertManager.DefaultClusterIssuer,
-				Kind: "ClusterIssuer",
-			},
-			CommonName: input.Spec.ArgoCD.Subdomain + "." + input.Spec.BaseDomain,
-			DNSNames: []string{
-				input.Spec.ArgoCD.Subdomain + "." + input.Spec.BaseDomain,
-			},
+// ArgocdRoute exposes the UI and the gRPC API of the argocd server
+func ArgocdRoute(input types.Stolos) routing.Route {
+	return routing.Route{
+		Name:      "argocd",
+		Namespace: input.Spec.ArgoCD.Namespace,
+		Host:      input.Spec.ArgoCD.Subdomain + "." + input.Spec.BaseDomain,
+		TLSSecret: "argocd-tls",
+		Rules: []routing.Rule{
+			// Both ports reach the insecure server, only the https one has the h2c appProtocol, see argocd-values.yaml
+			{GRPC: true, Service: "argocd-server", Port: 443, H2C: true},
+			{Service: "argocd-server", Port: 80},
 		},
 	}
 }
@@ -0,0 +1,17 @@
+package argocd_test
+
+import (
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/argocd"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/yokecd/yoke/pkg/flight"
+)
+
+func TestAllArgoCD(t *testing.T) {
+	input := rendertest.Platform(t)
+	resources := rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return argocd.AllArgoCD(input)
+	})
+	rendertest.Golden(t, "argocd", resources...)
+}
@@ -7,17 +7,29 @@ import (
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/argocd"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
 	"github.com/yokecd/yoke/pkg/flight"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
+// CloudDNSSecret holds the key of the GCP service account used for Cloud DNS
+const CloudDNSSecret = "clouddns-credentials"
+
+// gcpConfigSecret is the secret where the bootstrap stores the GCP service account
+const gcpConfigSecret = "stolos-system-config"
+
 func AllCertManager(input types.Stolos) []flight.Resource {
 	all := []flight.Resource{
 		CreateCertManagerNamespace(input),
 		DeployCertManagerHelm(input),
 	}
+	if input.Spec.CertManager.DNS01.Provider == "clouddns" {
+		if secret := CreateCloudDNSSecret(input, input.Spec.CertManager.Namespace); secret != nil {
+			all = append(all, secret)
+		}
+	}
 	all = append(all, DeployClusterIssuer(input)...)
 	return all
 }
@@ -47,12 +59,7 @@ func DeployCertManagerHelm(input types.Stolos) *types.Application {
 				TargetRevision: input.Spec.CertManager.Version,
 				Chart:          "cert-manager",
 				Helm: &types.ApplicationSourceHelm{
-					Parameters: []types.HelmParameter{
-						{
-							Name:  "installCRDs",
-							Value: "true",
-						},
-					},
+					Parameters: helmParameters(input),
 				},
 			},
 			Destination: types.ApplicationDestination{
@@ -70,6 +77,121 @@ func DeployCertManagerHelm(input types.Stolos) *types.Application {
 	return &app
 }
 
+func helmParameters(input types.Stolos) []types.HelmParameter {
+	parameters := []types.HelmParameter{
+		{
+			Name:  "installCRDs",
+			Value: "true",
+		},
+	}
+
+	// The HTTP-01 solver of the gateway provider creates HTTPRoutes
+	if input.Spec.Ingress.Provider == routing.ProviderGateway {
+		parameters = append(parameters,
+			types.HelmParameter{Name: "config.apiVersion", Value: "controller.config.cert-manager.io/v1alpha1"},
+			types.HelmParameter{Name: "config.kind", Value: "ControllerConfiguration"},
+			types.HelmParameter{Name: "config.enableGatewayAPI", Value: "true"},
+		)
+	}
+	return parameters
+}
+
+// acmeSolvers answers the challenges of BaseDomain with the DNS-01 provider when there is one, and the others with HTTP-01
+func acmeSolvers(input types.Stolos) []cmacme.ACMEChallengeSolver {
+	solvers := []cmacme.ACMEChallengeSolver{
+		{
+			HTTP01: routing.HTTP01Solver(utils.IngressConfig(input)),
+		},
+	}
+
+	dns01 := dns01Solver(input)
+	if dns01 == nil {
+		return solvers
+	}
+	return append([]cmacme.ACMEChallengeSolver{
+		{
+			Selector: &cmacme.CertificateDNSNameSelector{
+				DNSZones: []string{input.Spec.BaseDomain},
+			},
+			DNS01: dns01,
+		},
+	}, solvers...)
+}
+
+func dns01Solver(input types.Stolos) *cmacme.ACMEChallengeSolverDNS01 {
+	dns01 := input.Spec.CertManager.DNS01
+	switch dns01.Provider {
+	case "clouddns":
+		return &cmacme.ACMEChallengeSolverDNS01{
+			CloudDNS: &cmacme.ACMEIssuerDNS01ProviderCloudDNS{
+				ServiceAccount: &cmmeta.SecretKeySelector{
+					LocalObjectReference: cmmeta.LocalObjectReference{Name: CloudDNSSecret},
+					Key:                  "key.json",
+				},
+				Project: CloudDNSProject(input),
+			},
+		}
+	case "cloudflare":
+		return &cmacme.ACMEChallengeSolverDNS01{
+			Cloudflare: &cmacme.ACMEIssuerDNS01ProviderCloudflare{
+				APIToken: &cmmeta.SecretKeySelector{
+					LocalObjectReference: cmmeta.LocalObjectReference{Name: dns01.Cloudflare.APITokenSecret},
+					Key:                  dns01.Cloudflare.APITokenKey,
+				},
+			},
+		}
+	case "rfc2136":
+		return &cmacme.ACMEChallengeSolverDNS01{
+			RFC2136: &cmacme.ACMEIssuerDNS01ProviderRFC2136{
+				Nameserver:    dns01.RFC2136.Nameserver,
+				TSIGKeyName:   dns01.RFC2136.TSIGKeyName,
+				TSIGAlgorithm: dns01.RFC2136.TSIGAlgorithm,
+				TSIGSecret: cmmeta.SecretKeySelector{
+					LocalObjectReference: cmmeta.LocalObjectReference{Name: dns01.RFC2136.TSIGSecret},
+					Key:                  dns01.RFC2136.TSIGSecretKey,
+				},
+			},
+		}
+	}
+	return nil
+}
+
+// CloudDNSProject is the project of the Cloud DNS zone, the project of the GCP service account by default
+func CloudDNSProject(input types.Stolos) string {
+	if project := input.Spec.CertManager.DNS01.CloudDNS.Project; project != "" {
+		return project
+	}
+	secret, err := utils.GetExistingSecret(gcpConfigSecret, input.Spec.StolosPlatform.Namespace)
+	if err != nil || secret == nil {
+		return ""
+	}
+	return string(secret.Data["GCP_PROJECT_ID"])
+}
+
+// CreateCloudDNSSecret copies the key of the GCP service account of the platform to a namespace, nil until the
+// bootstrap has stored it
+func CreateCloudDNSSecret(input types.Stolos, namespace string) *v1.Secret {
+	gcpConfig, err := utils.GetExistingSecret(gcpConfigSecret, input.Spec.StolosPlatform.Namespace)
+	if err != nil || gcpConfig == nil || len(gcpConfig.Data["GCP_SERVICE_ACCOUNT_JSON"]) == 0 {
+		return nil
+	}
+
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CloudDNSSecret,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			"key.json": gcpConfig.Data["GCP_SERVICE_ACCOUNT_JSON"],
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&secret)
+	secret.SetGroupVersionKind(gvks[0])
+
+	return &secret
+}
+
 func DeployClusterIssuer(input types.Stolos) []flight.Resource {
 	var issuerConfigStg certmanagerv1.IssuerConfig
 	var issuerConfigPrd certmanagerv1.IssuerConfig
@@ -91,15 +213,7 @@ func DeployClusterIssuer(input types.Stolos) []flight.Resource {
 						Name: input.Spec.CertManager.ClusterIssuerStaging,
 					},
 				},
-				Solvers: []cmacme.ACMEChallengeSolver{
-					{
-						HTTP01: &cmacme.ACMEChallengeSolverHTTP01{
-							Ingress: &cmacme.ACMEChallengeSolverHTTP01Ingress{
-								Class: utils.PtrTo("contour"),
-							},
-						},
-					},
-				},
+				Solvers: acmeSolvers(input),
 			},
 		}
 
@@ -112,15 +226,7 @@ func DeployClusterIssuer(input types.Stolos) []flight.Resource {
 						Name: input.Spec.CertManager.ClusterIssuerProd,
 					},
 				},
-				Solvers: []cmacme.ACMEChallengeSolver{
-					{
-						HTTP01: &cmacme.ACMEChallengeSolverHTTP01{
-							Ingress: &cmacme.ACMEChallengeSolverHTTP01Ingress{
-								Class: utils.PtrTo("contour"),
-							},
-						},
-					},
-				},
+				Solvers: acmeSolvers(input),
 			},
 		}
 	}
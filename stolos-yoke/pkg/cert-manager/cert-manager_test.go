@@ -0,0 +1,48 @@
+package cert_manager_test
+
+import (
+	"testing"
+
+	cert_manager "github.com/stolos-cloud/stolos/stolos-yoke/pkg/cert-manager"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/yokecd/yoke/pkg/flight"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAllCertManager(t *testing.T) {
+	input := rendertest.Platform(t)
+	resources := rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return cert_manager.AllCertManager(input)
+	})
+	rendertest.Golden(t, "cert-manager", resources...)
+}
+
+func TestSelfSigned(t *testing.T) {
+	input := rendertest.Platform(t)
+	input.Spec.CertManager.SelfSigned = true
+	resources := rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return cert_manager.AllCertManager(input)
+	})
+	rendertest.Golden(t, "cert-manager-self-signed", resources...)
+}
+
+// The Cloud DNS solver copies the key of the service account stored by the bootstrap
+func TestCloudDNS(t *testing.T) {
+	input := rendertest.Platform(t)
+	input.Spec.CertManager.DNS01.Provider = "clouddns"
+	cluster := rendertest.NewCluster(input, corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stolos-system-config",
+			Namespace: input.Spec.StolosPlatform.Namespace,
+		},
+		Data: map[string][]byte{
+			"GCP_PROJECT_ID":           []byte("stolos-test"),
+			"GCP_SERVICE_ACCOUNT_JSON": []byte(`{"type":"service_account"}`),
+		},
+	})
+	resources := rendertest.Resources(t, cluster, func() []flight.Resource {
+		return cert_manager.AllCertManager(input)
+	})
+	rendertest.Golden(t, "cert-manager-clouddns", resources...)
+}
@@ -0,0 +1,84 @@
+package cnpg
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BarmanCloudPlugin is the name of the Barman Cloud plugin in the cluster and backup plugin configurations
+const BarmanCloudPlugin = "barman-cloud.cloudnative-pg.io"
+
+// ScheduledBackup is the Schema for the scheduledbackups API
+type ScheduledBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Specification of the desired behavior of the ScheduledBackup.
+	Spec ScheduledBackupSpec `json:"spec"`
+}
+
+// ScheduledBackupSpec defines the desired state of ScheduledBackup
+type ScheduledBackupSpec struct {
+	// If this backup is suspended or not
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// If the first backup has to be immediately start after creation or not
+	// +optional
+	Immediate *bool `json:"immediate,omitempty"`
+
+	// The schedule does not follow the same format used in Kubernetes CronJobs
+	// as it includes an additional seconds specifier,
+	// see https://pkg.go.dev/github.com/robfig/cron#hdr-CRON_Expression_Format
+	Schedule string `json:"schedule"`
+
+	// The cluster to backup
+	Cluster LocalObjectReference `json:"cluster"`
+
+	// Indicates which ownerReference should be put inside the created backup resources.<br />
+	// - none: no owner reference for created backup objects (same behavior as before the field was introduced)<br />
+	// - self: sets the Scheduled backup object as owner of the backup<br />
+	// - cluster: set the cluster as owner of the backup<br />
+	// +kubebuilder:validation:Enum=none;self;cluster
+	// +kubebuilder:default:=none
+	// +optional
+	BackupOwnerReference string `json:"backupOwnerReference,omitempty"`
+
+	// The policy to decide which instance should perform this backup. If empty,
+	// it defaults to `cluster.spec.backup.target`.
+	// +optional
+	// +kubebuilder:validation:Enum=primary;prefer-standby
+	Target BackupTarget `json:"target,omitempty"`
+
+	// The backup method to be used, possible options are `barmanObjectStore`,
+	// `volumeSnapshot` or `plugin`. Defaults to: `barmanObjectStore`.
+	// +optional
+	// +kubebuilder:validation:Enum=barmanObjectStore;volumeSnapshot;plugin
+	// +kubebuilder:default:=barmanObjectStore
+	Method BackupMethod `json:"method,omitempty"`
+
+	// Configuration parameters passed to the plugin managing this backup
+	// +optional
+	PluginConfiguration *BackupPluginConfiguration `json:"pluginConfiguration,omitempty"`
+}
+
+// ObjectStore is the object store of the Barman Cloud plugin (barmancloud.cnpg.io/v1)
+type ObjectStore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec ObjectStoreSpec `json:"spec"`
+}
+
+// ObjectStoreSpec defines the desired state of ObjectStore
+type ObjectStoreSpec struct {
+	// The configuration for the barman-cloud tool suite
+	Configuration BarmanObjectStoreConfiguration `json:"configuration"`
+
+	// RetentionPolicy is the retention policy to be used for backups
+	// and WALs (i.e. '60d'). The retention policy is expressed in the form
+	// of `XXu` where `XX` is a positive integer and `u` is in `[dwm]` -
+	// days, weeks, months.
+	// +kubebuilder:validation:Pattern=^[1-9][0-9]*[dwm]$
+	// +optional
+	RetentionPolicy string `json:"retentionPolicy,omitempty"`
+}
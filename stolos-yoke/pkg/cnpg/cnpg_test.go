@@ -0,0 +1,17 @@
+package cnpg_test
+
+import (
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/cnpg"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/yokecd/yoke/pkg/flight"
+)
+
+func TestAllCnpg(t *testing.T) {
+	input := rendertest.Platform(t)
+	resources := rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return cnpg.AllCnpg(input)
+	})
+	rendertest.Golden(t, "cnpg", resources...)
+}
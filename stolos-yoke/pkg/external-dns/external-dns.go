@@ -0,0 +1,189 @@
+package external_dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/argocd"
+	cert_manager "github.com/stolos-cloud/stolos/stolos-yoke/pkg/cert-manager"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
+	"github.com/yokecd/yoke/pkg/flight"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func AllExternalDNS(input types.Stolos) []flight.Resource {
+	dns01 := input.Spec.CertManager.DNS01
+	if dns01.Provider == "" {
+		fmt.Fprintln(os.Stderr, "external-dns needs a DNS-01 provider in certManager.dns01, skipping it")
+		return nil
+	}
+
+	all := []flight.Resource{
+		CreateExternalDNSNamespace(input),
+		DeployExternalDNSHelm(input),
+	}
+
+	// The credentials of the DNS-01 solver are shared with external-dns
+	var secret *v1.Secret
+	switch dns01.Provider {
+	case "clouddns":
+		secret = cert_manager.CreateCloudDNSSecret(input, input.Spec.ExternalDNS.Namespace)
+	case "cloudflare":
+		secret = CopyCredentialsSecret(input, dns01.Cloudflare.APITokenSecret)
+	case "rfc2136":
+		secret = CopyCredentialsSecret(input, dns01.RFC2136.TSIGSecret)
+	}
+	if secret != nil {
+		all = append(all, secret)
+	}
+	return all
+}
+
+func CreateExternalDNSNamespace(input types.Stolos) *v1.Namespace {
+	ns := v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: input.Spec.ExternalDNS.Namespace,
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&ns)
+	ns.SetGroupVersionKind(gvks[0])
+
+	return &ns
+}
+
+func DeployExternalDNSHelm(input types.Stolos) *types.Application {
+	values, _ := json.Marshal(helmValues(input))
+
+	app := types.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "external-dns",
+			Namespace: input.Spec.ArgoCD.Namespace,
+		},
+		Spec: types.ApplicationSpec{
+			Source: &types.ApplicationSource{
+				RepoURL:        "https://kubernetes-sigs.github.io/external-dns/",
+				TargetRevision: input.Spec.ExternalDNS.Version,
+				Chart:          "external-dns",
+				Helm: &types.ApplicationSourceHelm{
+					ValuesObject: &runtime.RawExtension{Raw: values},
+				},
+			},
+			Destination: types.ApplicationDestination{
+				Server:    "https://kubernetes.default.svc",
+				Namespace: input.Spec.ExternalDNS.Namespace,
+			},
+			Project:    "default",
+			SyncPolicy: argocd.DefaultSyncPolicy,
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&app)
+	app.SetGroupVersionKind(gvks[0])
+
+	return &app
+}
+
+// helmValues watches the routes of the ingress provider, including the ones of the templates, and publishes the
+// hosts under BaseDomain
+func helmValues(input types.Stolos) map[string]any {
+	source := "contour-httpproxy"
+	switch utils.IngressConfig(input).Provider {
+	case routing.ProviderGateway:
+		source = "gateway-httproute"
+	case routing.ProviderIngress:
+		source = "ingress"
+	}
+
+	values := map[string]any{
+		"sources":       []string{source},
+		"domainFilters": []string{input.Spec.BaseDomain},
+		"policy":        input.Spec.ExternalDNS.Policy,
+		"registry":      "txt",
+		"txtOwnerId":    input.Spec.ClusterName,
+	}
+
+	dns01 := input.Spec.CertManager.DNS01
+	switch dns01.Provider {
+	case "clouddns":
+		values["provider"] = map[string]any{"name": "google"}
+		values["extraArgs"] = []string{"--google-project=" + cert_manager.CloudDNSProject(input)}
+		values["env"] = []map[string]any{
+			{"name": "GOOGLE_APPLICATION_CREDENTIALS", "value": "/etc/secrets/clouddns/key.json"},
+		}
+		values["extraVolumes"] = []map[string]any{
+			{"name": "clouddns", "secret": map[string]any{"secretName": cert_manager.CloudDNSSecret}},
+		}
+		values["extraVolumeMounts"] = []map[string]any{
+			{"name": "clouddns", "mountPath": "/etc/secrets/clouddns", "readOnly": true},
+		}
+	case "cloudflare":
+		values["provider"] = map[string]any{"name": "cloudflare"}
+		values["env"] = []map[string]any{
+			secretEnv("CF_API_TOKEN", dns01.Cloudflare.APITokenSecret, dns01.Cloudflare.APITokenKey),
+		}
+	case "rfc2136":
+		host, port, err := net.SplitHostPort(dns01.RFC2136.Nameserver)
+		if err != nil {
+			host, port = dns01.RFC2136.Nameserver, "53"
+		}
+		values["provider"] = map[string]any{"name": "rfc2136"}
+		values["extraArgs"] = []string{
+			"--rfc2136-host=" + host,
+			"--rfc2136-port=" + port,
+			"--rfc2136-zone=" + input.Spec.BaseDomain,
+			"--rfc2136-tsig-keyname=" + dns01.RFC2136.TSIGKeyName,
+			"--rfc2136-tsig-secret-alg=" + tsigAlgorithm(dns01.RFC2136.TSIGAlgorithm),
+			"--rfc2136-tsig-axfr",
+		}
+		values["env"] = []map[string]any{
+			secretEnv("EXTERNAL_DNS_RFC2136_TSIG_SECRET", dns01.RFC2136.TSIGSecret, dns01.RFC2136.TSIGSecretKey),
+		}
+	}
+
+	return values
+}
+
+func secretEnv(name, secret, key string) map[string]any {
+	return map[string]any{
+		"name": name,
+		"valueFrom": map[string]any{
+			"secretKeyRef": map[string]any{"name": secret, "key": key},
+		},
+	}
+}
+
+// tsigAlgorithm converts the cert-manager name of an algorithm, e.g. HMACSHA256, to the external-dns one, hmac-sha256
+func tsigAlgorithm(algorithm string) string {
+	return "hmac-" + strings.ToLower(strings.TrimPrefix(algorithm, "HMAC"))
+}
+
+// CopyCredentialsSecret copies a credentials secret of the cert-manager namespace, nil until it exists
+func CopyCredentialsSecret(input types.Stolos, name string) *v1.Secret {
+	existing, err := utils.GetExistingSecret(name, input.Spec.CertManager.Namespace)
+	if err != nil || existing == nil {
+		fmt.Fprintf(os.Stderr, "secret %s/%s not found, external-dns can't authenticate\n", input.Spec.CertManager.Namespace, name)
+		return nil
+	}
+
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: input.Spec.ExternalDNS.Namespace,
+		},
+		Data: existing.Data,
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&secret)
+	secret.SetGroupVersionKind(gvks[0])
+
+	return &secret
+}
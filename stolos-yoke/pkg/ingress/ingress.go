@@ -0,0 +1,228 @@
+package ingress
+
+import (
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/argocd"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
+	"github.com/yokecd/yoke/pkg/flight"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	gwv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	envoyGatewayClass      = "envoy-gateway"
+	envoyGatewayController = "gateway.envoyproxy.io/gatewayclass-controller"
+	ciliumGatewayClass     = "cilium" // created by Cilium when its Gateway API support is enabled
+	wildcardTLSSecret      = "wildcard-tls"
+)
+
+// AllIngress returns the shared gateway of the gateway provider, with an HTTPS listener for each route of the
+// platform and, when its certificate can be issued, a wildcard listener for the routes of the templates. The other
+// providers need nothing more.
+func AllIngress(input types.Stolos, routes []routing.Route) []flight.Resource {
+	if input.Spec.Ingress.Provider != routing.ProviderGateway {
+		return nil
+	}
+
+	all := []flight.Resource{
+		CreateGatewayNamespace(input),
+	}
+	if input.Spec.Ingress.Gateway.Implementation != "cilium" {
+		all = append(all, DeployEnvoyGateway(input), CreateEnvoyGatewayClass(input))
+	}
+	all = append(all, CreateGateway(input, routes), CreateHTTPSRedirect(input))
+	if wildcardListener(input) {
+		all = append(all, CreateWildcardCertificate(input))
+	}
+	return all
+}
+
+// wildcardListener tells whether the gateway serves the routes of the templates with a wildcard certificate. ACME only
+// issues wildcard certificates with a DNS-01 solver, with HTTP-01 the templates need their own listener.
+func wildcardListener(input types.Stolos) bool {
+	if input.Spec.BaseDomain == "" {
+		return false
+	}
+	return input.Spec.CertManager.SelfSigned || input.Spec.CertManager.DNS01.Provider != ""
+}
+
+func CreateGatewayNamespace(input types.Stolos) *corev1.Namespace {
+	ns := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: input.Spec.Ingress.Gateway.Namespace,
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&ns)
+	ns.SetGroupVersionKind(gvks[0])
+
+	return &ns
+}
+
+// DeployEnvoyGateway installs Envoy Gateway and the Gateway API CRDs
+func DeployEnvoyGateway(input types.Stolos) *types.Application {
+	app := types.Application{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Application",
+			APIVersion: "argoproj.io/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "envoy-gateway",
+			Namespace: input.Spec.ArgoCD.Namespace,
+		},
+		Spec: types.ApplicationSpec{
+			Source: &types.ApplicationSource{
+				RepoURL:        "oci://docker.io/envoyproxy/gateway-helm",
+				TargetRevision: input.Spec.Ingress.Gateway.EnvoyGatewayVersion,
+				Path:           ".",
+			},
+			Destination: types.ApplicationDestination{
+				Server:    "https://kubernetes.default.svc",
+				Namespace: input.Spec.Ingress.Gateway.Namespace,
+			},
+			Project:    "default",
+			SyncPolicy: argocd.DefaultSyncPolicy,
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&app)
+	app.SetGroupVersionKind(gvks[0])
+
+	return &app
+}
+
+func CreateEnvoyGatewayClass(input types.Stolos) *gwv1.GatewayClass {
+	return &gwv1.GatewayClass{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "GatewayClass",
+			APIVersion: "gateway.networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: envoyGatewayClass,
+		},
+		Spec: gwv1.GatewayClassSpec{
+			ControllerName: envoyGatewayController,
+		},
+	}
+}
+
+// CreateGateway creates the gateway shared by the platform and the templates. The HTTP listener only accepts the
+// routes of its namespace, the ACME challenges and the HTTPS redirect.
+func CreateGateway(input types.Stolos, routes []routing.Route) *gwv1.Gateway {
+	cfg := utils.IngressConfig(input)
+
+	className := envoyGatewayClass
+	if input.Spec.Ingress.Gateway.Implementation == "cilium" {
+		className = ciliumGatewayClass
+	}
+
+	listeners := []gwv1.Listener{
+		{
+			Name:     routing.HTTPListenerName,
+			Protocol: gwv1.HTTPProtocolType,
+			Port:     80,
+			AllowedRoutes: &gwv1.AllowedRoutes{
+				Namespaces: &gwv1.RouteNamespaces{From: ptr.To(gwv1.NamespacesFromSame)},
+			},
+		},
+	}
+	if wildcardListener(input) {
+		listeners = append(listeners, httpsListener("https", "*."+input.Spec.BaseDomain, wildcardTLSSecret))
+	}
+	for _, route := range routes {
+		listeners = append(listeners, httpsListener("https-"+route.Namespace+"-"+route.Name, route.Host, routing.TLSSecretName(cfg, route)))
+	}
+
+	return &gwv1.Gateway{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Gateway",
+			APIVersion: "gateway.networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      input.Spec.Ingress.Gateway.Name,
+			Namespace: input.Spec.Ingress.Gateway.Namespace,
+		},
+		Spec: gwv1.GatewaySpec{
+			GatewayClassName: gwv1.ObjectName(className),
+			Listeners:        listeners,
+		},
+	}
+}
+
+func httpsListener(name, host, secretName string) gwv1.Listener {
+	return gwv1.Listener{
+		Name:     gwv1.SectionName(name),
+		Hostname: ptr.To(gwv1.Hostname(host)),
+		Protocol: gwv1.HTTPSProtocolType,
+		Port:     443,
+		TLS: &gwv1.GatewayTLSConfig{
+			Mode: ptr.To(gwv1.TLSModeTerminate),
+			CertificateRefs: []gwv1.SecretObjectReference{
+				{Name: gwv1.ObjectName(secretName)},
+			},
+		},
+		AllowedRoutes: &gwv1.AllowedRoutes{
+			Namespaces: &gwv1.RouteNamespaces{From: ptr.To(gwv1.NamespacesFromAll)},
+		},
+	}
+}
+
+// CreateHTTPSRedirect redirects the plain HTTP requests to HTTPS, the ACME challenge routes are more specific
+func CreateHTTPSRedirect(input types.Stolos) *gwv1.HTTPRoute {
+	return &gwv1.HTTPRoute{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "HTTPRoute",
+			APIVersion: "gateway.networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "https-redirect",
+			Namespace: input.Spec.Ingress.Gateway.Namespace,
+		},
+		Spec: gwv1.HTTPRouteSpec{
+			CommonRouteSpec: gwv1.CommonRouteSpec{
+				ParentRefs: []gwv1.ParentReference{{
+					Name:        gwv1.ObjectName(input.Spec.Ingress.Gateway.Name),
+					SectionName: ptr.To(gwv1.SectionName(routing.HTTPListenerName)),
+				}},
+			},
+			Rules: []gwv1.HTTPRouteRule{{
+				Filters: []gwv1.HTTPRouteFilter{{
+					Type: gwv1.HTTPRouteFilterRequestRedirect,
+					RequestRedirect: &gwv1.HTTPRequestRedirectFilter{
+						Scheme:     ptr.To("https"),
+						StatusCode: ptr.To(301),
+					},
+				}},
+			}},
+		},
+	}
+}
+
+// CreateWildcardCertificate is the certificate of the wildcard listener, see wildcardListener
+func CreateWildcardCertificate(input types.Stolos) *certmanagerv1.Certificate {
+	return &certmanagerv1.Certificate{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Certificate",
+			APIVersion: "cert-manager.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wildcardTLSSecret,
+			Namespace: input.Spec.Ingress.Gateway.Namespace,
+		},
+		Spec: certmanagerv1.CertificateSpec{
+			SecretName: wildcardTLSSecret,
+			IssuerRef: certmanagermetav1.ObjectReference{
+				Name: input.Spec.CertManager.DefaultClusterIssuer,
+				Kind: "ClusterIssuer",
+			},
+			CommonName: "*." + input.Spec.BaseDomain,
+			DNSNames:   []string{"*." + input.Spec.BaseDomain},
+		},
+	}
+}
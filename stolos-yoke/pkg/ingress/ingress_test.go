@@ -0,0 +1,57 @@
+package ingress_test
+
+import (
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/ingress"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
+	"github.com/yokecd/yoke/pkg/flight"
+	gwv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestWildcardListener(t *testing.T) {
+	tests := []struct {
+		name       string
+		selfSigned bool
+		dns01      string
+		want       bool
+	}{
+		{name: "http-01", want: false},
+		{name: "dns-01", dns01: "cloudflare", want: true},
+		{name: "self-signed", selfSigned: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := rendertest.Platform(t)
+			input.Spec.Ingress.Provider = routing.ProviderGateway
+			input.Spec.CertManager.SelfSigned = tt.selfSigned
+			input.Spec.CertManager.DNS01.Provider = tt.dns01
+
+			var gateway *gwv1.Gateway
+			certificate := false
+			rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+				resources := ingress.AllIngress(input, nil)
+				for _, res := range resources {
+					switch res.GroupVersionKind().Kind {
+					case "Gateway":
+						gateway = res.(*gwv1.Gateway)
+					case "Certificate":
+						certificate = true
+					}
+				}
+				return resources
+			})
+
+			listener := false
+			for _, l := range gateway.Spec.Listeners {
+				if l.Hostname != nil && *l.Hostname == gwv1.Hostname("*."+input.Spec.BaseDomain) {
+					listener = true
+				}
+			}
+			if listener != tt.want || certificate != tt.want {
+				t.Errorf("wildcard listener %v and certificate %v, want %v", listener, certificate, tt.want)
+			}
+		})
+	}
+}
@@ -1,22 +1,74 @@
 package metallb
 
 import (
+	"maps"
+	"slices"
+
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/argocd"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
 	"github.com/yokecd/yoke/pkg/flight"
 	metallb "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
 )
 
 func AllMetalLB(input types.Stolos) []flight.Resource {
-	return []flight.Resource{
+	all := []flight.Resource{
 		CreateMetalLBNamespace(input),
 		DeployMetalLBHelm(input),
-		DeployIPAddressPool(input),
-		DeployL2Advertisement(input),
 	}
+
+	pools := Pools(input)
+	for _, pool := range pools {
+		all = append(all, DeployIPAddressPool(input, pool))
+	}
+	if l2Pools := poolNames(pools, true); len(l2Pools) > 0 {
+		all = append(all, DeployL2Advertisement(input, l2Pools))
+	}
+
+	bgp := input.Spec.MetalLB.BGP
+	if len(bgp.Peers) == 0 {
+		return all
+	}
+	for _, peer := range bgp.Peers {
+		all = append(all, DeployBGPPeer(input, peer))
+	}
+	if len(bgp.Communities) > 0 {
+		all = append(all, DeployCommunities(input))
+	}
+	for _, advertisement := range BGPAdvertisements(input, pools) {
+		all = append(all, DeployBGPAdvertisement(input, advertisement))
+	}
+	return all
+}
+
+// Pools are the pools of the spec, or the single address pool of ArpIp
+func Pools(input types.Stolos) []types.MetalLBPool {
+	if len(input.Spec.MetalLB.Pools) > 0 {
+		return input.Spec.MetalLB.Pools
+	}
+	if input.Spec.MetalLB.ArpIp == "" {
+		return nil
+	}
+	return []types.MetalLBPool{{
+		Name:       "public-ip",
+		Addresses:  []string{input.Spec.MetalLB.ArpIp + "/32"},
+		AutoAssign: true,
+		L2:         input.Spec.MetalLB.ConfigureArp,
+	}}
+}
+
+func poolNames(pools []types.MetalLBPool, l2 bool) []string {
+	names := []string{}
+	for _, pool := range pools {
+		if pool.L2 == l2 {
+			names = append(names, pool.Name)
+		}
+	}
+	return names
 }
 
 func CreateMetalLBNamespace(input types.Stolos) *v1.Namespace {
@@ -64,36 +116,140 @@ func DeployMetalLBHelm(input types.Stolos) *types.Application {
 	return &app
 }
 
-func DeployIPAddressPool(input types.Stolos) *metallb.IPAddressPool {
-	autoAssign := true
+func DeployIPAddressPool(input types.Stolos, pool types.MetalLBPool) *metallb.IPAddressPool {
+	spec := metallb.IPAddressPoolSpec{
+		Addresses:  pool.Addresses,
+		AutoAssign: ptr.To(pool.AutoAssign),
+	}
+	if len(pool.Namespaces) > 0 || len(pool.NamespaceSelector) > 0 {
+		spec.AllocateTo = &metallb.ServiceAllocation{
+			Namespaces: pool.Namespaces,
+		}
+		if len(pool.NamespaceSelector) > 0 {
+			spec.AllocateTo.NamespaceSelectors = []metav1.LabelSelector{{MatchLabels: pool.NamespaceSelector}}
+		}
+	}
+
 	return &metallb.IPAddressPool{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "IPAddressPool",
 			APIVersion: "metallb.io/v1beta1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "public-ip",
+			Name:      pool.Name,
 			Namespace: input.Spec.MetalLB.Namespace,
 		},
-		Spec: metallb.IPAddressPoolSpec{
-			Addresses:  []string{input.Spec.MetalLB.ArpIp + "/32"},
-			AutoAssign: &autoAssign,
-		},
+		Spec: spec,
 	}
 }
 
-func DeployL2Advertisement(input types.Stolos) *metallb.L2Advertisement {
+func DeployL2Advertisement(input types.Stolos, pools []string) *metallb.L2Advertisement {
 	return &metallb.L2Advertisement{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "L2Advertisement",
 			APIVersion: "metallb.io/v1beta1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "public-ip",
+			Name:      "stolos-l2",
 			Namespace: input.Spec.MetalLB.Namespace,
 		},
 		Spec: metallb.L2AdvertisementSpec{
-			IPAddressPools: []string{"public-ip"},
+			IPAddressPools: pools,
+			Interfaces:     input.Spec.MetalLB.L2Interfaces,
+		},
+	}
+}
+
+func DeployBGPPeer(input types.Stolos, peer types.MetalLBBGPPeer) *metallbv1beta2.BGPPeer {
+	spec := metallbv1beta2.BGPPeerSpec{
+		MyASN:        peer.MyASN,
+		ASN:          peer.ASN,
+		Address:      peer.Address,
+		Port:         uint16(peer.Port),
+		EBGPMultiHop: peer.EBGPMultiHop,
+	}
+	if len(peer.NodeSelector) > 0 {
+		spec.NodeSelectors = []metav1.LabelSelector{{MatchLabels: peer.NodeSelector}}
+	}
+	if peer.PasswordSecret != "" {
+		spec.PasswordSecret = v1.SecretReference{
+			Name:      peer.PasswordSecret,
+			Namespace: input.Spec.MetalLB.Namespace,
+		}
+	}
+
+	return &metallbv1beta2.BGPPeer{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "BGPPeer",
+			APIVersion: "metallb.io/v1beta2",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      peer.Name,
+			Namespace: input.Spec.MetalLB.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// DeployCommunities declares the community aliases usable in the BGP advertisements
+func DeployCommunities(input types.Stolos) *metallb.Community {
+	communities := input.Spec.MetalLB.BGP.Communities
+	aliases := []metallb.CommunityAlias{}
+	for _, name := range slices.Sorted(maps.Keys(communities)) {
+		aliases = append(aliases, metallb.CommunityAlias{Name: name, Value: communities[name]})
+	}
+
+	return &metallb.Community{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Community",
+			APIVersion: "metallb.io/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stolos-communities",
+			Namespace: input.Spec.MetalLB.Namespace,
+		},
+		Spec: metallb.CommunitySpec{
+			Communities: aliases,
+		},
+	}
+}
+
+// BGPAdvertisements are the advertisements of the spec, or one announcing the pools without L2 to all the peers
+func BGPAdvertisements(input types.Stolos, pools []types.MetalLBPool) []types.MetalLBBGPAdvertisement {
+	if len(input.Spec.MetalLB.BGP.Advertisements) > 0 {
+		return input.Spec.MetalLB.BGP.Advertisements
+	}
+	bgpPools := poolNames(pools, false)
+	if len(bgpPools) == 0 {
+		return nil
+	}
+	return []types.MetalLBBGPAdvertisement{{
+		Name:              "stolos-bgp",
+		Pools:             bgpPools,
+		AggregationLength: 32,
+	}}
+}
+
+func DeployBGPAdvertisement(input types.Stolos, advertisement types.MetalLBBGPAdvertisement) *metallb.BGPAdvertisement {
+	spec := metallb.BGPAdvertisementSpec{
+		IPAddressPools: advertisement.Pools,
+		Peers:          advertisement.Peers,
+		Communities:    advertisement.Communities,
+		LocalPref:      advertisement.LocalPref,
+	}
+	if advertisement.AggregationLength > 0 {
+		spec.AggregationLength = ptr.To(advertisement.AggregationLength)
+	}
+
+	return &metallb.BGPAdvertisement{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "BGPAdvertisement",
+			APIVersion: "metallb.io/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      advertisement.Name,
+			Namespace: input.Spec.MetalLB.Namespace,
 		},
+		Spec: spec,
 	}
 }
@@ -0,0 +1,85 @@
+package metallb_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/metallb"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/yokecd/yoke/pkg/flight"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+)
+
+func allMetalLB(t *testing.T, input types.Stolos) []flight.Resource {
+	return rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return metallb.AllMetalLB(input)
+	})
+}
+
+func identifiers(resources []flight.Resource) []string {
+	ids := []string{}
+	for _, res := range resources {
+		ids = append(ids, render.Identifier(res))
+	}
+	return ids
+}
+
+// Without pools, the ARP address is a single pool announced with L2
+func TestArpIp(t *testing.T) {
+	input := rendertest.Platform(t)
+	resources := allMetalLB(t, input)
+
+	want := []string{
+		"v1 Namespace metallb-system",
+		"argoproj.io/v1alpha1 Application argocd/metallb",
+		"metallb.io/v1beta1 IPAddressPool metallb-system/public-ip",
+		"metallb.io/v1beta1 L2Advertisement metallb-system/stolos-l2",
+	}
+	if got := identifiers(resources); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	pool := resources[2].(*metallbv1beta1.IPAddressPool)
+	if !slices.Equal(pool.Spec.Addresses, []string{"192.168.1.240/32"}) {
+		t.Errorf("expected the ARP address in the pool, got %v", pool.Spec.Addresses)
+	}
+}
+
+func TestBGP(t *testing.T) {
+	input := rendertest.Platform(t)
+	input.Spec.MetalLB.Pools = []types.MetalLBPool{
+		{Name: "lan", Addresses: []string{"192.168.10.0/24"}, AutoAssign: true, L2: true},
+		{Name: "public", Addresses: []string{"203.0.113.0/28"}, AutoAssign: false},
+	}
+	input.Spec.MetalLB.BGP = types.MetalLBBGP{
+		Peers: []types.MetalLBBGPPeer{
+			{Name: "router", Address: "192.168.10.1", ASN: 64512, MyASN: 64513, Port: 179},
+		},
+		Communities: map[string]string{"no-advertise": "65535:65282"},
+	}
+	resources := allMetalLB(t, input)
+
+	want := []string{
+		"v1 Namespace metallb-system",
+		"argoproj.io/v1alpha1 Application argocd/metallb",
+		"metallb.io/v1beta1 IPAddressPool metallb-system/lan",
+		"metallb.io/v1beta1 IPAddressPool metallb-system/public",
+		"metallb.io/v1beta1 L2Advertisement metallb-system/stolos-l2",
+		"metallb.io/v1beta2 BGPPeer metallb-system/router",
+		"metallb.io/v1beta1 Community metallb-system/stolos-communities",
+		"metallb.io/v1beta1 BGPAdvertisement metallb-system/stolos-bgp",
+	}
+	if got := identifiers(resources); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	l2 := resources[4].(*metallbv1beta1.L2Advertisement)
+	if !slices.Equal(l2.Spec.IPAddressPools, []string{"lan"}) {
+		t.Errorf("expected only the L2 pool announced with L2, got %v", l2.Spec.IPAddressPools)
+	}
+	bgp := resources[7].(*metallbv1beta1.BGPAdvertisement)
+	if !slices.Equal(bgp.Spec.IPAddressPools, []string{"public"}) {
+		t.Errorf("expected the pools without L2 announced with BGP, got %v", bgp.Spec.IPAddressPools)
+	}
+}
@@ -0,0 +1,342 @@
+package monitoring
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
+	"github.com/yokecd/yoke/pkg/flight"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+//go:embed manifests
+var Manifests embed.FS
+
+// component is a directory of manifests/ with the settings patched into its resources
+type component struct {
+	name            string
+	enabled         bool
+	volume          string // data volume, persisted in a PVC when size is set
+	size            string
+	retention       string
+	retentionArg    string         // container argument of the retention
+	retentionConfig *regexp.Regexp // ConfigMap setting of the retention
+}
+
+func components(input types.Stolos) []component {
+	spec := input.Spec.Monitoring
+	return []component{
+		{
+			name:         "prometheus",
+			enabled:      spec.Components.Prometheus,
+			volume:       "data-volume",
+			size:         spec.Storage.Prometheus,
+			retention:    spec.Retention.Metrics,
+			retentionArg: "--storage.tsdb.retention.time=",
+		},
+		{
+			name:         "mimir",
+			enabled:      spec.Components.Mimir,
+			volume:       "data",
+			size:         spec.Storage.Mimir,
+			retention:    spec.Retention.Metrics,
+			retentionArg: "-compactor.blocks-retention-period=",
+		},
+		{
+			name:            "loki",
+			enabled:         spec.Components.Loki,
+			volume:          "storage",
+			size:            spec.Storage.Loki,
+			retention:       spec.Retention.Logs,
+			retentionConfig: regexp.MustCompile(`(?m)^(\s*retention_period:\s*)\S+$`),
+		},
+		{
+			name:            "tempo",
+			enabled:         spec.Components.Tempo,
+			volume:          "tempo-storage",
+			size:            spec.Storage.Tempo,
+			retention:       spec.Retention.Traces,
+			retentionConfig: regexp.MustCompile(`(?m)^(\s*block_retention:\s*)\S+$`),
+		},
+		{
+			name:    "grafana",
+			enabled: spec.Components.Grafana,
+			volume:  "grafana-storage",
+			size:    spec.Storage.Grafana,
+		},
+		{
+			name:    "pyroscope",
+			enabled: spec.Components.Pyroscope,
+			volume:  "storage",
+			size:    spec.Storage.Pyroscope,
+		},
+		{
+			name:    "alloy",
+			enabled: spec.Components.Alloy,
+		},
+		{
+			name:    "obi",
+			enabled: spec.Components.Obi,
+		},
+	}
+}
+
+func AllMonitoring(input types.Stolos) []flight.Resource {
+	resources := []flight.Resource{
+		CreateMonitoringNamespace(input),
+	}
+	if input.Spec.Monitoring.Components.Grafana {
+		resources = append(resources, CreateGrafanaAdminSecret(input))
+	}
+
+	for _, c := range components(input) {
+		if !c.enabled {
+			continue
+		}
+		resources = append(resources, DeployComponent(input, c)...)
+	}
+
+	for _, route := range Routes(input) {
+		resources = append(resources, routing.Resources(utils.IngressConfig(input), route)...)
+	}
+
+	return resources
+}
+
+// TempoOTLPEndpoint is the OTLP/HTTP endpoint receiving traces in Tempo, empty when Tempo is not deployed
+func TempoOTLPEndpoint(input types.Stolos) string {
+	spec := input.Spec.Monitoring
+	if !spec.Deploy || !spec.Components.Tempo {
+		return ""
+	}
+	return fmt.Sprintf("http://tempo.%s.svc:4318", spec.Namespace)
+}
+
+func CreateMonitoringNamespace(input types.Stolos) *corev1.Namespace {
+	ns := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: input.Spec.Monitoring.Namespace,
+			Labels: map[string]string{
+				"pod-security.kubernetes.io/enforce": "privileged", // alloy and obi read from the host
+				"pod-security.kubernetes.io/audit":   "privileged",
+				"pod-security.kubernetes.io/warn":    "privileged",
+			},
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&ns)
+	ns.SetGroupVersionKind(gvks[0])
+
+	return &ns
+}
+
+// CreateGrafanaAdminSecret holds the credentials of the Grafana admin, read by the grafana Deployment. The password
+// is generated once and kept, Grafana only sets it when it creates its database.
+func CreateGrafanaAdminSecret(input types.Stolos) *corev1.Secret {
+	secretName := "grafana-admin"
+	existingSecret, err := utils.GetExistingSecret(secretName, input.Spec.Monitoring.Namespace)
+
+	password := ""
+	if err == nil && existingSecret != nil {
+		password = string(existingSecret.Data["admin-password"])
+	}
+	if password == "" {
+		password = utils.GenerateRandomString(16)
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: input.Spec.Monitoring.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name": "grafana",
+			},
+		},
+		StringData: map[string]string{
+			"admin-user":     "admin",
+			"admin-password": password,
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&secret)
+	secret.SetGroupVersionKind(gvks[0])
+
+	return &secret
+}
+
+// DeployComponent renders the manifests of a component in the monitoring namespace
+func DeployComponent(input types.Stolos, c component) []flight.Resource {
+	namespace := input.Spec.Monitoring.Namespace
+	dir := path.Join("manifests", c.name)
+	entries, err := Manifests.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s manifests: %v\n", c.name, err)
+		return nil
+	}
+
+	var results []flight.Resource
+	for _, entry := range entries {
+		data, err := Manifests.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", entry.Name(), err)
+			continue
+		}
+
+		for _, res := range utils.ReadMultiDocument(data) {
+			switch res.GetKind() {
+			case "ClusterRole":
+				results = append(results, &res)
+			case "ClusterRoleBinding":
+				crb := utils.ConvertUnstructured[rbacv1.ClusterRoleBinding](res)
+				for i := range crb.Subjects {
+					if crb.Subjects[i].Kind == "ServiceAccount" {
+						crb.Subjects[i].Namespace = namespace
+					}
+				}
+				results = append(results, &crb)
+			case "ConfigMap":
+				res.SetNamespace(namespace)
+				cm := utils.ConvertUnstructured[corev1.ConfigMap](res)
+				if c.retentionConfig != nil && c.retention != "" {
+					for key, value := range cm.Data {
+						cm.Data[key] = c.retentionConfig.ReplaceAllString(value, "${1}"+c.retention)
+					}
+				}
+				results = append(results, &cm)
+			case "Deployment":
+				res.SetNamespace(namespace)
+				dep := utils.ConvertUnstructured[appsv1.Deployment](res)
+				setRetentionArg(&dep.Spec.Template.Spec, c)
+				if pvc := persistVolume(input, &dep.Spec.Template.Spec, c); pvc != nil {
+					// A ReadWriteOnce volume can't be mounted by the old and the new pod at once
+					dep.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+					results = append(results, pvc)
+				}
+				results = append(results, &dep)
+			case "StatefulSet":
+				res.SetNamespace(namespace)
+				sts := utils.ConvertUnstructured[appsv1.StatefulSet](res)
+				setRetentionArg(&sts.Spec.Template.Spec, c)
+				if pvc := persistVolume(input, &sts.Spec.Template.Spec, c); pvc != nil {
+					results = append(results, pvc)
+				}
+				results = append(results, &sts)
+			case "DaemonSet":
+				res.SetNamespace(namespace)
+				ds := utils.ConvertUnstructured[appsv1.DaemonSet](res)
+				for i := range ds.Spec.Template.Spec.Containers {
+					for j, env := range ds.Spec.Template.Spec.Containers[i].Env {
+						if env.Name == "OTEL_EBPF_KUBE_CLUSTER_NAME" {
+							ds.Spec.Template.Spec.Containers[i].Env[j].Value = input.Spec.ClusterName
+						}
+					}
+				}
+				results = append(results, &ds)
+			default:
+				res.SetNamespace(namespace)
+				results = append(results, &res)
+			}
+		}
+	}
+
+	return results
+}
+
+func setRetentionArg(pod *corev1.PodSpec, c component) {
+	if c.retentionArg == "" || c.retention == "" {
+		return
+	}
+	for i := range pod.Containers {
+		for j, arg := range pod.Containers[i].Args {
+			if strings.HasPrefix(arg, c.retentionArg) {
+				pod.Containers[i].Args[j] = c.retentionArg + c.retention
+			}
+		}
+	}
+}
+
+// persistVolume replaces the emptyDir data volume of a component with a PVC, the components run a single replica
+func persistVolume(input types.Stolos, pod *corev1.PodSpec, c component) *corev1.PersistentVolumeClaim {
+	if c.volume == "" || c.size == "" {
+		return nil
+	}
+	size, err := resource.ParseQuantity(c.size)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s storage size %q, keeping an emptyDir: %v\n", c.name, c.size, err)
+		return nil
+	}
+
+	found := false
+	for i := range pod.Volumes {
+		if pod.Volumes[i].Name == c.volume {
+			pod.Volumes[i].VolumeSource = corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: c.name + "-data"},
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	// Non-root components need to own the volume
+	if pod.SecurityContext != nil && pod.SecurityContext.FSGroup == nil && pod.SecurityContext.RunAsGroup != nil {
+		pod.SecurityContext.FSGroup = pod.SecurityContext.RunAsGroup
+	}
+
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.name + "-data",
+			Namespace: input.Spec.Monitoring.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name": c.name,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: size,
+				},
+			},
+		},
+	}
+	if input.Spec.Monitoring.StorageClass != "" {
+		pvc.Spec.StorageClassName = utils.PtrTo(input.Spec.Monitoring.StorageClass)
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&pvc)
+	pvc.SetGroupVersionKind(gvks[0])
+
+	return &pvc
+}
+
+// Routes are the hosts of the monitoring stack, Grafana when it has a subdomain. Its admin password is in the
+// grafana-admin Secret.
+func Routes(input types.Stolos) []routing.Route {
+	if !input.Spec.Monitoring.Components.Grafana || input.Spec.Monitoring.GrafanaSubdomain == "" {
+		return nil
+	}
+	return []routing.Route{
+		{
+			Name:      "grafana",
+			Namespace: input.Spec.Monitoring.Namespace,
+			Host:      input.Spec.Monitoring.GrafanaSubdomain + "." + input.Spec.BaseDomain,
+			TLSSecret: "grafana-tls",
+			Rules: []routing.Rule{
+				{Service: "grafana", Port: 3000, WebSockets: true}, // grafana live
+			},
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package monitoring_test
+
+import (
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/monitoring"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/yokecd/yoke/pkg/flight"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// grafanaAdmin is the admin Secret of an installed Grafana, the flight keeps its password
+func grafanaAdmin(namespace string) corev1.Secret {
+	return corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "grafana-admin", Namespace: namespace},
+		Data:       map[string][]byte{"admin-password": []byte("test-grafana-password")},
+	}
+}
+
+func TestAllMonitoring(t *testing.T) {
+	input := rendertest.Platform(t)
+	cluster := rendertest.NewCluster(input, grafanaAdmin(input.Spec.Monitoring.Namespace))
+	resources := rendertest.Resources(t, cluster, func() []flight.Resource {
+		return monitoring.AllMonitoring(input)
+	})
+	rendertest.Golden(t, "monitoring", resources...)
+}
+
+func TestAllMonitoringWithoutGrafana(t *testing.T) {
+	input := rendertest.Platform(t)
+	input.Spec.Monitoring.Storage.Mimir = ""
+	input.Spec.Monitoring.Components.Grafana = false
+
+	resources := rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return monitoring.AllMonitoring(input)
+	})
+	for _, res := range resources {
+		switch res.GetName() {
+		case "grafana", "grafana-admin":
+			t.Errorf("%s %s rendered with Grafana disabled", res.GroupVersionKind().Kind, res.GetName())
+		case "mimir-data":
+			t.Error("mimir PVC rendered without a storage size")
+		}
+	}
+}
+
+func TestGrafanaAdminPassword(t *testing.T) {
+	input := rendertest.Platform(t)
+
+	var secret *corev1.Secret
+	rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		secret = monitoring.CreateGrafanaAdminSecret(input)
+		return []flight.Resource{secret}
+	})
+	if password := secret.StringData["admin-password"]; password == "" || password == "admin" {
+		t.Errorf("expected a generated admin password, got %q", password)
+	}
+
+	rendertest.Resources(t, rendertest.NewCluster(input, grafanaAdmin(input.Spec.Monitoring.Namespace)), func() []flight.Resource {
+		secret = monitoring.CreateGrafanaAdminSecret(input)
+		return []flight.Resource{secret}
+	})
+	if password := secret.StringData["admin-password"]; password != "test-grafana-password" {
+		t.Errorf("expected the admin password of the cluster to be kept, got %q", password)
+	}
+}
+
+func TestMimirPersistsItsData(t *testing.T) {
+	input := rendertest.Platform(t)
+
+	var sts *appsv1.StatefulSet
+	rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		resources := monitoring.AllMonitoring(input)
+		for _, res := range resources {
+			if s, ok := res.(*appsv1.StatefulSet); ok && s.Name == "mimir" {
+				sts = s
+			}
+		}
+		return resources
+	})
+	if sts == nil {
+		t.Fatal("mimir StatefulSet not rendered")
+	}
+
+	volumes := map[string]corev1.VolumeSource{}
+	for _, v := range sts.Spec.Template.Spec.Volumes {
+		volumes[v.Name] = v.VolumeSource
+	}
+	for _, mount := range sts.Spec.Template.Spec.Containers[0].VolumeMounts {
+		switch mount.MountPath {
+		case "/data", "/tsdb", "/tsdb-sync", "/compactor":
+			if volumes[mount.Name].PersistentVolumeClaim == nil {
+				t.Errorf("%s is on volume %s, not on the PVC", mount.MountPath, mount.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package render collects the resources of the modules enabled in the spec. The flight applies them, its dry-run
+// reports them and the tests compare them to golden files.
+package render
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/argocd"
+	cert_manager "github.com/stolos-cloud/stolos/stolos-yoke/pkg/cert-manager"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/cnpg"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/contour"
+	external_dns "github.com/stolos-cloud/stolos/stolos-yoke/pkg/external-dns"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/ingress"
+	localpathprovisioner "github.com/stolos-cloud/stolos/stolos-yoke/pkg/local-path-provisioner"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/metallb"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/monitoring"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/stolos"
+	types "github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
+	"github.com/yokecd/yoke/pkg/flight"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func init() {
+	// The modules set the kind of their argocd applications from the scheme
+	argoApp := &types.Application{}
+	scheme.Scheme.AddKnownTypes(argoApp.GetObjectKind().GroupVersionKind().GroupVersion(), argoApp)
+}
+
+// Skipped is a resource left out of the flight because the cluster doesn't serve its kind yet
+type Skipped struct {
+	Resource flight.Resource
+	Reason   error
+}
+
+func (s Skipped) String() string {
+	return fmt.Sprintf("%s: %v", Identifier(s.Resource), s.Reason)
+}
+
+// Result is the flight for a spec
+type Result struct {
+	Resources []flight.Resource // first stage, including the migration job of the platform
+	Workloads []flight.Resource // second stage, rolled out once the first one is ready
+	Skipped   []Skipped
+}
+
+// Stages are the resources applied by yoke, stage after stage
+func (r Result) Stages() flight.Stages {
+	return flight.Stages{r.Resources, r.Workloads}
+}
+
+// Render collects the resources of the modules enabled in the spec. The resources of kinds the cluster doesn't
+// serve are skipped, they are applied by a later run once their CRDs are installed.
+func Render(input types.Stolos) Result {
+	resources := []flight.Resource{}
+	if input.Spec.ArgoCD.Deploy {
+		resources = append(resources, argocd.AllArgoCD(input)...)
+	}
+
+	if input.Spec.LocalPathProvisioner.Deploy {
+		resources = append(resources, localpathprovisioner.AllLocalPathProvisioner(input)...)
+	}
+
+	if input.Spec.MetalLB.Deploy {
+		resources = append(resources, metallb.AllMetalLB(input)...)
+	}
+
+	if input.Spec.CertManager.Deploy {
+		resources = append(resources, cert_manager.AllCertManager(input)...)
+	}
+
+	if input.Spec.ExternalDNS.Deploy {
+		resources = append(resources, external_dns.AllExternalDNS(input)...)
+	}
+
+	if input.Spec.CNPG.Deploy {
+		resources = append(resources, cnpg.AllCnpg(input)...)
+	}
+
+	if input.Spec.Contour.Deploy {
+		resources = append(resources, contour.AllContour(input)...)
+	}
+
+	// The workloads of the platform are the second stage of the flight
+	workloads := []flight.Resource{}
+	if input.Spec.StolosPlatform.Deploy {
+		resources = append(resources, stolos.AllStolos(input)...)
+		workloads = append(workloads, stolos.AllStolosWorkloads(input)...)
+	}
+
+	if input.Spec.Monitoring.Deploy {
+		resources = append(resources, monitoring.AllMonitoring(input)...)
+	}
+
+	// The gateway has a listener for each host of the platform
+	routes := []routing.Route{}
+	if input.Spec.ArgoCD.Deploy {
+		routes = append(routes, argocd.ArgocdRoute(input))
+	}
+	if input.Spec.StolosPlatform.Deploy {
+		routes = append(routes, stolos.Routes(input)...)
+	}
+	if input.Spec.Monitoring.Deploy {
+		routes = append(routes, monitoring.Routes(input)...)
+	}
+	resources = append(resources, ingress.AllIngress(input, routes)...)
+
+	result := Result{Workloads: workloads}
+	coreScheme := k8sruntime.NewScheme()
+	scheme.AddToScheme(coreScheme)
+	allTypes := coreScheme.AllKnownTypes()
+	existingCrds := map[schema.GroupVersionKind]bool{}
+
+	for _, res := range resources {
+		_, isCoreRes := allTypes[res.GroupVersionKind()]
+		_, crdExists := existingCrds[res.GroupVersionKind()]
+		if isCoreRes || crdExists {
+			result.Resources = append(result.Resources, res)
+			continue
+		}
+
+		err := utils.RestMapping(res.GroupVersionKind().GroupVersion().String(), res.GroupVersionKind().Kind)
+		if err == nil {
+			result.Resources = append(result.Resources, res)
+			existingCrds[res.GroupVersionKind()] = true
+		} else {
+			result.Skipped = append(result.Skipped, Skipped{Resource: res, Reason: err})
+		}
+	}
+
+	return result
+}
+
+// Identifier names a resource, e.g. "apps/v1 Deployment stolos-system/stolos-backend"
+func Identifier(res flight.Resource) string {
+	name := res.GetName()
+	if res.GetNamespace() != "" {
+		name = res.GetNamespace() + "/" + name
+	}
+	return fmt.Sprintf("%s %s %s", res.GroupVersionKind().GroupVersion(), res.GroupVersionKind().Kind, name)
+}
+
+// Report writes the resources of each stage and the skipped ones with the reason, the output of the dry-run
+func Report(w io.Writer, result Result) error {
+	for i, stage := range result.Stages() {
+		if _, err := fmt.Fprintf(w, "stage %d:\n", i+1); err != nil {
+			return err
+		}
+		for _, res := range stage {
+			if _, err := fmt.Fprintf(w, "  apply %s\n", Identifier(res)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(result.Skipped) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(w, "skipped:"); err != nil {
+		return err
+	}
+	for _, skipped := range result.Skipped {
+		if _, err := fmt.Fprintf(w, "  %s\n", skipped); err != nil {
+			return err
+		}
+	}
+	return nil
+}
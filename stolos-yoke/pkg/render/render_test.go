@@ -0,0 +1,53 @@
+package render_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+func TestRenderPlatform(t *testing.T) {
+	input := rendertest.Platform(t)
+	result := rendertest.Render(t, rendertest.NewCluster(input), input)
+
+	var report bytes.Buffer
+	if err := render.Report(&report, result); err != nil {
+		t.Fatal(err)
+	}
+	rendertest.GoldenBytes(t, "platform.golden", report.Bytes())
+}
+
+func TestRenderSkipsMissingKinds(t *testing.T) {
+	input := rendertest.Platform(t)
+	utils.SetCluster(&rendertest.Cluster{})
+	result := render.Render(input)
+
+	if len(result.Skipped) == 0 {
+		t.Fatal("expected the resources of the platform kinds to be skipped on a cluster without their CRDs")
+	}
+	for _, skipped := range result.Skipped {
+		if !meta.IsNoMatchError(skipped.Reason) {
+			t.Errorf("%s: expected a missing kind", skipped)
+		}
+	}
+
+	// A cluster with the CRDs applies everything the first one skipped
+	installed := rendertest.Render(t, rendertest.NewCluster(input), input)
+	if got, want := len(installed.Resources), len(result.Resources)+len(result.Skipped); got != want {
+		t.Errorf("expected %d resources once the CRDs are installed, got %d", want, got)
+	}
+}
+
+// The sample spec of the repository is a valid StolosPlatform
+func TestSampleSpec(t *testing.T) {
+	data, err := os.ReadFile("../../../stolos-platform.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendertest.Input(t, data)
+}
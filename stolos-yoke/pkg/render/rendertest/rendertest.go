@@ -0,0 +1,376 @@
+// Package rendertest runs the flight against a fake cluster, validates the rendered resources against their OpenAPI
+// schemas and compares them to golden files. Run the tests with -update to rewrite the golden files.
+package rendertest
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/yokecd/yoke/pkg/flight"
+	yokeK8s "github.com/yokecd/yoke/pkg/flight/wasi/k8s"
+	"github.com/yokecd/yoke/pkg/openapi"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	structuraldefaulting "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/defaulting"
+	structuralpruning "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/applyconfigurations"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the rendered resources")
+
+//go:embed platform.yaml
+var platformYaml []byte
+
+// PlatformKinds are the custom kinds of the components installed by the platform
+var PlatformKinds = []schema.GroupVersionKind{
+	{Group: "argoproj.io", Version: "v1alpha1", Kind: "Application"},
+	{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"},
+	{Group: "cert-manager.io", Version: "v1", Kind: "ClusterIssuer"},
+	{Group: "postgresql.cnpg.io", Version: "v1", Kind: "Cluster"},
+	{Group: "postgresql.cnpg.io", Version: "v1", Kind: "ScheduledBackup"},
+	{Group: "barmancloud.cnpg.io", Version: "v1", Kind: "ObjectStore"},
+	{Group: "projectcontour.io", Version: "v1", Kind: "HTTPProxy"},
+	{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "GatewayClass"},
+	{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "Gateway"},
+	{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"},
+	{Group: "metallb.io", Version: "v1beta1", Kind: "IPAddressPool"},
+	{Group: "metallb.io", Version: "v1beta1", Kind: "L2Advertisement"},
+	{Group: "metallb.io", Version: "v1beta1", Kind: "BGPAdvertisement"},
+	{Group: "metallb.io", Version: "v1beta1", Kind: "Community"},
+	{Group: "metallb.io", Version: "v1beta2", Kind: "BGPPeer"},
+	{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"},
+}
+
+// Cluster is a fake cluster serving the built-in kinds, the kinds of its CRDs and Kinds
+type Cluster struct {
+	Kinds   []schema.GroupVersionKind
+	CRDs    []apiextv1.CustomResourceDefinition // their schemas validate the resources of their kinds
+	Secrets []corev1.Secret
+}
+
+// NewCluster is a cluster where the platform is installed: it serves PlatformKinds and the kind of the flight input,
+// and has the JWT key of the backend, which the flight keeps instead of generating a new one
+func NewCluster(input types.Stolos, secrets ...corev1.Secret) *Cluster {
+	backendSecrets := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stolos-backend-secrets",
+			Namespace: input.Spec.StolosPlatform.Namespace,
+		},
+		Data: map[string][]byte{
+			"JWT_SECRET_KEY": []byte("test-jwt-secret-key"),
+		},
+	}
+	return &Cluster{
+		Kinds:   PlatformKinds,
+		CRDs:    []apiextv1.CustomResourceDefinition{PlatformCRD()},
+		Secrets: append([]corev1.Secret{backendSecrets}, secrets...),
+	}
+}
+
+func (c *Cluster) RestMapping(apiVersion, kind string) error {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return err
+	}
+	gvk := gv.WithKind(kind)
+	if builtinScheme.Recognizes(gvk) {
+		return nil
+	}
+	for _, served := range c.Kinds {
+		if served == gvk {
+			return nil
+		}
+	}
+	for _, crd := range c.CRDs {
+		if crdServes(crd, gvk) {
+			return nil
+		}
+	}
+	return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gv.Version}}
+}
+
+func (c *Cluster) Secret(name, namespace string) (*corev1.Secret, error) {
+	for _, secret := range c.Secrets {
+		if secret.Name == name && secret.Namespace == namespace {
+			return &secret, nil
+		}
+	}
+	return nil, yokeK8s.ErrorNotFound(fmt.Sprintf("secrets %q not found", name))
+}
+
+func crdServes(crd apiextv1.CustomResourceDefinition, gvk schema.GroupVersionKind) bool {
+	if crd.Spec.Group != gvk.Group || crd.Spec.Names.Kind != gvk.Kind {
+		return false
+	}
+	for _, version := range crd.Spec.Versions {
+		if version.Name == gvk.Version && version.Served {
+			return true
+		}
+	}
+	return false
+}
+
+// PlatformCRD is the CRD of the flight input, with the schema of the airway
+func PlatformCRD() apiextv1.CustomResourceDefinition {
+	return apiextv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "stolosplatforms.stolos.cloud"},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: "stolos.cloud",
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Plural:   "stolosplatforms",
+				Singular: "stolosplatform",
+				Kind:     "StolosPlatform",
+			},
+			Scope: apiextv1.ClusterScoped,
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{
+					Name:    "v1alpha",
+					Served:  true,
+					Storage: true,
+					Schema: &apiextv1.CustomResourceValidation{
+						OpenAPIV3Schema: openapi.SchemaFrom(reflect.TypeFor[types.Stolos]()),
+					},
+				},
+			},
+		},
+	}
+}
+
+// Platform is the input of a platform with every module deployed, see platform.yaml
+func Platform(t testing.TB) types.Stolos {
+	t.Helper()
+	return Input(t, platformYaml)
+}
+
+// Input decodes a StolosPlatform like the API server stores it: the defaults of the CRD are applied, and the unknown
+// fields and the values not matching the schema fail the test
+func Input(t testing.TB, data []byte) types.Stolos {
+	t.Helper()
+
+	var object map[string]any
+	if err := yaml.Unmarshal(data, &object); err != nil {
+		t.Fatalf("failed to decode the input: %v", err)
+	}
+
+	crd := PlatformCRD()
+	s, err := newCRDSchema(crd.Spec.Versions[0].Schema.OpenAPIV3Schema)
+	if err != nil {
+		t.Fatalf("failed to build the schema of the input: %v", err)
+	}
+	structuraldefaulting.Default(object, s.structural)
+	if err := s.validate(object); err != nil {
+		t.Fatalf("invalid input: %v", err)
+	}
+
+	var input types.Stolos
+	if err := k8sruntime.DefaultUnstructuredConverter.FromUnstructured(object, &input); err != nil {
+		t.Fatalf("failed to convert the input: %v", err)
+	}
+	return input
+}
+
+// Render runs the flight against the cluster. The resources skipped for a kind the cluster doesn't serve, and the
+// resources not matching their schema, fail the test.
+func Render(t testing.TB, cluster *Cluster, input types.Stolos) render.Result {
+	t.Helper()
+
+	utils.SetCluster(cluster)
+	result := render.Render(input)
+	for _, skipped := range result.Skipped {
+		t.Errorf("dropped %s", skipped)
+	}
+	for _, stage := range result.Stages() {
+		Validate(t, cluster, stage...)
+	}
+	return result
+}
+
+// Resources runs a module against the cluster, e.g. Resources(t, cluster, func() []flight.Resource { return
+// argocd.AllArgoCD(input) }), and validates the resources it renders
+func Resources(t testing.TB, cluster *Cluster, module func() []flight.Resource) []flight.Resource {
+	t.Helper()
+
+	utils.SetCluster(cluster)
+	resources := module()
+	Validate(t, cluster, resources...)
+	return resources
+}
+
+var builtinScheme = func() *k8sruntime.Scheme {
+	s := k8sruntime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+var builtinSchemas = applyconfigurations.NewTypeConverter(builtinScheme)
+
+// Validate checks the resources against the OpenAPI schemas of the built-in kinds, and of the kinds of the CRDs of
+// the cluster. The resources of other custom kinds aren't validated.
+func Validate(t testing.TB, cluster *Cluster, resources ...flight.Resource) {
+	t.Helper()
+
+	for _, res := range resources {
+		if err := validate(cluster, res); err != nil {
+			t.Errorf("%s: %v", render.Identifier(res), err)
+		}
+	}
+}
+
+func validate(cluster *Cluster, res flight.Resource) error {
+	object, err := toUnstructured(res)
+	if err != nil {
+		return err
+	}
+
+	gvk := res.GroupVersionKind()
+	if builtinScheme.Recognizes(gvk) {
+		_, err := builtinSchemas.ObjectToTyped(&unstructured.Unstructured{Object: object})
+		return err
+	}
+
+	for _, crd := range cluster.CRDs {
+		if !crdServes(crd, gvk) {
+			continue
+		}
+		for _, version := range crd.Spec.Versions {
+			if version.Name != gvk.Version || version.Schema == nil {
+				continue
+			}
+			s, err := newCRDSchema(version.Schema.OpenAPIV3Schema)
+			if err != nil {
+				return err
+			}
+			return s.validate(object)
+		}
+	}
+	return nil
+}
+
+func toUnstructured(res flight.Resource) (map[string]any, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal: %w", err)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	return object, nil
+}
+
+type crdSchema struct {
+	structural *structuralschema.Structural
+	validator  validation.SchemaValidator
+}
+
+func newCRDSchema(props *apiextv1.JSONSchemaProps) (*crdSchema, error) {
+	var internal apiextensions.JSONSchemaProps
+	if err := apiextv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(props, &internal, nil); err != nil {
+		return nil, fmt.Errorf("failed to convert the schema: %w", err)
+	}
+	structural, err := structuralschema.NewStructural(&internal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the structural schema: %w", err)
+	}
+	validator, _, err := validation.NewSchemaValidator(&internal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the schema validator: %w", err)
+	}
+	return &crdSchema{structural: structural, validator: validator}, nil
+}
+
+// validate rejects the fields the API server would prune, and the values not matching the schema
+func (s *crdSchema) validate(object map[string]any) error {
+	pruned := structuralpruning.PruneWithOptions(k8sruntime.DeepCopyJSON(object), s.structural, true,
+		structuralschema.UnknownFieldPathOptions{TrackUnknownFieldPaths: true})
+	if len(pruned) > 0 {
+		return fmt.Errorf("unknown fields %v", pruned)
+	}
+	if errs := validation.ValidateCustomResource(nil, object, s.validator); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	return nil
+}
+
+// Golden compares the resources, as YAML documents, to testdata/<name>.golden.yaml
+func Golden(t testing.TB, name string, resources ...flight.Resource) {
+	t.Helper()
+
+	var out bytes.Buffer
+	for i, res := range resources {
+		data, err := yaml.Marshal(res)
+		if err != nil {
+			t.Fatalf("failed to marshal %s: %v", render.Identifier(res), err)
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(data)
+	}
+	GoldenBytes(t, name+".golden.yaml", out.Bytes())
+}
+
+// GoldenBytes compares data to testdata/<name>
+func GoldenBytes(t testing.TB, name string, data []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create the testdata directory: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		return
+	}
+
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s, run the test with -update to create it: %v", path, err)
+	}
+	if !bytes.Equal(golden, data) {
+		t.Errorf("rendered resources differ from %s, run the test with -update if the change is expected:\n%s", path, diff(golden, data))
+	}
+}
+
+// diff is the first differing line, the golden files are long
+func diff(golden, rendered []byte) string {
+	goldenLines := bytes.Split(golden, []byte("\n"))
+	renderedLines := bytes.Split(rendered, []byte("\n"))
+	for i := range max(len(goldenLines), len(renderedLines)) {
+		var want, got []byte
+		if i < len(goldenLines) {
+			want = goldenLines[i]
+		}
+		if i < len(renderedLines) {
+			got = renderedLines[i]
+		}
+		if !bytes.Equal(want, got) {
+			return fmt.Sprintf("line %d:\n- %s\n+ %s", i+1, want, got)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package rendertest
+
+import (
+	"testing"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestValidate(t *testing.T) {
+	cluster := &Cluster{CRDs: []apiextv1.CustomResourceDefinition{PlatformCRD()}}
+
+	tests := []struct {
+		name   string
+		object map[string]any
+		valid  bool
+	}{
+		{
+			name: "built-in kind",
+			object: map[string]any{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]any{"name": "test"},
+				"data":       map[string]any{"key": "value"},
+			},
+			valid: true,
+		},
+		{
+			name: "unknown field of a built-in kind",
+			object: map[string]any{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]any{"name": "test"},
+				"datas":      map[string]any{"key": "value"},
+			},
+		},
+		{
+			name: "wrong type of a built-in kind",
+			object: map[string]any{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]any{"name": "test"},
+				"data":       map[string]any{"key": int64(1)},
+			},
+		},
+		{
+			name: "unknown field of a CRD",
+			object: map[string]any{
+				"apiVersion": "stolos.cloud/v1alpha",
+				"kind":       "StolosPlatform",
+				"metadata":   map[string]any{"name": "test"},
+				"spec":       map[string]any{"unknown": true},
+			},
+		},
+		{
+			name: "custom kind without schema",
+			object: map[string]any{
+				"apiVersion": "example.com/v1",
+				"kind":       "Example",
+				"metadata":   map[string]any{"name": "test"},
+			},
+			valid: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validate(cluster, &unstructured.Unstructured{Object: test.object})
+			if test.valid && err != nil {
+				t.Errorf("expected a valid resource, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected an invalid resource")
+			}
+		})
+	}
+}
@@ -2,30 +2,45 @@ package stolos
 
 import (
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
 	"github.com/yokecd/yoke/pkg/flight"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/kubectl/pkg/scheme"
 )
 
+// AllStolos returns the resources of the platform, except the workloads of AllStolosWorkloads
 func AllStolos(input types.Stolos) []flight.Resource {
-	return []flight.Resource{
+	all := []flight.Resource{
 		CreateStolosNamespace(input),
 		CreateDatabase(input),
 		CreateBackendServiceAccount(input),
 		CreateBackendClusterRole(input),
 		CreateBackendClusterRoleBinding(input),
 		CreateBackendSecrets(input),
-		CreateDeployment(input),
-		CreateBackendService(input),
-		CreateBackendGrpcService(input),
-		CreateBackendHttpProxy(input),
-		CreateBackendCertificate(input),
-		CreateFrontendDeployment(input),
-		CreateFrontendService(input),
-		CreateHTTPProxy(input),
-		CreateFrontendCertificate(input),
+		CreateMigrationJob(input),
+		CreateBackendServiceMonitor(input),
+		CreateIngressConfigMap(input),
 	}
+	all = append(all, AllDatabaseBackup(input)...)
+	for _, route := range Routes(input) {
+		all = append(all, routing.Resources(utils.IngressConfig(input), route)...)
+	}
+	return all
+}
+
+// Routes are the hosts of the platform
+func Routes(input types.Stolos) []routing.Route {
+	return []routing.Route{
+		BackendGrpcRoute(input),
+		FrontendRoute(input),
+	}
+}
+
+// CreateIngressConfigMap publishes the ingress implementation, so the templates can expose their services with it
+func CreateIngressConfigMap(input types.Stolos) *corev1.ConfigMap {
+	return routing.ConfigMap(utils.IngressConfig(input), input.Spec.StolosPlatform.Namespace)
 }
 
 func CreateStolosNamespace(input types.Stolos) *corev1.Namespace {
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"strconv"
 
-	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
-	certmanagerv1meta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
-	contourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/cnpg"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/monitoring"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -29,7 +29,7 @@ func CreateDeployment(input types.Stolos) *appsv1.Deployment {
 			},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: utils.PtrTo(int32(2)),
+			Replicas: componentReplicas(input.Spec.StolosPlatform.Backend),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": "stolos-backend",
@@ -43,10 +43,12 @@ func CreateDeployment(input types.Stolos) *appsv1.Deployment {
 				},
 				Spec: corev1.PodSpec{
 					ServiceAccountName: "stolos-backend-sa",
+					NodeSelector:       input.Spec.StolosPlatform.Backend.NodeSelector,
+					Tolerations:        input.Spec.StolosPlatform.Backend.Tolerations,
 					Containers: []corev1.Container{
 						{
 							Name:  "backend",
-							Image: "ghcr.io/stolos-cloud/stolos-backend:latest",
+							Image: ComponentImage(input, input.Spec.StolosPlatform.Backend, backendRepository),
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "http",
@@ -57,28 +59,14 @@ func CreateDeployment(input types.Stolos) *appsv1.Deployment {
 									ContainerPort: 8082,
 								},
 							},
-							Env: []corev1.EnvVar{
-								{Name: "PORT", Value: "8080"},
-								{Name: "DB_HOST", Value: "postgresql-stolos-rw"},
-								{Name: "DB_PORT", Value: "5432"},
-								{Name: "DB_USER", Value: "stolos"},
-								{Name: "DB_NAME", Value: "stolos"},
-								{Name: "ADMIN_EMAIL", Value: input.Spec.StolosPlatform.DefaultAdminEmail},
-								{Name: "ADMIN_PASSWORD", Value: input.Spec.StolosPlatform.DefaultAdminPassword},
-								{Name: "DB_SSL_MODE", Value: "disable"},
-								{Name: "TALOS_FOLDER", Value: "/app/talos-configs"},
-								{
-									Name: "DB_PASSWORD",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "postgresql-stolos-app",
-											},
-											Key: "password",
-										},
-									},
-								},
-							},
+							Env: append(databaseEnv(),
+								corev1.EnvVar{Name: "PORT", Value: "8080"},
+								corev1.EnvVar{Name: "ADMIN_EMAIL", Value: input.Spec.StolosPlatform.DefaultAdminEmail},
+								corev1.EnvVar{Name: "ADMIN_PASSWORD", Value: input.Spec.StolosPlatform.DefaultAdminPassword},
+								corev1.EnvVar{Name: "TALOS_FOLDER", Value: "/app/talos-configs"},
+								// The migration job has migrated the database before the rollout
+								corev1.EnvVar{Name: "DB_SKIP_MIGRATIONS", Value: "true"},
+							),
 							EnvFrom: []corev1.EnvFromSource{
 								{
 									SecretRef: &corev1.SecretEnvSource{
@@ -123,7 +111,7 @@ func CreateDeployment(input types.Stolos) *appsv1.Deployment {
 								InitialDelaySeconds: 5,
 								PeriodSeconds:       5,
 							},
-							Resources: corev1.ResourceRequirements{
+							Resources: componentResources(input.Spec.StolosPlatform.Backend.Resources, corev1.ResourceRequirements{
 								Requests: corev1.ResourceList{
 									corev1.ResourceMemory: resource.MustParse("256Mi"),
 									corev1.ResourceCPU:    resource.MustParse("250m"),
@@ -132,7 +120,7 @@ func CreateDeployment(input types.Stolos) *appsv1.Deployment {
 									corev1.ResourceMemory: resource.MustParse("512Mi"),
 									corev1.ResourceCPU:    resource.MustParse("500m"),
 								},
-							},
+							}),
 						},
 					},
 					Volumes: []corev1.Volume{
@@ -151,6 +139,14 @@ func CreateDeployment(input types.Stolos) *appsv1.Deployment {
 		},
 	}
 
+	if endpoint := monitoring.TempoOTLPEndpoint(input); endpoint != "" {
+		container := &deployment.Spec.Template.Spec.Containers[0]
+		container.Env = append(container.Env,
+			corev1.EnvVar{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: endpoint},
+			corev1.EnvVar{Name: "OTEL_SERVICE_NAME", Value: "stolos-backend"},
+		)
+	}
+
 	gvks, _, _ := scheme.Scheme.ObjectKinds(&deployment)
 	deployment.SetGroupVersionKind(gvks[0])
 
@@ -165,6 +161,12 @@ func CreateBackendService(input types.Stolos) *corev1.Service {
 			Labels: map[string]string{
 				"app": "stolos-backend",
 			},
+			// scrape config of the monitoring stack prometheus
+			Annotations: map[string]string{
+				"prometheus.io/scrape": "true",
+				"prometheus.io/port":   "8080",
+				"prometheus.io/path":   "/metrics",
+			},
 		},
 		Spec: corev1.ServiceSpec{
 			Type: corev1.ServiceTypeClusterIP,
@@ -189,6 +191,37 @@ func CreateBackendService(input types.Stolos) *corev1.Service {
 
 }
 
+// CreateBackendServiceMonitor scrapes the backend metrics with the prometheus operator, when it is installed
+func CreateBackendServiceMonitor(input types.Stolos) *monitoringv1.ServiceMonitor {
+	return &monitoringv1.ServiceMonitor{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "monitoring.coreos.com/v1",
+			Kind:       "ServiceMonitor",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stolos-backend",
+			Namespace: input.Spec.StolosPlatform.Namespace,
+			Labels: map[string]string{
+				"app": "stolos-backend",
+			},
+		},
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "stolos-backend",
+				},
+			},
+			Endpoints: []monitoringv1.Endpoint{
+				{
+					Port:     "http",
+					Path:     "/metrics",
+					Interval: "30s",
+				},
+			},
+		},
+	}
+}
+
 func CreateBackendGrpcService(input types.Stolos) *corev1.Service {
 	backendService := corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -209,6 +242,8 @@ func CreateBackendGrpcService(input types.Stolos) *corev1.Service {
 					Protocol:   corev1.ProtocolTCP,
 					Port:       8082,
 					TargetPort: intstr.FromInt32(8082),
+					// The gateway provider proxies over HTTP/2 without TLS only when the port asks for it
+					AppProtocol: utils.PtrTo("kubernetes.io/h2c"),
 				},
 			},
 		},
@@ -221,69 +256,22 @@ func CreateBackendGrpcService(input types.Stolos) *corev1.Service {
 
 }
 
-func CreateBackendHttpProxy(input types.Stolos) *contourv1.HTTPProxy {
-	return &contourv1.HTTPProxy{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "projectcontour.io/v1",
-			Kind:       "HTTPProxy",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "stolos-grpc",
-			Namespace: input.Spec.StolosPlatform.Namespace,
-		},
-		Spec: contourv1.HTTPProxySpec{
-			Routes: []contourv1.Route{
-				{
-					Conditions: []contourv1.MatchCondition{
-						{
-							Header: &contourv1.HeaderMatchCondition{
-								Name:     "Content-Type",
-								Contains: "application/grpc",
-							},
-						},
-					},
-					Services: []contourv1.Service{
-						{
-							Name: "stolos-grpc-backend",
-							Port: 8082,
-						},
-					},
-				},
-			},
-			VirtualHost: &contourv1.VirtualHost{
-				Fqdn: fmt.Sprintf("grpc.%s.%s", input.Spec.StolosPlatform.BackendSubdomain, input.Spec.BaseDomain),
-				TLS: &contourv1.TLS{
-					MinimumProtocolVersion: "1.3",
-					SecretName:             "stolos-grpc-ingress-tls",
-				},
-			},
-		},
-	}
-}
-
-func CreateBackendCertificate(input types.Stolos) *certmanagerv1.Certificate {
-	return &certmanagerv1.Certificate{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "cert-manager.io/v1",
-			Kind:       "Certificate",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "stolos-grpc-tls",
-			Namespace: input.Spec.StolosPlatform.Namespace,
-		},
-		Spec: certmanagerv1.CertificateSpec{
-			SecretName: "stolos-grpc-ingress-tls",
-			IssuerRef: certmanagerv1meta.ObjectReference{
-				Name: input.Spec.CertManager.DefaultClusterIssuer,
-				Kind: "ClusterIssuer",
-			},
-			CommonName: fmt.Sprintf("grpc.%s.%s", input.Spec.StolosPlatform.BackendSubdomain, input.Spec.BaseDomain),
-			DNSNames:   []string{fmt.Sprintf("grpc.%s.%s", input.Spec.StolosPlatform.BackendSubdomain, input.Spec.BaseDomain)},
+// BackendGrpcRoute exposes the gRPC event sink of the backend to the Talos nodes
+func BackendGrpcRoute(input types.Stolos) routing.Route {
+	return routing.Route{
+		Name:              "stolos-grpc",
+		Namespace:         input.Spec.StolosPlatform.Namespace,
+		Host:              fmt.Sprintf("grpc.%s.%s", input.Spec.StolosPlatform.BackendSubdomain, input.Spec.BaseDomain),
+		TLSSecret:         "stolos-grpc-ingress-tls",
+		MinimumTLSVersion: "1.3",
+		Rules: []routing.Rule{
+			{GRPC: true, Service: "stolos-grpc-backend", Port: 8082, H2C: true},
 		},
 	}
 }
 
 func CreateDatabase(input types.Stolos) *cnpg.Cluster {
+	bootstrap, externalClusters := databaseBootstrap(input)
 
 	return &cnpg.Cluster{
 		TypeMeta: metav1.TypeMeta{
@@ -291,18 +279,15 @@ func CreateDatabase(input types.Stolos) *cnpg.Cluster {
 			Kind:       "Cluster",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "postgresql-stolos",
+			Name:      databaseClusterName,
 			Namespace: input.Spec.StolosPlatform.Namespace,
 		},
 		Spec: cnpg.ClusterSpec{
-			ImageName: input.Spec.StolosPlatform.Database.Image, //"ghcr.io/cloudnative-pg/postgresql:17.6",
-			Instances: input.Spec.StolosPlatform.Database.InstanceCount,
-			Bootstrap: &cnpg.BootstrapConfiguration{
-				InitDB: &cnpg.BootstrapInitDB{
-					Database: "stolos",
-					Owner:    "stolos",
-				},
-			},
+			ImageName:        input.Spec.StolosPlatform.Database.Image, //"ghcr.io/cloudnative-pg/postgresql:17.6",
+			Instances:        input.Spec.StolosPlatform.Database.InstanceCount,
+			Bootstrap:        bootstrap,
+			ExternalClusters: externalClusters,
+			Plugins:          databasePlugins(input),
 			StorageConfiguration: cnpg.StorageConfiguration{
 				Size: strconv.Itoa(input.Spec.StolosPlatform.Database.SizeInGigabytes) + "Gi",
 			},
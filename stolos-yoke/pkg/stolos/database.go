@@ -0,0 +1,167 @@
+package stolos
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/cnpg"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/yokecd/yoke/pkg/flight"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	databaseClusterName   = "postgresql-stolos"
+	databaseObjectStore   = "postgresql-stolos-backup"
+	databaseRestoreSource = "postgresql-stolos-origin"
+)
+
+// AllDatabaseBackup returns the object store of the Barman Cloud plugin and the scheduled backup of the database
+func AllDatabaseBackup(input types.Stolos) []flight.Resource {
+	db := input.Spec.StolosPlatform.Database
+	if !db.Backup.Enabled && !db.Restore.Enabled {
+		return nil
+	}
+	if db.Backup.Bucket == "" {
+		fmt.Fprintln(os.Stderr, "database backup and restore need stolosPlatform.database.backup.bucket, skipping them")
+		return nil
+	}
+
+	serverName := db.Backup.ServerName
+	if serverName == "" {
+		serverName = databaseClusterName
+	}
+	if db.Backup.Enabled && db.Restore.Enabled && serverName == db.Restore.ServerName {
+		fmt.Fprintf(os.Stderr, "the restored database archives to the folder %s it restores from, set backup.serverName to a new one\n", serverName)
+	}
+
+	all := []flight.Resource{
+		CreateDatabaseObjectStore(input),
+	}
+	if db.Backup.Enabled {
+		all = append(all, CreateDatabaseScheduledBackup(input))
+	}
+	return all
+}
+
+func CreateDatabaseObjectStore(input types.Stolos) *cnpg.ObjectStore {
+	backup := input.Spec.StolosPlatform.Database.Backup
+
+	secretKey := func(key string) *cnpg.SecretKeySelector {
+		return &cnpg.SecretKeySelector{
+			LocalObjectReference: cnpg.LocalObjectReference{Name: backup.CredentialsSecret},
+			Key:                  key,
+		}
+	}
+
+	return &cnpg.ObjectStore{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "barmancloud.cnpg.io/v1",
+			Kind:       "ObjectStore",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      databaseObjectStore,
+			Namespace: input.Spec.StolosPlatform.Namespace,
+		},
+		Spec: cnpg.ObjectStoreSpec{
+			Configuration: cnpg.BarmanObjectStoreConfiguration{
+				BarmanCredentials: cnpg.BarmanCredentials{
+					AWS: &cnpg.S3Credentials{
+						AccessKeyIDReference:     secretKey("ACCESS_KEY_ID"),
+						SecretAccessKeyReference: secretKey("ACCESS_SECRET_KEY"),
+					},
+				},
+				EndpointURL:     backup.EndpointURL,
+				DestinationPath: "s3://" + strings.TrimSuffix(backup.Bucket+"/"+strings.Trim(backup.Path, "/"), "/"),
+				Wal: &cnpg.WalBackupConfiguration{
+					Compression: "gzip",
+				},
+				Data: &cnpg.DataBackupConfiguration{
+					Compression: "gzip",
+				},
+			},
+			RetentionPolicy: backup.Retention,
+		},
+	}
+}
+
+func CreateDatabaseScheduledBackup(input types.Stolos) *cnpg.ScheduledBackup {
+	return &cnpg.ScheduledBackup{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "postgresql.cnpg.io/v1",
+			Kind:       "ScheduledBackup",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      databaseClusterName,
+			Namespace: input.Spec.StolosPlatform.Namespace,
+		},
+		Spec: cnpg.ScheduledBackupSpec{
+			Schedule:             input.Spec.StolosPlatform.Database.Backup.Schedule,
+			Immediate:            ptr.To(true),
+			BackupOwnerReference: "self",
+			Cluster:              cnpg.LocalObjectReference{Name: databaseClusterName},
+			Method:               cnpg.BackupMethodPlugin,
+			PluginConfiguration: &cnpg.BackupPluginConfiguration{
+				Name: cnpg.BarmanCloudPlugin,
+			},
+		},
+	}
+}
+
+// databasePlugins makes the Barman Cloud plugin archive the WAL of the cluster and take its backups
+func databasePlugins(input types.Stolos) []cnpg.PluginConfiguration {
+	backup := input.Spec.StolosPlatform.Database.Backup
+	if !backup.Enabled || backup.Bucket == "" {
+		return nil
+	}
+
+	parameters := map[string]string{"barmanObjectName": databaseObjectStore}
+	if backup.ServerName != "" {
+		parameters["serverName"] = backup.ServerName
+	}
+	return []cnpg.PluginConfiguration{{
+		Name:          cnpg.BarmanCloudPlugin,
+		IsWALArchiver: ptr.To(true),
+		Parameters:    parameters,
+	}}
+}
+
+// databaseBootstrap creates the database with initdb, or recovers it from the object store in restore mode. CNPG
+// only reads the bootstrap section when it creates the cluster, see types.CnpgRestore.
+func databaseBootstrap(input types.Stolos) (*cnpg.BootstrapConfiguration, []cnpg.ExternalCluster) {
+	db := input.Spec.StolosPlatform.Database
+	if !db.Restore.Enabled || db.Backup.Bucket == "" {
+		return &cnpg.BootstrapConfiguration{
+			InitDB: &cnpg.BootstrapInitDB{
+				Database: "stolos",
+				Owner:    "stolos",
+			},
+		}, nil
+	}
+
+	recovery := &cnpg.BootstrapRecovery{
+		Source:   databaseRestoreSource,
+		Database: "stolos",
+		Owner:    "stolos",
+	}
+	if db.Restore.TargetTime != "" || db.Restore.BackupID != "" {
+		recovery.RecoveryTarget = &cnpg.RecoveryTarget{
+			TargetTime: db.Restore.TargetTime,
+			BackupID:   db.Restore.BackupID,
+		}
+	}
+
+	source := cnpg.ExternalCluster{
+		Name: databaseRestoreSource,
+		PluginConfiguration: &cnpg.PluginConfiguration{
+			Name: cnpg.BarmanCloudPlugin,
+			Parameters: map[string]string{
+				"barmanObjectName": databaseObjectStore,
+				"serverName":       db.Restore.ServerName,
+			},
+		},
+	}
+	return &cnpg.BootstrapConfiguration{Recovery: recovery}, []cnpg.ExternalCluster{source}
+}
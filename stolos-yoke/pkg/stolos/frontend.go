@@ -1,17 +1,14 @@
 package stolos
 
 import (
-	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
-	certmanagerv1meta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
-	contourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/kubectl/pkg/scheme"
-	"k8s.io/utils/pointer"
 )
 
 func CreateFrontendDeployment(input types.Stolos) *appsv1.Deployment {
@@ -24,7 +21,7 @@ func CreateFrontendDeployment(input types.Stolos) *appsv1.Deployment {
 			},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: pointer.Int32(2),
+			Replicas: componentReplicas(input.Spec.StolosPlatform.Frontend),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": "stolos-frontend",
@@ -37,10 +34,12 @@ func CreateFrontendDeployment(input types.Stolos) *appsv1.Deployment {
 					},
 				},
 				Spec: corev1.PodSpec{
+					NodeSelector: input.Spec.StolosPlatform.Frontend.NodeSelector,
+					Tolerations:  input.Spec.StolosPlatform.Frontend.Tolerations,
 					Containers: []corev1.Container{
 						{
 							Name:  "frontend",
-							Image: "ghcr.io/stolos-cloud/stolos-frontend:latest",
+							Image: ComponentImage(input, input.Spec.StolosPlatform.Frontend, frontendRepository),
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "http",
@@ -53,7 +52,7 @@ func CreateFrontendDeployment(input types.Stolos) *appsv1.Deployment {
 									Value: "http://stolos-backend:8080",
 								},
 							},
-							Resources: corev1.ResourceRequirements{
+							Resources: componentResources(input.Spec.StolosPlatform.Frontend.Resources, corev1.ResourceRequirements{
 								Requests: corev1.ResourceList{
 									corev1.ResourceMemory: resource.MustParse("128Mi"),
 									corev1.ResourceCPU:    resource.MustParse("100m"),
@@ -62,7 +61,7 @@ func CreateFrontendDeployment(input types.Stolos) *appsv1.Deployment {
 									corev1.ResourceMemory: resource.MustParse("256Mi"),
 									corev1.ResourceCPU:    resource.MustParse("200m"),
 								},
-							},
+							}),
 						},
 					},
 				},
@@ -107,74 +106,17 @@ func CreateFrontendService(input types.Stolos) *corev1.Service {
 	return &svc
 }
 
-func CreateHTTPProxy(input types.Stolos) *contourv1.HTTPProxy {
-	return &contourv1.HTTPProxy{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "stolos",
-			Namespace: input.Spec.StolosPlatform.Namespace,
-		},
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "HTTPProxy",
-			APIVersion: "projectcontour.io/v1",
-		},
-		Spec: contourv1.HTTPProxySpec{
-			VirtualHost: &contourv1.VirtualHost{
-				Fqdn: input.Spec.StolosPlatform.FrontendSubdomain + "." + input.Spec.BaseDomain,
-				TLS: &contourv1.TLS{
-					MinimumProtocolVersion: "1.3",
-					SecretName:             "stolos-ingress-tls",
-				},
-			},
-			Routes: []contourv1.Route{
-				{
-					Conditions: []contourv1.MatchCondition{
-						{
-							Prefix: "/api/v1",
-						},
-					},
-					Services: []contourv1.Service{
-						{
-							Name: "stolos-backend",
-							Port: 8080,
-						},
-					},
-				},
-				{
-					Conditions: []contourv1.MatchCondition{
-						{
-							Prefix: "/",
-						},
-					},
-					Services: []contourv1.Service{
-						{
-							Name: "stolos-frontend",
-							Port: 80,
-						},
-					},
-				},
-			},
-		},
-	}
-}
-
-func CreateFrontendCertificate(input types.Stolos) *certmanagerv1.Certificate {
-	return &certmanagerv1.Certificate{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "cert-manager.io/v1",
-			Kind:       "Certificate",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "stolos-tls",
-			Namespace: input.Spec.StolosPlatform.Namespace,
-		},
-		Spec: certmanagerv1.CertificateSpec{
-			SecretName: "stolos-ingress-tls",
-			IssuerRef: certmanagerv1meta.ObjectReference{
-				Name: input.Spec.CertManager.DefaultClusterIssuer,
-				Kind: "ClusterIssuer",
-			},
-			CommonName: input.Spec.StolosPlatform.FrontendSubdomain + "." + input.Spec.BaseDomain,
-			DNSNames:   []string{input.Spec.StolosPlatform.FrontendSubdomain + "." + input.Spec.BaseDomain},
+// FrontendRoute exposes the frontend and the API of the backend on the same host
+func FrontendRoute(input types.Stolos) routing.Route {
+	return routing.Route{
+		Name:              "stolos",
+		Namespace:         input.Spec.StolosPlatform.Namespace,
+		Host:              input.Spec.StolosPlatform.FrontendSubdomain + "." + input.Spec.BaseDomain,
+		TLSSecret:         "stolos-ingress-tls",
+		MinimumTLSVersion: "1.3",
+		Rules: []routing.Rule{
+			{PathPrefix: "/api/v1", Service: "stolos-backend", Port: 8080},
+			{PathPrefix: "/", Service: "stolos-frontend", Port: 80},
 		},
 	}
 }
@@ -0,0 +1,50 @@
+package stolos_test
+
+import (
+	"testing"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/cnpg"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/render/rendertest"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/stolos"
+	"github.com/yokecd/yoke/pkg/flight"
+)
+
+func TestAllStolos(t *testing.T) {
+	input := rendertest.Platform(t)
+	resources := rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return stolos.AllStolos(input)
+	})
+	rendertest.Golden(t, "stolos", resources...)
+}
+
+func TestAllStolosWorkloads(t *testing.T) {
+	input := rendertest.Platform(t)
+	input.Spec.StolosPlatform.UpgradeChannel = "pinned"
+	resources := rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		return stolos.AllStolosWorkloads(input)
+	})
+	rendertest.Golden(t, "stolos-workloads", resources...)
+}
+
+func TestDatabaseRestore(t *testing.T) {
+	input := rendertest.Platform(t)
+	input.Spec.StolosPlatform.Database.Restore.Enabled = true
+	input.Spec.StolosPlatform.Database.Restore.TargetTime = "2026-10-19T08:00:00Z"
+
+	var cluster *cnpg.Cluster
+	rendertest.Resources(t, rendertest.NewCluster(input), func() []flight.Resource {
+		cluster = stolos.CreateDatabase(input)
+		return []flight.Resource{cluster}
+	})
+
+	recovery := cluster.Spec.Bootstrap.Recovery
+	if recovery == nil || cluster.Spec.Bootstrap.InitDB != nil {
+		t.Fatalf("expected the database to be recovered instead of initialized, got %+v", cluster.Spec.Bootstrap)
+	}
+	if recovery.RecoveryTarget == nil || recovery.RecoveryTarget.TargetTime != "2026-10-19T08:00:00Z" {
+		t.Errorf("expected the recovery to stop at the target time, got %+v", recovery.RecoveryTarget)
+	}
+	if len(cluster.Spec.ExternalClusters) != 1 || cluster.Spec.ExternalClusters[0].Name != recovery.Source {
+		t.Errorf("expected the recovery source %q in the external clusters, got %+v", recovery.Source, cluster.Spec.ExternalClusters)
+	}
+}
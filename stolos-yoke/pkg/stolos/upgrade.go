@@ -0,0 +1,161 @@
+package stolos
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"os"
+
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/utils"
+	"github.com/yokecd/yoke/pkg/flight"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+// Version is the release of the flight, set when it is built with
+// -ldflags "-X github.com/stolos-cloud/stolos/stolos-yoke/pkg/stolos.Version=v0.3.0"
+var Version = "latest"
+
+const (
+	backendRepository  = "ghcr.io/stolos-cloud/stolos-backend"
+	frontendRepository = "ghcr.io/stolos-cloud/stolos-frontend"
+)
+
+// AllStolosWorkloads are rolled out after the other resources, once the migration job of their version has completed
+func AllStolosWorkloads(input types.Stolos) []flight.Resource {
+	return []flight.Resource{
+		CreateDeployment(input),
+		CreateBackendService(input),
+		CreateBackendGrpcService(input),
+		CreateFrontendDeployment(input),
+		CreateFrontendService(input),
+	}
+}
+
+// PlatformVersion is the release of the images, the one of the flight unless the upgrade channel is pinned
+func PlatformVersion(input types.Stolos) string {
+	platform := input.Spec.StolosPlatform
+	if platform.UpgradeChannel == "pinned" {
+		if platform.Version != "" {
+			return platform.Version
+		}
+		fmt.Fprintln(os.Stderr, "the pinned upgrade channel needs stolosPlatform.version, using the release of the flight")
+	}
+	return Version
+}
+
+// ComponentImage is the image reference of a component, by digest when one is set
+func ComponentImage(input types.Stolos, component types.StolosComponent, repository string) string {
+	repository = cmp.Or(component.Image.Repository, repository)
+	if component.Image.Digest != "" {
+		return repository + "@" + component.Image.Digest
+	}
+	return repository + ":" + cmp.Or(component.Image.Tag, PlatformVersion(input))
+}
+
+// componentResources parses the quantities of a component, its defaults apply when none is set
+func componentResources(resources types.StolosResources, defaults corev1.ResourceRequirements) corev1.ResourceRequirements {
+	if len(resources.Requests) == 0 && len(resources.Limits) == 0 {
+		return defaults
+	}
+	return corev1.ResourceRequirements{
+		Requests: resourceList(resources.Requests),
+		Limits:   resourceList(resources.Limits),
+	}
+}
+
+func resourceList(quantities map[string]string) corev1.ResourceList {
+	if len(quantities) == 0 {
+		return nil
+	}
+	list := corev1.ResourceList{}
+	for name, value := range quantities {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid quantity %q for %s, ignoring it\n", value, name)
+			continue
+		}
+		list[corev1.ResourceName(name)] = quantity
+	}
+	return list
+}
+
+func componentReplicas(component types.StolosComponent) *int32 {
+	return utils.PtrTo(int32(cmp.Or(component.Replicas, 2)))
+}
+
+// CreateMigrationJob migrates the database with the new backend image. Its name changes with the image, so each
+// upgrade runs it once, and the workloads wait for it to complete.
+func CreateMigrationJob(input types.Stolos) *batchv1.Job {
+	backend := input.Spec.StolosPlatform.Backend
+	image := ComponentImage(input, backend, backendRepository)
+
+	hash := fnv.New32a()
+	hash.Write([]byte(image))
+
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("stolos-migrate-%08x", hash.Sum32()),
+			Namespace: input.Spec.StolosPlatform.Namespace,
+			Labels: map[string]string{
+				"app": "stolos-migrate",
+			},
+			Annotations: map[string]string{
+				"stolos.cloud/image": image,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: utils.PtrTo(int32(10)),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": "stolos-migrate",
+					},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					NodeSelector:  backend.NodeSelector,
+					Tolerations:   backend.Tolerations,
+					Containers: []corev1.Container{
+						{
+							Name:    "migrate",
+							Image:   image,
+							Command: []string{"./main", "migrate"},
+							Env:     databaseEnv(),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	gvks, _, _ := scheme.Scheme.ObjectKinds(&job)
+	job.SetGroupVersionKind(gvks[0])
+
+	return &job
+}
+
+func databaseEnv() []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "DB_HOST", Value: "postgresql-stolos-rw"},
+		{Name: "DB_PORT", Value: "5432"},
+		{Name: "DB_USER", Value: "stolos"},
+		{Name: "DB_NAME", Value: "stolos"},
+		{Name: "DB_SSL_MODE", Value: "disable"},
+		{
+			Name: "DB_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "postgresql-stolos-app",
+					},
+					Key: "password",
+				},
+			},
+		},
+	}
+}
@@ -1,6 +1,9 @@
 package types
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 type Stolos struct {
 	metav1.TypeMeta
@@ -15,9 +18,12 @@ type StolosSpec struct {
 	MetalLB              MetalLB              `json:"metallb"`
 	ArgoCD               ArgoCD               `json:"argocd"`
 	Contour              Contour              `json:"contour"`
+	Ingress              Ingress              `json:"ingress"`
 	CertManager          CertManager          `json:"certManager"`
+	ExternalDNS          ExternalDNS          `json:"externalDns"`
 	CNPG                 CNPG                 `json:"cnpg"`
 	StolosPlatform       StolosPlatform       `json:"stolosPlatform"`
+	Monitoring           Monitoring           `json:"monitoring"`
 }
 
 type LocalPathProvisioner struct {
@@ -26,12 +32,56 @@ type LocalPathProvisioner struct {
 	Version   string `json:"version" Default:"\"v0.0.32\""`
 }
 
+// MetalLB assigns the addresses of the LoadBalancer services from its pools. Without pools, ArpIp is a single
+// address pool announced with L2.
 type MetalLB struct {
-	Deploy       bool   `json:"deploy" Default:"true"`
-	ConfigureArp bool   `json:"configureArp" Default:"true"`
-	ArpIp        string `json:"arpIp"`
-	Namespace    string `json:"namespace" Default:"\"metallb-system\""`
-	Version      string `json:"version"`
+	Deploy       bool          `json:"deploy" Default:"true"`
+	ConfigureArp bool          `json:"configureArp" Default:"true"`
+	ArpIp        string        `json:"arpIp"`
+	Namespace    string        `json:"namespace" Default:"\"metallb-system\""`
+	Version      string        `json:"version"`
+	Pools        []MetalLBPool `json:"pools,omitempty"`
+	L2Interfaces []string      `json:"l2Interfaces,omitempty"` // interfaces announcing the L2 pools, all when empty
+	BGP          MetalLBBGP    `json:"bgp"`
+}
+
+type MetalLBPool struct {
+	Name       string   `json:"name"`
+	Addresses  []string `json:"addresses"` // CIDRs or ranges, e.g. 192.168.10.0/24 or 192.168.10.10-192.168.10.20
+	AutoAssign bool     `json:"autoAssign" Default:"true"`
+	L2         bool     `json:"l2" Default:"true"` // announced with L2, the BGP advertisements announce the others
+	// Only the services of these namespaces, or of the namespaces with these labels, get addresses of the pool
+	Namespaces        []string          `json:"namespaces,omitempty"`
+	NamespaceSelector map[string]string `json:"namespaceSelector,omitempty"`
+}
+
+type MetalLBBGP struct {
+	Peers          []MetalLBBGPPeer          `json:"peers,omitempty"`
+	Advertisements []MetalLBBGPAdvertisement `json:"advertisements,omitempty"`
+	// Communities are aliases of BGP communities, e.g. no-advertise: 65535:65282
+	Communities map[string]string `json:"communities,omitempty"`
+}
+
+type MetalLBBGPPeer struct {
+	Name           string            `json:"name"`
+	Address        string            `json:"address"`
+	ASN            uint32            `json:"asn"`
+	MyASN          uint32            `json:"myAsn"`
+	Port           int               `json:"port" Default:"179"`
+	PasswordSecret string            `json:"passwordSecret"` // secret with a password key, in the MetalLB namespace
+	EBGPMultiHop   bool              `json:"ebgpMultiHop" Default:"false"`
+	NodeSelector   map[string]string `json:"nodeSelector,omitempty"` // nodes peering with the router, all when empty
+}
+
+// MetalLBBGPAdvertisement announces pools to BGP peers. Without advertisements, the pools not announced with L2 are
+// announced to all the peers.
+type MetalLBBGPAdvertisement struct {
+	Name              string   `json:"name"`
+	Pools             []string `json:"pools"`
+	Peers             []string `json:"peers,omitempty"`       // all the peers when empty
+	Communities       []string `json:"communities,omitempty"` // values or aliases of MetalLBBGP.Communities
+	LocalPref         uint32   `json:"localPref" Default:"0"`
+	AggregationLength int32    `json:"aggregationLength" Default:"32"`
 }
 
 type ArgoCD struct {
@@ -51,6 +101,21 @@ type Contour struct {
 	Version   string `json:"version"`
 }
 
+// Ingress selects how the services are exposed: Contour HTTPProxy, Gateway API HTTPRoute or Kubernetes Ingress
+type Ingress struct {
+	Provider  string  `json:"provider" Enum:"contour,gateway,ingress" Default:"\"contour\""`
+	ClassName string  `json:"className"` // IngressClass of the ingress provider, the default class when empty
+	Gateway   Gateway `json:"gateway"`
+}
+
+// Gateway is the shared Gateway of the gateway provider
+type Gateway struct {
+	Implementation      string `json:"implementation" Enum:"envoy,cilium" Default:"\"envoy\""`
+	Name                string `json:"name" Default:"\"stolos\""`
+	Namespace           string `json:"namespace" Default:"\"stolos-gateway\""`
+	EnvoyGatewayVersion string `json:"envoyGatewayVersion"`
+}
+
 type CertManager struct {
 	Deploy               bool   `json:"deploy" Default:"true"`
 	Version              string `json:"version"`
@@ -60,6 +125,44 @@ type CertManager struct {
 	DefaultClusterIssuer string `json:"defaultClusterIssuer" Default:"\"letsencrypt-prod\""`
 	SelfSigned           bool   `json:"selfSigned" Default:"false"`
 	Email                string `json:"email"`
+	DNS01                DNS01  `json:"dns01"`
+}
+
+// DNS01 solves the ACME challenges of BaseDomain with TXT records, for clusters that can't be reached from the
+// internet. external-dns publishes the records of the routes with the same provider.
+type DNS01 struct {
+	Provider   string     `json:"provider" Enum:",clouddns,cloudflare,rfc2136"` // HTTP-01 only when empty
+	CloudDNS   CloudDNS   `json:"cloudDNS"`
+	Cloudflare Cloudflare `json:"cloudflare"`
+	RFC2136    RFC2136    `json:"rfc2136"`
+}
+
+// CloudDNS uses the GCP service account of the platform, which needs the DNS Administrator role
+type CloudDNS struct {
+	Project string `json:"project"` // project of the zone, the project of the service account when empty
+}
+
+// Cloudflare reads an API token with the Zone DNS Edit permission from a secret of the cert-manager namespace
+type Cloudflare struct {
+	APITokenSecret string `json:"apiTokenSecret" Default:"\"cloudflare-api-token\""`
+	APITokenKey    string `json:"apiTokenKey" Default:"\"api-token\""`
+}
+
+// RFC2136 updates a DNS server authenticated with TSIG, the TSIG secret is read from a secret of the cert-manager namespace
+type RFC2136 struct {
+	Nameserver    string `json:"nameserver"` // host:port
+	TSIGKeyName   string `json:"tsigKeyName"`
+	TSIGAlgorithm string `json:"tsigAlgorithm" Enum:"HMACMD5,HMACSHA1,HMACSHA256,HMACSHA512" Default:"\"HMACSHA256\""`
+	TSIGSecret    string `json:"tsigSecret" Default:"\"rfc2136-tsig\""`
+	TSIGSecretKey string `json:"tsigSecretKey" Default:"\"tsig-secret\""`
+}
+
+// ExternalDNS publishes the hosts of the routes under BaseDomain with the DNS-01 provider of cert-manager
+type ExternalDNS struct {
+	Deploy    bool   `json:"deploy" Default:"false"`
+	Namespace string `json:"namespace" Default:"\"external-dns\""`
+	Version   string `json:"version"`
+	Policy    string `json:"policy" Enum:"upsert-only,sync" Default:"\"upsert-only\""`
 }
 
 type CNPG struct {
@@ -78,10 +181,100 @@ type StolosPlatform struct {
 	DefaultAdminPassword string       `json:"defaultAdminPassword"`
 	DefaultAdminEmail    string       `json:"defaultAdminEmail"`
 	PathToYaml           string       `json:"pathToYaml"`
+	// The images follow the release of the flight, or the version with the pinned channel. Changing the version
+	// runs the database migrations in a Job before the new backend rolls out.
+	UpgradeChannel string          `json:"upgradeChannel" Enum:"release,pinned" Default:"\"release\""`
+	Version        string          `json:"version"`
+	Backend        StolosComponent `json:"backend"`
+	Frontend       StolosComponent `json:"frontend"`
+}
+
+type StolosComponent struct {
+	Image        StolosImage         `json:"image"`
+	Replicas     int                 `json:"replicas" Default:"2"`
+	Resources    StolosResources     `json:"resources"`
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+type StolosImage struct {
+	Repository string `json:"repository"` // ghcr.io/stolos-cloud/stolos-<component> when empty
+	Tag        string `json:"tag"`        // the platform version when empty
+	Digest     string `json:"digest"`     // sha256:..., takes precedence over the tag
+}
+
+// StolosResources are quantities by resource name, e.g. cpu: 250m
+type StolosResources struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
 }
 
 type CnpgDbConfig struct {
-	Image           string `json:"image"`
-	InstanceCount   int    `json:"instanceCount" Default:"1"`
-	SizeInGigabytes int    `json:"sizeInGigabytes" Default:"5"`
+	Image           string      `json:"image"`
+	InstanceCount   int         `json:"instanceCount" Default:"1"`
+	SizeInGigabytes int         `json:"sizeInGigabytes" Default:"5"`
+	Backup          CnpgBackup  `json:"backup"`
+	Restore         CnpgRestore `json:"restore"`
+}
+
+// CnpgBackup archives the WAL and takes scheduled base backups of the database to an S3 compatible object store with
+// the Barman Cloud plugin. The credentials secret, in the Stolos namespace, has the keys ACCESS_KEY_ID and
+// ACCESS_SECRET_KEY.
+type CnpgBackup struct {
+	Enabled           bool   `json:"enabled" Default:"false"`
+	EndpointURL       string `json:"endpointURL"` // empty for AWS S3
+	Bucket            string `json:"bucket"`
+	Path              string `json:"path" Default:"\"stolos\""`
+	CredentialsSecret string `json:"credentialsSecret" Default:"\"stolos-database-backup\""`
+	ServerName        string `json:"serverName"`                         // folder of the backups, the cluster name when empty
+	Schedule          string `json:"schedule" Default:"\"0 0 2 * * *\""` // with seconds, every day at 02:00
+	Retention         string `json:"retention" Default:"\"30d\""`
+}
+
+// CnpgRestore bootstraps the database from the backups of the object store, at the latest WAL, a backup or a point in
+// time. It only applies when the cluster is created: delete the postgresql-stolos cluster, or install on a new
+// cluster, with the restore enabled. CNPG refuses to archive to a folder that already has backups, so the backup
+// serverName must differ from the restored one, e.g. postgresql-stolos-2.
+type CnpgRestore struct {
+	Enabled    bool   `json:"enabled" Default:"false"`
+	ServerName string `json:"serverName" Default:"\"postgresql-stolos\""`
+	TargetTime string `json:"targetTime"` // RFC 3339, e.g. 2026-10-19T08:00:00Z, the latest WAL when empty
+	BackupID   string `json:"backupID"`
+}
+
+type Monitoring struct {
+	Deploy           bool                 `json:"deploy" Default:"true"`
+	Namespace        string               `json:"namespace" Default:"\"monitoring\""`
+	GrafanaSubdomain string               `json:"grafanaSubdomain" Default:"\"grafana\""`
+	StorageClass     string               `json:"storageClass"`
+	Components       MonitoringComponents `json:"components"`
+	Retention        MonitoringRetention  `json:"retention"`
+	Storage          MonitoringStorage    `json:"storage"`
+}
+
+type MonitoringComponents struct {
+	Prometheus bool `json:"prometheus" Default:"true"`
+	Mimir      bool `json:"mimir" Default:"true"`
+	Loki       bool `json:"loki" Default:"true"`
+	Tempo      bool `json:"tempo" Default:"true"`
+	Grafana    bool `json:"grafana" Default:"true"`
+	Alloy      bool `json:"alloy" Default:"true"`
+	Pyroscope  bool `json:"pyroscope" Default:"true"`
+	Obi        bool `json:"obi" Default:"true"`
+}
+
+type MonitoringRetention struct {
+	Metrics string `json:"metrics" Default:"\"15d\""`
+	Logs    string `json:"logs" Default:"\"24h\""`
+	Traces  string `json:"traces" Default:"\"24h\""`
+}
+
+// MonitoringStorage are the volume sizes of the components, an empty size keeps the data in an emptyDir
+type MonitoringStorage struct {
+	Prometheus string `json:"prometheus" Default:"\"10Gi\""`
+	Mimir      string `json:"mimir" Default:"\"20Gi\""`
+	Loki       string `json:"loki" Default:"\"10Gi\""`
+	Tempo      string `json:"tempo" Default:"\"10Gi\""`
+	Grafana    string `json:"grafana" Default:"\"2Gi\""`
+	Pyroscope  string `json:"pyroscope" Default:"\"5Gi\""`
 }
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	yokeK8s "github.com/yokecd/yoke/pkg/flight/wasi/k8s"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Cluster is what the modules read from the cluster the flight is deployed to. The flight asks the yoke host, a
+// native build the cluster of the kubeconfig, and the tests replace it with SetCluster.
+type Cluster interface {
+	// RestMapping fails when the cluster doesn't serve the kind, e.g. until the CRDs of a component are installed
+	RestMapping(apiVersion, kind string) error
+	// Secret fails with a yoke ErrorNotFound when the secret doesn't exist
+	Secret(name, namespace string) (*corev1.Secret, error)
+}
+
+var cluster = defaultCluster()
+
+// SetCluster replaces the cluster the modules read from
+func SetCluster(c Cluster) {
+	cluster = c
+}
+
+// RestMapping checks that the cluster serves a kind
+func RestMapping(apiVersion, kind string) error {
+	return cluster.RestMapping(apiVersion, kind)
+}
+
+// HostCluster reads the cluster through the yoke host, only available to the wasm build of the flight
+type HostCluster struct{}
+
+func (HostCluster) RestMapping(apiVersion, kind string) error {
+	_, err := yokeK8s.GetRestMapping(apiVersion, kind)
+	return err
+}
+
+func (HostCluster) Secret(name, namespace string) (*corev1.Secret, error) {
+	return yokeK8s.Lookup[corev1.Secret](yokeK8s.ResourceIdentifier{
+		ApiVersion: "v1",
+		Kind:       "Secret",
+		Name:       name,
+		Namespace:  namespace,
+	})
+}
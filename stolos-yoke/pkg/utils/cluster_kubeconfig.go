@@ -0,0 +1,79 @@
+//go:build !wasip1
+
+package utils
+
+import (
+	"context"
+	"sync"
+
+	yokeK8s "github.com/yokecd/yoke/pkg/flight/wasi/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// The yoke host only exists in wasm, a native build of the flight reads the cluster of the kubeconfig instead
+func defaultCluster() Cluster {
+	return &KubeconfigCluster{}
+}
+
+// KubeconfigCluster reads the cluster of the current context of the kubeconfig, connecting on first use
+type KubeconfigCluster struct {
+	once   sync.Once
+	err    error
+	client kubernetes.Interface
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+}
+
+func (c *KubeconfigCluster) connect() error {
+	c.once.Do(func() {
+		loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), nil)
+		config, err := loader.ClientConfig()
+		if err != nil {
+			c.err = err
+			return
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			c.err = err
+			return
+		}
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.client = client
+		c.mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	})
+	return c.err
+}
+
+func (c *KubeconfigCluster) RestMapping(apiVersion, kind string) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return err
+	}
+	_, err = c.mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	return err
+}
+
+func (c *KubeconfigCluster) Secret(name, namespace string) (*corev1.Secret, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	secret, err := c.client.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, yokeK8s.ErrorNotFound(err.Error())
+	}
+	return secret, err
+}
@@ -0,0 +1,5 @@
+package utils
+
+func defaultCluster() Cluster {
+	return HostCluster{}
+}
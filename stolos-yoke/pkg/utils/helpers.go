@@ -6,6 +6,8 @@ import (
 	"encoding/hex"
 	"io"
 
+	"github.com/stolos-cloud/stolos/stolos-yoke/pkg/types"
+	"github.com/stolos-cloud/stolos/yoke-base/pkg/routing"
 	yokeK8s "github.com/yokecd/yoke/pkg/flight/wasi/k8s"
 	corev1 "k8s.io/api/core/v1"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -56,13 +58,7 @@ func CheckCrdPresence(crdName string) bool {
 
 // GetExistingSecret to fetch an existing secret
 func GetExistingSecret(name, namespace string) (*corev1.Secret, error) {
-	secret, err := yokeK8s.Lookup[corev1.Secret](yokeK8s.ResourceIdentifier{
-		ApiVersion: "v1",
-		Kind:       "Secret",
-		Name:       name,
-		Namespace:  namespace,
-	})
-
+	secret, err := cluster.Secret(name, namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -77,3 +73,14 @@ func GenerateRandomString(length int) string {
 	}
 	return hex.EncodeToString(bytes)
 }
+
+// IngressConfig is the ingress implementation selected in the spec, used to build the routes and certificates
+func IngressConfig(input types.Stolos) routing.Config {
+	return routing.Config{
+		Provider:         input.Spec.Ingress.Provider,
+		ClassName:        input.Spec.Ingress.ClassName,
+		GatewayName:      input.Spec.Ingress.Gateway.Name,
+		GatewayNamespace: input.Spec.Ingress.Gateway.Namespace,
+		ClusterIssuer:    input.Spec.CertManager.DefaultClusterIssuer,
+	}
+}
@@ -0,0 +1,37 @@
+// Package loadbalancer requests the address of a LoadBalancer service from a MetalLB pool of the platform. The
+// backend lists the pools at /api/v1/templates/loadbalancer-pools, so a template can take the pool in its spec.
+package loadbalancer
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PoolAnnotation is the MetalLB pool a service gets its address from
+	PoolAnnotation = "metallb.io/address-pool"
+	// AddressAnnotation requests specific addresses of the pool, comma separated
+	AddressAnnotation = "metallb.io/loadBalancerIPs"
+)
+
+// RequestPool makes a LoadBalancer service take its address from a pool, or from the auto-assigned pools when it is
+// empty
+func RequestPool(svc *corev1.Service, pool string) {
+	if pool == "" {
+		return
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = map[string]string{}
+	}
+	svc.Annotations[PoolAnnotation] = pool
+}
+
+// RequestAddress makes a LoadBalancer service take a specific address, it must belong to a pool
+func RequestAddress(svc *corev1.Service, address string) {
+	if address == "" {
+		return
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = map[string]string{}
+	}
+	svc.Annotations[AddressAnnotation] = address
+}
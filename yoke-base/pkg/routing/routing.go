@@ -0,0 +1,361 @@
+// Package routing exposes services over HTTPS with the ingress implementation chosen for the platform: Contour
+// HTTPProxy, Gateway API HTTPRoute or Kubernetes Ingress. The platform publishes its Config in a ConfigMap, so the
+// templates can request their routes the same way with LookupConfig and Resources.
+package routing
+
+import (
+	"fmt"
+	"os"
+
+	cmacme "github.com/cert-manager/cert-manager/pkg/apis/acme/v1"
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	contourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/yokecd/yoke/pkg/flight"
+	yokeK8s "github.com/yokecd/yoke/pkg/flight/wasi/k8s"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	gwv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	ProviderContour = "contour"
+	ProviderGateway = "gateway"
+	ProviderIngress = "ingress"
+)
+
+// ConfigMapName is the ConfigMap holding the Config of the platform, in the namespace of the platform
+const ConfigMapName = "stolos-ingress"
+
+// HTTPListenerName is the plain HTTP listener of the gateway, used by the ACME challenges and the HTTPS redirect
+const HTTPListenerName = "http"
+
+// Config is the ingress implementation of the platform
+type Config struct {
+	Provider         string
+	ClassName        string // IngressClass of the ingress provider
+	GatewayName      string
+	GatewayNamespace string
+	ClusterIssuer    string // issuer of the certificates
+}
+
+// Route is a host served over TLS and the services behind it
+type Route struct {
+	Name              string // name of the route objects
+	Namespace         string // namespace of the services
+	Host              string
+	TLSSecret         string
+	MinimumTLSVersion string // only applied by Contour
+	Rules             []Rule
+}
+
+// Rule sends the requests matching a path prefix to a service
+type Rule struct {
+	PathPrefix string // every path when empty
+	GRPC       bool   // only matches the requests with a gRPC content type
+	Service    string
+	Port       int32
+	H2C        bool // the service speaks HTTP/2 without TLS, the Gateway API reads it from the appProtocol of the port
+	WebSockets bool
+}
+
+// Resources returns the certificate and the route objects of a route
+func Resources(cfg Config, route Route) []flight.Resource {
+	resources := []flight.Resource{Certificate(cfg, route)}
+	switch cfg.Provider {
+	case ProviderGateway:
+		resources = append(resources, HTTPRoute(cfg, route))
+	case ProviderIngress:
+		resources = append(resources, Ingress(cfg, route))
+	default:
+		resources = append(resources, HTTPProxy(route))
+	}
+	return resources
+}
+
+// TLSSecretName is the secret of the certificate of a route. With the gateway provider the certificates live in the
+// namespace of the gateway, so the name is prefixed with the namespace of the route.
+func TLSSecretName(cfg Config, route Route) string {
+	if cfg.Provider == ProviderGateway {
+		return route.Namespace + "-" + route.TLSSecret
+	}
+	return route.TLSSecret
+}
+
+// Certificate requests the certificate of a route from the issuer of the platform
+func Certificate(cfg Config, route Route) *certmanagerv1.Certificate {
+	name, namespace := route.Name+"-tls", route.Namespace
+	if cfg.Provider == ProviderGateway {
+		name, namespace = route.Namespace+"-"+name, cfg.GatewayNamespace
+	}
+
+	return &certmanagerv1.Certificate{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Certificate",
+			APIVersion: "cert-manager.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: certmanagerv1.CertificateSpec{
+			SecretName: TLSSecretName(cfg, route),
+			IssuerRef: certmanagermetav1.ObjectReference{
+				Name: cfg.ClusterIssuer,
+				Kind: "ClusterIssuer",
+			},
+			CommonName: route.Host,
+			DNSNames:   []string{route.Host},
+		},
+	}
+}
+
+// HTTPProxy is the route of the contour provider
+func HTTPProxy(route Route) *contourv1.HTTPProxy {
+	routes := make([]contourv1.Route, 0, len(route.Rules))
+	for _, rule := range route.Rules {
+		var conditions []contourv1.MatchCondition
+		if rule.PathPrefix != "" {
+			conditions = append(conditions, contourv1.MatchCondition{Prefix: rule.PathPrefix})
+		}
+		if rule.GRPC {
+			conditions = append(conditions, contourv1.MatchCondition{
+				Header: &contourv1.HeaderMatchCondition{
+					Name:     "Content-Type",
+					Contains: "application/grpc",
+				},
+			})
+		}
+
+		service := contourv1.Service{Name: rule.Service, Port: int(rule.Port)}
+		if rule.H2C {
+			service.Protocol = ptr.To("h2c")
+		}
+		routes = append(routes, contourv1.Route{
+			Conditions:       conditions,
+			Services:         []contourv1.Service{service},
+			EnableWebsockets: rule.WebSockets,
+		})
+	}
+
+	return &contourv1.HTTPProxy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "HTTPProxy",
+			APIVersion: "projectcontour.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      route.Name,
+			Namespace: route.Namespace,
+		},
+		Spec: contourv1.HTTPProxySpec{
+			VirtualHost: &contourv1.VirtualHost{
+				Fqdn: route.Host,
+				TLS: &contourv1.TLS{
+					MinimumProtocolVersion: route.MinimumTLSVersion,
+					SecretName:             route.TLSSecret,
+				},
+			},
+			Routes: routes,
+		},
+	}
+}
+
+// HTTPRoute is the route of the gateway provider, attached to the HTTPS listeners of the gateway of the platform
+func HTTPRoute(cfg Config, route Route) *gwv1.HTTPRoute {
+	rules := make([]gwv1.HTTPRouteRule, 0, len(route.Rules))
+	for _, rule := range route.Rules {
+		prefix := rule.PathPrefix
+		if prefix == "" {
+			prefix = "/"
+		}
+		match := gwv1.HTTPRouteMatch{
+			Path: &gwv1.HTTPPathMatch{
+				Type:  ptr.To(gwv1.PathMatchPathPrefix),
+				Value: ptr.To(prefix),
+			},
+		}
+		if rule.GRPC {
+			match.Headers = []gwv1.HTTPHeaderMatch{{
+				Type:  ptr.To(gwv1.HeaderMatchRegularExpression),
+				Name:  "Content-Type",
+				Value: "^application/grpc",
+			}}
+		}
+
+		rules = append(rules, gwv1.HTTPRouteRule{
+			Matches: []gwv1.HTTPRouteMatch{match},
+			BackendRefs: []gwv1.HTTPBackendRef{{
+				BackendRef: gwv1.BackendRef{
+					BackendObjectReference: gwv1.BackendObjectReference{
+						Name: gwv1.ObjectName(rule.Service),
+						Port: ptr.To(gwv1.PortNumber(rule.Port)),
+					},
+				},
+			}},
+		})
+	}
+
+	return &gwv1.HTTPRoute{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "HTTPRoute",
+			APIVersion: "gateway.networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      route.Name,
+			Namespace: route.Namespace,
+		},
+		Spec: gwv1.HTTPRouteSpec{
+			CommonRouteSpec: gwv1.CommonRouteSpec{
+				ParentRefs: []gwv1.ParentReference{{
+					Name:      gwv1.ObjectName(cfg.GatewayName),
+					Namespace: ptr.To(gwv1.Namespace(cfg.GatewayNamespace)),
+				}},
+			},
+			Hostnames: []gwv1.Hostname{gwv1.Hostname(route.Host)},
+			Rules:     rules,
+		},
+	}
+}
+
+// Ingress is the route of the ingress provider. An Ingress can't match on headers, so a gRPC rule is only kept when
+// no other rule has its path. It can't request gRPC to the service either, so the gRPC rules are reported on stderr.
+func Ingress(cfg Config, route Route) *networkingv1.Ingress {
+	httpPrefixes := map[string]bool{}
+	for _, rule := range route.Rules {
+		if !rule.GRPC {
+			httpPrefixes[ingressPath(rule)] = true
+		}
+	}
+
+	var paths []networkingv1.HTTPIngressPath
+	for _, rule := range route.Rules {
+		if rule.GRPC && httpPrefixes[ingressPath(rule)] {
+			fmt.Fprintf(os.Stderr, "route %s/%s: an Ingress can't match gRPC requests, the gRPC requests of %s go to the HTTP rule\n",
+				route.Namespace, route.Name, ingressPath(rule))
+			continue
+		}
+		if rule.GRPC {
+			fmt.Fprintf(os.Stderr, "route %s/%s: an Ingress can't request gRPC, the ingress controller must proxy %s to %s over HTTP/2\n",
+				route.Namespace, route.Name, ingressPath(rule), rule.Service)
+		}
+		paths = append(paths, networkingv1.HTTPIngressPath{
+			Path:     ingressPath(rule),
+			PathType: ptr.To(networkingv1.PathTypePrefix),
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: rule.Service,
+					Port: networkingv1.ServiceBackendPort{Number: rule.Port},
+				},
+			},
+		})
+	}
+
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Ingress",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      route.Name,
+			Namespace: route.Namespace,
+		},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{{
+				Hosts:      []string{route.Host},
+				SecretName: route.TLSSecret,
+			}},
+			Rules: []networkingv1.IngressRule{{
+				Host: route.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+				},
+			}},
+		},
+	}
+	if cfg.ClassName != "" {
+		ingress.Spec.IngressClassName = ptr.To(cfg.ClassName)
+	}
+	return ingress
+}
+
+func ingressPath(rule Rule) string {
+	if rule.PathPrefix == "" {
+		return "/"
+	}
+	return rule.PathPrefix
+}
+
+// HTTP01Solver is the ACME HTTP-01 solver answering the challenges through the ingress implementation
+func HTTP01Solver(cfg Config) *cmacme.ACMEChallengeSolverHTTP01 {
+	switch cfg.Provider {
+	case ProviderGateway:
+		return &cmacme.ACMEChallengeSolverHTTP01{
+			GatewayHTTPRoute: &cmacme.ACMEChallengeSolverHTTP01GatewayHTTPRoute{
+				ParentRefs: []gwv1.ParentReference{{
+					Name:        gwv1.ObjectName(cfg.GatewayName),
+					Namespace:   ptr.To(gwv1.Namespace(cfg.GatewayNamespace)),
+					SectionName: ptr.To(gwv1.SectionName(HTTPListenerName)),
+				}},
+			},
+		}
+	case ProviderIngress:
+		solver := &cmacme.ACMEChallengeSolverHTTP01{Ingress: &cmacme.ACMEChallengeSolverHTTP01Ingress{}}
+		if cfg.ClassName != "" {
+			solver.Ingress.IngressClassName = ptr.To(cfg.ClassName)
+		}
+		return solver
+	default:
+		return &cmacme.ACMEChallengeSolverHTTP01{
+			Ingress: &cmacme.ACMEChallengeSolverHTTP01Ingress{
+				Class: ptr.To("contour"),
+			},
+		}
+	}
+}
+
+// ConfigMap publishes the Config of the platform to the templates
+func ConfigMap(cfg Config, namespace string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName,
+			Namespace: namespace,
+		},
+		Data: map[string]string{
+			"provider":         cfg.Provider,
+			"className":        cfg.ClassName,
+			"gatewayName":      cfg.GatewayName,
+			"gatewayNamespace": cfg.GatewayNamespace,
+			"clusterIssuer":    cfg.ClusterIssuer,
+		},
+	}
+}
+
+// LookupConfig reads the Config published by the platform in its namespace, e.g. stolos-system
+func LookupConfig(namespace string) (Config, error) {
+	cm, err := yokeK8s.Lookup[corev1.ConfigMap](yokeK8s.ResourceIdentifier{
+		ApiVersion: "v1",
+		Kind:       "ConfigMap",
+		Name:       ConfigMapName,
+		Namespace:  namespace,
+	})
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to get ingress configuration: %w", err)
+	}
+	if cm == nil {
+		return Config{}, fmt.Errorf("ingress configuration %s/%s not found", namespace, ConfigMapName)
+	}
+
+	return Config{
+		Provider:         cm.Data["provider"],
+		ClassName:        cm.Data["className"],
+		GatewayName:      cm.Data["gatewayName"],
+		GatewayNamespace: cm.Data["gatewayNamespace"],
+		ClusterIssuer:    cm.Data["clusterIssuer"],
+	}, nil
+}
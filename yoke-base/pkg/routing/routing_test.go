@@ -0,0 +1,205 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	gwv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func testRoute() Route {
+	return Route{
+		Name:      "argocd",
+		Namespace: "argocd",
+		Host:      "argocd.example.com",
+		TLSSecret: "argocd-tls",
+		Rules: []Rule{
+			{GRPC: true, Service: "argocd-server", Port: 443, H2C: true},
+			{Service: "argocd-server", Port: 80, WebSockets: true},
+		},
+	}
+}
+
+func TestResources(t *testing.T) {
+	tests := []struct {
+		provider string
+		kind     string
+	}{
+		{ProviderContour, "HTTPProxy"},
+		{"", "HTTPProxy"},
+		{ProviderGateway, "HTTPRoute"},
+		{ProviderIngress, "Ingress"},
+	}
+	for _, tt := range tests {
+		cfg := Config{Provider: tt.provider, GatewayName: "stolos", GatewayNamespace: "gateway", ClusterIssuer: "letsencrypt-prod"}
+		resources := Resources(cfg, testRoute())
+		if len(resources) != 2 {
+			t.Fatalf("provider %q: got %d resources, want the certificate and the route", tt.provider, len(resources))
+		}
+		if kind := resources[0].GroupVersionKind().Kind; kind != "Certificate" {
+			t.Errorf("provider %q: first resource is a %s, want the Certificate", tt.provider, kind)
+		}
+		if kind := resources[1].GroupVersionKind().Kind; kind != tt.kind {
+			t.Errorf("provider %q: route is a %s, want a %s", tt.provider, kind, tt.kind)
+		}
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	cfg := Config{Provider: ProviderContour, ClusterIssuer: "letsencrypt-prod"}
+	cert := Certificate(cfg, testRoute())
+	assertCertificate(t, cert, "argocd", "argocd-tls", "argocd-tls")
+	if cert.Spec.IssuerRef.Name != "letsencrypt-prod" || cert.Spec.IssuerRef.Kind != "ClusterIssuer" {
+		t.Errorf("issuer = %+v, want the cluster issuer of the platform", cert.Spec.IssuerRef)
+	}
+	if !slices.Equal(cert.Spec.DNSNames, []string{"argocd.example.com"}) {
+		t.Errorf("DNS names = %v", cert.Spec.DNSNames)
+	}
+
+	// The gateway reads the certificates from its namespace, the names are prefixed to stay unique
+	cfg = Config{Provider: ProviderGateway, GatewayNamespace: "gateway", ClusterIssuer: "letsencrypt-prod"}
+	assertCertificate(t, Certificate(cfg, testRoute()), "gateway", "argocd-argocd-tls", "argocd-argocd-tls")
+}
+
+func assertCertificate(t *testing.T, cert *certmanagerv1.Certificate, namespace, name, secret string) {
+	t.Helper()
+	if cert.Namespace != namespace || cert.Name != name || cert.Spec.SecretName != secret {
+		t.Errorf("certificate %s/%s with secret %s, want %s/%s with secret %s",
+			cert.Namespace, cert.Name, cert.Spec.SecretName, namespace, name, secret)
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	proxy := HTTPProxy(testRoute())
+
+	if proxy.Spec.VirtualHost.Fqdn != "argocd.example.com" || proxy.Spec.VirtualHost.TLS.SecretName != "argocd-tls" {
+		t.Errorf("virtual host = %+v", proxy.Spec.VirtualHost)
+	}
+	if len(proxy.Spec.Routes) != 2 {
+		t.Fatalf("got %d routes, want 2", len(proxy.Spec.Routes))
+	}
+
+	grpc := proxy.Spec.Routes[0]
+	if len(grpc.Conditions) != 1 || grpc.Conditions[0].Header == nil || grpc.Conditions[0].Header.Contains != "application/grpc" {
+		t.Errorf("gRPC conditions = %+v, want a match on the gRPC content type", grpc.Conditions)
+	}
+	if protocol := grpc.Services[0].Protocol; protocol == nil || *protocol != "h2c" {
+		t.Errorf("gRPC service protocol = %v, want h2c", protocol)
+	}
+
+	http := proxy.Spec.Routes[1]
+	if len(http.Conditions) != 0 || http.Services[0].Protocol != nil || !http.EnableWebsockets {
+		t.Errorf("HTTP route = %+v, want every path over HTTP/1.1 with websockets", http)
+	}
+	if service := http.Services[0]; service.Name != "argocd-server" || service.Port != 80 {
+		t.Errorf("HTTP service = %s:%d, want argocd-server:80", service.Name, service.Port)
+	}
+}
+
+func TestHTTPRoute(t *testing.T) {
+	cfg := Config{Provider: ProviderGateway, GatewayName: "stolos", GatewayNamespace: "gateway"}
+	route := HTTPRoute(cfg, testRoute())
+
+	parent := route.Spec.ParentRefs[0]
+	if parent.Name != "stolos" || parent.Namespace == nil || *parent.Namespace != "gateway" {
+		t.Errorf("parent = %+v, want the gateway of the platform", parent)
+	}
+	if !slices.Equal(route.Spec.Hostnames, []gwv1.Hostname{"argocd.example.com"}) {
+		t.Errorf("hostnames = %v", route.Spec.Hostnames)
+	}
+	if len(route.Spec.Rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(route.Spec.Rules))
+	}
+
+	for i, rule := range route.Spec.Rules {
+		if path := rule.Matches[0].Path; path == nil || *path.Value != "/" {
+			t.Errorf("rule %d path = %+v, want every path", i, path)
+		}
+	}
+	grpc := route.Spec.Rules[0]
+	if headers := grpc.Matches[0].Headers; len(headers) != 1 || headers[0].Value != "^application/grpc" {
+		t.Errorf("gRPC headers = %+v, want a match on the gRPC content type", headers)
+	}
+	if port := grpc.BackendRefs[0].Port; port == nil || *port != 443 {
+		t.Errorf("gRPC backend port = %v, want 443", port)
+	}
+	if headers := route.Spec.Rules[1].Matches[0].Headers; len(headers) != 0 {
+		t.Errorf("HTTP headers = %+v, want none", headers)
+	}
+}
+
+func TestIngress(t *testing.T) {
+	cfg := Config{Provider: ProviderIngress, ClassName: "nginx"}
+	ingress := Ingress(cfg, testRoute())
+
+	if ingress.Spec.IngressClassName == nil || *ingress.Spec.IngressClassName != "nginx" {
+		t.Errorf("ingress class = %v, want nginx", ingress.Spec.IngressClassName)
+	}
+	if tls := ingress.Spec.TLS[0]; tls.SecretName != "argocd-tls" || !slices.Equal(tls.Hosts, []string{"argocd.example.com"}) {
+		t.Errorf("TLS = %+v", tls)
+	}
+	// The gRPC rule has the path of the HTTP rule, an Ingress can't tell them apart
+	paths := ingress.Spec.Rules[0].HTTP.Paths
+	if len(paths) != 1 || paths[0].Path != "/" || paths[0].Backend.Service.Port.Number != 80 {
+		t.Errorf("paths = %+v, want only the HTTP rule", paths)
+	}
+
+	// A gRPC rule is kept when no HTTP rule has its path
+	route := testRoute()
+	route.Rules[0].PathPrefix = "/grpc"
+	paths = Ingress(Config{Provider: ProviderIngress}, route).Spec.Rules[0].HTTP.Paths
+	if len(paths) != 2 || paths[0].Path != "/grpc" || paths[1].Path != "/" {
+		t.Errorf("paths = %+v, want the gRPC and the HTTP rules", paths)
+	}
+	for _, path := range paths {
+		if *path.PathType != networkingv1.PathTypePrefix {
+			t.Errorf("path %s is a %s, want a prefix", path.Path, *path.PathType)
+		}
+	}
+	if class := Ingress(Config{Provider: ProviderIngress}, route).Spec.IngressClassName; class != nil {
+		t.Errorf("ingress class = %s, want the default class", *class)
+	}
+}
+
+func TestHTTP01Solver(t *testing.T) {
+	solver := HTTP01Solver(Config{Provider: ProviderGateway, GatewayName: "stolos", GatewayNamespace: "gateway"})
+	if solver.GatewayHTTPRoute == nil || len(solver.GatewayHTTPRoute.ParentRefs) != 1 {
+		t.Fatalf("gateway solver = %+v, want a route on the gateway", solver)
+	}
+	if section := solver.GatewayHTTPRoute.ParentRefs[0].SectionName; section == nil || *section != HTTPListenerName {
+		t.Errorf("gateway solver listener = %v, want the HTTP listener", section)
+	}
+
+	solver = HTTP01Solver(Config{Provider: ProviderIngress, ClassName: "nginx"})
+	if solver.Ingress == nil || solver.Ingress.IngressClassName == nil || *solver.Ingress.IngressClassName != "nginx" {
+		t.Errorf("ingress solver = %+v, want the ingress class", solver.Ingress)
+	}
+
+	solver = HTTP01Solver(Config{Provider: ProviderContour})
+	if solver.Ingress == nil || solver.Ingress.Class == nil || *solver.Ingress.Class != "contour" {
+		t.Errorf("contour solver = %+v, want the contour class", solver.Ingress)
+	}
+}
+
+func TestConfigMap(t *testing.T) {
+	cfg := Config{Provider: ProviderGateway, ClassName: "nginx", GatewayName: "stolos", GatewayNamespace: "gateway", ClusterIssuer: "letsencrypt-prod"}
+	cm := ConfigMap(cfg, "stolos-system")
+	if cm.Name != ConfigMapName || cm.Namespace != "stolos-system" {
+		t.Errorf("config map %s/%s", cm.Namespace, cm.Name)
+	}
+	// LookupConfig reads the same keys
+	want := map[string]string{
+		"provider":         ProviderGateway,
+		"className":        "nginx",
+		"gatewayName":      "stolos",
+		"gatewayNamespace": "gateway",
+		"clusterIssuer":    "letsencrypt-prod",
+	}
+	for key, value := range want {
+		if cm.Data[key] != value {
+			t.Errorf("%s = %q, want %q", key, cm.Data[key], value)
+		}
+	}
+}